@@ -1,36 +1,18 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 )
 
 // POST /api/admin/verify-master
-func handleAdminVerifyMaster(apx *ApxClient) http.HandlerFunc {
+func handleAdminVerifyMaster(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		if r.Method != http.MethodPost {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-			return
-		}
-
-		cookie, err := r.Cookie("session")
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
-			return
-		}
-		user, err := apx.GetSessionUser(cookie.Value)
-		if err != nil || !user.IsAdmin {
-			jsonError(w, http.StatusForbidden, "Keine Berechtigung")
-			return
-		}
-
 		var req struct {
 			Password string `json:"password"`
 		}
@@ -49,167 +31,259 @@ func handleAdminVerifyMaster(apx *ApxClient) http.HandlerFunc {
 	}
 }
 
-// handleAdminUserActions handles:
-//
-//	GET    /api/admin/users/<username>
-//	POST   /api/admin/users/<username>/deactivate
-//	POST   /api/admin/users/<username>/activate
-//	POST   /api/admin/users/<username>/toggle-2fa
-//	POST   /api/admin/users/<username>/event-access
-//	DELETE /api/admin/users/<username>
-func handleAdminUserActions(apx *ApxClient) http.HandlerFunc {
+// protectedTarget reports whether admin actions on username are refused: the
+// built-in admin account, the acting user themselves, and users whose role
+// grants anything the actor lacks (a moderator must not lock out a
+// superadmin). Unknown users are left to the handler's own 404.
+func protectedTarget(w http.ResponseWriter, r *http.Request, apx Store, username string) bool {
+	actor := sessionUser(r)
+	if username == "admin" || username == actor.Username {
+		jsonError(w, http.StatusForbidden, "Diese Aktion ist für diesen Nutzer nicht erlaubt")
+		return true
+	}
+	if target, err := apx.GetUserByUsernameAny(r.Context(), username); err == nil && !actor.covers(target) {
+		jsonError(w, http.StatusForbidden, "Diese Aktion ist für diesen Nutzer nicht erlaubt")
+		return true
+	}
+	return false
+}
+
+// userAuditState is the admin-managed state of a user as recorded in audit
+// entries. It returns nil if the user cannot be loaded.
+func userAuditState(ctx context.Context, apx Store, username string) map[string]any {
+	u, err := apx.GetUserByUsernameAny(ctx, username)
+	if err != nil {
+		return nil
+	}
+	return map[string]any{
+		"is_active":      u.IsActive,
+		"two_fa_enabled": u.TwoFAEnabled,
+		"event_access":   u.EventAccess,
+		"role":           u.effectiveRole(),
+	}
+}
+
+// withState returns a copy of state with key set to val.
+func withState(state map[string]any, key string, val any) map[string]any {
+	out := make(map[string]any, len(state)+1)
+	for k, v := range state {
+		out[k] = v
+	}
+	out[key] = val
+	return out
+}
+
+// GET /api/admin/users/{username}
+func handleAdminGetUser(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
+		u, err := apx.GetUserByUsernameAny(r.Context(), r.PathValue("username"))
+		if err != nil {
+			jsonError(w, http.StatusNotFound, "Nutzer nicht gefunden")
 			return
 		}
+		links, _ := apx.GetLinkedAccounts(r.Context(), u.ID)
+		if links == nil {
+			links = []LinkedAccount{}
+		}
+		var lockedUntil *time.Time
+		if until, ok := loginAccountLimiter.lockedUntil(accountKey(u.ID)); ok {
+			lockedUntil = &until
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"username":       u.Username,
+			"nickname":       u.Nickname,
+			"avatar_url":     u.AvatarURL,
+			"banner_url":     u.BannerURL,
+			"email":          u.Email,
+			"is_active":      u.IsActive,
+			"two_fa_enabled": u.TwoFAEnabled,
+			"is_admin":       u.IsAdmin,
+			"role":           u.effectiveRole(),
+			"event_access":   u.EventAccess,
+			"created_at":     u.CreatedAt,
+			"links":          links,
+			"locked_until":   lockedUntil,
+		})
+	}
+}
 
-		cookie, err := r.Cookie("session")
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
+// POST /api/admin/users/{username}/deactivate
+func handleAdminDeactivateUser(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := r.PathValue("username")
+		if protectedTarget(w, r, apx, username) {
 			return
 		}
-		admin, err := apx.GetSessionUser(cookie.Value)
-		if err != nil || !admin.IsAdmin {
-			jsonError(w, http.StatusForbidden, "Keine Berechtigung")
+		before := userAuditState(r.Context(), apx, username)
+		if err := apx.DeactivateUserByUsername(r.Context(), username); err != nil {
+			log.Printf("admin deactivate %s: %v", username, err)
+			jsonError(w, http.StatusNotFound, "Nutzer nicht gefunden")
 			return
 		}
+		recordAudit(apx, r, "user.deactivate", "user", username, before, withState(before, "is_active", false))
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
 
-		path := strings.TrimPrefix(r.URL.Path, "/api/admin/users/")
-		parts := strings.SplitN(path, "/", 2)
-		username := parts[0]
-		if username == "" {
-			jsonError(w, http.StatusBadRequest, "missing username")
+// POST /api/admin/users/{username}/activate
+func handleAdminActivateUser(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := r.PathValue("username")
+		if protectedTarget(w, r, apx, username) {
 			return
 		}
-		action := ""
-		if len(parts) == 2 {
-			action = parts[1]
+		before := userAuditState(r.Context(), apx, username)
+		if err := apx.ActivateByUsername(r.Context(), username); err != nil {
+			log.Printf("admin activate %s: %v", username, err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
 		}
+		recordAudit(apx, r, "user.activate", "user", username, before, withState(before, "is_active", true))
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
 
-		switch {
-		case r.Method == http.MethodGet && action == "":
-			u, err := apx.GetUserByUsernameAny(username)
-			if err != nil {
-				jsonError(w, http.StatusNotFound, "Nutzer nicht gefunden")
-				return
-			}
-			links, _ := apx.GetLinkedAccounts(u.ID)
-			if links == nil {
-				links = []LinkedAccount{}
-			}
-			jsonResponse(w, http.StatusOK, map[string]interface{}{
-				"username":       u.Username,
-				"nickname":       u.Nickname,
-				"avatar_url":     u.AvatarURL,
-				"banner_url":     u.BannerURL,
-				"email":          u.Email,
-				"is_active":      u.IsActive,
-				"two_fa_enabled": u.TwoFAEnabled,
-				"is_admin":       u.IsAdmin,
-				"event_access":   u.EventAccess,
-				"created_at":     u.CreatedAt,
-				"links":          links,
-			})
-
-		case r.Method == http.MethodPost && action == "deactivate":
-			if username == "admin" || username == admin.Username {
-				jsonError(w, http.StatusForbidden, "Diese Aktion ist für diesen Nutzer nicht erlaubt")
-				return
-			}
-			if err := apx.DeactivateUserByUsername(username); err != nil {
-				log.Printf("admin deactivate %s: %v", username, err)
-				jsonError(w, http.StatusNotFound, "Nutzer nicht gefunden")
-				return
-			}
-			jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
-
-		case r.Method == http.MethodPost && action == "activate":
-			if username == "admin" || username == admin.Username {
-				jsonError(w, http.StatusForbidden, "Diese Aktion ist für diesen Nutzer nicht erlaubt")
-				return
-			}
-			if err := apx.ActivateByUsername(username); err != nil {
-				log.Printf("admin activate %s: %v", username, err)
-				jsonError(w, http.StatusInternalServerError, "internal error")
-				return
-			}
-			jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
-
-		case r.Method == http.MethodPost && action == "toggle-2fa":
-			if username == "admin" || username == admin.Username {
-				jsonError(w, http.StatusForbidden, "Diese Aktion ist für diesen Nutzer nicht erlaubt")
-				return
-			}
-			newVal, err := apx.ToggleUser2FA(username)
-			if err != nil {
-				log.Printf("admin toggle-2fa %s: %v", username, err)
-				jsonError(w, http.StatusNotFound, "Nutzer nicht gefunden")
-				return
-			}
-			jsonResponse(w, http.StatusOK, map[string]interface{}{"two_fa_enabled": newVal})
+// POST /api/admin/users/{username}/toggle-2fa
+func handleAdminToggleUser2FA(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := r.PathValue("username")
+		if protectedTarget(w, r, apx, username) {
+			return
+		}
+		before := userAuditState(r.Context(), apx, username)
+		newVal, err := apx.ToggleUser2FA(r.Context(), username)
+		if err != nil {
+			log.Printf("admin toggle-2fa %s: %v", username, err)
+			jsonError(w, http.StatusNotFound, "Nutzer nicht gefunden")
+			return
+		}
+		recordAudit(apx, r, "user.toggle_2fa", "user", username, before, withState(before, "two_fa_enabled", newVal))
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"two_fa_enabled": newVal})
+	}
+}
 
-		case r.Method == http.MethodPost && action == "event-access":
-			var req struct {
-				EventAccess bool `json:"event_access"`
-			}
-			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-				jsonError(w, http.StatusBadRequest, "invalid body")
-				return
-			}
-			if err := apx.SetUserEventAccessByUsername(username, req.EventAccess); err != nil {
-				log.Printf("admin event-access %s: %v", username, err)
-				jsonError(w, http.StatusNotFound, "Nutzer nicht gefunden")
-				return
-			}
-			jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+// POST /api/admin/users/{username}/event-access
+func handleAdminUserEventAccess(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := r.PathValue("username")
+		var req struct {
+			EventAccess bool `json:"event_access"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+		if protectedTarget(w, r, apx, username) {
+			return
+		}
+		before := userAuditState(r.Context(), apx, username)
+		if err := apx.SetUserEventAccessByUsername(r.Context(), username, req.EventAccess); err != nil {
+			log.Printf("admin event-access %s: %v", username, err)
+			jsonError(w, http.StatusNotFound, "Nutzer nicht gefunden")
+			return
+		}
+		recordAudit(apx, r, "user.event_access", "user", username, before, withState(before, "event_access", req.EventAccess))
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
 
-		case r.Method == http.MethodDelete && action == "":
-			if username == "admin" || username == admin.Username {
-				jsonError(w, http.StatusForbidden, "Diese Aktion ist für diesen Nutzer nicht erlaubt")
-				return
-			}
-			if err := apx.DeleteUserByUsername(username); err != nil {
-				log.Printf("admin delete %s: %v", username, err)
-				jsonError(w, http.StatusNotFound, "Nutzer nicht gefunden")
-				return
-			}
-			jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+// POST /api/admin/users/{username}/unlock
+func handleAdminUnlockUser(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := r.PathValue("username")
+		if protectedTarget(w, r, apx, username) {
+			return
+		}
+		u, err := apx.GetUserByUsernameAny(r.Context(), username)
+		if err != nil {
+			jsonError(w, http.StatusNotFound, "Nutzer nicht gefunden")
+			return
+		}
+		until, locked := loginAccountLimiter.lockedUntil(accountKey(u.ID))
+		loginAccountLimiter.reset(accountKey(u.ID))
+		var before map[string]any
+		if locked {
+			before = map[string]any{"locked_until": until}
+		}
+		recordAudit(apx, r, "user.unlock", "user", u.Username, before, nil)
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
 
-		default:
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+// POST /api/admin/users/{username}/role
+func handleAdminUserRole(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := r.PathValue("username")
+		var req struct {
+			Role string `json:"role"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+		if _, known := rolePermissions[req.Role]; req.Role != "" && !known {
+			jsonError(w, http.StatusBadRequest, "invalid role")
+			return
+		}
+		if protectedTarget(w, r, apx, username) {
+			return
+		}
+		u, err := apx.GetUserByUsernameAny(r.Context(), username)
+		if err != nil {
+			jsonError(w, http.StatusNotFound, "Nutzer nicht gefunden")
+			return
+		}
+		if u.IsAdmin {
+			jsonError(w, http.StatusForbidden, "Diese Aktion ist für diesen Nutzer nicht erlaubt")
+			return
+		}
+		if err := apx.SetUserRole(r.Context(), u.ID, req.Role); err != nil {
+			log.Printf("admin role %s: %v", username, err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
 		}
+		recordAudit(apx, r, "user.role", "user", username, map[string]any{"role": u.Role}, map[string]any{"role": req.Role})
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"success": true, "role": req.Role})
 	}
 }
 
-// handleAdminPublicUser wraps handlePublicUser but includes email/2FA for admins.
-func handleAdminPublicUser(apx *ApxClient) http.HandlerFunc {
+// DELETE /api/admin/users/{username}
+func handleAdminDeleteUser(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		username := r.PathValue("username")
+		if protectedTarget(w, r, apx, username) {
 			return
 		}
+		before := userAuditState(r.Context(), apx, username)
+		if err := apx.DeleteUserByUsername(r.Context(), username); err != nil {
+			log.Printf("admin delete %s: %v", username, err)
+			jsonError(w, http.StatusNotFound, "Nutzer nicht gefunden")
+			return
+		}
+		recordAudit(apx, r, "user.delete", "user", username, before, nil)
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
 
+// handleAdminPublicUser wraps handlePublicUser but includes email/2FA for admins.
+func handleAdminPublicUser(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 		username := r.URL.Query().Get("u")
 		if username == "" {
 			jsonError(w, http.StatusBadRequest, "missing username")
 			return
 		}
 
-		isAdmin := false
-		if cookie, err := r.Cookie("session"); err == nil {
-			if user, err := apx.GetSessionUser(cookie.Value); err == nil {
-				isAdmin = user.IsAdmin
-			}
-		}
+		viewer := sessionUser(r)
+		isAdmin := viewer != nil && viewer.can(PermViewUsers)
 
-		u, err := apx.GetUserByUsernameAny(username)
+		u, err := apx.GetUserByUsernameAny(r.Context(), username)
 		if err != nil {
 			jsonError(w, http.StatusNotFound, "user not found")
 			return
 		}
 
-		links, err := apx.GetLinkedAccounts(u.ID)
+		links, err := apx.GetLinkedAccounts(r.Context(), u.ID)
 		if err != nil {
 			log.Printf("handleAdminPublicUser GetLinkedAccounts: %v", err)
 			links = nil
@@ -243,7 +317,7 @@ func handleAdminPublicUser(apx *ApxClient) http.HandlerFunc {
 			})
 		}
 
-		badges, err := apx.GetUserBadges(u.ID)
+		badges, err := apx.GetUserBadges(r.Context(), u.ID)
 		if err != nil {
 			log.Printf("handleAdminPublicUser GetUserBadges: %v", err)
 			badges = nil
@@ -279,6 +353,9 @@ func handleAdminPublicUser(apx *ApxClient) http.HandlerFunc {
 		if u.Timezone != "" {
 			resp["timezone"] = u.Timezone
 		}
+		if tenure := tenureFor(r.Context(), apx, u.Username); tenure != nil {
+			resp["tenure"] = tenure
+		}
 		if isAdmin {
 			resp["email"] = u.Email
 			resp["two_fa_enabled"] = u.TwoFAEnabled
@@ -0,0 +1,589 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Application statuses. New applications are pending; accepted, rejected and
+// withdrawn are final. Only the applicant can withdraw.
+const (
+	AppStatusPending   = "pending"
+	AppStatusInReview  = "in_review"
+	AppStatusTrial     = "trial"
+	AppStatusAccepted  = "accepted"
+	AppStatusRejected  = "rejected"
+	AppStatusWithdrawn = "withdrawn"
+)
+
+// applicationOpen reports whether an application in status is still being
+// processed.
+func applicationOpen(status string) bool {
+	return status == AppStatusPending || status == AppStatusInReview || status == AppStatusTrial
+}
+
+// applicationCooldown is how long a rejected applicant has to wait before
+// applying again: APPLICATION_COOLDOWN_DAYS, default 30.
+func applicationCooldown() time.Duration {
+	days := 30
+	if s := os.Getenv("APPLICATION_COOLDOWN_DAYS"); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+			days = n
+		}
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// reapplyBlocked explains why the owner of latest cannot submit a new
+// application yet, or returns "" if they can.
+func reapplyBlocked(latest *ApplicationRecord, now time.Time) string {
+	if applicationOpen(latest.Status) {
+		return "Du hast bereits eine offene Bewerbung"
+	}
+	if latest.Status != AppStatusRejected {
+		return ""
+	}
+	decided, err := time.Parse(time.RFC3339, cmp.Or(latest.StatusUpdatedAt, latest.CreatedAt))
+	if err != nil {
+		return ""
+	}
+	if until := decided.Add(applicationCooldown()); now.Before(until) {
+		return "Erneute Bewerbung ab " + until.Format("02.01.2006") + " möglich"
+	}
+	return ""
+}
+
+// applicationTransitions lists the statuses reachable from each status.
+var applicationTransitions = map[string][]string{
+	AppStatusPending:  {AppStatusInReview, AppStatusRejected},
+	AppStatusInReview: {AppStatusTrial, AppStatusAccepted, AppStatusRejected},
+	AppStatusTrial:    {AppStatusAccepted, AppStatusRejected},
+}
+
+// errApplicationOpen is returned by CreateApplication when the user already
+// has an open application.
+var errApplicationOpen = errors.New("user already has an open application")
+
+// errApplicationStatusChanged is returned by ChangeApplicationStatus and
+// UpdateApplicationByUserID when the application is no longer in the
+// expected status.
+var errApplicationStatusChanged = errors.New("application status changed")
+
+// applicationMails names the mail template sent to the applicant when an
+// application enters a status. in_review is internal and sends nothing.
+var applicationMails = map[string]string{
+	AppStatusPending:  "application_received",
+	AppStatusTrial:    "application_trial",
+	AppStatusAccepted: "application_accepted",
+	AppStatusRejected: "application_rejected",
+}
+
+// notifyApplicant mails user about app entering status, unless they opted
+// out. The mail is sent in the background; failures are only logged.
+func notifyApplicant(user *User, app ApplicationRecord, status, reason string) {
+	name, ok := applicationMails[status]
+	if !ok || user.MailOptOut || user.Email == "" {
+		return
+	}
+	data := map[string]string{"Game": app.Game, "Discord": app.Discord, "Reason": reason}
+	go func() {
+		if err := sendMail(user.Email, name, user.MailLanguage, app.Name, data, true); err != nil {
+			log.Printf("send %s mail for application %d: %v", name, app.ID, err)
+		}
+	}()
+}
+
+func canTransitionApplication(from, to string) bool {
+	return slices.Contains(applicationTransitions[from], to)
+}
+
+func applicationIDParam(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		jsonError(w, http.StatusBadRequest, "invalid id")
+		return 0, false
+	}
+	return id, true
+}
+
+// loadApplication fetches the application named by the {id} path value and
+// writes the error response if there is none.
+func loadApplication(w http.ResponseWriter, r *http.Request, apx Store) (*ApplicationRecord, bool) {
+	id, ok := applicationIDParam(w, r)
+	if !ok {
+		return nil, false
+	}
+	app, err := apx.GetApplicationByID(r.Context(), id)
+	if errors.Is(err, errNotFound) {
+		jsonError(w, http.StatusNotFound, "Bewerbung nicht gefunden")
+		return nil, false
+	} else if err != nil {
+		log.Printf("GetApplicationByID error: %v", err)
+		jsonError(w, http.StatusInternalServerError, "internal error")
+		return nil, false
+	}
+	return app, true
+}
+
+// applicationRanks are the ranks offered by the application form, lowest
+// first. Sorting by rank uses this order; unknown ranks come first.
+var applicationRanks = []string{"Copper", "Bronze", "Silver", "Gold", "Platinum", "Emerald", "Diamond", "Champion"}
+
+func rankPosition(rank string) int {
+	return slices.IndexFunc(applicationRanks, func(r string) bool { return strings.EqualFold(r, rank) })
+}
+
+// hasRole reports whether role is in the comma-separated role list the
+// application form submits.
+func hasRole(roles, role string) bool {
+	for r := range strings.SplitSeq(roles, ",") {
+		if strings.EqualFold(strings.TrimSpace(r), role) {
+			return true
+		}
+	}
+	return false
+}
+
+// adminApplication is an application as reviewers see it in the list, with
+// the aggregated rating score (null until someone rated it).
+type adminApplication struct {
+	ApplicationRecord
+	Score       *float64 `json:"score"`
+	RatingCount int      `json:"rating_count"`
+}
+
+func roundScore(score float64) *float64 {
+	s := math.Round(score*100) / 100
+	return &s
+}
+
+// applicationSorts are the sort keys of the admin list, all ascending.
+var applicationSorts = map[string]func(a, b *adminApplication) int{
+	"created_at": func(a, b *adminApplication) int {
+		return cmp.Or(cmp.Compare(a.CreatedAt, b.CreatedAt), cmp.Compare(a.ID, b.ID))
+	},
+	"score": func(a, b *adminApplication) int { return cmp.Compare(*a.Score, *b.Score) },
+	"rank":  func(a, b *adminApplication) int { return cmp.Compare(rankPosition(a.Rank), rankPosition(b.Rank)) },
+	"game": func(a, b *adminApplication) int {
+		return cmp.Compare(strings.ToLower(a.Game), strings.ToLower(b.Game))
+	},
+	"name": func(a, b *adminApplication) int {
+		return cmp.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	},
+}
+
+// handleAdminApplications serves GET /api/admin/applications. Optional query
+// parameters filter by game, rank, role (attacker or defender), status and
+// min_score; sort is one of created_at (default), score, rank, game or name
+// and order is asc or desc (default desc for created_at and score). Unrated
+// applications always sort after rated ones by score.
+func handleAdminApplications(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		sortBy := cmp.Or(q.Get("sort"), "created_at")
+		compare, ok := applicationSorts[sortBy]
+		if !ok {
+			jsonError(w, http.StatusBadRequest, "invalid sort")
+			return
+		}
+		order := q.Get("order")
+		if order == "" {
+			order = "asc"
+			if sortBy == "created_at" || sortBy == "score" {
+				order = "desc"
+			}
+		}
+		if order != "asc" && order != "desc" {
+			jsonError(w, http.StatusBadRequest, "invalid order")
+			return
+		}
+		var minScore float64
+		if v := q.Get("min_score"); v != "" {
+			var err error
+			if minScore, err = strconv.ParseFloat(v, 64); err != nil {
+				jsonError(w, http.StatusBadRequest, "invalid min_score")
+				return
+			}
+		}
+
+		apps, err := apx.GetApplications(r.Context())
+		if err != nil {
+			log.Printf("Failed to get applications: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		scores, err := apx.GetApplicationScores(r.Context())
+		if err != nil {
+			log.Printf("GetApplicationScores error: %v", err)
+		}
+		byID := make(map[int64]ApplicationScore, len(scores))
+		for _, sc := range scores {
+			byID[sc.ApplicationID] = sc
+		}
+
+		game, rank, role, status := q.Get("game"), q.Get("rank"), q.Get("role"), q.Get("status")
+		list := []adminApplication{}
+		for _, a := range apps {
+			if (game != "" && !strings.EqualFold(a.Game, game)) ||
+				(rank != "" && !strings.EqualFold(a.Rank, rank)) ||
+				(role != "" && !hasRole(a.AttackerRole, role) && !hasRole(a.DefenderRole, role)) ||
+				(status != "" && a.Status != status) {
+				continue
+			}
+			item := adminApplication{ApplicationRecord: a}
+			if sc, ok := byID[a.ID]; ok {
+				item.Score, item.RatingCount = roundScore(sc.Score), sc.RatingCount
+			}
+			if minScore > 0 && (item.Score == nil || *item.Score < minScore) {
+				continue
+			}
+			list = append(list, item)
+		}
+		slices.SortStableFunc(list, func(a, b adminApplication) int {
+			if sortBy == "score" && (a.Score == nil || b.Score == nil) {
+				return cmp.Compare(boolInt(a.Score == nil), boolInt(b.Score == nil))
+			}
+			if order == "desc" {
+				return compare(&b, &a)
+			}
+			return compare(&a, &b)
+		})
+
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"applications": list,
+		})
+	}
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// handleAdminApplicationStatus serves POST /api/admin/applications/{id}/status.
+// Accepting can optionally add the applicant to the roster and grant event
+// access; those side effects need their own permissions and are reported as
+// warnings if they fail after the status has changed.
+func handleAdminApplicationStatus(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Status           string `json:"status"`
+			Reason           string `json:"reason"`
+			CreateTeamMember bool   `json:"create_team_member"`
+			MainRoster       bool   `json:"main_roster"`
+			GrantEventAccess bool   `json:"grant_event_access"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		req.Reason = strings.TrimSpace(req.Reason)
+
+		app, ok := loadApplication(w, r, apx)
+		if !ok {
+			return
+		}
+		id := app.ID
+		if !canTransitionApplication(app.Status, req.Status) {
+			jsonError(w, http.StatusBadRequest, fmt.Sprintf("Statuswechsel von %q nach %q nicht erlaubt", app.Status, req.Status))
+			return
+		}
+		if req.Status == AppStatusRejected && req.Reason == "" {
+			jsonError(w, http.StatusBadRequest, "Begründung erforderlich")
+			return
+		}
+		if (req.CreateTeamMember || req.GrantEventAccess) && req.Status != AppStatusAccepted {
+			jsonError(w, http.StatusBadRequest, "Roster und Event-Zugang nur bei Annahme möglich")
+			return
+		}
+		actor := sessionUser(r)
+		if (req.CreateTeamMember && !actor.can(PermManageRoster)) || (req.GrantEventAccess && !actor.can(PermManageEvents)) {
+			jsonError(w, http.StatusForbidden, "Keine Berechtigung")
+			return
+		}
+		if req.CreateTeamMember && req.MainRoster {
+			if count, _ := apx.CountMainRoster(r.Context(), 0); count >= 5 {
+				jsonError(w, http.StatusBadRequest, "Maximal 5 Main Roster Spieler erlaubt")
+				return
+			}
+		}
+
+		change := ApplicationStatusChange{
+			ApplicationID: id,
+			FromStatus:    app.Status,
+			ToStatus:      req.Status,
+			Reason:        req.Reason,
+			ActorID:       actor.ID,
+			ActorName:     actor.Username,
+		}
+		if err := apx.ChangeApplicationStatus(r.Context(), change); errors.Is(err, errApplicationStatusChanged) {
+			jsonError(w, http.StatusConflict, "Bewerbung wurde inzwischen geändert")
+			return
+		} else if err != nil {
+			log.Printf("ChangeApplicationStatus error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		recordAudit(apx, r, "application.status", "application", strconv.FormatInt(id, 10),
+			map[string]any{"status": app.Status},
+			map[string]any{"status": req.Status, "reason": req.Reason})
+		if applicant, err := apx.GetUserByUsername(r.Context(), app.Username); err == nil {
+			notifyApplicant(applicant, *app, req.Status, req.Reason)
+		}
+
+		warnings := []string{}
+		if req.CreateTeamMember {
+			member := map[string]any{
+				"name": app.Name, "username": app.Username, "atk_role": app.AttackerRole,
+				"def_role": app.DefenderRole, "is_main_roster": req.MainRoster,
+			}
+			memberID, err := apx.AddTeamMember(r.Context(), app.Name, app.Username, app.AttackerRole, app.DefenderRole, req.MainRoster)
+			if err != nil {
+				log.Printf("AddTeamMember for application %d: %v", id, err)
+				warnings = append(warnings, "Roster-Eintrag konnte nicht angelegt werden")
+			} else {
+				publicRoster.invalidate()
+				recordRosterEvents(r.Context(), apx, rosterJoined(memberID, app.Name, app.Username, req.MainRoster))
+				recordAudit(apx, r, "team.create", "team_member", strconv.FormatInt(memberID, 10), nil, member)
+			}
+		}
+		if req.GrantEventAccess {
+			if err := apx.SetUserEventAccess(r.Context(), app.UserID, true); err != nil {
+				log.Printf("SetUserEventAccess for application %d: %v", id, err)
+				warnings = append(warnings, "Event-Zugang konnte nicht vergeben werden")
+			} else {
+				recordAudit(apx, r, "user.event_access", "user", app.Username, nil, map[string]any{"event_access": true})
+			}
+		}
+
+		updated, err := apx.GetApplicationByID(r.Context(), id)
+		if err != nil {
+			updated = app
+			updated.Status, updated.StatusReason = req.Status, req.Reason
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"success":     true,
+			"application": updated,
+			"warnings":    warnings,
+		})
+	}
+}
+
+// handleAdminApplicationHistory serves GET /api/admin/applications/{id}/history.
+func handleAdminApplicationHistory(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := applicationIDParam(w, r)
+		if !ok {
+			return
+		}
+		history, err := apx.GetApplicationHistory(r.Context(), id)
+		if err != nil {
+			log.Printf("GetApplicationHistory error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"history": history})
+	}
+}
+
+// handleAdminApplicationReviews serves GET /api/admin/applications/{id}/reviews:
+// reviewer notes, every reviewer's rating and the aggregated score.
+func handleAdminApplicationReviews(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		app, ok := loadApplication(w, r, apx)
+		if !ok {
+			return
+		}
+		notes, err := apx.GetApplicationNotes(r.Context(), app.ID)
+		if err != nil {
+			log.Printf("GetApplicationNotes error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		ratings, err := apx.GetApplicationRatings(r.Context(), app.ID)
+		if err != nil {
+			log.Printf("GetApplicationRatings error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		var score *float64
+		if len(ratings) > 0 {
+			sum := 0
+			for _, rt := range ratings {
+				sum += rt.Rank + rt.Experience + rt.Availability + rt.Communication
+			}
+			score = roundScore(float64(sum) / float64(4*len(ratings)))
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"notes":        notes,
+			"ratings":      ratings,
+			"score":        score,
+			"rating_count": len(ratings),
+		})
+	}
+}
+
+// handleAdminApplicationNote serves POST /api/admin/applications/{id}/notes.
+func handleAdminApplicationNote(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Body string `json:"body"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		req.Body = strings.TrimSpace(req.Body)
+		if req.Body == "" {
+			jsonError(w, http.StatusBadRequest, "Notiz darf nicht leer sein")
+			return
+		}
+		if len([]rune(req.Body)) > 2000 {
+			jsonError(w, http.StatusBadRequest, "Notiz zu lang (max 2000 Zeichen)")
+			return
+		}
+		app, ok := loadApplication(w, r, apx)
+		if !ok {
+			return
+		}
+		actor := sessionUser(r)
+		noteID, err := apx.AddApplicationNote(r.Context(), ApplicationNote{
+			ApplicationID: app.ID,
+			AuthorID:      actor.ID,
+			AuthorName:    actor.Username,
+			Body:          req.Body,
+		})
+		if err != nil {
+			log.Printf("AddApplicationNote error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		// The note text stays out of the audit log: audit.view does not imply
+		// applications.review.
+		recordAudit(apx, r, "application.note", "application", strconv.FormatInt(app.ID, 10), nil,
+			map[string]any{"note_id": noteID})
+		jsonResponse(w, http.StatusCreated, map[string]interface{}{"id": noteID, "success": true})
+	}
+}
+
+// ratingAuditState is what the audit log keeps of a rating: who rated and
+// the mean score, not the per-criterion assessment.
+func ratingAuditState(rt ApplicationRating) map[string]any {
+	mean := float64(rt.Rank+rt.Experience+rt.Availability+rt.Communication) / 4
+	return map[string]any{"reviewer_id": rt.ReviewerID, "score": *roundScore(mean)}
+}
+
+// handleAdminApplicationRating serves PUT /api/admin/applications/{id}/rating,
+// setting the current reviewer's rating (1–5 per criterion).
+func handleAdminApplicationRating(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Rank          int `json:"rank"`
+			Experience    int `json:"experience"`
+			Availability  int `json:"availability"`
+			Communication int `json:"communication"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		for _, v := range []int{req.Rank, req.Experience, req.Availability, req.Communication} {
+			if v < 1 || v > 5 {
+				jsonError(w, http.StatusBadRequest, "Bewertungen müssen zwischen 1 und 5 liegen")
+				return
+			}
+		}
+		app, ok := loadApplication(w, r, apx)
+		if !ok {
+			return
+		}
+		actor := sessionUser(r)
+
+		var before any
+		if ratings, err := apx.GetApplicationRatings(r.Context(), app.ID); err == nil {
+			if i := slices.IndexFunc(ratings, func(rt ApplicationRating) bool { return rt.ReviewerID == actor.ID }); i >= 0 {
+				before = ratingAuditState(ratings[i])
+			}
+		}
+		rating := ApplicationRating{
+			ApplicationID: app.ID,
+			ReviewerID:    actor.ID,
+			ReviewerName:  actor.Username,
+			Rank:          req.Rank,
+			Experience:    req.Experience,
+			Availability:  req.Availability,
+			Communication: req.Communication,
+		}
+		if err := apx.UpsertApplicationRating(r.Context(), rating); err != nil {
+			log.Printf("UpsertApplicationRating error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		recordAudit(apx, r, "application.rating", "application", strconv.FormatInt(app.ID, 10), before, ratingAuditState(rating))
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
+
+// handleWithdrawMyApplication serves POST /api/auth/my-application/withdraw.
+// Open applications can be withdrawn; withdrawn applications do not start the
+// reapply cooldown.
+func handleWithdrawMyApplication(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := sessionUser(r)
+		var req struct {
+			Reason string `json:"reason"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				jsonError(w, http.StatusBadRequest, "invalid request body")
+				return
+			}
+		}
+		app, err := apx.GetApplicationByUserID(r.Context(), user.ID)
+		if errors.Is(err, errNotFound) {
+			jsonError(w, http.StatusNotFound, "Keine Bewerbung gefunden")
+			return
+		} else if err != nil {
+			log.Printf("GetApplicationByUserID error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		if !applicationOpen(app.Status) {
+			jsonError(w, http.StatusConflict, "Bewerbung ist bereits abgeschlossen")
+			return
+		}
+
+		change := ApplicationStatusChange{
+			ApplicationID: app.ID,
+			FromStatus:    app.Status,
+			ToStatus:      AppStatusWithdrawn,
+			Reason:        strings.TrimSpace(req.Reason),
+			ActorID:       user.ID,
+			ActorName:     user.Username,
+		}
+		if err := apx.ChangeApplicationStatus(r.Context(), change); errors.Is(err, errApplicationStatusChanged) {
+			jsonError(w, http.StatusConflict, "Bewerbung wurde inzwischen geändert")
+			return
+		} else if err != nil {
+			log.Printf("ChangeApplicationStatus error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		recordAudit(apx, r, "application.withdraw", "application", strconv.FormatInt(app.ID, 10),
+			map[string]any{"status": app.Status},
+			map[string]any{"status": AppStatusWithdrawn, "reason": change.Reason})
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
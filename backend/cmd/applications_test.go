@@ -0,0 +1,28 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"teamapx-backend/cmd/apxfake"
+)
+
+func TestApplyOnceAndEditKeepsGame(t *testing.T) {
+	e := newTestEnv(t)
+	cookie := e.session(e.addUser(apxfake.User{Username: "applicant", Email: "applicant@example.com"}, "secret123"))
+	form := map[string]any{
+		"game": "Rainbow Six Siege", "name": "Applicant", "age": 20, "discord": "applicant",
+		"rank": "Gold", "attacker_role": "Entry", "defender_role": "Anchor",
+		"experience": "2 Jahre", "motivation": "Team",
+	}
+
+	expectStatus(t, e.do("POST", "/api/apply", form, cookie), http.StatusOK)
+	expectStatus(t, e.do("POST", "/api/apply", form, cookie), http.StatusConflict)
+
+	form["game"], form["age"] = "Valorant", 21
+	expectStatus(t, e.do("PUT", "/api/auth/my-application", form, cookie), http.StatusOK)
+	app, _ := decodeBody(t, e.do("GET", "/api/auth/my-application", nil, cookie))["application"].(map[string]any)
+	if app["game"] != "Rainbow Six Siege" || app["age"] != float64(21) {
+		t.Errorf("application after edit = %v", app)
+	}
+}
@@ -5,17 +5,31 @@ import (
 	"context"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"math/rand/v2"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
 // ApxClient is an HTTP client for the ApxApi service.
+//
+// Every call runs under the caller's context, so a request is abandoned as
+// soon as the browser disconnects. GETs are retried with jittered backoff on
+// transport errors and 5xx responses. A circuit breaker stops calling ApxApi
+// after repeated failures; while it is open, or when retries are exhausted,
+// GETs of public read-only data are answered from the last good response.
 type ApxClient struct {
 	baseURL string
 	apiKey  string
 	http    *http.Client
+	breaker breaker
+	stale   staleCache
 }
 
 func NewApxClient(baseURL, apiKey string) *ApxClient {
@@ -23,12 +37,132 @@ func NewApxClient(baseURL, apiKey string) *ApxClient {
 		baseURL: baseURL,
 		apiKey:  apiKey,
 		http:    &http.Client{Timeout: 10 * time.Second},
+		stale:   staleCache{entries: map[string]staleEntry{}},
 	}
 }
 
+const (
+	getAttempts      = 3
+	retryBaseDelay   = 100 * time.Millisecond
+	breakerThreshold = 5
+	breakerCooldown  = 30 * time.Second
+	staleCacheMax    = 512
+	staleCacheMaxAge = time.Hour
+)
+
+var (
+	errNotFound            = fmt.Errorf("not found")
+	errUpstreamUnavailable = fmt.Errorf("apxapi unavailable")
+	errUnsupported         = fmt.Errorf("not supported by apxapi")
+)
+
+// staleCachePrefixes lists the GET paths whose responses may be served from
+// the stale cache. Auth, session and 2FA lookups are deliberately excluded: a
+// revoked session or changed password must never be resurrected from cache.
+var staleCachePrefixes = []string{
+	"/events", "/team", "/staff", "/log", "/badges", "/items", "/matches",
+	"/bot/leaderboard", "/progression/", "/users/search", "/users/all-usernames",
+	"/application-forms", "/stats/weekly", "/org/",
+}
+
+// ── Circuit breaker ──────────────────────────────────────────────────────────
+
+// breaker opens after breakerThreshold consecutive failures. While open,
+// requests fail fast with errUpstreamUnavailable; once per breakerCooldown a
+// single trial request is let through, and its outcome closes or re-opens it.
+type breaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < breakerThreshold {
+		return true
+	}
+	if time.Now().Before(b.openUntil) {
+		return false
+	}
+	b.openUntil = time.Now().Add(breakerCooldown)
+	return true
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures >= breakerThreshold {
+		log.Printf("ApxApi circuit closed")
+	}
+	b.failures = 0
+}
+
+func (b *breaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.failures >= breakerThreshold {
+		if b.failures == breakerThreshold {
+			log.Printf("ApxApi circuit open after %d consecutive failures", b.failures)
+		}
+		b.openUntil = time.Now().Add(breakerCooldown)
+	}
+}
+
+// ── Stale cache ──────────────────────────────────────────────────────────────
+
+type staleEntry struct {
+	data json.RawMessage
+	at   time.Time
+}
+
+// staleCache keeps the last successful response per GET path. When full, the
+// oldest entry is evicted.
+type staleCache struct {
+	mu      sync.Mutex
+	entries map[string]staleEntry
+}
+
+func (s *staleCache) put(path string, data json.RawMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[path]; !ok && len(s.entries) >= staleCacheMax {
+		var oldest string
+		for k, e := range s.entries {
+			if oldest == "" || e.at.Before(s.entries[oldest].at) {
+				oldest = k
+			}
+		}
+		delete(s.entries, oldest)
+	}
+	s.entries[path] = staleEntry{data: data, at: time.Now()}
+}
+
+func (s *staleCache) get(path string) (json.RawMessage, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[path]
+	if !ok || time.Since(e.at) > staleCacheMaxAge {
+		return nil, false
+	}
+	return e.data, true
+}
+
+func staleCacheable(path string) bool {
+	for _, p := range staleCachePrefixes {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
 // ── HTTP helpers ─────────────────────────────────────────────────────────────
 
-func (c *ApxClient) req(method, path string, payload any) (*http.Response, error) {
+// req performs one HTTP call and feeds its outcome to the circuit breaker.
+// Cancellation by the caller does not count as an upstream failure.
+func (c *ApxClient) req(ctx context.Context, method, path string, payload any) (*http.Response, error) {
 	var body *bytes.Reader
 	if payload != nil {
 		b, err := json.Marshal(payload)
@@ -39,7 +173,10 @@ func (c *ApxClient) req(method, path string, payload any) (*http.Response, error
 	} else {
 		body = bytes.NewReader(nil)
 	}
-	r, err := http.NewRequestWithContext(context.Background(), method, c.baseURL+path, body)
+	if !c.breaker.allow() {
+		return nil, errUpstreamUnavailable
+	}
+	r, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
 	if err != nil {
 		return nil, err
 	}
@@ -47,42 +184,109 @@ func (c *ApxClient) req(method, path string, payload any) (*http.Response, error
 		r.Header.Set("Content-Type", "application/json")
 	}
 	r.Header.Set("X-API-KEY", c.apiKey)
-	return c.http.Do(r)
+	resp, err := c.http.Do(r)
+	switch {
+	case err != nil:
+		if ctx.Err() == nil {
+			c.breaker.failure()
+		}
+	case resp.StatusCode >= 500:
+		c.breaker.failure()
+	default:
+		c.breaker.success()
+	}
+	return resp, err
 }
 
-// decodeData unwraps {"data": ...} into dest.
-func decodeData(resp *http.Response, dest any) error {
+// readData unwraps {"data": ...} and returns the raw data.
+func readData(resp *http.Response) (json.RawMessage, error) {
 	defer resp.Body.Close()
 	var wrapper struct {
 		Data json.RawMessage `json:"data"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&wrapper); err != nil {
+		return nil, err
+	}
+	return wrapper.Data, nil
+}
+
+// decodeData unwraps {"data": ...} into dest.
+func decodeData(resp *http.Response, dest any) error {
+	data, err := readData(resp)
+	if err != nil || dest == nil {
 		return err
 	}
-	if dest == nil {
-		return nil
+	return json.Unmarshal(data, dest)
+}
+
+// retryDelay returns a full-jitter exponential backoff for the given retry.
+func retryDelay(retry int) time.Duration {
+	return rand.N(retryBaseDelay << retry)
+}
+
+// getData performs a GET with retries on transport errors, 429 and 5xx.
+func (c *ApxClient) getData(ctx context.Context, path string) (json.RawMessage, error) {
+	var lastErr error
+	for attempt := 0; attempt < getAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(retryDelay(attempt)):
+			}
+		}
+		resp, err := c.req(ctx, http.MethodGet, path, nil)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, errUpstreamUnavailable) {
+				return nil, err
+			}
+			lastErr = err
+			continue
+		}
+		switch {
+		case resp.StatusCode == http.StatusNotFound:
+			resp.Body.Close()
+			return nil, errNotFound
+		case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+			resp.Body.Close()
+			lastErr = fmt.Errorf("http %d: GET %s", resp.StatusCode, path)
+			continue
+		case resp.StatusCode >= 400:
+			resp.Body.Close()
+			return nil, fmt.Errorf("http %d: GET %s", resp.StatusCode, path)
+		}
+		return readData(resp)
 	}
-	return json.Unmarshal(wrapper.Data, dest)
+	return nil, lastErr
 }
 
-func (c *ApxClient) get(path string, dest any) error {
-	resp, err := c.req(http.MethodGet, path, nil)
-	if err != nil {
+// get fetches path into dest. If ApxApi is unreachable or failing, cacheable
+// paths fall back to the last good response.
+func (c *ApxClient) get(ctx context.Context, path string, dest any) error {
+	data, err := c.getData(ctx, path)
+	switch {
+	case err == nil:
+		if staleCacheable(path) {
+			c.stale.put(path, data)
+		}
+	case errors.Is(err, errNotFound) || ctx.Err() != nil || !staleCacheable(path):
 		return err
+	default:
+		cached, ok := c.stale.get(path)
+		if !ok {
+			return err
+		}
+		log.Printf("ApxApi GET %s failed (%v), serving cached response", path, err)
+		data = cached
 	}
-	if resp.StatusCode == http.StatusNotFound {
-		resp.Body.Close()
-		return errNotFound
-	}
-	if resp.StatusCode >= 400 {
-		resp.Body.Close()
-		return fmt.Errorf("http %d: GET %s", resp.StatusCode, path)
+	if dest == nil {
+		return nil
 	}
-	return decodeData(resp, dest)
+	return json.Unmarshal(data, dest)
 }
 
-func (c *ApxClient) post(path string, payload any, dest any) error {
-	resp, err := c.req(http.MethodPost, path, payload)
+func (c *ApxClient) post(ctx context.Context, path string, payload any, dest any) error {
+	resp, err := c.req(ctx, http.MethodPost, path, payload)
 	if err != nil {
 		return err
 	}
@@ -97,8 +301,8 @@ func (c *ApxClient) post(path string, payload any, dest any) error {
 	return decodeData(resp, dest)
 }
 
-func (c *ApxClient) patch(path string, payload any) error {
-	resp, err := c.req(http.MethodPatch, path, payload)
+func (c *ApxClient) patch(ctx context.Context, path string, payload any) error {
+	resp, err := c.req(ctx, http.MethodPatch, path, payload)
 	if err != nil {
 		return err
 	}
@@ -109,8 +313,8 @@ func (c *ApxClient) patch(path string, payload any) error {
 	return nil
 }
 
-func (c *ApxClient) patchGet(path string, payload any, dest any) error {
-	resp, err := c.req(http.MethodPatch, path, payload)
+func (c *ApxClient) patchGet(ctx context.Context, path string, payload any, dest any) error {
+	resp, err := c.req(ctx, http.MethodPatch, path, payload)
 	if err != nil {
 		return err
 	}
@@ -121,8 +325,8 @@ func (c *ApxClient) patchGet(path string, payload any, dest any) error {
 	return decodeData(resp, dest)
 }
 
-func (c *ApxClient) put(path string, payload any) error {
-	resp, err := c.req(http.MethodPut, path, payload)
+func (c *ApxClient) put(ctx context.Context, path string, payload any) error {
+	resp, err := c.req(ctx, http.MethodPut, path, payload)
 	if err != nil {
 		return err
 	}
@@ -133,8 +337,8 @@ func (c *ApxClient) put(path string, payload any) error {
 	return nil
 }
 
-func (c *ApxClient) del(path string) error {
-	resp, err := c.req(http.MethodDelete, path, nil)
+func (c *ApxClient) del(ctx context.Context, path string) error {
+	resp, err := c.req(ctx, http.MethodDelete, path, nil)
 	if err != nil {
 		return err
 	}
@@ -145,8 +349,8 @@ func (c *ApxClient) del(path string) error {
 	return nil
 }
 
-func (c *ApxClient) delBody(path string, payload any) error {
-	resp, err := c.req(http.MethodDelete, path, payload)
+func (c *ApxClient) delBody(ctx context.Context, path string, payload any) error {
+	resp, err := c.req(ctx, http.MethodDelete, path, payload)
 	if err != nil {
 		return err
 	}
@@ -157,8 +361,6 @@ func (c *ApxClient) delBody(path string, payload any) error {
 	return nil
 }
 
-var errNotFound = fmt.Errorf("not found")
-
 func randHex(n int) string {
 	b := make([]byte, n)
 	for i := range b {
@@ -169,9 +371,9 @@ func randHex(n int) string {
 
 // ── Auth / Sessions ──────────────────────────────────────────────────────────
 
-func (c *ApxClient) GetSessionUser(token string) (*User, error) {
+func (c *ApxClient) GetSessionUser(ctx context.Context, token string) (*User, error) {
 	var u User
-	if err := c.get("/auth/sessions/validate/"+token, &u); err != nil {
+	if err := c.get(ctx, "/auth/sessions/validate/"+token, &u); err != nil {
 		return nil, err
 	}
 	if u.SocialLinks == nil {
@@ -180,67 +382,87 @@ func (c *ApxClient) GetSessionUser(token string) (*User, error) {
 	return &u, nil
 }
 
-func (c *ApxClient) CreateSession(userID int64) (string, error) {
+func (c *ApxClient) CreateSession(ctx context.Context, userID int64) (string, error) {
 	var result struct {
 		Token string `json:"token"`
 	}
-	if err := c.post("/auth/sessions", map[string]any{"user_id": userID}, &result); err != nil {
+	if err := c.post(ctx, "/auth/sessions", map[string]any{"user_id": userID}, &result); err != nil {
 		return "", err
 	}
 	return result.Token, nil
 }
 
-func (c *ApxClient) DeleteSession(token string) error {
-	return c.del("/auth/sessions/" + token)
+func (c *ApxClient) DeleteSession(ctx context.Context, token string) error {
+	return c.del(ctx, "/auth/sessions/"+token)
 }
 
 // ── Email Verifications ──────────────────────────────────────────────────────
 
-func (c *ApxClient) CreateEmailVerification(email, username, nickname, hashedPw, code string, expiresAt time.Time) error {
-	return c.post("/auth/verifications", map[string]any{
+func (c *ApxClient) CreateEmailVerification(ctx context.Context, email, username, nickname, hashedPw, code string, expiresAt time.Time) error {
+	return c.post(ctx, "/auth/verifications", map[string]any{
 		"email": email, "username": username, "nickname": nickname,
 		"password": hashedPw, "code": code, "expires_at": expiresAt,
 	}, nil)
 }
 
-func (c *ApxClient) GetEmailVerification(email string) (*EmailVerification, error) {
+func (c *ApxClient) GetEmailVerification(ctx context.Context, email string) (*EmailVerification, error) {
 	var v EmailVerification
-	if err := c.get("/auth/verifications/"+email, &v); err != nil {
+	if err := c.get(ctx, "/auth/verifications/"+email, &v); err != nil {
 		return nil, err
 	}
 	return &v, nil
 }
 
-func (c *ApxClient) DeleteEmailVerification(email string) {
-	_ = c.del("/auth/verifications/" + email)
+func (c *ApxClient) DeleteEmailVerification(ctx context.Context, email string) {
+	_ = c.del(ctx, "/auth/verifications/"+email)
 }
 
 // ── Email Change Requests ────────────────────────────────────────────────────
 
-func (c *ApxClient) CreateEmailChangeRequest(userID int64, newEmail, code string, expiresAt time.Time) error {
-	return c.post("/auth/email-changes", map[string]any{
+func (c *ApxClient) CreateEmailChangeRequest(ctx context.Context, userID int64, newEmail, code string, expiresAt time.Time) error {
+	return c.post(ctx, "/auth/email-changes", map[string]any{
 		"user_id": userID, "new_email": newEmail, "code": code, "expires_at": expiresAt,
 	}, nil)
 }
 
-func (c *ApxClient) GetEmailChangeRequest(userID int64) (*EmailChangeRequest, error) {
+func (c *ApxClient) GetEmailChangeRequest(ctx context.Context, userID int64) (*EmailChangeRequest, error) {
 	var r EmailChangeRequest
-	if err := c.get(fmt.Sprintf("/auth/email-changes/%d", userID), &r); err != nil {
+	if err := c.get(ctx, fmt.Sprintf("/auth/email-changes/%d", userID), &r); err != nil {
 		return nil, err
 	}
 	return &r, nil
 }
 
-func (c *ApxClient) DeleteEmailChangeRequest(userID int64) {
-	_ = c.del(fmt.Sprintf("/auth/email-changes/%d", userID))
+func (c *ApxClient) DeleteEmailChangeRequest(ctx context.Context, userID int64) {
+	_ = c.del(ctx, fmt.Sprintf("/auth/email-changes/%d", userID))
+}
+
+// ── Password Resets ──────────────────────────────────────────────────────────
+
+func (c *ApxClient) CreatePasswordReset(ctx context.Context, userID int64, email, code string, expiresAt time.Time) error {
+	return c.post(ctx, "/auth/password-resets", map[string]any{
+		"user_id": userID, "email": email, "code": code, "expires_at": expiresAt,
+	}, nil)
+}
+
+func (c *ApxClient) GetPasswordReset(ctx context.Context, email string) (*PasswordReset, error) {
+	var pr PasswordReset
+	if err := c.get(ctx, "/auth/password-resets/"+email, &pr); err != nil {
+		return nil, err
+	}
+	return &pr, nil
+}
+
+func (c *ApxClient) DeletePasswordReset(ctx context.Context, email string) {
+	_ = c.del(ctx, "/auth/password-resets/"+email)
 }
 
 // ── 2FA Pending ──────────────────────────────────────────────────────────────
 
-func (c *ApxClient) CreateLogin2FAPending(userID int64, code string) (string, error) {
+func (c *ApxClient) CreateLogin2FAPending(ctx context.Context, userID int64, code string) (string, error) {
 	token := randHex(16)
 	expiresAt := time.Now().Add(10 * time.Minute)
-	if err := c.post("/auth/2fa/pending", map[string]any{
+	if err := c.post(ctx, "/auth/2fa/pending", map[string]any{
 		"token": token, "user_id": userID, "code": code, "expires_at": expiresAt,
 	}, nil); err != nil {
 		return "", err
@@ -248,27 +470,84 @@ func (c *ApxClient) CreateLogin2FAPending(userID int64, code string) (string, er
 	return token, nil
 }
 
-func (c *ApxClient) GetLogin2FAPending(token string) (int64, string, error) {
+func (c *ApxClient) GetLogin2FAPending(ctx context.Context, token string) (int64, string, error) {
 	var result struct {
 		UserID int64  `json:"user_id"`
 		Code   string `json:"code"`
 	}
-	if err := c.get("/auth/2fa/pending/"+token, &result); err != nil {
+	if err := c.get(ctx, "/auth/2fa/pending/"+token, &result); err != nil {
 		return 0, "", err
 	}
 	return result.UserID, result.Code, nil
 }
 
-func (c *ApxClient) DeleteLogin2FAPending(token string) {
-	_ = c.del("/auth/2fa/pending/" + token)
+func (c *ApxClient) DeleteLogin2FAPending(ctx context.Context, token string) {
+	_ = c.del(ctx, "/auth/2fa/pending/"+token)
+}
+
+// ── TOTP / Recovery Codes ────────────────────────────────────────────────────
+
+func (c *ApxClient) GetUser2FAConfig(ctx context.Context, userID int64) (*TwoFAConfig, error) {
+	var cfg TwoFAConfig
+	if err := c.get(ctx, fmt.Sprintf("/users/%d/2fa-config", userID), &cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Method == "" {
+		cfg.Method = "email"
+	}
+	return &cfg, nil
+}
+
+func (c *ApxClient) SetUser2FAMethod(ctx context.Context, userID int64, method string) error {
+	return c.patch(ctx, fmt.Sprintf("/users/%d/2fa-method", userID), map[string]any{"method": method})
+}
+
+func (c *ApxClient) SetUserTOTPSecret(ctx context.Context, userID int64, secret string) error {
+	return c.put(ctx, fmt.Sprintf("/users/%d/totp", userID), map[string]any{"secret": secret})
+}
+
+func (c *ApxClient) ConfirmUserTOTP(ctx context.Context, userID int64) error {
+	return c.patch(ctx, fmt.Sprintf("/users/%d/totp/confirm", userID), map[string]any{})
+}
+
+// DeleteUserTOTP removes the secret and all recovery codes and resets the
+// 2FA method to email.
+func (c *ApxClient) DeleteUserTOTP(ctx context.Context, userID int64) error {
+	return c.del(ctx, fmt.Sprintf("/users/%d/totp", userID))
+}
+
+func (c *ApxClient) SetRecoveryCodes(ctx context.Context, userID int64, hashes []string) error {
+	return c.put(ctx, fmt.Sprintf("/users/%d/recovery-codes", userID), map[string]any{"hashes": hashes})
+}
+
+// ConsumeRecoveryCode marks an unused recovery code as used. It reports
+// whether a matching unused code existed.
+func (c *ApxClient) ConsumeRecoveryCode(ctx context.Context, userID int64, hash string) (bool, error) {
+	var result struct {
+		Consumed bool `json:"consumed"`
+	}
+	if err := c.post(ctx, fmt.Sprintf("/users/%d/recovery-codes/consume", userID), map[string]any{"hash": hash}, &result); err != nil {
+		return false, err
+	}
+	return result.Consumed, nil
+}
+
+func (c *ApxClient) UseTOTPStep(ctx context.Context, userID int64, step int64) (bool, error) {
+	var result struct {
+		Accepted bool `json:"accepted"`
+	}
+	if err := c.post(ctx, fmt.Sprintf("/users/%d/totp/step", userID), map[string]any{"step": step}, &result); err != nil {
+		return false, err
+	}
+	return result.Accepted, nil
 }
 
 // ── Trusted Devices ──────────────────────────────────────────────────────────
 
-func (c *ApxClient) CreateTrustedDevice(userID int64, deviceName, ip, location string) (string, error) {
+func (c *ApxClient) CreateTrustedDevice(ctx context.Context, userID int64, deviceName, ip, location string) (string, error) {
 	token := randHex(32)
 	expiresAt := time.Now().Add(30 * 24 * time.Hour)
-	if err := c.post("/auth/devices", map[string]any{
+	if err := c.post(ctx, "/auth/devices", map[string]any{
 		"token": token, "user_id": userID, "device_name": deviceName,
 		"ip": ip, "location": location, "expires_at": expiresAt,
 	}, nil); err != nil {
@@ -277,19 +556,19 @@ func (c *ApxClient) CreateTrustedDevice(userID int64, deviceName, ip, location s
 	return token, nil
 }
 
-func (c *ApxClient) GetTrustedDeviceUserID(token string) (int64, error) {
+func (c *ApxClient) GetTrustedDeviceUserID(ctx context.Context, token string) (int64, error) {
 	var result struct {
 		UserID int64 `json:"user_id"`
 	}
-	if err := c.get("/auth/devices/by-token/"+token, &result); err != nil {
+	if err := c.get(ctx, "/auth/devices/by-token/"+token, &result); err != nil {
 		return 0, err
 	}
 	return result.UserID, nil
 }
 
-func (c *ApxClient) GetTrustedDevices(userID int64) ([]TrustedDevice, error) {
+func (c *ApxClient) GetTrustedDevices(ctx context.Context, userID int64) ([]TrustedDevice, error) {
 	var devices []TrustedDevice
-	if err := c.get(fmt.Sprintf("/auth/devices/user/%d", userID), &devices); err != nil {
+	if err := c.get(ctx, fmt.Sprintf("/auth/devices/user/%d", userID), &devices); err != nil {
 		return nil, err
 	}
 	if devices == nil {
@@ -298,24 +577,24 @@ func (c *ApxClient) GetTrustedDevices(userID int64) ([]TrustedDevice, error) {
 	return devices, nil
 }
 
-func (c *ApxClient) DeleteTrustedDevice(token string, userID int64) error {
-	return c.delBody("/auth/devices/"+token, map[string]any{"user_id": userID})
+func (c *ApxClient) DeleteTrustedDevice(ctx context.Context, token string, userID int64) error {
+	return c.delBody(ctx, "/auth/devices/"+token, map[string]any{"user_id": userID})
 }
 
 // ── OAuth States ─────────────────────────────────────────────────────────────
 
-func (c *ApxClient) CreateOAuthState(state string, userID int64, codeVerifier string, expiresAt time.Time) error {
-	return c.post("/auth/oauth-states", map[string]any{
+func (c *ApxClient) CreateOAuthState(ctx context.Context, state string, userID int64, codeVerifier string, expiresAt time.Time) error {
+	return c.post(ctx, "/auth/oauth-states", map[string]any{
 		"state": state, "user_id": userID, "code_verifier": codeVerifier, "expires_at": expiresAt,
 	}, nil)
 }
 
-func (c *ApxClient) GetAndDeleteOAuthState(state string) (*OAuthState, error) {
+func (c *ApxClient) GetAndDeleteOAuthState(ctx context.Context, state string) (*OAuthState, error) {
 	var result struct {
 		UserID       int64  `json:"user_id"`
 		CodeVerifier string `json:"code_verifier"`
 	}
-	if err := c.post("/auth/oauth-states/consume", map[string]any{"state": state}, &result); err != nil {
+	if err := c.post(ctx, "/auth/oauth-states/consume", map[string]any{"state": state}, &result); err != nil {
 		return nil, err
 	}
 	return &OAuthState{UserID: result.UserID, CodeVerifier: result.CodeVerifier}, nil
@@ -323,11 +602,11 @@ func (c *ApxClient) GetAndDeleteOAuthState(state string) (*OAuthState, error) {
 
 // ── Users ────────────────────────────────────────────────────────────────────
 
-func (c *ApxClient) CreateUser(username, nickname, email, hashedPw string) (int64, error) {
+func (c *ApxClient) CreateUser(ctx context.Context, username, nickname, email, hashedPw string) (int64, error) {
 	var result struct {
 		ID int64 `json:"id"`
 	}
-	if err := c.post("/users/create-with-hash", map[string]any{
+	if err := c.post(ctx, "/users/create-with-hash", map[string]any{
 		"username": username, "nickname": nickname, "email": email, "password_hash": hashedPw,
 	}, &result); err != nil {
 		return 0, err
@@ -335,33 +614,67 @@ func (c *ApxClient) CreateUser(username, nickname, email, hashedPw string) (int6
 	return result.ID, nil
 }
 
-func (c *ApxClient) GetUserByEmail(email string) (*User, error) {
+func (c *ApxClient) GetUserByEmail(ctx context.Context, email string) (*User, error) {
 	var u User
-	if err := c.get("/users/by-email/"+email, &u); err != nil {
+	if err := c.get(ctx, "/users/by-email/"+email, &u); err != nil {
 		return nil, err
 	}
 	return &u, nil
 }
 
-func (c *ApxClient) GetUserByUsername(username string) (*User, error) {
+func (c *ApxClient) GetUserByUsername(ctx context.Context, username string) (*User, error) {
 	var u User
-	if err := c.get("/users/by-username/"+username, &u); err != nil {
+	if err := c.get(ctx, "/users/by-username/"+username, &u); err != nil {
 		return nil, err
 	}
 	return &u, nil
 }
 
-func (c *ApxClient) GetUserByUsernameAny(username string) (*User, error) {
+func (c *ApxClient) GetUserByUsernameAny(ctx context.Context, username string) (*User, error) {
 	var u User
-	if err := c.get("/users/by-username-any/"+username, &u); err != nil {
+	if err := c.get(ctx, "/users/by-username-any/"+username, &u); err != nil {
 		return nil, err
 	}
 	return &u, nil
 }
 
-func (c *ApxClient) GetLinkedAccounts(userID int64) ([]LinkedAccount, error) {
+// GetUsersByUsernames looks up many active users in one call. Unknown or
+// inactive usernames are absent from the result. ApxApi releases without
+// POST /users/by-usernames answer 404 or 405, reported as errUnsupported
+// (see usersByUsernames for the fallback).
+func (c *ApxClient) GetUsersByUsernames(ctx context.Context, usernames []string) (map[string]*User, error) {
+	result := map[string]*User{}
+	if len(usernames) == 0 {
+		return result, nil
+	}
+	resp, err := c.req(ctx, http.MethodPost, "/users/by-usernames", map[string]any{"usernames": usernames})
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed:
+		resp.Body.Close()
+		return nil, fmt.Errorf("POST /users/by-usernames: %w", errUnsupported)
+	case resp.StatusCode >= 400:
+		resp.Body.Close()
+		return nil, fmt.Errorf("http %d: POST /users/by-usernames", resp.StatusCode)
+	}
+	var users []User
+	if err := decodeData(resp, &users); err != nil {
+		return nil, err
+	}
+	for i := range users {
+		if users[i].SocialLinks == nil {
+			users[i].SocialLinks = []string{}
+		}
+		result[users[i].Username] = &users[i]
+	}
+	return result, nil
+}
+
+func (c *ApxClient) GetLinkedAccounts(ctx context.Context, userID int64) ([]LinkedAccount, error) {
 	var accounts []LinkedAccount
-	if err := c.get(fmt.Sprintf("/users/%d/linked-accounts", userID), &accounts); err != nil {
+	if err := c.get(ctx, fmt.Sprintf("/users/%d/linked-accounts", userID), &accounts); err != nil {
 		return nil, err
 	}
 	if accounts == nil {
@@ -370,23 +683,23 @@ func (c *ApxClient) GetLinkedAccounts(userID int64) ([]LinkedAccount, error) {
 	return accounts, nil
 }
 
-func (c *ApxClient) UpsertLinkedAccount(userID int64, service, serviceID, username, avatarURL, profileURL string) error {
-	return c.post(fmt.Sprintf("/users/%d/linked-account", userID), map[string]any{
+func (c *ApxClient) UpsertLinkedAccount(ctx context.Context, userID int64, service, serviceID, username, avatarURL, profileURL string) error {
+	return c.post(ctx, fmt.Sprintf("/users/%d/linked-account", userID), map[string]any{
 		"service": service, "service_id": serviceID, "username": username,
 		"avatar_url": avatarURL, "profile_url": profileURL,
 	}, nil)
 }
 
-func (c *ApxClient) DeleteLinkedAccount(userID int64, service string) error {
-	return c.del(fmt.Sprintf("/users/%d/linked-account/%s", userID, service))
+func (c *ApxClient) DeleteLinkedAccount(ctx context.Context, userID int64, service string) error {
+	return c.del(ctx, fmt.Sprintf("/users/%d/linked-account/%s", userID, service))
 }
 
-func (c *ApxClient) UpdateLinkedAccountField(userID int64, service, field, value string) error {
-	return c.patch(fmt.Sprintf("/users/%d/linked-account/%s/%s", userID, service, field),
+func (c *ApxClient) UpdateLinkedAccountField(ctx context.Context, userID int64, service, field, value string) error {
+	return c.patch(ctx, fmt.Sprintf("/users/%d/linked-account/%s/%s", userID, service, field),
 		map[string]any{"value": value})
 }
 
-func (c *ApxClient) UpdateUserProfile(userID int64, username, nickname, email, avatarURL, bannerURL, bio string) error {
+func (c *ApxClient) UpdateUserProfile(ctx context.Context, userID int64, username, nickname, email, avatarURL, bannerURL, bio string) error {
 	updates := map[string]any{}
 	if username != "" {
 		updates["username"] = username
@@ -400,22 +713,28 @@ func (c *ApxClient) UpdateUserProfile(userID int64, username, nickname, email, a
 	updates["avatar_url"] = avatarURL
 	updates["banner_url"] = bannerURL
 	updates["bio"] = bio
-	return c.patch(fmt.Sprintf("/users/%d", userID), updates)
+	return c.patch(ctx, fmt.Sprintf("/users/%d", userID), updates)
 }
 
-func (c *ApxClient) UpdateProfileSettings(userID int64, timezone string, showLocalTime bool, socialLinks []string) error {
+func (c *ApxClient) UpdateProfileSettings(ctx context.Context, userID int64, timezone string, showLocalTime bool, socialLinks []string) error {
 	if socialLinks == nil {
 		socialLinks = []string{}
 	}
 	linksJSON, _ := json.Marshal(socialLinks)
-	return c.patch(fmt.Sprintf("/users/%d", userID), map[string]any{
+	return c.patch(ctx, fmt.Sprintf("/users/%d", userID), map[string]any{
 		"timezone": timezone, "show_local_time": showLocalTime, "social_links": string(linksJSON),
 	})
 }
 
-func (c *ApxClient) GetAllUsernames() ([]string, error) {
+func (c *ApxClient) UpdateMailSettings(ctx context.Context, userID int64, language string, optOut bool) error {
+	return c.patch(ctx, fmt.Sprintf("/users/%d", userID), map[string]any{
+		"mail_language": language, "mail_opt_out": optOut,
+	})
+}
+
+func (c *ApxClient) GetAllUsernames(ctx context.Context) ([]string, error) {
 	var names []string
-	if err := c.get("/users/all-usernames", &names); err != nil {
+	if err := c.get(ctx, "/users/all-usernames", &names); err != nil {
 		return nil, err
 	}
 	if names == nil {
@@ -424,9 +743,9 @@ func (c *ApxClient) GetAllUsernames() ([]string, error) {
 	return names, nil
 }
 
-func (c *ApxClient) SearchUsers(query string) ([]User, error) {
+func (c *ApxClient) SearchUsers(ctx context.Context, query string) ([]User, error) {
 	var users []User
-	if err := c.get("/users/search?q="+query, &users); err != nil {
+	if err := c.get(ctx, "/users/search?q="+query, &users); err != nil {
 		return nil, err
 	}
 	if users == nil {
@@ -435,30 +754,30 @@ func (c *ApxClient) SearchUsers(query string) ([]User, error) {
 	return users, nil
 }
 
-func (c *ApxClient) CheckUserConflict(username, email string) (bool, error) {
+func (c *ApxClient) CheckUserConflict(ctx context.Context, username, email string) (bool, error) {
 	var result struct {
 		Conflict bool `json:"conflict"`
 	}
-	if err := c.post("/users/check-conflict", map[string]any{"username": username, "email": email}, &result); err != nil {
+	if err := c.post(ctx, "/users/check-conflict", map[string]any{"username": username, "email": email}, &result); err != nil {
 		return false, err
 	}
 	return result.Conflict, nil
 }
 
-func (c *ApxClient) EnsureAdminUser(hashedPw string) error {
-	return c.post("/users/ensure-admin", map[string]any{"hashed_pw": hashedPw}, nil)
+func (c *ApxClient) EnsureAdminUser(ctx context.Context, hashedPw string) error {
+	return c.post(ctx, "/users/ensure-admin", map[string]any{"hashed_pw": hashedPw}, nil)
 }
 
-func (c *ApxClient) DeactivateUser(userID int64) error {
-	return c.patch(fmt.Sprintf("/users/%d/deactivate", userID), map[string]any{})
+func (c *ApxClient) DeactivateUser(ctx context.Context, userID int64) error {
+	return c.patch(ctx, fmt.Sprintf("/users/%d/deactivate", userID), map[string]any{})
 }
 
-func (c *ApxClient) DeactivateUserByUsername(username string) error {
-	return c.patch("/users/deactivate-by-username/"+username, map[string]any{})
+func (c *ApxClient) DeactivateUserByUsername(ctx context.Context, username string) error {
+	return c.patch(ctx, "/users/deactivate-by-username/"+username, map[string]any{})
 }
 
-func (c *ApxClient) DeleteUserByUsername(username string) error {
-	resp, err := c.req(http.MethodDelete, "/users/by-username/"+username, nil)
+func (c *ApxClient) DeleteUserByUsername(ctx context.Context, username string) error {
+	resp, err := c.req(ctx, http.MethodDelete, "/users/by-username/"+username, nil)
 	if err != nil {
 		return err
 	}
@@ -472,63 +791,85 @@ func (c *ApxClient) DeleteUserByUsername(username string) error {
 	return nil
 }
 
-func (c *ApxClient) SetUserEventAccess(userID int64, enabled bool) error {
-	return c.patch(fmt.Sprintf("/users/%d/event-access", userID), map[string]any{"enabled": enabled})
+func (c *ApxClient) SetUserEventAccess(ctx context.Context, userID int64, enabled bool) error {
+	return c.patch(ctx, fmt.Sprintf("/users/%d/event-access", userID), map[string]any{"enabled": enabled})
 }
 
-func (c *ApxClient) SetUserEventAccessByUsername(username string, enabled bool) error {
-	u, err := c.GetUserByUsernameAny(username)
+func (c *ApxClient) SetUserEventAccessByUsername(ctx context.Context, username string, enabled bool) error {
+	u, err := c.GetUserByUsernameAny(ctx, username)
 	if err != nil {
 		return fmt.Errorf("user not found: %w", err)
 	}
-	return c.SetUserEventAccess(u.ID, enabled)
+	return c.SetUserEventAccess(ctx, u.ID, enabled)
 }
 
-func (c *ApxClient) SetUser2FAEnabled(userID int64, enabled bool) error {
-	return c.patch(fmt.Sprintf("/users/%d/2fa", userID), map[string]any{"enabled": enabled})
+func (c *ApxClient) SetUserRole(ctx context.Context, userID int64, role string) error {
+	return c.patch(ctx, fmt.Sprintf("/users/%d/role", userID), map[string]any{"role": role})
 }
 
-func (c *ApxClient) SetUserTrustDevices(userID int64, enabled bool) error {
-	return c.patch(fmt.Sprintf("/users/%d/trust-devices", userID), map[string]any{"enabled": enabled})
+func (c *ApxClient) SetUser2FAEnabled(ctx context.Context, userID int64, enabled bool) error {
+	return c.patch(ctx, fmt.Sprintf("/users/%d/2fa", userID), map[string]any{"enabled": enabled})
 }
 
-func (c *ApxClient) ToggleUser2FA(username string) (bool, error) {
+func (c *ApxClient) SetUserTrustDevices(ctx context.Context, userID int64, enabled bool) error {
+	return c.patch(ctx, fmt.Sprintf("/users/%d/trust-devices", userID), map[string]any{"enabled": enabled})
+}
+
+func (c *ApxClient) ToggleUser2FA(ctx context.Context, username string) (bool, error) {
 	var result struct {
 		TwoFAEnabled bool `json:"two_fa_enabled"`
 	}
-	if err := c.post("/users/toggle-2fa", map[string]any{"username": username}, &result); err != nil {
+	if err := c.post(ctx, "/users/toggle-2fa", map[string]any{"username": username}, &result); err != nil {
 		return false, err
 	}
 	return result.TwoFAEnabled, nil
 }
 
-func (c *ApxClient) GetUserTwoFASettings(userID int64) (twoFAEnabled, trustDevices bool) {
+func (c *ApxClient) GetUserTwoFASettings(ctx context.Context, userID int64) (twoFAEnabled, trustDevices bool) {
 	var result struct {
 		TwoFAEnabled bool `json:"two_fa_enabled"`
 		TrustDevices bool `json:"trust_devices"`
 	}
-	_ = c.get(fmt.Sprintf("/users/%d/2fa-settings", userID), &result)
+	_ = c.get(ctx, fmt.Sprintf("/users/%d/2fa-settings", userID), &result)
 	return result.TwoFAEnabled, result.TrustDevices
 }
 
-func (c *ApxClient) UpdateUserEmail(userID int64, newEmail string) error {
-	return c.patch(fmt.Sprintf("/users/%d/email", userID), map[string]any{"email": newEmail})
+func (c *ApxClient) UpdateUserEmail(ctx context.Context, userID int64, newEmail string) error {
+	return c.patch(ctx, fmt.Sprintf("/users/%d/email", userID), map[string]any{"email": newEmail})
+}
+
+func (c *ApxClient) UpdateUserPassword(ctx context.Context, userID int64, hashedPw string) error {
+	return c.patch(ctx, fmt.Sprintf("/users/%d/password", userID), map[string]any{"password_hash": hashedPw})
 }
 
-func (c *ApxClient) ActivateByUsername(username string) error {
-	return c.patch("/users/activate-by-username/"+username, map[string]any{})
+func (c *ApxClient) ActivateByUsername(ctx context.Context, username string) error {
+	return c.patch(ctx, "/users/activate-by-username/"+username, map[string]any{})
 }
 
-func (c *ApxClient) GetUserAvatarByUsername(username string) string {
-	u, err := c.GetUserByUsername(username)
+// CountUsersCreatedBetween counts the accounts registered in [from, to).
+func (c *ApxClient) CountUsersCreatedBetween(ctx context.Context, from, to time.Time) (int, error) {
+	q := url.Values{}
+	q.Set("from", from.UTC().Format(time.RFC3339))
+	q.Set("to", to.UTC().Format(time.RFC3339))
+	var resp struct {
+		Count int `json:"count"`
+	}
+	if err := c.get(ctx, "/users/count?"+q.Encode(), &resp); err != nil {
+		return 0, err
+	}
+	return resp.Count, nil
+}
+
+func (c *ApxClient) GetUserAvatarByUsername(ctx context.Context, username string) string {
+	u, err := c.GetUserByUsername(ctx, username)
 	if err != nil {
 		return ""
 	}
 	return u.AvatarURL
 }
 
-func (c *ApxClient) GetUserNicknameByUsername(username string) string {
-	u, err := c.GetUserByUsername(username)
+func (c *ApxClient) GetUserNicknameByUsername(ctx context.Context, username string) string {
+	u, err := c.GetUserByUsername(ctx, username)
 	if err != nil {
 		return ""
 	}
@@ -538,25 +879,25 @@ func (c *ApxClient) GetUserNicknameByUsername(username string) string {
 	return u.Username
 }
 
-func (c *ApxClient) GetUserIDByUsername(username string) (int64, error) {
-	u, err := c.GetUserByUsername(username)
+func (c *ApxClient) GetUserIDByUsername(ctx context.Context, username string) (int64, error) {
+	u, err := c.GetUserByUsername(ctx, username)
 	if err != nil {
 		return 0, err
 	}
 	return u.ID, nil
 }
 
-func (c *ApxClient) UpdateBotUserApxID(discordID string, apxID int64) error {
-	return c.patch("/bot/user/"+discordID+"/apx-id", map[string]any{"apx_id": apxID})
+func (c *ApxClient) UpdateBotUserApxID(ctx context.Context, discordID string, apxID int64) error {
+	return c.patch(ctx, "/bot/user/"+discordID+"/apx-id", map[string]any{"apx_id": apxID})
 }
 
 // ── Team ─────────────────────────────────────────────────────────────────────
 
-func (c *ApxClient) GetTeamMembers() ([]TeamMember, error) {
+func (c *ApxClient) GetTeamMembers(ctx context.Context) ([]TeamMember, error) {
 	var result struct {
 		Members []TeamMember `json:"members"`
 	}
-	if err := c.get("/team", &result); err != nil {
+	if err := c.get(ctx, "/team", &result); err != nil {
 		return nil, err
 	}
 	if result.Members == nil {
@@ -565,11 +906,11 @@ func (c *ApxClient) GetTeamMembers() ([]TeamMember, error) {
 	return result.Members, nil
 }
 
-func (c *ApxClient) AddTeamMember(name, username, atkRole, defRole string, isMainRoster bool) (int64, error) {
+func (c *ApxClient) AddTeamMember(ctx context.Context, name, username, atkRole, defRole string, isMainRoster bool) (int64, error) {
 	var result struct {
 		ID int64 `json:"id"`
 	}
-	if err := c.post("/team", map[string]any{
+	if err := c.post(ctx, "/team", map[string]any{
 		"name": name, "username": username, "atk_role": atkRole,
 		"def_role": defRole, "is_main_roster": isMainRoster,
 	}, &result); err != nil {
@@ -578,35 +919,160 @@ func (c *ApxClient) AddTeamMember(name, username, atkRole, defRole string, isMai
 	return result.ID, nil
 }
 
-func (c *ApxClient) UpdateTeamMember(m TeamMember) error {
-	return c.put(fmt.Sprintf("/team/%d", m.ID), m)
+func (c *ApxClient) UpdateTeamMember(ctx context.Context, m TeamMember) error {
+	return c.put(ctx, fmt.Sprintf("/team/%d", m.ID), m)
 }
 
-func (c *ApxClient) DeleteTeamMember(id int64) error {
-	return c.del(fmt.Sprintf("/team/%d", id))
+// SetTeamStats replaces the stored counters of several members with one
+// PUT /team/stats. Older apxapi releases lack that endpoint; they answer 404
+// or 405, which is reported as errUnsupported rather than falling back to
+// per-member updates that could leave an import half applied.
+func (c *ApxClient) SetTeamStats(ctx context.Context, stats map[int64]PlayerStats) error {
+	type entry struct {
+		ID int64 `json:"id"`
+		PlayerStats
+	}
+	body := make([]entry, 0, len(stats))
+	for id, s := range stats {
+		body = append(body, entry{ID: id, PlayerStats: s})
+	}
+	resp, err := c.req(ctx, http.MethodPut, "/team/stats", body)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed:
+		return fmt.Errorf("PUT /team/stats: %w", errUnsupported)
+	case resp.StatusCode >= 400:
+		return fmt.Errorf("http %d: PUT /team/stats", resp.StatusCode)
+	}
+	return nil
 }
 
-func (c *ApxClient) CountMainRoster(excludeID int64) (int, error) {
+func (c *ApxClient) DeleteTeamMember(ctx context.Context, id int64) error {
+	return c.del(ctx, fmt.Sprintf("/team/%d", id))
+}
+
+func (c *ApxClient) CountMainRoster(ctx context.Context, excludeID int64) (int, error) {
 	var result struct {
 		Count int `json:"count"`
 	}
-	if err := c.get(fmt.Sprintf("/team/main-roster-count?exclude_id=%d", excludeID), &result); err != nil {
+	if err := c.get(ctx, fmt.Sprintf("/team/main-roster-count?exclude_id=%d", excludeID), &result); err != nil {
 		return 0, err
 	}
 	return result.Count, nil
 }
 
-func (c *ApxClient) EnsureTeamPlayers() error {
-	return c.post("/team/ensure-players", map[string]any{}, nil)
+func (c *ApxClient) EnsureTeamPlayers(ctx context.Context) error {
+	return c.post(ctx, "/team/ensure-players", map[string]any{}, nil)
+}
+
+func (c *ApxClient) AddRosterEvents(ctx context.Context, events []RosterEvent) error {
+	return c.post(ctx, "/team/history", events, nil)
+}
+
+// GetRosterEvents returns the whole roster history, oldest first.
+func (c *ApxClient) GetRosterEvents(ctx context.Context) ([]RosterEvent, error) {
+	var events []RosterEvent
+	if err := c.get(ctx, "/team/history", &events); err != nil {
+		return nil, err
+	}
+	if events == nil {
+		events = []RosterEvent{}
+	}
+	return events, nil
+}
+
+// GetUserRosterEvents asks for the history of username's team entries only.
+// ApxApi releases that ignore the filter return the whole history, which
+// playerTenure filters again.
+func (c *ApxClient) GetUserRosterEvents(ctx context.Context, username string) ([]RosterEvent, error) {
+	var events []RosterEvent
+	if err := c.get(ctx, "/team/history?username="+url.QueryEscape(username), &events); err != nil {
+		return nil, err
+	}
+	if events == nil {
+		events = []RosterEvent{}
+	}
+	return events, nil
+}
+
+// ── Matches ──────────────────────────────────────────────────────────────────
+
+func (c *ApxClient) GetMatches(ctx context.Context) ([]Match, error) {
+	var matches []Match
+	if err := c.get(ctx, "/matches", &matches); err != nil {
+		return nil, err
+	}
+	if matches == nil {
+		matches = []Match{}
+	}
+	return matches, nil
+}
+
+func (c *ApxClient) GetMatch(ctx context.Context, id int64) (*Match, error) {
+	var m Match
+	if err := c.get(ctx, fmt.Sprintf("/matches/%d", id), &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// CreateMatch stores the match with its stat lines in one request.
+func (c *ApxClient) CreateMatch(ctx context.Context, m Match) (int64, error) {
+	var result struct {
+		ID int64 `json:"id"`
+	}
+	if err := c.post(ctx, "/matches", m, &result); err != nil {
+		return 0, err
+	}
+	return result.ID, nil
+}
+
+// UpdateMatch replaces the match and all of its stat lines.
+func (c *ApxClient) UpdateMatch(ctx context.Context, m Match) error {
+	return c.put(ctx, fmt.Sprintf("/matches/%d", m.ID), m)
+}
+
+func (c *ApxClient) DeleteMatch(ctx context.Context, id int64) error {
+	return c.del(ctx, fmt.Sprintf("/matches/%d", id))
+}
+
+// GetMatchStatTotals returns the summed stat lines per team member id.
+func (c *ApxClient) GetMatchStatTotals(ctx context.Context) (map[int64]PlayerStats, error) {
+	var rows []struct {
+		TeamMemberID int64 `json:"team_member_id"`
+		PlayerStats
+	}
+	if err := c.get(ctx, "/matches/totals", &rows); err != nil {
+		return nil, err
+	}
+	totals := make(map[int64]PlayerStats, len(rows))
+	for _, r := range rows {
+		totals[r.TeamMemberID] = r.PlayerStats
+	}
+	return totals, nil
+}
+
+func (c *ApxClient) GetPlayerMatchLines(ctx context.Context, teamMemberID int64) ([]PlayerMatchLine, error) {
+	var lines []PlayerMatchLine
+	if err := c.get(ctx, fmt.Sprintf("/team/%d/matches", teamMemberID), &lines); err != nil {
+		return nil, err
+	}
+	if lines == nil {
+		lines = []PlayerMatchLine{}
+	}
+	return lines, nil
 }
 
 // ── Staff ─────────────────────────────────────────────────────────────────────
 
-func (c *ApxClient) GetStaffMembers() ([]StaffMember, error) {
+func (c *ApxClient) GetStaffMembers(ctx context.Context) ([]StaffMember, error) {
 	var result struct {
 		Staff []StaffMember `json:"staff"`
 	}
-	if err := c.get("/staff", &result); err != nil {
+	if err := c.get(ctx, "/staff", &result); err != nil {
 		return nil, err
 	}
 	if result.Staff == nil {
@@ -615,29 +1081,93 @@ func (c *ApxClient) GetStaffMembers() ([]StaffMember, error) {
 	return result.Staff, nil
 }
 
-func (c *ApxClient) AddStaffMember(name, role, username string) (int64, error) {
+func (c *ApxClient) AddStaffMember(ctx context.Context, name, role, username string) (int64, error) {
 	var result struct {
 		ID int64 `json:"id"`
 	}
-	if err := c.post("/staff", map[string]any{"name": name, "role": role, "username": username}, &result); err != nil {
+	if err := c.post(ctx, "/staff", map[string]any{"name": name, "role": role, "username": username}, &result); err != nil {
 		return 0, err
 	}
 	return result.ID, nil
 }
 
-func (c *ApxClient) UpdateStaffMember(id int64, name, role, username string) error {
-	return c.put(fmt.Sprintf("/staff/%d", id), map[string]any{"name": name, "role": role, "username": username})
+func (c *ApxClient) UpdateStaffMember(ctx context.Context, id int64, name, role, username string) error {
+	return c.put(ctx, fmt.Sprintf("/staff/%d", id), map[string]any{"name": name, "role": role, "username": username})
+}
+
+func (c *ApxClient) DeleteStaffMember(ctx context.Context, id int64) error {
+	return c.del(ctx, fmt.Sprintf("/staff/%d", id))
 }
 
-func (c *ApxClient) DeleteStaffMember(id int64) error {
-	return c.del(fmt.Sprintf("/staff/%d", id))
+func (c *ApxClient) SetStaffReporting(ctx context.Context, id int64, departmentID, reportsTo *int64) error {
+	return c.put(ctx, fmt.Sprintf("/org/staff/%d", id), map[string]any{"department_id": departmentID, "reports_to": reportsTo})
+}
+
+// ── Organization chart ───────────────────────────────────────────────────────
+
+func (c *ApxClient) GetOrgDepartments(ctx context.Context) ([]OrgDepartment, error) {
+	var departments []OrgDepartment
+	if err := c.get(ctx, "/org/departments", &departments); err != nil {
+		return nil, err
+	}
+	if departments == nil {
+		departments = []OrgDepartment{}
+	}
+	return departments, nil
+}
+
+func (c *ApxClient) CreateOrgDepartment(ctx context.Context, d OrgDepartment) (int64, error) {
+	var result struct {
+		ID int64 `json:"id"`
+	}
+	if err := c.post(ctx, "/org/departments", d, &result); err != nil {
+		return 0, err
+	}
+	return result.ID, nil
+}
+
+func (c *ApxClient) UpdateOrgDepartment(ctx context.Context, d OrgDepartment) error {
+	return c.put(ctx, fmt.Sprintf("/org/departments/%d", d.ID), d)
+}
+
+func (c *ApxClient) DeleteOrgDepartment(ctx context.Context, id int64) error {
+	return c.del(ctx, fmt.Sprintf("/org/departments/%d", id))
+}
+
+func (c *ApxClient) GetStaffRoles(ctx context.Context) ([]StaffRole, error) {
+	var roles []StaffRole
+	if err := c.get(ctx, "/org/roles", &roles); err != nil {
+		return nil, err
+	}
+	if roles == nil {
+		roles = []StaffRole{}
+	}
+	return roles, nil
+}
+
+func (c *ApxClient) CreateStaffRole(ctx context.Context, role StaffRole) (int64, error) {
+	var result struct {
+		ID int64 `json:"id"`
+	}
+	if err := c.post(ctx, "/org/roles", role, &result); err != nil {
+		return 0, err
+	}
+	return result.ID, nil
+}
+
+func (c *ApxClient) UpdateStaffRole(ctx context.Context, role StaffRole) error {
+	return c.put(ctx, fmt.Sprintf("/org/roles/%d", role.ID), role)
+}
+
+func (c *ApxClient) DeleteStaffRole(ctx context.Context, id int64) error {
+	return c.del(ctx, fmt.Sprintf("/org/roles/%d", id))
 }
 
 // ── Badges ───────────────────────────────────────────────────────────────────
 
-func (c *ApxClient) GetAllBadges() ([]Badge, error) {
+func (c *ApxClient) GetAllBadges(ctx context.Context) ([]Badge, error) {
 	var badges []Badge
-	if err := c.get("/badges/all", &badges); err != nil {
+	if err := c.get(ctx, "/badges/all", &badges); err != nil {
 		return nil, err
 	}
 	if badges == nil {
@@ -646,11 +1176,11 @@ func (c *ApxClient) GetAllBadges() ([]Badge, error) {
 	return badges, nil
 }
 
-func (c *ApxClient) CreateBadge(name, description, info, imageURL, category string, maxLevel int) (int64, error) {
+func (c *ApxClient) CreateBadge(ctx context.Context, name, description, info, imageURL, category string, maxLevel int) (int64, error) {
 	var result struct {
 		ID int64 `json:"id"`
 	}
-	if err := c.post("/badges", map[string]any{
+	if err := c.post(ctx, "/badges", map[string]any{
 		"name": name, "description": description, "info": info,
 		"image_url": imageURL, "category": category, "max_level": maxLevel,
 	}, &result); err != nil {
@@ -659,20 +1189,20 @@ func (c *ApxClient) CreateBadge(name, description, info, imageURL, category stri
 	return result.ID, nil
 }
 
-func (c *ApxClient) UpdateBadge(id int64, name, description, info, imageURL, category string, maxLevel int, available bool) error {
-	return c.put(fmt.Sprintf("/badges/%d", id), map[string]any{
+func (c *ApxClient) UpdateBadge(ctx context.Context, id int64, name, description, info, imageURL, category string, maxLevel int, available bool) error {
+	return c.put(ctx, fmt.Sprintf("/badges/%d", id), map[string]any{
 		"name": name, "description": description, "info": info,
 		"image_url": imageURL, "category": category, "max_level": maxLevel, "available": available,
 	})
 }
 
-func (c *ApxClient) DeleteBadge(id int64) error {
-	return c.del(fmt.Sprintf("/badges/%d", id))
+func (c *ApxClient) DeleteBadge(ctx context.Context, id int64) error {
+	return c.del(ctx, fmt.Sprintf("/badges/%d", id))
 }
 
-func (c *ApxClient) GetUserBadges(userID int64) ([]UserBadge, error) {
+func (c *ApxClient) GetUserBadges(ctx context.Context, userID int64) ([]UserBadge, error) {
 	var badges []UserBadge
-	if err := c.get(fmt.Sprintf("/badges/users/%d", userID), &badges); err != nil {
+	if err := c.get(ctx, fmt.Sprintf("/badges/users/%d", userID), &badges); err != nil {
 		return nil, err
 	}
 	if badges == nil {
@@ -681,35 +1211,35 @@ func (c *ApxClient) GetUserBadges(userID int64) ([]UserBadge, error) {
 	return badges, nil
 }
 
-func (c *ApxClient) EnsureApxMemberBadge() error {
-	return c.post("/badges/ensure-member", map[string]any{}, nil)
+func (c *ApxClient) EnsureApxMemberBadge(ctx context.Context) error {
+	return c.post(ctx, "/badges/ensure-member", map[string]any{}, nil)
 }
 
-func (c *ApxClient) GetBadgeIDByName(name string) (int64, error) {
+func (c *ApxClient) GetBadgeIDByName(ctx context.Context, name string) (int64, error) {
 	var result struct {
 		ID int64 `json:"id"`
 	}
-	if err := c.get("/badges/by-name/"+name, &result); err != nil {
+	if err := c.get(ctx, "/badges/by-name/"+name, &result); err != nil {
 		return 0, err
 	}
 	return result.ID, nil
 }
 
-func (c *ApxClient) UpsertUserBadge(userID, badgeID int64, level int) error {
-	return c.post(fmt.Sprintf("/badges/users/%d", userID), map[string]any{
+func (c *ApxClient) UpsertUserBadge(ctx context.Context, userID, badgeID int64, level int) error {
+	return c.post(ctx, fmt.Sprintf("/badges/users/%d", userID), map[string]any{
 		"badge_id": badgeID, "level": level,
 	}, nil)
 }
 
-func (c *ApxClient) RemoveUserBadge(userID, badgeID int64) error {
-	return c.del(fmt.Sprintf("/badges/users/%d/%d", userID, badgeID))
+func (c *ApxClient) RemoveUserBadge(ctx context.Context, userID, badgeID int64) error {
+	return c.del(ctx, fmt.Sprintf("/badges/users/%d/%d", userID, badgeID))
 }
 
 // ── Items ─────────────────────────────────────────────────────────────────────
 
-func (c *ApxClient) GetAllItems() ([]Item, error) {
+func (c *ApxClient) GetAllItems(ctx context.Context) ([]Item, error) {
 	var items []Item
-	if err := c.get("/items", &items); err != nil {
+	if err := c.get(ctx, "/items", &items); err != nil {
 		return nil, err
 	}
 	if items == nil {
@@ -718,17 +1248,17 @@ func (c *ApxClient) GetAllItems() ([]Item, error) {
 	return items, nil
 }
 
-func (c *ApxClient) CreateItem(item *Item) error {
-	return c.post("/items", item, nil)
+func (c *ApxClient) CreateItem(ctx context.Context, item *Item) error {
+	return c.post(ctx, "/items", item, nil)
 }
 
-func (c *ApxClient) DeleteItem(itemID string) error {
-	return c.del("/items/" + itemID)
+func (c *ApxClient) DeleteItem(ctx context.Context, itemID string) error {
+	return c.del(ctx, "/items/"+itemID)
 }
 
-func (c *ApxClient) GetUserItems(userID int64) ([]ProgressionInventoryItem, error) {
+func (c *ApxClient) GetUserItems(ctx context.Context, userID int64) ([]ProgressionInventoryItem, error) {
 	var items []ProgressionInventoryItem
-	if err := c.get(fmt.Sprintf("/progression/inventory/%d", userID), &items); err != nil {
+	if err := c.get(ctx, fmt.Sprintf("/progression/inventory/%d", userID), &items); err != nil {
 		return nil, err
 	}
 	if items == nil {
@@ -739,9 +1269,9 @@ func (c *ApxClient) GetUserItems(userID int64) ([]ProgressionInventoryItem, erro
 
 // ── Events ───────────────────────────────────────────────────────────────────
 
-func (c *ApxClient) GetAllEvents() ([]Event, error) {
+func (c *ApxClient) GetAllEvents(ctx context.Context) ([]Event, error) {
 	var events []Event
-	if err := c.get("/events", &events); err != nil {
+	if err := c.get(ctx, "/events", &events); err != nil {
 		return nil, err
 	}
 	if events == nil {
@@ -750,51 +1280,72 @@ func (c *ApxClient) GetAllEvents() ([]Event, error) {
 	return events, nil
 }
 
-func (c *ApxClient) GetEventByID(id string) (*Event, error) {
+func (c *ApxClient) GetEventByID(ctx context.Context, id string) (*Event, error) {
 	var e Event
-	if err := c.get("/events/"+id, &e); err != nil {
+	if err := c.get(ctx, "/events/"+id, &e); err != nil {
 		return nil, err
 	}
 	return &e, nil
 }
 
-func (c *ApxClient) IsEventParticipant(userID int64, eventID string) (bool, error) {
+func (c *ApxClient) IsEventParticipant(ctx context.Context, userID int64, eventID string) (bool, error) {
 	var result struct {
 		IsParticipant bool `json:"is_participant"`
 	}
-	if err := c.get(fmt.Sprintf("/events/%s/is-participant?user_id=%d", eventID, userID), &result); err != nil {
+	if err := c.get(ctx, fmt.Sprintf("/events/%s/is-participant?user_id=%d", eventID, userID), &result); err != nil {
 		return false, err
 	}
 	return result.IsParticipant, nil
 }
 
-func (c *ApxClient) JoinEvent(userID int64, eventID string) error {
-	return c.post(fmt.Sprintf("/events/%s/service-join", eventID), map[string]any{"user_id": userID}, nil)
+// JoinedEventIDs reports which of eventIDs the user has joined, in one call.
+func (c *ApxClient) JoinedEventIDs(ctx context.Context, userID int64, eventIDs []string) (map[string]bool, error) {
+	joined := map[string]bool{}
+	if len(eventIDs) == 0 {
+		return joined, nil
+	}
+	q := url.Values{}
+	q.Set("user_id", strconv.FormatInt(userID, 10))
+	q.Set("event_ids", strings.Join(eventIDs, ","))
+	var result struct {
+		EventIDs []string `json:"event_ids"`
+	}
+	if err := c.get(ctx, "/events/joined?"+q.Encode(), &result); err != nil {
+		return nil, err
+	}
+	for _, id := range result.EventIDs {
+		joined[id] = true
+	}
+	return joined, nil
 }
 
-func (c *ApxClient) LeaveEvent(userID int64, eventID string) error {
-	return c.delBody(fmt.Sprintf("/events/%s/service-leave", eventID), map[string]any{"user_id": userID})
+func (c *ApxClient) JoinEvent(ctx context.Context, userID int64, eventID string) error {
+	return c.post(ctx, fmt.Sprintf("/events/%s/service-join", eventID), map[string]any{"user_id": userID}, nil)
 }
 
-func (c *ApxClient) CreateEvent(in CreateEventInput) (*Event, error) {
+func (c *ApxClient) LeaveEvent(ctx context.Context, userID int64, eventID string) error {
+	return c.delBody(ctx, fmt.Sprintf("/events/%s/service-leave", eventID), map[string]any{"user_id": userID})
+}
+
+func (c *ApxClient) CreateEvent(ctx context.Context, in CreateEventInput) (*Event, error) {
 	var e Event
-	if err := c.post("/events/service-create", in, &e); err != nil {
+	if err := c.post(ctx, "/events/service-create", in, &e); err != nil {
 		return nil, err
 	}
 	return &e, nil
 }
 
-func (c *ApxClient) UpdateEvent(e *Event) error {
-	return c.put("/events/"+e.ID, e)
+func (c *ApxClient) UpdateEvent(ctx context.Context, e *Event) error {
+	return c.put(ctx, "/events/"+e.ID, e)
 }
 
-func (c *ApxClient) DeleteEvent(id string) error {
-	return c.del("/events/" + id)
+func (c *ApxClient) DeleteEvent(ctx context.Context, id string) error {
+	return c.del(ctx, "/events/"+id)
 }
 
-func (c *ApxClient) GetEventParticipants(eventID string) ([]EventParticipant, error) {
+func (c *ApxClient) GetEventParticipants(ctx context.Context, eventID string) ([]EventParticipant, error) {
 	var participants []EventParticipant
-	if err := c.get("/events/"+eventID+"/participants", &participants); err != nil {
+	if err := c.get(ctx, "/events/"+eventID+"/participants", &participants); err != nil {
 		return nil, err
 	}
 	if participants == nil {
@@ -805,9 +1356,9 @@ func (c *ApxClient) GetEventParticipants(eventID string) ([]EventParticipant, er
 
 // ── Log (Team News) ───────────────────────────────────────────────────────────
 
-func (c *ApxClient) GetAllLogEntries() ([]LogEntry, error) {
+func (c *ApxClient) GetAllLogEntries(ctx context.Context) ([]LogEntry, error) {
 	var entries []LogEntry
-	if err := c.get("/log", &entries); err != nil {
+	if err := c.get(ctx, "/log", &entries); err != nil {
 		return nil, err
 	}
 	if entries == nil {
@@ -816,33 +1367,47 @@ func (c *ApxClient) GetAllLogEntries() ([]LogEntry, error) {
 	return entries, nil
 }
 
-func (c *ApxClient) CreateLogEntry(entry *LogEntry) error {
-	return c.post("/log", entry, nil)
+func (c *ApxClient) CreateLogEntry(ctx context.Context, entry *LogEntry) error {
+	return c.post(ctx, "/log", entry, nil)
 }
 
-func (c *ApxClient) UpdateLogEntry(entry *LogEntry) error {
-	return c.put(fmt.Sprintf("/log/%d", entry.ID), entry)
+func (c *ApxClient) UpdateLogEntry(ctx context.Context, entry *LogEntry) error {
+	return c.put(ctx, fmt.Sprintf("/log/%d", entry.ID), entry)
 }
 
-func (c *ApxClient) DeleteLogEntry(id int64) error {
-	return c.del(fmt.Sprintf("/log/%d", id))
+func (c *ApxClient) DeleteLogEntry(ctx context.Context, id int64) error {
+	return c.del(ctx, fmt.Sprintf("/log/%d", id))
 }
 
 // ── Applications ─────────────────────────────────────────────────────────────
 
-func (c *ApxClient) CreateApplication(app ApplicationRecord) (int64, error) {
+// CreateApplication stores a new application. ApxApi answers 409 when the
+// user already has an open one.
+func (c *ApxClient) CreateApplication(ctx context.Context, app ApplicationRecord) (int64, error) {
+	resp, err := c.req(ctx, http.MethodPost, "/applications", app)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusConflict:
+		resp.Body.Close()
+		return 0, errApplicationOpen
+	case resp.StatusCode >= 400:
+		resp.Body.Close()
+		return 0, fmt.Errorf("http %d: POST /applications", resp.StatusCode)
+	}
 	var result struct {
 		ID int64 `json:"id"`
 	}
-	if err := c.post("/applications", app, &result); err != nil {
+	if err := decodeData(resp, &result); err != nil {
 		return 0, err
 	}
 	return result.ID, nil
 }
 
-func (c *ApxClient) GetApplications() ([]ApplicationRecord, error) {
+func (c *ApxClient) GetApplications(ctx context.Context) ([]ApplicationRecord, error) {
 	var apps []ApplicationRecord
-	if err := c.get("/applications", &apps); err != nil {
+	if err := c.get(ctx, "/applications", &apps); err != nil {
 		return nil, err
 	}
 	if apps == nil {
@@ -851,70 +1416,390 @@ func (c *ApxClient) GetApplications() ([]ApplicationRecord, error) {
 	return apps, nil
 }
 
-func (c *ApxClient) GetApplicationByUserID(userID int64) (*ApplicationRecord, error) {
+func (c *ApxClient) GetApplicationByUserID(ctx context.Context, userID int64) (*ApplicationRecord, error) {
+	var app ApplicationRecord
+	if err := c.get(ctx, fmt.Sprintf("/applications/user/%d", userID), &app); err != nil {
+		return nil, err
+	}
+	return &app, nil
+}
+
+// UpdateApplicationByUserID overwrites the user's latest application. ApxApi
+// answers 409 when it is no longer pending.
+func (c *ApxClient) UpdateApplicationByUserID(ctx context.Context, userID int64, app ApplicationRecord) error {
+	path := fmt.Sprintf("/applications/user/%d", userID)
+	resp, err := c.req(ctx, http.MethodPut, path, app)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return errNotFound
+	case resp.StatusCode == http.StatusConflict:
+		return errApplicationStatusChanged
+	case resp.StatusCode >= 400:
+		return fmt.Errorf("http %d: PUT %s", resp.StatusCode, path)
+	}
+	return nil
+}
+
+func (c *ApxClient) GetApplicationByID(ctx context.Context, id int64) (*ApplicationRecord, error) {
 	var app ApplicationRecord
-	if err := c.get(fmt.Sprintf("/applications/user/%d", userID), &app); err != nil {
+	if err := c.get(ctx, fmt.Sprintf("/applications/%d", id), &app); err != nil {
 		return nil, err
 	}
 	return &app, nil
 }
 
-func (c *ApxClient) UpdateApplicationByUserID(userID int64, app ApplicationRecord) error {
-	return c.put(fmt.Sprintf("/applications/user/%d", userID), app)
+// ChangeApplicationStatus moves the application from change.FromStatus to
+// change.ToStatus and records the transition. ApxApi answers 409 when the
+// application is no longer in FromStatus.
+func (c *ApxClient) ChangeApplicationStatus(ctx context.Context, change ApplicationStatusChange) error {
+	path := fmt.Sprintf("/applications/%d/status", change.ApplicationID)
+	resp, err := c.req(ctx, http.MethodPost, path, change)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return errNotFound
+	case resp.StatusCode == http.StatusConflict:
+		return errApplicationStatusChanged
+	case resp.StatusCode >= 400:
+		return fmt.Errorf("http %d: POST %s", resp.StatusCode, path)
+	}
+	return nil
+}
+
+func (c *ApxClient) GetApplicationHistory(ctx context.Context, applicationID int64) ([]ApplicationStatusChange, error) {
+	var history []ApplicationStatusChange
+	if err := c.get(ctx, fmt.Sprintf("/applications/%d/history", applicationID), &history); err != nil {
+		return nil, err
+	}
+	if history == nil {
+		history = []ApplicationStatusChange{}
+	}
+	return history, nil
+}
+
+func (c *ApxClient) AddApplicationNote(ctx context.Context, note ApplicationNote) (int64, error) {
+	var result struct {
+		ID int64 `json:"id"`
+	}
+	if err := c.post(ctx, fmt.Sprintf("/applications/%d/notes", note.ApplicationID), note, &result); err != nil {
+		return 0, err
+	}
+	return result.ID, nil
+}
+
+func (c *ApxClient) GetApplicationNotes(ctx context.Context, applicationID int64) ([]ApplicationNote, error) {
+	var notes []ApplicationNote
+	if err := c.get(ctx, fmt.Sprintf("/applications/%d/notes", applicationID), &notes); err != nil {
+		return nil, err
+	}
+	if notes == nil {
+		notes = []ApplicationNote{}
+	}
+	return notes, nil
+}
+
+// UpsertApplicationRating stores the reviewer's rating, replacing an earlier
+// one by the same reviewer.
+func (c *ApxClient) UpsertApplicationRating(ctx context.Context, rating ApplicationRating) error {
+	return c.put(ctx, fmt.Sprintf("/applications/%d/ratings/%d", rating.ApplicationID, rating.ReviewerID), rating)
+}
+
+func (c *ApxClient) GetApplicationRatings(ctx context.Context, applicationID int64) ([]ApplicationRating, error) {
+	var ratings []ApplicationRating
+	if err := c.get(ctx, fmt.Sprintf("/applications/%d/ratings", applicationID), &ratings); err != nil {
+		return nil, err
+	}
+	if ratings == nil {
+		ratings = []ApplicationRating{}
+	}
+	return ratings, nil
+}
+
+func (c *ApxClient) GetApplicationScores(ctx context.Context) ([]ApplicationScore, error) {
+	var scores []ApplicationScore
+	if err := c.get(ctx, "/applications/scores", &scores); err != nil {
+		return nil, err
+	}
+	if scores == nil {
+		scores = []ApplicationScore{}
+	}
+	return scores, nil
+}
+
+// ── Application forms ────────────────────────────────────────────────────────
+
+func (c *ApxClient) GetApplicationForms(ctx context.Context) ([]ApplicationForm, error) {
+	var forms []ApplicationForm
+	if err := c.get(ctx, "/application-forms", &forms); err != nil {
+		return nil, err
+	}
+	if forms == nil {
+		forms = []ApplicationForm{}
+	}
+	return forms, nil
+}
+
+func (c *ApxClient) GetApplicationForm(ctx context.Context, game string) (*ApplicationForm, error) {
+	var form ApplicationForm
+	if err := c.get(ctx, "/application-forms/"+url.PathEscape(game), &form); err != nil {
+		return nil, err
+	}
+	return &form, nil
+}
+
+func (c *ApxClient) SaveApplicationForm(ctx context.Context, form ApplicationForm) error {
+	return c.put(ctx, "/application-forms/"+url.PathEscape(form.Game), form)
+}
+
+func (c *ApxClient) DeleteApplicationForm(ctx context.Context, game string) error {
+	return c.del(ctx, "/application-forms/"+url.PathEscape(game))
+}
+
+// ── Schedule ─────────────────────────────────────────────────────────────────
+
+func (c *ApxClient) GetAvailability(ctx context.Context, userID int64) ([]AvailabilitySlot, error) {
+	var slots []AvailabilitySlot
+	if err := c.get(ctx, fmt.Sprintf("/schedule/availability/%d", userID), &slots); err != nil {
+		return nil, err
+	}
+	if slots == nil {
+		slots = []AvailabilitySlot{}
+	}
+	return slots, nil
+}
+
+func (c *ApxClient) GetAllAvailability(ctx context.Context) (map[int64][]AvailabilitySlot, error) {
+	var rows []struct {
+		UserID int64              `json:"user_id"`
+		Slots  []AvailabilitySlot `json:"slots"`
+	}
+	if err := c.get(ctx, "/schedule/availability", &rows); err != nil {
+		return nil, err
+	}
+	all := make(map[int64][]AvailabilitySlot, len(rows))
+	for _, r := range rows {
+		all[r.UserID] = r.Slots
+	}
+	return all, nil
+}
+
+func (c *ApxClient) SetAvailability(ctx context.Context, userID int64, slots []AvailabilitySlot) error {
+	return c.put(ctx, fmt.Sprintf("/schedule/availability/%d", userID), slots)
+}
+
+// GetScheduleSessions returns the sessions ending after from, soonest first.
+func (c *ApxClient) GetScheduleSessions(ctx context.Context, from time.Time) ([]ScheduleSession, error) {
+	var sessions []ScheduleSession
+	if err := c.get(ctx, "/schedule/sessions?from="+url.QueryEscape(from.UTC().Format(time.RFC3339)), &sessions); err != nil {
+		return nil, err
+	}
+	if sessions == nil {
+		sessions = []ScheduleSession{}
+	}
+	return sessions, nil
+}
+
+func (c *ApxClient) GetScheduleSession(ctx context.Context, id int64) (*ScheduleSession, error) {
+	var s ScheduleSession
+	if err := c.get(ctx, fmt.Sprintf("/schedule/sessions/%d", id), &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (c *ApxClient) CreateScheduleSession(ctx context.Context, s ScheduleSession) (int64, error) {
+	var result struct {
+		ID int64 `json:"id"`
+	}
+	if err := c.post(ctx, "/schedule/sessions", s, &result); err != nil {
+		return 0, err
+	}
+	return result.ID, nil
+}
+
+func (c *ApxClient) UpdateScheduleSession(ctx context.Context, s ScheduleSession) error {
+	return c.put(ctx, fmt.Sprintf("/schedule/sessions/%d", s.ID), s)
+}
+
+func (c *ApxClient) DeleteScheduleSession(ctx context.Context, id int64) error {
+	return c.del(ctx, fmt.Sprintf("/schedule/sessions/%d", id))
+}
+
+func (c *ApxClient) SetSessionRSVP(ctx context.Context, rsvp SessionRSVP) error {
+	return c.put(ctx, fmt.Sprintf("/schedule/sessions/%d/rsvps/%d", rsvp.SessionID, rsvp.UserID), rsvp)
+}
+
+// ── Lineups ──────────────────────────────────────────────────────────────────
+
+// GetMapPool returns the map pool in display order.
+func (c *ApxClient) GetMapPool(ctx context.Context) ([]string, error) {
+	var maps []string
+	if err := c.get(ctx, "/map-pool", &maps); err != nil {
+		return nil, err
+	}
+	if maps == nil {
+		maps = []string{}
+	}
+	return maps, nil
+}
+
+func (c *ApxClient) SetMapPool(ctx context.Context, maps []string) error {
+	return c.put(ctx, "/map-pool", maps)
+}
+
+func (c *ApxClient) GetMapLineups(ctx context.Context) ([]MapLineup, error) {
+	var lineups []MapLineup
+	if err := c.get(ctx, "/lineups", &lineups); err != nil {
+		return nil, err
+	}
+	if lineups == nil {
+		lineups = []MapLineup{}
+	}
+	return lineups, nil
+}
+
+func (c *ApxClient) GetMapLineup(ctx context.Context, mapName string) (*MapLineup, error) {
+	var l MapLineup
+	if err := c.get(ctx, "/lineups/"+url.PathEscape(mapName), &l); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+func (c *ApxClient) SaveMapLineup(ctx context.Context, l MapLineup) error {
+	return c.put(ctx, "/lineups/"+url.PathEscape(l.Map), l)
+}
+
+func (c *ApxClient) DeleteMapLineup(ctx context.Context, mapName string) error {
+	return c.del(ctx, "/lineups/"+url.PathEscape(mapName))
+}
+
+// ── Weekly statistics ────────────────────────────────────────────────────────
+
+func (c *ApxClient) SaveWeeklySnapshot(ctx context.Context, s WeeklySnapshot) error {
+	return c.put(ctx, "/stats/weekly/"+url.PathEscape(s.Week), s)
+}
+
+func (c *ApxClient) GetWeeklySnapshot(ctx context.Context, week string) (*WeeklySnapshot, error) {
+	var s WeeklySnapshot
+	if err := c.get(ctx, "/stats/weekly/"+url.PathEscape(week), &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// GetWeeklySnapshotWeeks returns the weeks with a snapshot, newest first.
+func (c *ApxClient) GetWeeklySnapshotWeeks(ctx context.Context) ([]string, error) {
+	var weeks []string
+	if err := c.get(ctx, "/stats/weekly", &weeks); err != nil {
+		return nil, err
+	}
+	return weeks, nil
+}
+
+// ── Audit log ────────────────────────────────────────────────────────────────
+
+func (c *ApxClient) CreateAuditEntry(ctx context.Context, e AuditEntry) error {
+	return c.post(ctx, "/audit", e, nil)
+}
+
+// GetAuditEntries returns one page of entries (newest first) and the total
+// number of entries matching f.
+func (c *ApxClient) GetAuditEntries(ctx context.Context, f AuditFilter) ([]AuditEntry, int, error) {
+	q := url.Values{}
+	if f.ActorName != "" {
+		q.Set("actor", f.ActorName)
+	}
+	if f.Action != "" {
+		q.Set("action", f.Action)
+	}
+	if f.TargetType != "" {
+		q.Set("target_type", f.TargetType)
+	}
+	if f.TargetID != "" {
+		q.Set("target_id", f.TargetID)
+	}
+	if !f.From.IsZero() {
+		q.Set("from", f.From.UTC().Format(time.RFC3339))
+	}
+	if !f.To.IsZero() {
+		q.Set("to", f.To.UTC().Format(time.RFC3339))
+	}
+	q.Set("limit", strconv.Itoa(f.Limit))
+	q.Set("offset", strconv.Itoa(f.Offset))
+
+	var result struct {
+		Entries []AuditEntry `json:"entries"`
+		Total   int          `json:"total"`
+	}
+	if err := c.get(ctx, "/audit?"+q.Encode(), &result); err != nil {
+		return nil, 0, err
+	}
+	if result.Entries == nil {
+		result.Entries = []AuditEntry{}
+	}
+	return result.Entries, result.Total, nil
 }
 
 // ── Progression ───────────────────────────────────────────────────────────────
 
-func (c *ApxClient) ResolveUserIDByDiscord(discordID string) (int64, error) {
+func (c *ApxClient) ResolveUserIDByDiscord(ctx context.Context, discordID string) (int64, error) {
 	var result struct {
 		UserID int64 `json:"user_id"`
 	}
-	if err := c.post("/progression/resolve-discord", map[string]any{"discord_id": discordID}, &result); err != nil {
+	if err := c.post(ctx, "/progression/resolve-discord", map[string]any{"discord_id": discordID}, &result); err != nil {
 		return 0, err
 	}
 	return result.UserID, nil
 }
 
-func (c *ApxClient) UpsertProgressionUser(userID int64, discordID string, level, xp, balance int) error {
-	return c.post("/progression/users", map[string]any{
+func (c *ApxClient) UpsertProgressionUser(ctx context.Context, userID int64, discordID string, level, xp, balance int) error {
+	return c.post(ctx, "/progression/users", map[string]any{
 		"user_id": userID, "discord_id": discordID,
 		"level": level, "xp": xp, "currency_balance": balance,
 	}, nil)
 }
 
-func (c *ApxClient) InsertInventoryItem(userID int64, invID, itemID int, name, rarity, itemType, assetKey string, sellPrice int) error {
-	return c.post("/progression/inventory", map[string]any{
+func (c *ApxClient) InsertInventoryItem(ctx context.Context, userID int64, invID, itemID int, name, rarity, itemType, assetKey string, sellPrice int) error {
+	return c.post(ctx, "/progression/inventory", map[string]any{
 		"user_id": userID, "inventory_id": invID, "item_id": itemID,
 		"name": name, "rarity": rarity, "item_type": itemType,
 		"asset_key": assetKey, "sell_price": sellPrice,
 	}, nil)
 }
 
-func (c *ApxClient) DeleteInventoryItem(userID int64, inventoryID int) error {
-	return c.del(fmt.Sprintf("/progression/inventory/%d/%d", userID, inventoryID))
+func (c *ApxClient) DeleteInventoryItem(ctx context.Context, userID int64, inventoryID int) error {
+	return c.del(ctx, fmt.Sprintf("/progression/inventory/%d/%d", userID, inventoryID))
 }
 
-func (c *ApxClient) UpdateProgressionUserRank(userID int64, discordID, rank string) error {
-	return c.patch(fmt.Sprintf("/progression/users/by-discord/%s/rank", discordID),
+func (c *ApxClient) UpdateProgressionUserRank(ctx context.Context, userID int64, discordID, rank string) error {
+	return c.patch(ctx, fmt.Sprintf("/progression/users/by-discord/%s/rank", discordID),
 		map[string]any{"user_id": userID, "rank": rank})
 }
 
-func (c *ApxClient) EquipInventoryItem(userID int64, inventoryID int, itemType string, equipped bool) error {
-	return c.patch(fmt.Sprintf("/progression/inventory/%d/%d/equip", userID, inventoryID),
+func (c *ApxClient) EquipInventoryItem(ctx context.Context, userID int64, inventoryID int, itemType string, equipped bool) error {
+	return c.patch(ctx, fmt.Sprintf("/progression/inventory/%d/%d/equip", userID, inventoryID),
 		map[string]any{"equipped": equipped, "item_type": itemType})
 }
 
-func (c *ApxClient) GetProgressionUser(userID int64) (*ProgressionUser, error) {
+func (c *ApxClient) GetProgressionUser(ctx context.Context, userID int64) (*ProgressionUser, error) {
 	var u ProgressionUser
-	if err := c.get(fmt.Sprintf("/progression/users/%d", userID), &u); err != nil {
+	if err := c.get(ctx, fmt.Sprintf("/progression/users/%d", userID), &u); err != nil {
 		return nil, err
 	}
 	return &u, nil
 }
 
-func (c *ApxClient) GetEquippedItems(userID int64) ([]ProgressionInventoryItem, error) {
+func (c *ApxClient) GetEquippedItems(ctx context.Context, userID int64) ([]ProgressionInventoryItem, error) {
 	var items []ProgressionInventoryItem
-	if err := c.get(fmt.Sprintf("/progression/equipped/%d", userID), &items); err != nil {
+	if err := c.get(ctx, fmt.Sprintf("/progression/equipped/%d", userID), &items); err != nil {
 		return nil, err
 	}
 	if items == nil {
@@ -923,9 +1808,9 @@ func (c *ApxClient) GetEquippedItems(userID int64) ([]ProgressionInventoryItem,
 	return items, nil
 }
 
-func (c *ApxClient) GetBotLeaderboard(guildID string, limit int) ([]BotUser, error) {
+func (c *ApxClient) GetBotLeaderboard(ctx context.Context, guildID string, limit int) ([]BotUser, error) {
 	var users []BotUser
-	if err := c.get(fmt.Sprintf("/bot/leaderboard?guild_id=%s&limit=%d", guildID, limit), &users); err != nil {
+	if err := c.get(ctx, fmt.Sprintf("/bot/leaderboard?guild_id=%s&limit=%d", guildID, limit), &users); err != nil {
 		return nil, err
 	}
 	if users == nil {
@@ -934,24 +1819,24 @@ func (c *ApxClient) GetBotLeaderboard(guildID string, limit int) ([]BotUser, err
 	return users, nil
 }
 
-func (c *ApxClient) GetBotUser(discordID, guildID string) (*BotUser, error) {
+func (c *ApxClient) GetBotUser(ctx context.Context, discordID, guildID string) (*BotUser, error) {
 	var u BotUser
-	if err := c.get(fmt.Sprintf("/bot/user/%s/%s", discordID, guildID), &u); err != nil {
+	if err := c.get(ctx, fmt.Sprintf("/bot/user/%s/%s", discordID, guildID), &u); err != nil {
 		return nil, err
 	}
 	return &u, nil
 }
 
-func (c *ApxClient) DeleteAllTrustedDevices(userID int64) {
-	devices, err := c.GetTrustedDevices(userID)
+func (c *ApxClient) DeleteAllTrustedDevices(ctx context.Context, userID int64) {
+	devices, err := c.GetTrustedDevices(ctx, userID)
 	if err != nil {
 		return
 	}
 	for _, d := range devices {
-		_ = c.DeleteTrustedDevice(d.Token, userID)
+		_ = c.DeleteTrustedDevice(ctx, d.Token, userID)
 	}
 }
 
-func (c *ApxClient) DeleteUserSessions(userID int64) error {
-	return c.del(fmt.Sprintf("/users/%d/sessions", userID))
+func (c *ApxClient) DeleteUserSessions(ctx context.Context, userID int64) error {
+	return c.del(ctx, fmt.Sprintf("/users/%d/sessions", userID))
 }
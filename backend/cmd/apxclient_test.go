@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+
+	"teamapx-backend/cmd/apxfake"
+)
+
+// TestUsersByUsernamesFallback runs against an ApxApi without the bulk
+// lookup route and expects the per-username lookups to fill in.
+func TestUsersByUsernamesFallback(t *testing.T) {
+	fake := apxfake.New()
+	defer fake.Close()
+	fake.AddUser(apxfake.User{Username: "ny", Nickname: "NY", IsActive: true})
+	fake.AddUser(apxfake.User{Username: "gone", IsActive: false})
+
+	target, _ := url.Parse(fake.URL)
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	old := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/users/by-usernames" {
+			http.NotFound(w, r)
+			return
+		}
+		proxy.ServeHTTP(w, r)
+	}))
+	defer old.Close()
+
+	users, err := usersByUsernames(context.Background(), NewApxClient(old.URL, apxfake.APIKey), []string{"ny", "gone", "ghost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 || users["ny"] == nil || users["ny"].Nickname != "NY" {
+		t.Errorf("users = %v, want only ny", users)
+	}
+}
+
+func TestCreateApplicationWhileOpen(t *testing.T) {
+	fake := apxfake.New()
+	defer fake.Close()
+	uid := fake.AddUser(apxfake.User{Username: "ny", IsActive: true})
+	apx := NewApxClient(fake.URL, apxfake.APIKey)
+	app := ApplicationRecord{UserID: uid, Name: "NY", Game: "Rainbow Six Siege"}
+
+	if _, err := apx.CreateApplication(context.Background(), app); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := apx.CreateApplication(context.Background(), app); !errors.Is(err, errApplicationOpen) {
+		t.Fatalf("second application: err = %v, want errApplicationOpen", err)
+	}
+}
@@ -0,0 +1,1390 @@
+// Package apxfake is an in-memory stand-in for the ApxApi service, served
+// over httptest so handler tests can run ApxClient without network access.
+//
+// It covers the routes ApxClient uses for sessions, email verification and
+// password reset, 2FA (pending logins, TOTP, recovery codes, trusted
+// devices), users and linked accounts, events, progression and applicant
+// applications. Audit entries can be written (POST /audit) and counted with
+// AuditCount but not queried. Everything else — team, staff, matches,
+// schedule, lineups, weekly stats, the org chart and the admin application
+// review — answers 404, so handlers using those parts need the SQLite store.
+package apxfake
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// APIKey is the X-API-KEY the fake expects.
+const APIKey = "apxfake-key"
+
+// User mirrors the user JSON returned by ApxApi.
+type User struct {
+	ID            int64  `json:"id"`
+	Username      string `json:"username"`
+	Nickname      string `json:"nickname"`
+	Email         string `json:"email"`
+	Password      string `json:"password"`
+	IsAdmin       bool   `json:"is_admin"`
+	Role          string `json:"role"`
+	IsActive      bool   `json:"is_active"`
+	TwoFAEnabled  bool   `json:"two_fa_enabled"`
+	TrustDevices  bool   `json:"trust_devices"`
+	EventAccess   bool   `json:"event_access"`
+	CreatedAt     string `json:"created_at"`
+	AvatarURL     string `json:"avatar_url"`
+	BannerURL     string `json:"banner_url"`
+	Timezone      string `json:"timezone"`
+	ShowLocalTime bool   `json:"show_local_time"`
+	SocialLinks   string `json:"social_links"`
+	Bio           string `json:"bio"`
+	MailLanguage  string `json:"mail_language"`
+	MailOptOut    bool   `json:"mail_opt_out"`
+
+	TwoFAMethod   string `json:"-"`
+	TOTPSecret    string `json:"-"`
+	TOTPConfirmed bool   `json:"-"`
+	TOTPLastStep  int64  `json:"-"`
+}
+
+type Event struct {
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	Status          string `json:"status"`
+	Date            string `json:"date"`
+	DurationDe      string `json:"duration_de"`
+	DurationEn      string `json:"duration_en"`
+	DescriptionDe   string `json:"description_de"`
+	DescriptionEn   string `json:"description_en"`
+	MaxParticipants int    `json:"max_participants"`
+	CreatedAt       string `json:"created_at"`
+}
+
+type LinkedAccount struct {
+	Service     string `json:"service"`
+	ServiceID   string `json:"service_id"`
+	Username    string `json:"username"`
+	AvatarURL   string `json:"avatar_url"`
+	ProfileURL  string `json:"profile_url"`
+	DiscordData string `json:"-"`
+	CMData      string `json:"-"`
+}
+
+type ProgressionUser struct {
+	UserID          int64  `json:"user_id"`
+	DiscordID       string `json:"discord_id"`
+	Level           int    `json:"level"`
+	XP              int    `json:"xp"`
+	CurrencyBalance int    `json:"currency_balance"`
+	DiscordRank     string `json:"discord_rank"`
+}
+
+type InventoryItem struct {
+	InventoryID int    `json:"inventory_id"`
+	ItemID      int    `json:"item_id"`
+	Name        string `json:"name"`
+	Rarity      string `json:"rarity"`
+	ItemType    string `json:"item_type"`
+	AssetKey    string `json:"asset_key"`
+	SellPrice   int    `json:"sell_price"`
+	Equipped    bool   `json:"equipped"`
+}
+
+type BotUser struct {
+	UserID          string  `json:"user_id"`
+	GuildID         string  `json:"guild_id"`
+	ApxID           *string `json:"apx_id"`
+	XP              int     `json:"xp"`
+	Level           int     `json:"level"`
+	Gold            int     `json:"gold"`
+	TotalEarned     int     `json:"total_earned"`
+	RankRoleID      *string `json:"rank_role_id"`
+	DiscordUsername string  `json:"discord_username"`
+}
+
+// Application mirrors ApplicationRecord.
+type Application struct {
+	ID           int64           `json:"id"`
+	UserID       int64           `json:"user_id"`
+	Name         string          `json:"name"`
+	Age          int             `json:"age"`
+	Discord      string          `json:"discord"`
+	Game         string          `json:"game"`
+	Rank         string          `json:"rank"`
+	AttackerRole string          `json:"attacker_role"`
+	DefenderRole string          `json:"defender_role"`
+	Experience   string          `json:"experience"`
+	Motivation   string          `json:"motivation"`
+	Availability string          `json:"availability"`
+	Status       string          `json:"status"`
+	CreatedAt    string          `json:"created_at"`
+	Answers      json.RawMessage `json:"answers,omitempty"`
+}
+
+type expiring struct {
+	UserID    int64
+	Code      string
+	Extra     map[string]string
+	ExpiresAt time.Time
+}
+
+type device struct {
+	UserID     int64
+	DeviceName string
+	Location   string
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+}
+
+// Server is the fake ApxApi. The exported helpers seed and inspect state
+// directly; everything else goes through HTTP like the real service.
+type Server struct {
+	*httptest.Server
+
+	mu            sync.Mutex
+	nextUserID    int64
+	users         map[int64]*User
+	sessions      map[string]expiring
+	verifications map[string]expiring
+	emailChanges  map[int64]expiring
+	resets        map[string]expiring
+	pending2FA    map[string]expiring
+	devices       map[string]device
+	oauthStates   map[string]expiring
+	recovery      map[int64]map[string]bool // hash → used
+	linked        map[int64]map[string]*LinkedAccount
+	events        map[string]*Event
+	participants  map[string]map[int64]time.Time
+	progression   map[int64]*ProgressionUser
+	inventory     map[int64]map[int]*InventoryItem
+	botUsers      map[string]*BotUser // key discordID + "/" + guildID
+	applications  []*Application
+	audit         []json.RawMessage
+
+	routes []route
+}
+
+// New starts a fake ApxApi. Callers must Close it.
+func New() *Server {
+	s := &Server{
+		nextUserID:    1,
+		users:         map[int64]*User{},
+		sessions:      map[string]expiring{},
+		verifications: map[string]expiring{},
+		emailChanges:  map[int64]expiring{},
+		resets:        map[string]expiring{},
+		pending2FA:    map[string]expiring{},
+		devices:       map[string]device{},
+		oauthStates:   map[string]expiring{},
+		recovery:      map[int64]map[string]bool{},
+		linked:        map[int64]map[string]*LinkedAccount{},
+		events:        map[string]*Event{},
+		participants:  map[string]map[int64]time.Time{},
+		progression:   map[int64]*ProgressionUser{},
+		inventory:     map[int64]map[int]*InventoryItem{},
+		botUsers:      map[string]*BotUser{},
+	}
+	s.registerRoutes()
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// ── Seeding / inspection ─────────────────────────────────────────────────────
+
+// AddUser stores u (active, with defaults for empty fields) and returns its id.
+func (s *Server) AddUser(u User) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addUserLocked(u)
+}
+
+func (s *Server) addUserLocked(u User) int64 {
+	u.ID = s.nextUserID
+	s.nextUserID++
+	if u.CreatedAt == "" {
+		u.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	if u.SocialLinks == "" {
+		u.SocialLinks = "[]"
+	}
+	if u.TwoFAMethod == "" {
+		u.TwoFAMethod = "email"
+	}
+	s.users[u.ID] = &u
+	return u.ID
+}
+
+// User returns a copy of the stored user.
+func (s *Server) User(id int64) (User, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[id]
+	if !ok {
+		return User{}, false
+	}
+	return *u, true
+}
+
+// UserByUsername returns a copy of the stored user.
+func (s *Server) UserByUsername(username string) (User, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if u := s.userByLocked(func(u *User) bool { return u.Username == username }); u != nil {
+		return *u, true
+	}
+	return User{}, false
+}
+
+// Verification returns the pending registration code for email.
+func (s *Server) Verification(email string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.verifications[email]
+	return v.Code, ok
+}
+
+// Pending2FACode returns the emailed code stored with a pending login.
+func (s *Server) Pending2FACode(token string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.pending2FA[token]
+	return p.Code, ok
+}
+
+// LinkAccount links an external account (e.g. service "discord") to a user.
+func (s *Server) LinkAccount(userID int64, a LinkedAccount) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.linked[userID] == nil {
+		s.linked[userID] = map[string]*LinkedAccount{}
+	}
+	s.linked[userID][a.Service] = &a
+}
+
+// AddEvent stores e, assigning an id when empty, and returns the id.
+func (s *Server) AddEvent(e Event) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e.ID == "" {
+		e.ID = newID()
+	}
+	if e.Status == "" {
+		e.Status = "upcoming"
+	}
+	s.events[e.ID] = &e
+	return e.ID
+}
+
+// Participants returns the ids of users who joined the event, sorted.
+func (s *Server) Participants(eventID string) []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ids []int64
+	for id := range s.participants[eventID] {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// ProgressionUser returns the synced progression state of a user.
+func (s *Server) ProgressionUser(userID int64) (ProgressionUser, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.progression[userID]
+	if !ok {
+		return ProgressionUser{}, false
+	}
+	return *p, true
+}
+
+// Inventory returns a user's synced inventory ordered by inventory id.
+func (s *Server) Inventory(userID int64) []InventoryItem {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inventoryLocked(userID, false)
+}
+
+// AddBotUser stores a Discord bot user row.
+func (s *Server) AddBotUser(b BotUser) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.botUsers[b.UserID+"/"+b.GuildID] = &b
+}
+
+// AddApplication stores a (by default pending) application and returns its id.
+func (s *Server) AddApplication(a Application) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addApplicationLocked(a)
+}
+
+func (s *Server) addApplicationLocked(a Application) int64 {
+	a.ID = int64(len(s.applications) + 1)
+	if a.Status == "" {
+		a.Status = "pending"
+	}
+	if a.CreatedAt == "" {
+		a.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	s.applications = append(s.applications, &a)
+	return a.ID
+}
+
+func openApplication(status string) bool {
+	return status == "pending" || status == "in_review" || status == "trial"
+}
+
+// latestApplicationLocked returns the user's newest application, or nil.
+func (s *Server) latestApplicationLocked(userID int64) *Application {
+	for i := len(s.applications) - 1; i >= 0; i-- {
+		if s.applications[i].UserID == userID {
+			return s.applications[i]
+		}
+	}
+	return nil
+}
+
+// AuditCount returns the number of audit entries written.
+func (s *Server) AuditCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.audit)
+}
+
+// ── Routing ──────────────────────────────────────────────────────────────────
+
+// route is matched segment by segment; "{}" matches any single segment.
+// Routes are tried in registration order, so literal paths such as
+// /users/by-email/{} are registered before /users/{}/….
+type route struct {
+	method string
+	parts  []string
+	h      func(w http.ResponseWriter, r *http.Request, p []string)
+}
+
+func (s *Server) handle(method, pattern string, h func(w http.ResponseWriter, r *http.Request, p []string)) {
+	s.routes = append(s.routes, route{method, strings.Split(strings.Trim(pattern, "/"), "/"), h})
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-API-KEY") != APIKey {
+		writeError(w, http.StatusUnauthorized, "invalid api key")
+		return
+	}
+	segs := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	for _, rt := range s.routes {
+		if rt.method != r.Method || len(rt.parts) != len(segs) {
+			continue
+		}
+		var params []string
+		ok := true
+		for i, p := range rt.parts {
+			if p == "{}" {
+				params = append(params, segs[i])
+			} else if p != segs[i] {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			rt.h(w, r, params)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "not found")
+}
+
+func writeData(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": v})
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+func decode(r *http.Request, v any) bool {
+	return json.NewDecoder(r.Body).Decode(v) == nil
+}
+
+func id64(s string) int64 {
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func newToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (s *Server) userByLocked(match func(*User) bool) *User {
+	for _, u := range s.users {
+		if match(u) {
+			return u
+		}
+	}
+	return nil
+}
+
+// userOr404 returns the user with the id in p[0], writing a 404 if missing.
+func (s *Server) userOr404(w http.ResponseWriter, p []string) *User {
+	u, ok := s.users[id64(p[0])]
+	if !ok {
+		writeError(w, http.StatusNotFound, "user not found")
+		return nil
+	}
+	return u
+}
+
+func (s *Server) registerRoutes() {
+	s.registerAuthRoutes()
+	s.registerUserRoutes()
+	s.registerEventRoutes()
+	s.registerProgressionRoutes()
+	s.registerApplicationRoutes()
+	s.handle("POST", "/audit", func(w http.ResponseWriter, r *http.Request, p []string) {
+		var e json.RawMessage
+		if !decode(r, &e) {
+			writeError(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+		s.audit = append(s.audit, e)
+		writeData(w, nil)
+	})
+}
+
+// ── Auth ─────────────────────────────────────────────────────────────────────
+
+func (s *Server) registerAuthRoutes() {
+	s.handle("GET", "/auth/sessions/validate/{}", func(w http.ResponseWriter, r *http.Request, p []string) {
+		sess, ok := s.sessions[p[0]]
+		u := s.users[sess.UserID]
+		if !ok || time.Now().After(sess.ExpiresAt) || u == nil || !u.IsActive {
+			writeError(w, http.StatusNotFound, "session not found")
+			return
+		}
+		writeData(w, u)
+	})
+	s.handle("POST", "/auth/sessions", func(w http.ResponseWriter, r *http.Request, p []string) {
+		var req struct {
+			UserID int64 `json:"user_id"`
+		}
+		if !decode(r, &req) {
+			writeError(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+		token := newToken()
+		s.sessions[token] = expiring{UserID: req.UserID, ExpiresAt: time.Now().Add(7 * 24 * time.Hour)}
+		writeData(w, map[string]string{"token": token})
+	})
+	s.handle("DELETE", "/auth/sessions/{}", func(w http.ResponseWriter, r *http.Request, p []string) {
+		delete(s.sessions, p[0])
+		writeData(w, nil)
+	})
+
+	s.handle("POST", "/auth/verifications", func(w http.ResponseWriter, r *http.Request, p []string) {
+		var req struct {
+			Email     string    `json:"email"`
+			Username  string    `json:"username"`
+			Nickname  string    `json:"nickname"`
+			Password  string    `json:"password"`
+			Code      string    `json:"code"`
+			ExpiresAt time.Time `json:"expires_at"`
+		}
+		if !decode(r, &req) {
+			writeError(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+		s.verifications[req.Email] = expiring{Code: req.Code, ExpiresAt: req.ExpiresAt, Extra: map[string]string{
+			"username": req.Username, "nickname": req.Nickname, "password": req.Password,
+		}}
+		writeData(w, nil)
+	})
+	s.handle("GET", "/auth/verifications/{}", func(w http.ResponseWriter, r *http.Request, p []string) {
+		v, ok := s.verifications[p[0]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		writeData(w, map[string]any{
+			"email": p[0], "username": v.Extra["username"], "nickname": v.Extra["nickname"],
+			"password": v.Extra["password"], "code": v.Code, "expires_at": v.ExpiresAt,
+		})
+	})
+	s.handle("DELETE", "/auth/verifications/{}", func(w http.ResponseWriter, r *http.Request, p []string) {
+		delete(s.verifications, p[0])
+		writeData(w, nil)
+	})
+
+	s.handle("POST", "/auth/email-changes", func(w http.ResponseWriter, r *http.Request, p []string) {
+		var req struct {
+			UserID    int64     `json:"user_id"`
+			NewEmail  string    `json:"new_email"`
+			Code      string    `json:"code"`
+			ExpiresAt time.Time `json:"expires_at"`
+		}
+		if !decode(r, &req) {
+			writeError(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+		s.emailChanges[req.UserID] = expiring{UserID: req.UserID, Code: req.Code, ExpiresAt: req.ExpiresAt,
+			Extra: map[string]string{"new_email": req.NewEmail}}
+		writeData(w, nil)
+	})
+	s.handle("GET", "/auth/email-changes/{}", func(w http.ResponseWriter, r *http.Request, p []string) {
+		c, ok := s.emailChanges[id64(p[0])]
+		if !ok {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		writeData(w, map[string]any{"user_id": c.UserID, "new_email": c.Extra["new_email"], "code": c.Code, "expires_at": c.ExpiresAt})
+	})
+	s.handle("DELETE", "/auth/email-changes/{}", func(w http.ResponseWriter, r *http.Request, p []string) {
+		delete(s.emailChanges, id64(p[0]))
+		writeData(w, nil)
+	})
+
+	s.handle("POST", "/auth/password-resets", func(w http.ResponseWriter, r *http.Request, p []string) {
+		var req struct {
+			UserID    int64     `json:"user_id"`
+			Email     string    `json:"email"`
+			Code      string    `json:"code"`
+			ExpiresAt time.Time `json:"expires_at"`
+		}
+		if !decode(r, &req) {
+			writeError(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+		s.resets[req.Email] = expiring{UserID: req.UserID, Code: req.Code, ExpiresAt: req.ExpiresAt}
+		writeData(w, nil)
+	})
+	s.handle("GET", "/auth/password-resets/{}", func(w http.ResponseWriter, r *http.Request, p []string) {
+		pr, ok := s.resets[p[0]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		writeData(w, map[string]any{"user_id": pr.UserID, "email": p[0], "code": pr.Code, "expires_at": pr.ExpiresAt})
+	})
+	s.handle("DELETE", "/auth/password-resets/{}", func(w http.ResponseWriter, r *http.Request, p []string) {
+		delete(s.resets, p[0])
+		writeData(w, nil)
+	})
+
+	s.handle("POST", "/auth/2fa/pending", func(w http.ResponseWriter, r *http.Request, p []string) {
+		var req struct {
+			Token     string    `json:"token"`
+			UserID    int64     `json:"user_id"`
+			Code      string    `json:"code"`
+			ExpiresAt time.Time `json:"expires_at"`
+		}
+		if !decode(r, &req) {
+			writeError(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+		s.pending2FA[req.Token] = expiring{UserID: req.UserID, Code: req.Code, ExpiresAt: req.ExpiresAt}
+		writeData(w, nil)
+	})
+	s.handle("GET", "/auth/2fa/pending/{}", func(w http.ResponseWriter, r *http.Request, p []string) {
+		pend, ok := s.pending2FA[p[0]]
+		if !ok || time.Now().After(pend.ExpiresAt) {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		writeData(w, map[string]any{"user_id": pend.UserID, "code": pend.Code})
+	})
+	s.handle("DELETE", "/auth/2fa/pending/{}", func(w http.ResponseWriter, r *http.Request, p []string) {
+		delete(s.pending2FA, p[0])
+		writeData(w, nil)
+	})
+
+	s.handle("POST", "/auth/devices", func(w http.ResponseWriter, r *http.Request, p []string) {
+		var req struct {
+			Token      string    `json:"token"`
+			UserID     int64     `json:"user_id"`
+			DeviceName string    `json:"device_name"`
+			Location   string    `json:"location"`
+			ExpiresAt  time.Time `json:"expires_at"`
+		}
+		if !decode(r, &req) {
+			writeError(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+		s.devices[req.Token] = device{UserID: req.UserID, DeviceName: req.DeviceName, Location: req.Location,
+			CreatedAt: time.Now(), ExpiresAt: req.ExpiresAt}
+		writeData(w, nil)
+	})
+	s.handle("GET", "/auth/devices/by-token/{}", func(w http.ResponseWriter, r *http.Request, p []string) {
+		d, ok := s.devices[p[0]]
+		if !ok || time.Now().After(d.ExpiresAt) {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		writeData(w, map[string]int64{"user_id": d.UserID})
+	})
+	s.handle("GET", "/auth/devices/user/{}", func(w http.ResponseWriter, r *http.Request, p []string) {
+		uid := id64(p[0])
+		out := []map[string]any{}
+		for token, d := range s.devices {
+			if d.UserID == uid && time.Now().Before(d.ExpiresAt) {
+				out = append(out, map[string]any{"token": token, "device_name": d.DeviceName,
+					"location": d.Location, "created_at": d.CreatedAt.UTC().Format(time.RFC3339)})
+			}
+		}
+		writeData(w, out)
+	})
+	s.handle("DELETE", "/auth/devices/{}", func(w http.ResponseWriter, r *http.Request, p []string) {
+		var req struct {
+			UserID int64 `json:"user_id"`
+		}
+		decode(r, &req)
+		if d, ok := s.devices[p[0]]; ok && d.UserID == req.UserID {
+			delete(s.devices, p[0])
+		}
+		writeData(w, nil)
+	})
+
+	s.handle("POST", "/auth/oauth-states", func(w http.ResponseWriter, r *http.Request, p []string) {
+		var req struct {
+			State        string    `json:"state"`
+			UserID       int64     `json:"user_id"`
+			CodeVerifier string    `json:"code_verifier"`
+			ExpiresAt    time.Time `json:"expires_at"`
+		}
+		if !decode(r, &req) {
+			writeError(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+		s.oauthStates[req.State] = expiring{UserID: req.UserID, Code: req.CodeVerifier, ExpiresAt: req.ExpiresAt}
+		writeData(w, nil)
+	})
+	s.handle("POST", "/auth/oauth-states/consume", func(w http.ResponseWriter, r *http.Request, p []string) {
+		var req struct {
+			State string `json:"state"`
+		}
+		decode(r, &req)
+		st, ok := s.oauthStates[req.State]
+		delete(s.oauthStates, req.State)
+		if !ok || time.Now().After(st.ExpiresAt) {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		writeData(w, map[string]any{"user_id": st.UserID, "code_verifier": st.Code})
+	})
+}
+
+// ── Users ────────────────────────────────────────────────────────────────────
+
+func (s *Server) registerUserRoutes() {
+	// Literal second segments first; see route.
+	s.handle("POST", "/users/create-with-hash", func(w http.ResponseWriter, r *http.Request, p []string) {
+		var req struct {
+			Username     string `json:"username"`
+			Nickname     string `json:"nickname"`
+			Email        string `json:"email"`
+			PasswordHash string `json:"password_hash"`
+		}
+		if !decode(r, &req) {
+			writeError(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+		if s.userByLocked(func(u *User) bool { return u.Username == req.Username || u.Email == req.Email }) != nil {
+			writeError(w, http.StatusConflict, "user exists")
+			return
+		}
+		id := s.addUserLocked(User{Username: req.Username, Nickname: req.Nickname, Email: req.Email,
+			Password: req.PasswordHash, IsActive: true, TwoFAEnabled: true, TrustDevices: true})
+		writeData(w, map[string]int64{"id": id})
+	})
+	s.handle("GET", "/users/by-email/{}", func(w http.ResponseWriter, r *http.Request, p []string) {
+		s.writeUser(w, func(u *User) bool { return strings.EqualFold(u.Email, p[0]) && u.IsActive })
+	})
+	s.handle("GET", "/users/by-username/{}", func(w http.ResponseWriter, r *http.Request, p []string) {
+		s.writeUser(w, func(u *User) bool { return u.Username == p[0] && u.IsActive })
+	})
+	s.handle("POST", "/users/by-usernames", func(w http.ResponseWriter, r *http.Request, p []string) {
+		var req struct {
+			Usernames []string `json:"usernames"`
+		}
+		if !decode(r, &req) {
+			writeError(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+		out := []*User{}
+		for _, name := range req.Usernames {
+			if u := s.userByLocked(func(u *User) bool { return u.Username == name && u.IsActive }); u != nil {
+				out = append(out, u)
+			}
+		}
+		writeData(w, out)
+	})
+	s.handle("GET", "/users/by-username-any/{}", func(w http.ResponseWriter, r *http.Request, p []string) {
+		s.writeUser(w, func(u *User) bool { return u.Username == p[0] })
+	})
+	s.handle("DELETE", "/users/by-username/{}", func(w http.ResponseWriter, r *http.Request, p []string) {
+		u := s.userByLocked(func(u *User) bool { return u.Username == p[0] })
+		if u == nil {
+			writeError(w, http.StatusNotFound, "user not found")
+			return
+		}
+		delete(s.users, u.ID)
+		writeData(w, nil)
+	})
+	s.handle("GET", "/users/all-usernames", func(w http.ResponseWriter, r *http.Request, p []string) {
+		names := []string{}
+		for _, u := range s.users {
+			names = append(names, u.Username)
+		}
+		sort.Strings(names)
+		writeData(w, names)
+	})
+	s.handle("GET", "/users/search", func(w http.ResponseWriter, r *http.Request, p []string) {
+		q := strings.ToLower(r.URL.Query().Get("q"))
+		out := []*User{}
+		for _, u := range s.users {
+			if u.IsActive && (strings.Contains(strings.ToLower(u.Username), q) || strings.Contains(strings.ToLower(u.Nickname), q)) {
+				out = append(out, u)
+			}
+		}
+		sort.Slice(out, func(i, j int) bool { return out[i].Username < out[j].Username })
+		writeData(w, out)
+	})
+	s.handle("POST", "/users/check-conflict", func(w http.ResponseWriter, r *http.Request, p []string) {
+		var req struct {
+			Username string `json:"username"`
+			Email    string `json:"email"`
+		}
+		decode(r, &req)
+		conflict := s.userByLocked(func(u *User) bool {
+			return u.Username == req.Username || strings.EqualFold(u.Email, req.Email)
+		}) != nil
+		writeData(w, map[string]bool{"conflict": conflict})
+	})
+	s.handle("POST", "/users/ensure-admin", func(w http.ResponseWriter, r *http.Request, p []string) {
+		var req struct {
+			HashedPw string `json:"hashed_pw"`
+		}
+		decode(r, &req)
+		if u := s.userByLocked(func(u *User) bool { return u.Username == "admin" }); u != nil {
+			u.Password, u.IsAdmin = req.HashedPw, true
+		} else {
+			s.addUserLocked(User{Username: "admin", Nickname: "Admin", Email: "admin@localhost", Password: req.HashedPw,
+				IsAdmin: true, Role: "superadmin", IsActive: true})
+		}
+		writeData(w, nil)
+	})
+	s.handle("POST", "/users/toggle-2fa", func(w http.ResponseWriter, r *http.Request, p []string) {
+		var req struct {
+			Username string `json:"username"`
+		}
+		decode(r, &req)
+		u := s.userByLocked(func(u *User) bool { return u.Username == req.Username })
+		if u == nil {
+			writeError(w, http.StatusNotFound, "user not found")
+			return
+		}
+		u.TwoFAEnabled = !u.TwoFAEnabled
+		writeData(w, map[string]bool{"two_fa_enabled": u.TwoFAEnabled})
+	})
+	s.handle("PATCH", "/users/deactivate-by-username/{}", func(w http.ResponseWriter, r *http.Request, p []string) {
+		s.setByUsername(w, p[0], func(u *User) { u.IsActive = false; s.dropSessionsLocked(u.ID) })
+	})
+	s.handle("PATCH", "/users/activate-by-username/{}", func(w http.ResponseWriter, r *http.Request, p []string) {
+		s.setByUsername(w, p[0], func(u *User) { u.IsActive = true })
+	})
+
+	s.handle("PATCH", "/users/{}", func(w http.ResponseWriter, r *http.Request, p []string) {
+		u := s.userOr404(w, p)
+		if u == nil {
+			return
+		}
+		var req map[string]any
+		if !decode(r, &req) {
+			writeError(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+		for k, v := range req {
+			str, _ := v.(string)
+			b, _ := v.(bool)
+			switch k {
+			case "username":
+				u.Username = str
+			case "nickname":
+				u.Nickname = str
+			case "email":
+				u.Email = str
+			case "avatar_url":
+				u.AvatarURL = str
+			case "banner_url":
+				u.BannerURL = str
+			case "bio":
+				u.Bio = str
+			case "timezone":
+				u.Timezone = str
+			case "show_local_time":
+				u.ShowLocalTime = b
+			case "social_links":
+				u.SocialLinks = str
+			case "mail_language":
+				u.MailLanguage = str
+			case "mail_opt_out":
+				u.MailOptOut = b
+			}
+		}
+		writeData(w, nil)
+	})
+	s.handle("DELETE", "/users/{}/sessions", func(w http.ResponseWriter, r *http.Request, p []string) {
+		s.dropSessionsLocked(id64(p[0]))
+		writeData(w, nil)
+	})
+	s.handle("PATCH", "/users/{}/deactivate", func(w http.ResponseWriter, r *http.Request, p []string) {
+		if u := s.userOr404(w, p); u != nil {
+			u.IsActive = false
+			s.dropSessionsLocked(u.ID)
+			writeData(w, nil)
+		}
+	})
+	s.patchUserBool("/users/{}/event-access", "enabled", func(u *User, v bool) { u.EventAccess = v })
+	s.patchUserBool("/users/{}/2fa", "enabled", func(u *User, v bool) { u.TwoFAEnabled = v })
+	s.patchUserBool("/users/{}/trust-devices", "enabled", func(u *User, v bool) { u.TrustDevices = v })
+	s.patchUserString("/users/{}/role", "role", func(u *User, v string) { u.Role = v })
+	s.patchUserString("/users/{}/email", "email", func(u *User, v string) { u.Email = v })
+	s.patchUserString("/users/{}/password", "password_hash", func(u *User, v string) { u.Password = v })
+	s.patchUserString("/users/{}/2fa-method", "method", func(u *User, v string) { u.TwoFAMethod = v })
+	s.handle("GET", "/users/{}/2fa-settings", func(w http.ResponseWriter, r *http.Request, p []string) {
+		if u := s.userOr404(w, p); u != nil {
+			writeData(w, map[string]bool{"two_fa_enabled": u.TwoFAEnabled, "trust_devices": u.TrustDevices})
+		}
+	})
+
+	s.handle("GET", "/users/{}/2fa-config", func(w http.ResponseWriter, r *http.Request, p []string) {
+		u := s.userOr404(w, p)
+		if u == nil {
+			return
+		}
+		left := 0
+		for _, used := range s.recovery[u.ID] {
+			if !used {
+				left++
+			}
+		}
+		writeData(w, map[string]any{"method": u.TwoFAMethod, "secret": u.TOTPSecret,
+			"confirmed": u.TOTPConfirmed, "recovery_codes_left": left})
+	})
+	s.handle("PUT", "/users/{}/totp", func(w http.ResponseWriter, r *http.Request, p []string) {
+		var req struct {
+			Secret string `json:"secret"`
+		}
+		decode(r, &req)
+		if u := s.userOr404(w, p); u != nil {
+			u.TOTPSecret, u.TOTPConfirmed = req.Secret, false
+			writeData(w, nil)
+		}
+	})
+	s.handle("PATCH", "/users/{}/totp/confirm", func(w http.ResponseWriter, r *http.Request, p []string) {
+		if u := s.userOr404(w, p); u != nil {
+			u.TOTPConfirmed = true
+			writeData(w, nil)
+		}
+	})
+	s.handle("POST", "/users/{}/totp/step", func(w http.ResponseWriter, r *http.Request, p []string) {
+		var req struct {
+			Step int64 `json:"step"`
+		}
+		decode(r, &req)
+		if u := s.userOr404(w, p); u != nil {
+			accepted := req.Step > u.TOTPLastStep
+			if accepted {
+				u.TOTPLastStep = req.Step
+			}
+			writeData(w, map[string]bool{"accepted": accepted})
+		}
+	})
+	s.handle("DELETE", "/users/{}/totp", func(w http.ResponseWriter, r *http.Request, p []string) {
+		if u := s.userOr404(w, p); u != nil {
+			u.TOTPSecret, u.TOTPConfirmed, u.TwoFAMethod = "", false, "email"
+			delete(s.recovery, u.ID)
+			writeData(w, nil)
+		}
+	})
+	s.handle("PUT", "/users/{}/recovery-codes", func(w http.ResponseWriter, r *http.Request, p []string) {
+		var req struct {
+			Hashes []string `json:"hashes"`
+		}
+		decode(r, &req)
+		codes := map[string]bool{}
+		for _, h := range req.Hashes {
+			codes[h] = false
+		}
+		s.recovery[id64(p[0])] = codes
+		writeData(w, nil)
+	})
+	s.handle("POST", "/users/{}/recovery-codes/consume", func(w http.ResponseWriter, r *http.Request, p []string) {
+		var req struct {
+			Hash string `json:"hash"`
+		}
+		decode(r, &req)
+		codes := s.recovery[id64(p[0])]
+		used, exists := codes[req.Hash]
+		consumed := exists && !used
+		if consumed {
+			codes[req.Hash] = true
+		}
+		writeData(w, map[string]bool{"consumed": consumed})
+	})
+
+	s.handle("GET", "/users/{}/linked-accounts", func(w http.ResponseWriter, r *http.Request, p []string) {
+		out := []*LinkedAccount{}
+		for _, a := range s.linked[id64(p[0])] {
+			out = append(out, a)
+		}
+		sort.Slice(out, func(i, j int) bool { return out[i].Service < out[j].Service })
+		writeData(w, out)
+	})
+	s.handle("POST", "/users/{}/linked-account", func(w http.ResponseWriter, r *http.Request, p []string) {
+		var a LinkedAccount
+		if !decode(r, &a) {
+			writeError(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+		uid := id64(p[0])
+		if s.linked[uid] == nil {
+			s.linked[uid] = map[string]*LinkedAccount{}
+		}
+		s.linked[uid][a.Service] = &a
+		writeData(w, nil)
+	})
+	s.handle("DELETE", "/users/{}/linked-account/{}", func(w http.ResponseWriter, r *http.Request, p []string) {
+		delete(s.linked[id64(p[0])], p[1])
+		writeData(w, nil)
+	})
+	s.handle("PATCH", "/users/{}/linked-account/{}/{}", func(w http.ResponseWriter, r *http.Request, p []string) {
+		var req struct {
+			Value string `json:"value"`
+		}
+		decode(r, &req)
+		a, ok := s.linked[id64(p[0])][p[1]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		switch p[2] {
+		case "username":
+			a.Username = req.Value
+		case "avatar_url":
+			a.AvatarURL = req.Value
+		case "profile_url":
+			a.ProfileURL = req.Value
+		case "discord_data":
+			a.DiscordData = req.Value
+		case "cm_data":
+			a.CMData = req.Value
+		default:
+			writeError(w, http.StatusBadRequest, "invalid field")
+			return
+		}
+		writeData(w, nil)
+	})
+
+	s.handle("PATCH", "/bot/user/{}/apx-id", func(w http.ResponseWriter, r *http.Request, p []string) {
+		var req struct {
+			ApxID int64 `json:"apx_id"`
+		}
+		decode(r, &req)
+		id := strconv.FormatInt(req.ApxID, 10)
+		for _, b := range s.botUsers {
+			if b.UserID == p[0] {
+				b.ApxID = &id
+			}
+		}
+		writeData(w, nil)
+	})
+}
+
+func (s *Server) writeUser(w http.ResponseWriter, match func(*User) bool) {
+	if u := s.userByLocked(match); u != nil {
+		writeData(w, u)
+		return
+	}
+	writeError(w, http.StatusNotFound, "user not found")
+}
+
+func (s *Server) setByUsername(w http.ResponseWriter, username string, set func(*User)) {
+	u := s.userByLocked(func(u *User) bool { return u.Username == username })
+	if u == nil {
+		writeError(w, http.StatusNotFound, "user not found")
+		return
+	}
+	set(u)
+	writeData(w, nil)
+}
+
+func (s *Server) patchUserBool(pattern, key string, set func(*User, bool)) {
+	s.handle("PATCH", pattern, func(w http.ResponseWriter, r *http.Request, p []string) {
+		var req map[string]bool
+		decode(r, &req)
+		if u := s.userOr404(w, p); u != nil {
+			set(u, req[key])
+			writeData(w, nil)
+		}
+	})
+}
+
+func (s *Server) patchUserString(pattern, key string, set func(*User, string)) {
+	s.handle("PATCH", pattern, func(w http.ResponseWriter, r *http.Request, p []string) {
+		var req map[string]string
+		decode(r, &req)
+		if u := s.userOr404(w, p); u != nil {
+			set(u, req[key])
+			writeData(w, nil)
+		}
+	})
+}
+
+func (s *Server) dropSessionsLocked(userID int64) {
+	for token, sess := range s.sessions {
+		if sess.UserID == userID {
+			delete(s.sessions, token)
+		}
+	}
+}
+
+// ── Events ───────────────────────────────────────────────────────────────────
+
+func (s *Server) eventJSON(e *Event) map[string]any {
+	return map[string]any{
+		"id": e.ID, "name": e.Name, "status": e.Status, "date": e.Date,
+		"duration_de": e.DurationDe, "duration_en": e.DurationEn,
+		"description_de": e.DescriptionDe, "description_en": e.DescriptionEn,
+		"max_participants": e.MaxParticipants, "participant_count": len(s.participants[e.ID]),
+		"created_at": e.CreatedAt,
+	}
+}
+
+func (s *Server) registerEventRoutes() {
+	s.handle("GET", "/events", func(w http.ResponseWriter, r *http.Request, p []string) {
+		events := make([]*Event, 0, len(s.events))
+		for _, e := range s.events {
+			events = append(events, e)
+		}
+		sort.Slice(events, func(i, j int) bool { return events[i].Date > events[j].Date })
+		out := make([]map[string]any, 0, len(events))
+		for _, e := range events {
+			out = append(out, s.eventJSON(e))
+		}
+		writeData(w, out)
+	})
+	s.handle("POST", "/events/service-create", func(w http.ResponseWriter, r *http.Request, p []string) {
+		var e Event
+		if !decode(r, &e) {
+			writeError(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+		e.ID = newID()
+		if e.Status == "" {
+			e.Status = "upcoming"
+		}
+		e.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+		s.events[e.ID] = &e
+		writeData(w, s.eventJSON(&e))
+	})
+	s.handle("GET", "/events/joined", func(w http.ResponseWriter, r *http.Request, p []string) {
+		uid := id64(r.URL.Query().Get("user_id"))
+		joined := []string{}
+		for _, id := range strings.Split(r.URL.Query().Get("event_ids"), ",") {
+			if _, ok := s.participants[id][uid]; ok {
+				joined = append(joined, id)
+			}
+		}
+		writeData(w, map[string][]string{"event_ids": joined})
+	})
+	s.handle("GET", "/events/{}", func(w http.ResponseWriter, r *http.Request, p []string) {
+		e, ok := s.events[p[0]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "event not found")
+			return
+		}
+		writeData(w, s.eventJSON(e))
+	})
+	s.handle("PUT", "/events/{}", func(w http.ResponseWriter, r *http.Request, p []string) {
+		e, ok := s.events[p[0]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "event not found")
+			return
+		}
+		var upd Event
+		if !decode(r, &upd) {
+			writeError(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+		upd.ID, upd.CreatedAt = e.ID, e.CreatedAt
+		*e = upd
+		writeData(w, nil)
+	})
+	s.handle("DELETE", "/events/{}", func(w http.ResponseWriter, r *http.Request, p []string) {
+		delete(s.events, p[0])
+		delete(s.participants, p[0])
+		writeData(w, nil)
+	})
+	s.handle("GET", "/events/{}/is-participant", func(w http.ResponseWriter, r *http.Request, p []string) {
+		_, joined := s.participants[p[0]][id64(r.URL.Query().Get("user_id"))]
+		writeData(w, map[string]bool{"is_participant": joined})
+	})
+	s.handle("POST", "/events/{}/service-join", func(w http.ResponseWriter, r *http.Request, p []string) {
+		var req struct {
+			UserID int64 `json:"user_id"`
+		}
+		decode(r, &req)
+		e, ok := s.events[p[0]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "event not found")
+			return
+		}
+		parts := s.participants[e.ID]
+		if parts == nil {
+			parts = map[int64]time.Time{}
+			s.participants[e.ID] = parts
+		}
+		if _, joined := parts[req.UserID]; joined {
+			writeError(w, http.StatusConflict, "already joined")
+			return
+		}
+		if e.MaxParticipants > 0 && len(parts) >= e.MaxParticipants {
+			writeError(w, http.StatusConflict, "event is full")
+			return
+		}
+		parts[req.UserID] = time.Now()
+		writeData(w, nil)
+	})
+	s.handle("DELETE", "/events/{}/service-leave", func(w http.ResponseWriter, r *http.Request, p []string) {
+		var req struct {
+			UserID int64 `json:"user_id"`
+		}
+		decode(r, &req)
+		delete(s.participants[p[0]], req.UserID)
+		writeData(w, nil)
+	})
+	s.handle("GET", "/events/{}/participants", func(w http.ResponseWriter, r *http.Request, p []string) {
+		out := []map[string]any{}
+		for uid, joined := range s.participants[p[0]] {
+			u := s.users[uid]
+			if u == nil {
+				continue
+			}
+			out = append(out, map[string]any{"user_id": uid, "username": u.Username, "nickname": u.Nickname,
+				"avatar_url": u.AvatarURL, "joined_at": joined.UTC().Format(time.RFC3339)})
+		}
+		sort.Slice(out, func(i, j int) bool { return out[i]["joined_at"].(string) < out[j]["joined_at"].(string) })
+		writeData(w, out)
+	})
+}
+
+// ── Progression ──────────────────────────────────────────────────────────────
+
+func (s *Server) inventoryLocked(userID int64, equippedOnly bool) []InventoryItem {
+	out := []InventoryItem{}
+	for _, it := range s.inventory[userID] {
+		if !equippedOnly || it.Equipped {
+			out = append(out, *it)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].InventoryID < out[j].InventoryID })
+	return out
+}
+
+func (s *Server) registerProgressionRoutes() {
+	s.handle("POST", "/progression/resolve-discord", func(w http.ResponseWriter, r *http.Request, p []string) {
+		var req struct {
+			DiscordID string `json:"discord_id"`
+		}
+		decode(r, &req)
+		for uid, accounts := range s.linked {
+			if a, ok := accounts["discord"]; ok && a.ServiceID == req.DiscordID {
+				writeData(w, map[string]int64{"user_id": uid})
+				return
+			}
+		}
+		writeError(w, http.StatusNotFound, "no linked user")
+	})
+	s.handle("POST", "/progression/users", func(w http.ResponseWriter, r *http.Request, p []string) {
+		var req ProgressionUser
+		if !decode(r, &req) {
+			writeError(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+		if cur, ok := s.progression[req.UserID]; ok {
+			req.DiscordRank = cur.DiscordRank
+		}
+		s.progression[req.UserID] = &req
+		writeData(w, nil)
+	})
+	s.handle("GET", "/progression/users/{}", func(w http.ResponseWriter, r *http.Request, p []string) {
+		pu, ok := s.progression[id64(p[0])]
+		if !ok {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		writeData(w, pu)
+	})
+	s.handle("PATCH", "/progression/users/by-discord/{}/rank", func(w http.ResponseWriter, r *http.Request, p []string) {
+		var req struct {
+			UserID int64  `json:"user_id"`
+			Rank   string `json:"rank"`
+		}
+		decode(r, &req)
+		pu, ok := s.progression[req.UserID]
+		if !ok {
+			pu = &ProgressionUser{UserID: req.UserID}
+			s.progression[req.UserID] = pu
+		}
+		pu.DiscordID, pu.DiscordRank = p[0], req.Rank
+		writeData(w, nil)
+	})
+	s.handle("POST", "/progression/inventory", func(w http.ResponseWriter, r *http.Request, p []string) {
+		var req struct {
+			UserID int64 `json:"user_id"`
+			InventoryItem
+		}
+		if !decode(r, &req) {
+			writeError(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+		if s.inventory[req.UserID] == nil {
+			s.inventory[req.UserID] = map[int]*InventoryItem{}
+		}
+		it := req.InventoryItem
+		s.inventory[req.UserID][it.InventoryID] = &it
+		writeData(w, nil)
+	})
+	s.handle("GET", "/progression/inventory/{}", func(w http.ResponseWriter, r *http.Request, p []string) {
+		writeData(w, s.inventoryLocked(id64(p[0]), false))
+	})
+	s.handle("DELETE", "/progression/inventory/{}/{}", func(w http.ResponseWriter, r *http.Request, p []string) {
+		inv, _ := strconv.Atoi(p[1])
+		delete(s.inventory[id64(p[0])], inv)
+		writeData(w, nil)
+	})
+	s.handle("PATCH", "/progression/inventory/{}/{}/equip", func(w http.ResponseWriter, r *http.Request, p []string) {
+		var req struct {
+			Equipped bool   `json:"equipped"`
+			ItemType string `json:"item_type"`
+		}
+		decode(r, &req)
+		uid := id64(p[0])
+		inv, _ := strconv.Atoi(p[1])
+		it, ok := s.inventory[uid][inv]
+		if !ok {
+			writeError(w, http.StatusNotFound, "item not found")
+			return
+		}
+		if req.Equipped {
+			for _, other := range s.inventory[uid] {
+				if other.ItemType == req.ItemType {
+					other.Equipped = false
+				}
+			}
+		}
+		it.Equipped = req.Equipped
+		writeData(w, nil)
+	})
+	s.handle("GET", "/progression/equipped/{}", func(w http.ResponseWriter, r *http.Request, p []string) {
+		writeData(w, s.inventoryLocked(id64(p[0]), true))
+	})
+
+	s.handle("GET", "/bot/leaderboard", func(w http.ResponseWriter, r *http.Request, p []string) {
+		guild := r.URL.Query().Get("guild_id")
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		out := []*BotUser{}
+		for _, b := range s.botUsers {
+			if b.GuildID == guild {
+				out = append(out, b)
+			}
+		}
+		sort.Slice(out, func(i, j int) bool {
+			if out[i].Level != out[j].Level {
+				return out[i].Level > out[j].Level
+			}
+			return out[i].XP > out[j].XP
+		})
+		if limit > 0 && len(out) > limit {
+			out = out[:limit]
+		}
+		writeData(w, out)
+	})
+	s.handle("GET", "/bot/user/{}/{}", func(w http.ResponseWriter, r *http.Request, p []string) {
+		b, ok := s.botUsers[p[0]+"/"+p[1]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		writeData(w, b)
+	})
+}
+
+// ── Applications ─────────────────────────────────────────────────────────────
+
+func (s *Server) registerApplicationRoutes() {
+	s.handle("POST", "/applications", func(w http.ResponseWriter, r *http.Request, p []string) {
+		var a Application
+		if !decode(r, &a) {
+			writeError(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+		// Like ApxApi, a user has at most one open application.
+		if prev := s.latestApplicationLocked(a.UserID); prev != nil && openApplication(prev.Status) {
+			writeError(w, http.StatusConflict, "user already has an open application")
+			return
+		}
+		a.Status, a.CreatedAt = "", ""
+		writeData(w, map[string]int64{"id": s.addApplicationLocked(a)})
+	})
+	s.handle("GET", "/applications/user/{}", func(w http.ResponseWriter, r *http.Request, p []string) {
+		a := s.latestApplicationLocked(id64(p[0]))
+		if a == nil {
+			writeError(w, http.StatusNotFound, "application not found")
+			return
+		}
+		writeData(w, a)
+	})
+	// Like ApxApi, only pending applications can be overwritten.
+	s.handle("PUT", "/applications/user/{}", func(w http.ResponseWriter, r *http.Request, p []string) {
+		a := s.latestApplicationLocked(id64(p[0]))
+		if a == nil {
+			writeError(w, http.StatusNotFound, "application not found")
+			return
+		}
+		if a.Status != "pending" {
+			writeError(w, http.StatusConflict, "application is no longer pending")
+			return
+		}
+		var upd Application
+		if !decode(r, &upd) {
+			writeError(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+		upd.ID, upd.UserID, upd.Status, upd.CreatedAt = a.ID, a.UserID, a.Status, a.CreatedAt
+		*a = upd
+		writeData(w, nil)
+	})
+}
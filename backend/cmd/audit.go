@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AuditEntry is one privileged action recorded in the audit trail. Before and
+// After hold only the fields that changed (full snapshots for create/delete).
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	ActorID    int64           `json:"actor_id"`
+	ActorName  string          `json:"actor_name"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	IP         string          `json:"ip"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// AuditFilter narrows an audit query. Zero values are ignored.
+type AuditFilter struct {
+	ActorName  string
+	Action     string
+	TargetType string
+	TargetID   string
+	From       time.Time
+	To         time.Time
+	Limit      int
+	Offset     int
+}
+
+const (
+	auditPageSize   = 50
+	auditMaxPage    = 200
+	auditExportMax  = 50000
+	auditExportStep = 500
+)
+
+// recordAudit writes an audit entry for the current admin request. Failures
+// are logged but never fail the action itself.
+func recordAudit(apx Store, r *http.Request, action, targetType, targetID string, before, after any) {
+	e := AuditEntry{
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		IP:         getClientIP(r),
+	}
+	if actor := sessionUser(r); actor != nil {
+		e.ActorID, e.ActorName = actor.ID, actor.Username
+	}
+	e.Before, e.After = auditDiff(before, after)
+	// The mutation already happened; record it even if the client has gone.
+	if err := apx.CreateAuditEntry(context.WithoutCancel(r.Context()), e); err != nil {
+		log.Printf("audit %s %s/%s error: %v", action, targetType, targetID, err)
+	}
+}
+
+// auditDiff marshals before/after. When both are JSON objects only the keys
+// of after whose values differ from before are kept, so partial "after"
+// payloads (request bodies) compare cleanly against full records.
+func auditDiff(before, after any) (json.RawMessage, json.RawMessage) {
+	b, a := auditJSON(before), auditJSON(after)
+	if b == nil || a == nil {
+		return b, a
+	}
+	var bm, am map[string]any
+	if json.Unmarshal(b, &bm) != nil || json.Unmarshal(a, &am) != nil {
+		return b, a
+	}
+	changedBefore := map[string]any{}
+	changedAfter := map[string]any{}
+	for k, av := range am {
+		if bv, ok := bm[k]; !ok || !reflect.DeepEqual(bv, av) {
+			changedBefore[k] = bm[k]
+			changedAfter[k] = av
+		}
+	}
+	b, _ = json.Marshal(changedBefore)
+	a, _ = json.Marshal(changedAfter)
+	return b, a
+}
+
+func auditJSON(v any) json.RawMessage {
+	if v == nil || reflect.ValueOf(v).Kind() == reflect.Pointer && reflect.ValueOf(v).IsNil() {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// parseAuditFilter reads the shared query parameters of the list and export
+// endpoints: actor, action, target_type, target_id, from, to (YYYY-MM-DD).
+func parseAuditFilter(r *http.Request) (AuditFilter, error) {
+	q := r.URL.Query()
+	f := AuditFilter{
+		ActorName:  q.Get("actor"),
+		Action:     q.Get("action"),
+		TargetType: q.Get("target_type"),
+		TargetID:   q.Get("target_id"),
+	}
+	if s := q.Get("from"); s != "" {
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return f, fmt.Errorf("invalid from date")
+		}
+		f.From = t
+	}
+	if s := q.Get("to"); s != "" {
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return f, fmt.Errorf("invalid to date")
+		}
+		f.To = t.AddDate(0, 0, 1) // inclusive
+	}
+	return f, nil
+}
+
+// handleAdminAudit serves GET /api/admin/audit?page=&per_page=&<filters>
+func handleAdminAudit(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f, err := parseAuditFilter(r)
+		if err != nil {
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 1 {
+			page = 1
+		}
+		perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+		if perPage < 1 {
+			perPage = auditPageSize
+		}
+		if perPage > auditMaxPage {
+			perPage = auditMaxPage
+		}
+		f.Limit, f.Offset = perPage, (page-1)*perPage
+
+		entries, total, err := apx.GetAuditEntries(r.Context(), f)
+		if err != nil {
+			log.Printf("GetAuditEntries error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"entries":  entries,
+			"total":    total,
+			"page":     page,
+			"per_page": perPage,
+		})
+	}
+}
+
+// csvSafe neutralises cells a spreadsheet would evaluate as a formula.
+func csvSafe(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+// handleAdminAuditExport serves GET /api/admin/audit/export?<filters> as CSV.
+// Entries are streamed page by page; the time window is pinned at the start
+// so entries written meanwhile do not shift the pages.
+func handleAdminAuditExport(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f, err := parseAuditFilter(r)
+		if err != nil {
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if now := time.Now(); f.To.IsZero() || f.To.After(now) {
+			f.To = now
+		}
+		f.Limit = auditExportStep
+
+		// The first page is loaded before writing so an upstream error can
+		// still produce a JSON error instead of an empty file.
+		entries, _, err := apx.GetAuditEntries(r.Context(), f)
+		if err != nil {
+			log.Printf("GetAuditEntries (export) error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="audit-%s.csv"`, time.Now().Format("2006-01-02")))
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"id", "created_at", "actor_id", "actor", "action", "target_type", "target_id", "ip", "before", "after"})
+		for {
+			for _, e := range entries {
+				cw.Write([]string{
+					strconv.FormatInt(e.ID, 10),
+					e.CreatedAt.UTC().Format(time.RFC3339),
+					strconv.FormatInt(e.ActorID, 10),
+					csvSafe(e.ActorName),
+					csvSafe(e.Action),
+					csvSafe(e.TargetType),
+					csvSafe(e.TargetID),
+					csvSafe(e.IP),
+					csvSafe(string(e.Before)),
+					csvSafe(string(e.After)),
+				})
+			}
+			cw.Flush()
+			f.Offset += auditExportStep
+			if len(entries) < auditExportStep || f.Offset >= auditExportMax {
+				return
+			}
+			if entries, _, err = apx.GetAuditEntries(r.Context(), f); err != nil {
+				// Headers are sent; the file ends early.
+				log.Printf("GetAuditEntries (export) error: %v", err)
+				return
+			}
+		}
+	}
+}
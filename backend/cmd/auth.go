@@ -1,13 +1,16 @@
 package main
 
 import (
+	"context"
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"log"
 	"math/big"
+	"net"
 	"net/http"
-	"net/smtp"
+	"net/netip"
 	"os"
 	"regexp"
 	"strings"
@@ -39,17 +42,8 @@ type verifyEmailRequest struct {
 	Code  string `json:"code"`
 }
 
-func handleRegister(apx *ApxClient) http.HandlerFunc {
+func handleRegister(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		if r.Method != http.MethodPost {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-			return
-		}
-
 		var req registerRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			jsonError(w, http.StatusBadRequest, "invalid request body")
@@ -77,7 +71,7 @@ func handleRegister(apx *ApxClient) http.HandlerFunc {
 			return
 		}
 
-		conflict, err := apx.CheckUserConflict(req.Username, req.Email)
+		conflict, err := apx.CheckUserConflict(r.Context(), req.Username, req.Email)
 		if err != nil {
 			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
@@ -96,13 +90,13 @@ func handleRegister(apx *ApxClient) http.HandlerFunc {
 		code := generateVerificationCode()
 		expiresAt := time.Now().Add(15 * time.Minute)
 
-		if err := apx.CreateEmailVerification(req.Email, req.Username, req.Nickname, string(hashed), code, expiresAt); err != nil {
+		if err := apx.CreateEmailVerification(r.Context(), req.Email, req.Username, req.Nickname, string(hashed), code, expiresAt); err != nil {
 			log.Printf("CreateEmailVerification error: %v", err)
 			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
 		}
 
-		if err := sendVerificationEmail(req.Email, req.Username, code); err != nil {
+		if err := sendVerificationEmail(req.Email, requestMailLanguage(r), req.Username, code); err != nil {
 			log.Printf("sendVerificationEmail error: %v", err)
 			jsonError(w, http.StatusInternalServerError, "E-Mail konnte nicht gesendet werden")
 			return
@@ -112,17 +106,8 @@ func handleRegister(apx *ApxClient) http.HandlerFunc {
 	}
 }
 
-func handleVerifyEmail(apx *ApxClient) http.HandlerFunc {
+func handleVerifyEmail(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		if r.Method != http.MethodPost {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-			return
-		}
-
 		var req verifyEmailRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			jsonError(w, http.StatusBadRequest, "invalid request body")
@@ -137,24 +122,24 @@ func handleVerifyEmail(apx *ApxClient) http.HandlerFunc {
 			return
 		}
 
-		v, err := apx.GetEmailVerification(req.Email)
+		v, err := apx.GetEmailVerification(r.Context(), req.Email)
 		if err != nil || v.Code != req.Code {
 			jsonError(w, http.StatusBadRequest, "Ungültiger oder abgelaufener Code")
 			return
 		}
 
-		conflict, err := apx.CheckUserConflict(v.Username, v.Email)
+		conflict, err := apx.CheckUserConflict(r.Context(), v.Username, v.Email)
 		if err != nil {
 			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
 		}
 		if conflict {
-			apx.DeleteEmailVerification(v.Email)
+			apx.DeleteEmailVerification(r.Context(), v.Email)
 			jsonError(w, http.StatusConflict, "Benutzername oder E-Mail bereits vergeben")
 			return
 		}
 
-		userID, err := apx.CreateUser(v.Username, v.Nickname, v.Email, v.Password)
+		userID, err := apx.CreateUser(r.Context(), v.Username, v.Nickname, v.Email, v.Password)
 		if err != nil {
 			if strings.Contains(err.Error(), "409") {
 				jsonError(w, http.StatusConflict, "Benutzername oder E-Mail bereits vergeben")
@@ -165,9 +150,9 @@ func handleVerifyEmail(apx *ApxClient) http.HandlerFunc {
 			return
 		}
 
-		apx.DeleteEmailVerification(v.Email)
+		apx.DeleteEmailVerification(r.Context(), v.Email)
 
-		token, err := apx.CreateSession(userID)
+		token, err := apx.CreateSession(r.Context(), userID)
 		if err != nil {
 			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
@@ -185,27 +170,9 @@ func handleVerifyEmail(apx *ApxClient) http.HandlerFunc {
 	}
 }
 
-func handleChangeEmail(apx *ApxClient) http.HandlerFunc {
+func handleChangeEmail(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		if r.Method != http.MethodPost {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-			return
-		}
-
-		cookie, err := r.Cookie("session")
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
-			return
-		}
-		user, err := apx.GetSessionUser(cookie.Value)
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
-			return
-		}
+		user := sessionUser(r)
 
 		var req struct {
 			Email string `json:"email"`
@@ -225,7 +192,7 @@ func handleChangeEmail(apx *ApxClient) http.HandlerFunc {
 			return
 		}
 
-		if _, err := apx.GetUserByEmail(newEmail); err == nil {
+		if _, err := apx.GetUserByEmail(r.Context(), newEmail); err == nil {
 			jsonError(w, http.StatusConflict, "Diese E-Mail Adresse ist bereits vergeben")
 			return
 		}
@@ -233,13 +200,13 @@ func handleChangeEmail(apx *ApxClient) http.HandlerFunc {
 		code := generateVerificationCode()
 		expiresAt := time.Now().Add(15 * time.Minute)
 
-		if err := apx.CreateEmailChangeRequest(user.ID, newEmail, code, expiresAt); err != nil {
+		if err := apx.CreateEmailChangeRequest(r.Context(), user.ID, newEmail, code, expiresAt); err != nil {
 			log.Printf("CreateEmailChangeRequest error: %v", err)
 			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
 		}
 
-		if err := sendVerificationEmail(newEmail, user.Username, code); err != nil {
+		if err := sendVerificationEmail(newEmail, user.MailLanguage, user.Username, code); err != nil {
 			log.Printf("sendVerificationEmail (change) error: %v", err)
 			jsonError(w, http.StatusInternalServerError, "E-Mail konnte nicht gesendet werden")
 			return
@@ -249,27 +216,9 @@ func handleChangeEmail(apx *ApxClient) http.HandlerFunc {
 	}
 }
 
-func handleVerifyEmailChange(apx *ApxClient) http.HandlerFunc {
+func handleVerifyEmailChange(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		if r.Method != http.MethodPost {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-			return
-		}
-
-		cookie, err := r.Cookie("session")
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
-			return
-		}
-		user, err := apx.GetSessionUser(cookie.Value)
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
-			return
-		}
+		user := sessionUser(r)
 
 		var req struct {
 			Code string `json:"code"`
@@ -279,13 +228,13 @@ func handleVerifyEmailChange(apx *ApxClient) http.HandlerFunc {
 			return
 		}
 
-		pending, err := apx.GetEmailChangeRequest(user.ID)
+		pending, err := apx.GetEmailChangeRequest(r.Context(), user.ID)
 		if err != nil || pending.Code != strings.TrimSpace(req.Code) {
 			jsonError(w, http.StatusBadRequest, "Ungültiger oder abgelaufener Code")
 			return
 		}
 
-		if err := apx.UpdateUserEmail(user.ID, pending.NewEmail); err != nil {
+		if err := apx.UpdateUserEmail(r.Context(), user.ID, pending.NewEmail); err != nil {
 			if strings.Contains(err.Error(), "409") {
 				jsonError(w, http.StatusConflict, "Diese E-Mail Adresse ist bereits vergeben")
 				return
@@ -295,114 +244,146 @@ func handleVerifyEmailChange(apx *ApxClient) http.HandlerFunc {
 			return
 		}
 
-		apx.DeleteEmailChangeRequest(user.ID)
+		apx.DeleteEmailChangeRequest(r.Context(), user.ID)
 		jsonResponse(w, http.StatusOK, map[string]interface{}{"success": true, "email": pending.NewEmail})
 	}
 }
 
-func generateVerificationCode() string {
-	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
-	if err != nil {
-		return "000000"
-	}
-	return fmt.Sprintf("%06d", n.Int64())
-}
+// handleForgotPassword serves POST /api/auth/forgot-password. The response is
+// identical whether or not the address belongs to an account.
+func handleForgotPassword(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Email string `json:"email"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		email := strings.TrimSpace(strings.ToLower(req.Email))
+		if !emailRe.MatchString(email) {
+			jsonError(w, http.StatusBadRequest, "Ungültige E-Mail Adresse")
+			return
+		}
 
-func sendVerificationEmail(to, username, code string) error {
-	host := os.Getenv("SMTP_HOST")
-	if host == "" {
-		log.Printf("[DEV] E-Mail Verifizierungscode für %s (%s): %s", username, to, code)
-		return nil
-	}
+		ipKey, emailKey := "ip:"+getClientIP(r), "reset:"+email
+		if rejectThrottled(w, resetRequestLimiter, ipKey) || rejectThrottled(w, resetRequestLimiter, emailKey) {
+			return
+		}
+		resetRequestLimiter.fail(ipKey)
+		resetRequestLimiter.fail(emailKey)
 
-	port := os.Getenv("SMTP_PORT")
-	if port == "" {
-		port = "587"
-	}
-	user := os.Getenv("SMTP_USER")
-	pass := os.Getenv("SMTP_PASS")
-	from := os.Getenv("SMTP_FROM")
-	if from == "" {
-		from = user
-	}
+		// The lookup and the mail run after the response so that its timing
+		// does not tell whether the address has an account.
+		ctx := context.WithoutCancel(r.Context())
+		go func() {
+			user, err := apx.GetUserByEmail(ctx, email)
+			if err != nil || !user.IsActive {
+				return
+			}
+			code := generateVerificationCode()
+			expiresAt := time.Now().Add(15 * time.Minute)
+			if err := apx.CreatePasswordReset(ctx, user.ID, email, code, expiresAt); err != nil {
+				log.Printf("CreatePasswordReset error: %v", err)
+			} else if err := sendPasswordResetEmail(email, user.MailLanguage, user.Username, code); err != nil {
+				log.Printf("sendPasswordResetEmail error: %v", err)
+			}
+		}()
 
-	plainBody := fmt.Sprintf(
-		"Hi %s,\r\n\r\n"+
-			"Your Team Apx verification code:\r\n\r\n"+
-			"    %s\r\n\r\n"+
-			"This code expires in 15 minutes.\r\n\r\n"+
-			"If you did not request this code, you can safely ignore this email.\r\n\r\n"+
-			"— Team Apx\r\n\r\n"+
-			"Contact us: team.apx.r6@gmail.com",
-		username, code,
-	)
-
-	htmlBody := fmt.Sprintf(
-		`
-<html>
-<body style="font-family:Arial,sans-serif; line-height:1.6; color:#111;">
-
-<table>
-<tr>
-<td style="font-size:18px; font-weight:bold;">
-Hi %s
-</td>
-
-<td style="padding-left:10px;">
-<img src="https://apx-team.com/assets/icons/TEAM_APX-120x120.png" width="50" alt="Team Apx">
-</td>
-</tr>
-</table>
-
-<p>Your Team Apx verification code:</p>
-
-<h2 style="letter-spacing:3px;">%s</h2>
-
-<p>This code expires in 15 minutes.</p>
-
-<p>If you did not request this code, you can safely ignore this email.</p>
-
-<p>— Team Apx</p>
-
-<hr>
-
-<p><u>Contact us:</u> team.apx.r6@gmail.com</p>
-
-</body>
-</html>
-	`, username, code)
-	boundary := "apx-boundary-123"
-
-	msg := []byte(
-		"From: " + from + "\r\n" +
-			"To: " + to + "\r\n" +
-			"Subject: Team Apx - E-Mail Verifizierung\r\n" +
-			"MIME-Version: 1.0\r\n" +
-			"Content-Type: multipart/alternative; boundary=" + boundary + "\r\n\r\n" +
-			"--" + boundary + "\r\n" +
-			"Content-Type: text/plain; charset=UTF-8\r\n\r\n" +
-			plainBody + "\r\n\r\n" +
-			"--" + boundary + "\r\n" +
-			"Content-Type: text/html; charset=UTF-8\r\n\r\n" +
-			htmlBody + "\r\n\r\n" +
-			"--" + boundary + "--",
-	)
-
-	auth := smtp.PlainAuth("", user, pass, host)
-	return smtp.SendMail(host+":"+port, auth, from, []string{to}, msg)
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"pending": true})
+	}
 }
 
-func handleLogin(apx *ApxClient) http.HandlerFunc {
+// handleResetPassword serves POST /api/auth/reset-password. On success every
+// session and trusted device of the account is revoked.
+func handleResetPassword(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
+		var req struct {
+			Email           string `json:"email"`
+			Code            string `json:"code"`
+			Password        string `json:"password"`
+			ConfirmPassword string `json:"confirm_password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
 			return
 		}
-		if r.Method != http.MethodPost {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+
+		req.Email = strings.TrimSpace(strings.ToLower(req.Email))
+		req.Code = strings.TrimSpace(req.Code)
+
+		if req.Email == "" || req.Code == "" {
+			jsonError(w, http.StatusBadRequest, "E-Mail und Code sind erforderlich")
 			return
 		}
+		if len(req.Password) < 8 {
+			jsonError(w, http.StatusBadRequest, "Passwort muss mindestens 8 Zeichen lang sein")
+			return
+		}
+		if req.Password != req.ConfirmPassword {
+			jsonError(w, http.StatusBadRequest, "Passwörter stimmen nicht überein")
+			return
+		}
+
+		ipKey := "ip:" + getClientIP(r)
+		if rejectThrottled(w, loginIPLimiter, ipKey) {
+			return
+		}
+
+		resetKey := "reset:" + req.Email
+		pending, err := apx.GetPasswordReset(r.Context(), req.Email)
+		if err != nil || subtle.ConstantTimeCompare([]byte(pending.Code), []byte(req.Code)) != 1 || time.Now().After(pending.ExpiresAt) {
+			loginIPLimiter.fail(ipKey)
+			if err == nil && codeAttemptLimiter.fail(resetKey) >= maxCodeAttempts {
+				apx.DeletePasswordReset(r.Context(), req.Email)
+				codeAttemptLimiter.reset(resetKey)
+			}
+			jsonError(w, http.StatusBadRequest, "Ungültiger oder abgelaufener Code")
+			return
+		}
+		codeAttemptLimiter.reset(resetKey)
+
+		hashed, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		if err := apx.UpdateUserPassword(r.Context(), pending.UserID, string(hashed)); err != nil {
+			log.Printf("UpdateUserPassword error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+
+		apx.DeletePasswordReset(r.Context(), req.Email)
+		if err := apx.DeleteUserSessions(r.Context(), pending.UserID); err != nil {
+			log.Printf("DeleteUserSessions (reset) error: %v", err)
+		}
+		apx.DeleteAllTrustedDevices(r.Context(), pending.UserID)
+		loginAccountLimiter.reset(accountKey(pending.UserID))
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     "session",
+			Value:    "",
+			Path:     "/",
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+			MaxAge:   -1,
+		})
+		http.SetCookie(w, &http.Cookie{Name: "td_token", Value: "", Path: "/", MaxAge: -1})
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
+
+func generateVerificationCode() string {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "000000"
+	}
+	return fmt.Sprintf("%06d", n.Int64())
+}
 
+func handleLogin(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 		var req loginRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			jsonError(w, http.StatusBadRequest, "invalid request body")
@@ -415,21 +396,43 @@ func handleLogin(apx *ApxClient) http.HandlerFunc {
 			return
 		}
 
-		user, err := apx.GetUserByEmail(strings.ToLower(req.Login))
+		ipKey := "ip:" + getClientIP(r)
+		if rejectThrottled(w, loginIPLimiter, ipKey) {
+			return
+		}
+
+		user, err := apx.GetUserByEmail(r.Context(), strings.ToLower(req.Login))
 		if err != nil {
-			user, err = apx.GetUserByUsername(req.Login)
+			user, err = apx.GetUserByUsername(r.Context(), req.Login)
 		}
 		if err != nil {
+			// Unknown logins are throttled like real accounts so the
+			// response does not reveal whether the account exists.
+			unknownKey := "login:" + strings.ToLower(req.Login)
+			if rejectThrottled(w, loginAccountLimiter, unknownKey) {
+				return
+			}
+			loginIPLimiter.fail(ipKey)
+			loginAccountLimiter.fail(unknownKey)
 			jsonError(w, http.StatusUnauthorized, "Ungültige Anmeldedaten")
 			return
 		}
 
+		acctKey := accountKey(user.ID)
+		if rejectThrottled(w, loginAccountLimiter, acctKey) {
+			return
+		}
+
 		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+			loginIPLimiter.fail(ipKey)
+			if n := loginAccountLimiter.fail(acctKey); n == loginAccountLimiter.policy.LockAfter {
+				log.Printf("login: account %s locked after %d failed attempts (last from %s)", user.Username, n, getClientIP(r))
+			}
 			jsonError(w, http.StatusUnauthorized, "Ungültige Anmeldedaten")
 			return
 		}
 
-		twoFAEnabled, _ := apx.GetUserTwoFASettings(user.ID)
+		twoFAEnabled, _ := apx.GetUserTwoFASettings(r.Context(), user.ID)
 
 		if user.Username == "admin" {
 			twoFAEnabled = false
@@ -438,32 +441,42 @@ func handleLogin(apx *ApxClient) http.HandlerFunc {
 		if twoFAEnabled {
 			skip := false
 			if tdCookie, err := r.Cookie("td_token"); err == nil && tdCookie.Value != "" {
-				if tdUserID, err := apx.GetTrustedDeviceUserID(tdCookie.Value); err == nil && tdUserID == user.ID {
+				if tdUserID, err := apx.GetTrustedDeviceUserID(r.Context(), tdCookie.Value); err == nil && tdUserID == user.ID {
 					skip = true
 				}
 			}
 			if !skip {
+				if cfg, err := apx.GetUser2FAConfig(r.Context(), user.ID); err == nil && cfg.usesTOTP() {
+					pendingToken, err := apx.CreateLogin2FAPending(r.Context(), user.ID, "")
+					if err != nil {
+						jsonError(w, http.StatusInternalServerError, "internal error")
+						return
+					}
+					jsonResponse(w, http.StatusOK, map[string]interface{}{"twofa": true, "token": pendingToken, "method": "totp"})
+					return
+				}
 				code := generateVerificationCode()
-				pendingToken, err := apx.CreateLogin2FAPending(user.ID, code)
+				pendingToken, err := apx.CreateLogin2FAPending(r.Context(), user.ID, code)
 				if err != nil {
 					jsonError(w, http.StatusInternalServerError, "internal error")
 					return
 				}
-				if err := sendVerificationEmail(user.Email, user.Username, code); err != nil {
+				if err := sendVerificationEmail(user.Email, user.MailLanguage, user.Username, code); err != nil {
 					log.Printf("send 2FA email error: %v", err)
 					jsonError(w, http.StatusInternalServerError, "E-Mail konnte nicht gesendet werden")
 					return
 				}
-				jsonResponse(w, http.StatusOK, map[string]interface{}{"twofa": true, "token": pendingToken, "email": user.Email})
+				jsonResponse(w, http.StatusOK, map[string]interface{}{"twofa": true, "token": pendingToken, "email": user.Email, "method": "email"})
 				return
 			}
 		}
 
-		token, err := apx.CreateSession(user.ID)
+		token, err := apx.CreateSession(r.Context(), user.ID)
 		if err != nil {
 			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
 		}
+		loginAccountLimiter.reset(acctKey)
 
 		setSessionCookie(w, token)
 		jsonResponse(w, http.StatusOK, map[string]interface{}{
@@ -477,23 +490,72 @@ func handleLogin(apx *ApxClient) http.HandlerFunc {
 	}
 }
 
-func getClientIP(r *http.Request) string {
-	ip := r.Header.Get("X-Real-IP")
-	if ip == "" {
-		ip = r.Header.Get("X-Forwarded-For")
-		if idx := strings.Index(ip, ","); idx != -1 {
-			ip = strings.TrimSpace(ip[:idx])
+// trustedProxies returns the reverse proxies whose X-Real-IP and
+// X-Forwarded-For headers are believed, from TRUSTED_PROXIES (comma-separated
+// IPs or CIDRs). Without the variable only loopback is trusted, which covers
+// nginx or the Vite dev server on the same host.
+func trustedProxies() []netip.Prefix {
+	spec := os.Getenv("TRUSTED_PROXIES")
+	if strings.TrimSpace(spec) == "" {
+		spec = "127.0.0.0/8,::1/128"
+	}
+	var prefixes []netip.Prefix
+	for _, s := range strings.Split(spec, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			if addr, err := netip.ParseAddr(s); err == nil {
+				prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+				continue
+			}
+		} else if p, err := netip.ParsePrefix(s); err == nil {
+			prefixes = append(prefixes, p.Masked())
+			continue
 		}
+		log.Printf("TRUSTED_PROXIES: ignoring invalid entry %q", s)
 	}
-	if ip == "" {
-		host := r.RemoteAddr
-		if idx := strings.LastIndex(host, ":"); idx != -1 {
-			ip = host[:idx]
-		} else {
-			ip = host
+	return prefixes
+}
+
+func isTrustedProxy(proxies []netip.Prefix, ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range proxies {
+		if p.Contains(addr) {
+			return true
 		}
 	}
-	return strings.TrimSpace(ip)
+	return false
+}
+
+// getClientIP returns the address the request came from. The proxy headers
+// are only used when the connection itself comes from a trusted proxy, so
+// clients cannot pick their own address for rate limits and the audit log.
+func getClientIP(r *http.Request) string {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	proxies := trustedProxies()
+	if !isTrustedProxy(proxies, ip) {
+		return ip
+	}
+	if real := strings.TrimSpace(r.Header.Get("X-Real-IP")); real != "" {
+		return real
+	}
+	// Earlier X-Forwarded-For entries are client-supplied; the client is the
+	// last hop that is not one of our proxies.
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		if hop := strings.TrimSpace(hops[i]); hop != "" && (i == 0 || !isTrustedProxy(proxies, hop)) {
+			return hop
+		}
+	}
+	return ip
 }
 
 func getLocation(ip string) string {
@@ -525,17 +587,8 @@ func getLocation(ip string) string {
 	return g.Country
 }
 
-func handleLoginVerify2FA(apx *ApxClient) http.HandlerFunc {
+func handleLoginVerify2FA(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		if r.Method != http.MethodPost {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-			return
-		}
-
 		var req struct {
 			Token      string `json:"token"`
 			Code       string `json:"code"`
@@ -550,25 +603,50 @@ func handleLoginVerify2FA(apx *ApxClient) http.HandlerFunc {
 		req.Code = strings.TrimSpace(req.Code)
 		req.DeviceName = strings.TrimSpace(req.DeviceName)
 
-		userID, expectedCode, err := apx.GetLogin2FAPending(req.Token)
-		if err != nil || expectedCode != req.Code {
+		ipKey := "ip:" + getClientIP(r)
+		if rejectThrottled(w, loginIPLimiter, ipKey) {
+			return
+		}
+
+		userID, expectedCode, err := apx.GetLogin2FAPending(r.Context(), req.Token)
+		if err != nil {
+			loginIPLimiter.fail(ipKey)
+			jsonError(w, http.StatusUnauthorized, "Ungültiger oder abgelaufener Code")
+			return
+		}
+		acctKey := accountKey(userID)
+		if rejectThrottled(w, loginAccountLimiter, acctKey) {
+			return
+		}
+		tokenKey := "2fa:" + req.Token
+		if !verifySecondFactor(r.Context(), apx, userID, expectedCode, req.Code) {
+			loginIPLimiter.fail(ipKey)
+			loginAccountLimiter.fail(acctKey)
+			if codeAttemptLimiter.fail(tokenKey) >= maxCodeAttempts {
+				apx.DeleteLogin2FAPending(r.Context(), req.Token)
+				codeAttemptLimiter.reset(tokenKey)
+				jsonError(w, http.StatusUnauthorized, "Zu viele Fehlversuche – bitte erneut anmelden")
+				return
+			}
 			jsonError(w, http.StatusUnauthorized, "Ungültiger oder abgelaufener Code")
 			return
 		}
 
-		apx.DeleteLogin2FAPending(req.Token)
+		apx.DeleteLogin2FAPending(r.Context(), req.Token)
+		codeAttemptLimiter.reset(tokenKey)
 
-		token, err := apx.CreateSession(userID)
+		token, err := apx.CreateSession(r.Context(), userID)
 		if err != nil {
 			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
 		}
+		loginAccountLimiter.reset(acctKey)
 		setSessionCookie(w, token)
 
 		if req.DeviceName != "" {
 			ip := getClientIP(r)
 			location := getLocation(ip)
-			if tdToken, err := apx.CreateTrustedDevice(userID, req.DeviceName, ip, location); err == nil {
+			if tdToken, err := apx.CreateTrustedDevice(r.Context(), userID, req.DeviceName, ip, location); err == nil {
 				http.SetCookie(w, &http.Cookie{
 					Name:     "td_token",
 					Value:    tdToken,
@@ -580,7 +658,7 @@ func handleLoginVerify2FA(apx *ApxClient) http.HandlerFunc {
 			}
 		}
 
-		user, err := apx.GetSessionUser(token)
+		user, err := apx.GetSessionUser(r.Context(), token)
 		if err != nil {
 			jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
 			return
@@ -596,152 +674,141 @@ func handleLoginVerify2FA(apx *ApxClient) http.HandlerFunc {
 	}
 }
 
-func handle2FASettings(apx *ApxClient) http.HandlerFunc {
+// handleGet2FASettings serves GET /api/auth/trust-devices
+func handleGet2FASettings(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
+		user := sessionUser(r)
+		twoFAEnabled, _ := apx.GetUserTwoFASettings(r.Context(), user.ID)
+		method, totpEnrolled := "email", false
+		if cfg, err := apx.GetUser2FAConfig(r.Context(), user.ID); err == nil {
+			method, totpEnrolled = cfg.Method, cfg.Confirmed
 		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"two_fa_enabled": twoFAEnabled,
+			"two_fa_method":  method,
+			"totp_enrolled":  totpEnrolled,
+		})
+	}
+}
 
-		cookie, err := r.Cookie("session")
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
-			return
+// handleUpdate2FASettings serves PUT /api/auth/trust-devices
+func handleUpdate2FASettings(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := sessionUser(r)
+		var req struct {
+			TwoFAEnabled bool   `json:"two_fa_enabled"`
+			Method       string `json:"method"`
 		}
-		user, err := apx.GetSessionUser(cookie.Value)
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
 			return
 		}
-
-		switch r.Method {
-		case http.MethodGet:
-			twoFAEnabled, _ := apx.GetUserTwoFASettings(user.ID)
-			jsonResponse(w, http.StatusOK, map[string]bool{"two_fa_enabled": twoFAEnabled})
-		case http.MethodPut:
-			var req struct {
-				TwoFAEnabled bool `json:"two_fa_enabled"`
-			}
-			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-				jsonError(w, http.StatusBadRequest, "invalid request body")
+		if req.Method != "" {
+			if req.Method != "email" && req.Method != "totp" {
+				jsonError(w, http.StatusBadRequest, "invalid method")
 				return
 			}
-			if err := apx.SetUser2FAEnabled(user.ID, req.TwoFAEnabled); err != nil {
+			if req.Method == "totp" {
+				cfg, err := apx.GetUser2FAConfig(r.Context(), user.ID)
+				if err != nil || !cfg.Confirmed {
+					jsonError(w, http.StatusBadRequest, "Authenticator-App ist nicht eingerichtet")
+					return
+				}
+			}
+			if err := apx.SetUser2FAMethod(r.Context(), user.ID, req.Method); err != nil {
 				jsonError(w, http.StatusInternalServerError, "internal error")
 				return
 			}
-			if !req.TwoFAEnabled {
-				apx.DeleteAllTrustedDevices(user.ID)
+		}
+		if err := apx.SetUser2FAEnabled(r.Context(), user.ID, req.TwoFAEnabled); err != nil {
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		if !req.TwoFAEnabled {
+			apx.DeleteAllTrustedDevices(r.Context(), user.ID)
+			http.SetCookie(w, &http.Cookie{
+				Name:   "td_token",
+				Value:  "",
+				Path:   "/",
+				MaxAge: -1,
+			})
+		} else {
+			ip := getClientIP(r)
+			location := getLocation(ip)
+			if tdToken, err := apx.CreateTrustedDevice(r.Context(), user.ID, "Dieses Gerät", ip, location); err == nil {
 				http.SetCookie(w, &http.Cookie{
-					Name:   "td_token",
-					Value:  "",
-					Path:   "/",
-					MaxAge: -1,
+					Name:     "td_token",
+					Value:    tdToken,
+					Path:     "/",
+					HttpOnly: true,
+					SameSite: http.SameSiteLaxMode,
+					MaxAge:   30 * 24 * 60 * 60,
 				})
-			} else {
-				ip := getClientIP(r)
-				location := getLocation(ip)
-				if tdToken, err := apx.CreateTrustedDevice(user.ID, "Dieses Gerät", ip, location); err == nil {
-					http.SetCookie(w, &http.Cookie{
-						Name:     "td_token",
-						Value:    tdToken,
-						Path:     "/",
-						HttpOnly: true,
-						SameSite: http.SameSiteLaxMode,
-						MaxAge:   30 * 24 * 60 * 60,
-					})
-				}
 			}
-			jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
-		default:
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
 		}
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
 	}
 }
 
-func handleDevices(apx *ApxClient) http.HandlerFunc {
+// handleListDevices serves GET /api/auth/devices
+func handleListDevices(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
+		user := sessionUser(r)
+		currentToken := ""
+		if tdCookie, err := r.Cookie("td_token"); err == nil {
+			currentToken = tdCookie.Value
 		}
-
-		cookie, err := r.Cookie("session")
+		devices, err := apx.GetTrustedDevices(r.Context(), user.ID)
 		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
-			return
-		}
-		user, err := apx.GetSessionUser(cookie.Value)
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
+			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
 		}
-
-		switch r.Method {
-		case http.MethodGet:
-			currentToken := ""
-			if tdCookie, err := r.Cookie("td_token"); err == nil {
-				currentToken = tdCookie.Value
-			}
-			devices, err := apx.GetTrustedDevices(user.ID)
-			if err != nil {
-				jsonError(w, http.StatusInternalServerError, "internal error")
-				return
-			}
-			type deviceResp struct {
-				Token      string `json:"token"`
-				DeviceName string `json:"device_name"`
-				Location   string `json:"location"`
-				CreatedAt  string `json:"created_at"`
-				IsCurrent  bool   `json:"is_current"`
-			}
-			resp := make([]deviceResp, 0, len(devices))
-			for _, d := range devices {
-				resp = append(resp, deviceResp{
-					Token:      d.Token,
-					DeviceName: d.DeviceName,
-					Location:   d.Location,
-					CreatedAt:  d.CreatedAt,
-					IsCurrent:  d.Token == currentToken,
-				})
-			}
-			jsonResponse(w, http.StatusOK, map[string]interface{}{"devices": resp})
-
-		case http.MethodDelete:
-			token := r.URL.Query().Get("token")
-			if token == "" {
-				jsonError(w, http.StatusBadRequest, "missing token")
-				return
-			}
-			if err := apx.DeleteTrustedDevice(token, user.ID); err != nil {
-				jsonError(w, http.StatusNotFound, "Gerät nicht gefunden")
-				return
-			}
-			if tdCookie, err := r.Cookie("td_token"); err == nil && tdCookie.Value == token {
-				http.SetCookie(w, &http.Cookie{Name: "td_token", Value: "", Path: "/", MaxAge: -1})
-			}
-			jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
-
-		default:
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-		}
+		type deviceResp struct {
+			Token      string `json:"token"`
+			DeviceName string `json:"device_name"`
+			Location   string `json:"location"`
+			CreatedAt  string `json:"created_at"`
+			IsCurrent  bool   `json:"is_current"`
+		}
+		resp := make([]deviceResp, 0, len(devices))
+		for _, d := range devices {
+			resp = append(resp, deviceResp{
+				Token:      d.Token,
+				DeviceName: d.DeviceName,
+				Location:   d.Location,
+				CreatedAt:  d.CreatedAt,
+				IsCurrent:  d.Token == currentToken,
+			})
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"devices": resp})
 	}
 }
 
-func handleLogout(apx *ApxClient) http.HandlerFunc {
+// handleRevokeDevices serves DELETE /api/auth/devices?token=...
+func handleRevokeDevices(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
+		user := sessionUser(r)
+		token := r.URL.Query().Get("token")
+		if token == "" {
+			jsonError(w, http.StatusBadRequest, "missing token")
 			return
 		}
-		if r.Method != http.MethodPost {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		if err := apx.DeleteTrustedDevice(r.Context(), token, user.ID); err != nil {
+			jsonError(w, http.StatusNotFound, "Gerät nicht gefunden")
 			return
 		}
+		if tdCookie, err := r.Cookie("td_token"); err == nil && tdCookie.Value == token {
+			http.SetCookie(w, &http.Cookie{Name: "td_token", Value: "", Path: "/", MaxAge: -1})
+		}
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
 
+func handleLogout(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 		cookie, err := r.Cookie("session")
 		if err == nil {
-			_ = apx.DeleteSession(cookie.Value)
+			_ = apx.DeleteSession(r.Context(), cookie.Value)
 		}
 
 		http.SetCookie(w, &http.Cookie{
@@ -757,25 +824,10 @@ func handleLogout(apx *ApxClient) http.HandlerFunc {
 	}
 }
 
-func handleMe(apx *ApxClient) http.HandlerFunc {
+func handleMe(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		if r.Method != http.MethodGet {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-			return
-		}
-
-		cookie, err := r.Cookie("session")
-		if err != nil {
-			jsonResponse(w, http.StatusOK, map[string]interface{}{"user": nil})
-			return
-		}
-
-		user, err := apx.GetSessionUser(cookie.Value)
-		if err != nil {
+		user := sessionUser(r)
+		if user == nil {
 			jsonResponse(w, http.StatusOK, map[string]interface{}{"user": nil})
 			return
 		}
@@ -787,6 +839,8 @@ func handleMe(apx *ApxClient) http.HandlerFunc {
 				"nickname":        user.Nickname,
 				"email":           user.Email,
 				"is_admin":        user.IsAdmin,
+				"role":            user.effectiveRole(),
+				"permissions":     user.permissions(),
 				"event_access":    user.EventAccess,
 				"avatar_url":      user.AvatarURL,
 				"banner_url":      user.BannerURL,
@@ -794,32 +848,16 @@ func handleMe(apx *ApxClient) http.HandlerFunc {
 				"show_local_time": user.ShowLocalTime,
 				"social_links":    user.SocialLinks,
 				"bio":             user.Bio,
+				"mail_language":   mailLanguage(user.MailLanguage),
+				"mail_opt_out":    user.MailOptOut,
 			},
 		})
 	}
 }
 
-func handleProfile(apx *ApxClient, uploadDir string) http.HandlerFunc {
+func handleProfile(apx Store, uploadDir string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		if r.Method != http.MethodPut {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-			return
-		}
-
-		cookie, err := r.Cookie("session")
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
-			return
-		}
-		user, err := apx.GetSessionUser(cookie.Value)
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
-			return
-		}
+		user := sessionUser(r)
 
 		var username, nickname, bio string
 		var avatarURL, bannerURL = user.AvatarURL, user.BannerURL
@@ -884,7 +922,7 @@ func handleProfile(apx *ApxClient, uploadDir string) http.HandlerFunc {
 		}
 
 		log.Printf("[PROFILE] Calling UpdateUserProfile: user_id=%d, username=%q, nickname=%q, email=%q, avatarURL=%q, bannerURL=%q, bio=%q", user.ID, username, nickname, user.Email, avatarURL, bannerURL, bio)
-		if err := apx.UpdateUserProfile(user.ID, username, nickname, user.Email, avatarURL, bannerURL, bio); err != nil {
+		if err := apx.UpdateUserProfile(r.Context(), user.ID, username, nickname, user.Email, avatarURL, bannerURL, bio); err != nil {
 			if strings.Contains(err.Error(), "409") {
 				jsonError(w, http.StatusConflict, "Benutzername oder E-Mail bereits vergeben")
 				return
@@ -903,29 +941,11 @@ func handleProfile(apx *ApxClient, uploadDir string) http.HandlerFunc {
 	}
 }
 
-func handleDeactivateAccount(apx *ApxClient) http.HandlerFunc {
+func handleDeactivateAccount(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		if r.Method != http.MethodPost {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-			return
-		}
+		user := sessionUser(r)
 
-		cookie, err := r.Cookie("session")
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
-			return
-		}
-		user, err := apx.GetSessionUser(cookie.Value)
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
-			return
-		}
-
-		if err := apx.DeactivateUser(user.ID); err != nil {
+		if err := apx.DeactivateUser(r.Context(), user.ID); err != nil {
 			log.Printf("DeactivateUser error: %v", err)
 			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
@@ -943,27 +963,9 @@ func handleDeactivateAccount(apx *ApxClient) http.HandlerFunc {
 	}
 }
 
-func handleDeleteAccount(apx *ApxClient) http.HandlerFunc {
+func handleDeleteAccount(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		if r.Method != http.MethodDelete {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-			return
-		}
-
-		cookie, err := r.Cookie("session")
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
-			return
-		}
-		user, err := apx.GetSessionUser(cookie.Value)
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
-			return
-		}
+		user := sessionUser(r)
 
 		var req struct {
 			Username string `json:"username"`
@@ -979,7 +981,7 @@ func handleDeleteAccount(apx *ApxClient) http.HandlerFunc {
 			return
 		}
 
-		u2, err := apx.GetUserByUsernameAny(user.Username)
+		u2, err := apx.GetUserByUsernameAny(r.Context(), user.Username)
 		if err != nil {
 			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
@@ -989,7 +991,7 @@ func handleDeleteAccount(apx *ApxClient) http.HandlerFunc {
 			return
 		}
 
-		if err := apx.DeleteUserByUsername(user.Username); err != nil {
+		if err := apx.DeleteUserByUsername(r.Context(), user.Username); err != nil {
 			log.Printf("DeleteUserByUsername error: %v", err)
 			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
@@ -1007,23 +1009,9 @@ func handleDeleteAccount(apx *ApxClient) http.HandlerFunc {
 	}
 }
 
-func handleProfileSettings(apx *ApxClient) http.HandlerFunc {
+func handleProfileSettings(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		cookie, err := r.Cookie("session")
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
-			return
-		}
-		user, err := apx.GetSessionUser(cookie.Value)
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
-			return
-		}
-
-		if r.Method != http.MethodPut {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-			return
-		}
+		user := sessionUser(r)
 
 		var req struct {
 			Timezone      string   `json:"timezone"`
@@ -1037,7 +1025,7 @@ func handleProfileSettings(apx *ApxClient) http.HandlerFunc {
 		if req.SocialLinks == nil {
 			req.SocialLinks = []string{}
 		}
-		if err := apx.UpdateProfileSettings(user.ID, req.Timezone, req.ShowLocalTime, req.SocialLinks); err != nil {
+		if err := apx.UpdateProfileSettings(r.Context(), user.ID, req.Timezone, req.ShowLocalTime, req.SocialLinks); err != nil {
 			log.Printf("UpdateProfileSettings error: %v", err)
 			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
@@ -1046,6 +1034,33 @@ func handleProfileSettings(apx *ApxClient) http.HandlerFunc {
 	}
 }
 
+// handleMailSettings serves PUT /api/auth/mail-settings. The opt-out only
+// covers notifications; verification and login codes are always sent.
+func handleMailSettings(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := sessionUser(r)
+
+		var req struct {
+			Language string `json:"mail_language"`
+			OptOut   bool   `json:"mail_opt_out"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		if req.Language != MailLangDE && req.Language != MailLangEN {
+			jsonError(w, http.StatusBadRequest, "Ungültige Sprache")
+			return
+		}
+		if err := apx.UpdateMailSettings(r.Context(), user.ID, req.Language, req.OptOut); err != nil {
+			log.Printf("UpdateMailSettings error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
+
 func setSessionCookie(w http.ResponseWriter, token string) {
 	http.SetCookie(w, &http.Cookie{
 		Name:     "session",
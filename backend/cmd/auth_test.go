@@ -0,0 +1,172 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"teamapx-backend/cmd/apxfake"
+)
+
+func TestRegisterAndVerifyEmail(t *testing.T) {
+	e := newTestEnv(t)
+
+	w := e.do("POST", "/api/auth/register", map[string]string{
+		"username": "newbie", "nickname": "Newbie", "email": "Newbie@Example.com",
+		"password": "secret123", "confirm_password": "secret123",
+	})
+	expectStatus(t, w, http.StatusOK)
+	if _, ok := e.fake.UserByUsername("newbie"); ok {
+		t.Fatal("user created before the email was verified")
+	}
+	code, ok := e.fake.Verification("newbie@example.com")
+	if !ok {
+		t.Fatal("no pending verification for the lower-cased email")
+	}
+
+	w = e.do("POST", "/api/auth/verify-email", map[string]string{"email": "newbie@example.com", "code": "000000x"})
+	expectStatus(t, w, http.StatusBadRequest)
+
+	w = e.do("POST", "/api/auth/verify-email", map[string]string{"email": "newbie@example.com", "code": code})
+	expectStatus(t, w, http.StatusCreated)
+	cookie := sessionCookie(w)
+	if cookie == nil {
+		t.Fatal("verification did not start a session")
+	}
+	if _, ok := e.fake.Verification("newbie@example.com"); ok {
+		t.Error("verification not deleted after use")
+	}
+
+	me := decodeBody(t, e.do("GET", "/api/auth/me", nil, cookie))
+	user, _ := me["user"].(map[string]any)
+	if user["username"] != "newbie" || user["email"] != "newbie@example.com" {
+		t.Errorf("me = %v", me)
+	}
+
+	w = e.do("POST", "/api/auth/register", map[string]string{
+		"username": "newbie", "email": "other@example.com",
+		"password": "secret123", "confirm_password": "secret123",
+	})
+	expectStatus(t, w, http.StatusConflict)
+}
+
+func TestLoginWithEmail2FA(t *testing.T) {
+	e := newTestEnv(t)
+	e.addUser(apxfake.User{Username: "mail2fa", Email: "mail2fa@example.com", TwoFAEnabled: true}, "secret123")
+
+	w := e.do("POST", "/api/auth/login", map[string]string{"login": "mail2fa", "password": "wrong-pass"})
+	expectStatus(t, w, http.StatusUnauthorized)
+
+	w = e.do("POST", "/api/auth/login", map[string]string{"login": "MAIL2FA@example.com", "password": "secret123"})
+	expectStatus(t, w, http.StatusOK)
+	if sessionCookie(w) != nil {
+		t.Fatal("session started before the second factor")
+	}
+	body := decodeBody(t, w)
+	token, _ := body["token"].(string)
+	if body["twofa"] != true || body["method"] != "email" || token == "" {
+		t.Fatalf("login = %v", body)
+	}
+	code, ok := e.fake.Pending2FACode(token)
+	if !ok {
+		t.Fatal("no pending 2FA login")
+	}
+
+	w = e.do("POST", "/api/auth/login-2fa", map[string]string{"token": token, "code": "000000x"})
+	expectStatus(t, w, http.StatusUnauthorized)
+
+	w = e.do("POST", "/api/auth/login-2fa", map[string]string{"token": token, "code": code})
+	expectStatus(t, w, http.StatusOK)
+	if sessionCookie(w) == nil {
+		t.Fatal("2FA did not start a session")
+	}
+	if _, ok := e.fake.Pending2FACode(token); ok {
+		t.Error("pending login not deleted after use")
+	}
+}
+
+func TestLoginWithTOTPRejectsReplay(t *testing.T) {
+	e := newTestEnv(t)
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.addUser(apxfake.User{Username: "totp", Email: "totp@example.com", TwoFAEnabled: true,
+		TwoFAMethod: "totp", TOTPSecret: secret, TOTPConfirmed: true}, "secret123")
+	code, err := totpCode(secret, uint64(time.Now().Unix()/totpPeriod))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	login := func() string {
+		t.Helper()
+		w := e.do("POST", "/api/auth/login", map[string]string{"login": "totp", "password": "secret123"})
+		expectStatus(t, w, http.StatusOK)
+		body := decodeBody(t, w)
+		if body["method"] != "totp" {
+			t.Fatalf("login = %v", body)
+		}
+		return body["token"].(string)
+	}
+
+	w := e.do("POST", "/api/auth/login-2fa", map[string]string{"token": login(), "code": code})
+	expectStatus(t, w, http.StatusOK)
+	if sessionCookie(w) == nil {
+		t.Fatal("TOTP login did not start a session")
+	}
+
+	w = e.do("POST", "/api/auth/login-2fa", map[string]string{"token": login(), "code": code})
+	expectStatus(t, w, http.StatusUnauthorized)
+}
+
+func TestTOTPSettingsThrottleWrongCodes(t *testing.T) {
+	e := newTestEnv(t)
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	uid := e.addUser(apxfake.User{Username: "enroll", Email: "enroll@example.com", TOTPSecret: secret}, "secret123")
+	t.Cleanup(func() { codeAttemptLimiter.reset(totpAttemptKey(uid)) })
+	cookie := e.session(uid)
+
+	for range maxCodeAttempts {
+		w := e.do("POST", "/api/auth/totp/confirm", map[string]string{"code": "000000x"}, cookie)
+		expectStatus(t, w, http.StatusBadRequest)
+	}
+	code, err := totpCode(secret, uint64(time.Now().Unix()/totpPeriod))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, e.do("POST", "/api/auth/totp/confirm", map[string]string{"code": code}, cookie), http.StatusTooManyRequests)
+	expectStatus(t, e.do("DELETE", "/api/auth/totp", map[string]string{"password": "secret123"}, cookie), http.StatusTooManyRequests)
+	if u, _ := e.fake.UserByUsername("enroll"); u.TOTPConfirmed {
+		t.Error("TOTP confirmed while locked")
+	}
+}
+
+func TestGetClientIPTrustsOnlyProxies(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8, 192.0.2.7")
+	for _, tc := range []struct {
+		remote, realIP, forwarded, want string
+	}{
+		{"203.0.113.5:1234", "1.2.3.4", "", "203.0.113.5"},
+		{"203.0.113.5:1234", "", "1.2.3.4", "203.0.113.5"},
+		{"10.1.2.3:1234", "1.2.3.4", "", "1.2.3.4"},
+		{"192.0.2.7:1234", "", "6.6.6.6, 1.2.3.4, 10.0.0.2", "1.2.3.4"},
+		{"192.0.2.7:1234", "", "", "192.0.2.7"},
+		{"[2001:db8::1]:1234", "1.2.3.4", "", "2001:db8::1"},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tc.remote
+		if tc.realIP != "" {
+			r.Header.Set("X-Real-IP", tc.realIP)
+		}
+		if tc.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", tc.forwarded)
+		}
+		if got := getClientIP(r); got != tc.want {
+			t.Errorf("getClientIP(%s, X-Real-IP %q, X-Forwarded-For %q) = %q, want %q", tc.remote, tc.realIP, tc.forwarded, got, tc.want)
+		}
+	}
+}
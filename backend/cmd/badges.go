@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -35,276 +36,270 @@ type UserBadge struct {
 	Owned       bool   `json:"owned"`
 }
 
-// handleAdminBadges handles admin badge CRUD: GET (list), POST (create), PUT (update), DELETE (delete).
-func handleAdminBadges(apx *ApxClient) http.HandlerFunc {
+// findBadge returns the badge definition with the given id, or nil.
+func findBadge(ctx context.Context, apx Store, id int64) *Badge {
+	badges, err := apx.GetAllBadges(ctx)
+	if err != nil {
+		return nil
+	}
+	for i := range badges {
+		if badges[i].ID == id {
+			return &badges[i]
+		}
+	}
+	return nil
+}
+
+// handleAdminBadgeList serves GET /api/admin/badges.
+func handleAdminBadgeList(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
+		badges, err := apx.GetAllBadges(r.Context())
+		if err != nil {
+			log.Printf("GetAllBadges error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
 		}
+		if badges == nil {
+			badges = []Badge{}
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"badges": badges})
+	}
+}
 
-		cookie, err := r.Cookie("session")
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
+// handleAdminBadgeCreate serves POST /api/admin/badges.
+func handleAdminBadgeCreate(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Name        string `json:"name"`
+			Description string `json:"description"`
+			Info        string `json:"info"`
+			ImageURL    string `json:"image_url"`
+			Category    string `json:"category"`
+			MaxLevel    int    `json:"max_level"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
 			return
 		}
-		user, err := apx.GetSessionUser(cookie.Value)
-		if err != nil || !user.IsAdmin {
-			jsonError(w, http.StatusForbidden, "Keine Berechtigung")
+		if req.Name == "" {
+			jsonError(w, http.StatusBadRequest, "name required")
 			return
 		}
+		if req.MaxLevel < 0 || req.MaxLevel > 15 {
+			req.MaxLevel = 0
+		}
+		if req.ImageURL == "" {
+			req.ImageURL = "/assets/icons/APX.png"
+		}
+		id, err := apx.CreateBadge(r.Context(), req.Name, req.Description, req.Info, req.ImageURL, req.Category, req.MaxLevel)
+		if err != nil {
+			log.Printf("CreateBadge error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		recordAudit(apx, r, "badge.create", "badge", strconv.FormatInt(id, 10), nil, req)
+		jsonResponse(w, http.StatusCreated, map[string]interface{}{"id": id, "success": true})
+	}
+}
 
-		switch r.Method {
-		case http.MethodGet:
-			badges, err := apx.GetAllBadges()
-			if err != nil {
-				log.Printf("GetAllBadges error: %v", err)
-				jsonError(w, http.StatusInternalServerError, "internal error")
-				return
-			}
-			if badges == nil {
-				badges = []Badge{}
-			}
-			jsonResponse(w, http.StatusOK, map[string]interface{}{"badges": badges})
-
-		case http.MethodPost:
-			var req struct {
-				Name        string `json:"name"`
-				Description string `json:"description"`
-				Info        string `json:"info"`
-				ImageURL    string `json:"image_url"`
-				Category    string `json:"category"`
-				MaxLevel    int    `json:"max_level"`
-			}
-			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-				jsonError(w, http.StatusBadRequest, "invalid request body")
-				return
-			}
-			if req.Name == "" {
-				jsonError(w, http.StatusBadRequest, "name required")
-				return
-			}
-			if req.MaxLevel < 0 || req.MaxLevel > 15 {
-				req.MaxLevel = 0
-			}
-			if req.ImageURL == "" {
-				req.ImageURL = "/assets/icons/APX.png"
-			}
-			id, err := apx.CreateBadge(req.Name, req.Description, req.Info, req.ImageURL, req.Category, req.MaxLevel)
-			if err != nil {
-				log.Printf("CreateBadge error: %v", err)
-				jsonError(w, http.StatusInternalServerError, "internal error")
-				return
-			}
-			jsonResponse(w, http.StatusCreated, map[string]interface{}{"id": id, "success": true})
-
-		case http.MethodPut:
-			var req struct {
-				ID          int64  `json:"id"`
-				Name        string `json:"name"`
-				Description string `json:"description"`
-				Info        string `json:"info"`
-				ImageURL    string `json:"image_url"`
-				Category    string `json:"category"`
-				MaxLevel    int    `json:"max_level"`
-				Available   bool   `json:"available"`
-			}
-			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-				jsonError(w, http.StatusBadRequest, "invalid request body")
-				return
-			}
-			if req.ID == 0 {
-				jsonError(w, http.StatusBadRequest, "id required")
-				return
-			}
-			if req.Name == "" {
-				jsonError(w, http.StatusBadRequest, "name required")
-				return
-			}
-			if req.MaxLevel < 0 || req.MaxLevel > 15 {
-				req.MaxLevel = 0
-			}
-			if req.ImageURL == "" {
-				req.ImageURL = "/assets/icons/APX.png"
-			}
-			if err := apx.UpdateBadge(req.ID, req.Name, req.Description, req.Info, req.ImageURL, req.Category, req.MaxLevel, req.Available); err != nil {
-				log.Printf("UpdateBadge error: %v", err)
-				jsonError(w, http.StatusInternalServerError, "internal error")
-				return
-			}
-			jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
-
-		case http.MethodDelete:
-			var req struct {
-				ID int64 `json:"id"`
-			}
-			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-				jsonError(w, http.StatusBadRequest, "invalid request body")
-				return
-			}
-			if req.ID == 0 {
-				jsonError(w, http.StatusBadRequest, "id required")
-				return
-			}
-			if err := apx.DeleteBadge(req.ID); err != nil {
-				log.Printf("DeleteBadge error: %v", err)
-				jsonError(w, http.StatusInternalServerError, "internal error")
-				return
-			}
-			jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
-
-		default:
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+// handleAdminBadgeUpdate serves PUT /api/admin/badges.
+func handleAdminBadgeUpdate(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID          int64  `json:"id"`
+			Name        string `json:"name"`
+			Description string `json:"description"`
+			Info        string `json:"info"`
+			ImageURL    string `json:"image_url"`
+			Category    string `json:"category"`
+			MaxLevel    int    `json:"max_level"`
+			Available   bool   `json:"available"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
+			return
 		}
+		if req.ID == 0 {
+			jsonError(w, http.StatusBadRequest, "id required")
+			return
+		}
+		if req.Name == "" {
+			jsonError(w, http.StatusBadRequest, "name required")
+			return
+		}
+		if req.MaxLevel < 0 || req.MaxLevel > 15 {
+			req.MaxLevel = 0
+		}
+		if req.ImageURL == "" {
+			req.ImageURL = "/assets/icons/APX.png"
+		}
+		before := findBadge(r.Context(), apx, req.ID)
+		if err := apx.UpdateBadge(r.Context(), req.ID, req.Name, req.Description, req.Info, req.ImageURL, req.Category, req.MaxLevel, req.Available); err != nil {
+			log.Printf("UpdateBadge error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		recordAudit(apx, r, "badge.update", "badge", strconv.FormatInt(req.ID, 10), before, req)
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
 	}
 }
 
-// handleAdminUserBadges handles admin user-badge operations.
-// GET  ?username=X          → list all badges with owned status for user
-// POST body {username, badge_id, level} → upsert
-// DELETE ?username=X&badge_id=Y        → remove
-func handleAdminUserBadges(apx *ApxClient) http.HandlerFunc {
+// handleAdminBadgeDelete serves DELETE /api/admin/badges.
+func handleAdminBadgeDelete(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
+		var req struct {
+			ID int64 `json:"id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		if req.ID == 0 {
+			jsonError(w, http.StatusBadRequest, "id required")
 			return
 		}
+		before := findBadge(r.Context(), apx, req.ID)
+		if err := apx.DeleteBadge(r.Context(), req.ID); err != nil {
+			log.Printf("DeleteBadge error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		recordAudit(apx, r, "badge.delete", "badge", strconv.FormatInt(req.ID, 10), before, nil)
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
 
-		cookie, err := r.Cookie("session")
+// handleAdminUserBadgeList serves GET /api/admin/user-badges?username=X – all badges
+// with owned status for the user.
+func handleAdminUserBadgeList(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := r.URL.Query().Get("username")
+		if username == "" {
+			jsonError(w, http.StatusBadRequest, "username required")
+			return
+		}
+		u, err := apx.GetUserByUsername(r.Context(), username)
 		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
+			jsonError(w, http.StatusNotFound, "user not found")
 			return
 		}
-		user, err := apx.GetSessionUser(cookie.Value)
-		if err != nil || !user.IsAdmin {
-			jsonError(w, http.StatusForbidden, "Keine Berechtigung")
+		// Merge all badges with owned status
+		allBadges, err := apx.GetAllBadges(r.Context())
+		if err != nil {
+			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
 		}
+		ownedBadges, _ := apx.GetUserBadges(r.Context(), u.ID)
+		ownedMap := make(map[int64]UserBadge, len(ownedBadges))
+		for _, ub := range ownedBadges {
+			ownedMap[ub.BadgeID] = ub
+		}
+		result := make([]UserBadge, 0, len(allBadges))
+		for _, b := range allBadges {
+			ub := UserBadge{
+				BadgeID: b.ID, Name: b.Name, Description: b.Description,
+				Info: b.Info, ImageURL: b.ImageURL, MaxLevel: b.MaxLevel,
+				Available: b.Available, Category: b.Category,
+			}
+			if owned, ok := ownedMap[b.ID]; ok {
+				ub.Level = owned.Level
+				ub.Owned = true
+			}
+			result = append(result, ub)
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"badges": result})
+	}
+}
 
-		switch r.Method {
-		case http.MethodGet:
-			username := r.URL.Query().Get("username")
-			if username == "" {
-				jsonError(w, http.StatusBadRequest, "username required")
-				return
-			}
-			u, err := apx.GetUserByUsername(username)
-			if err != nil {
-				jsonError(w, http.StatusNotFound, "user not found")
-				return
-			}
-			// Merge all badges with owned status
-			allBadges, err := apx.GetAllBadges()
-			if err != nil {
-				jsonError(w, http.StatusInternalServerError, "internal error")
-				return
-			}
-			ownedBadges, _ := apx.GetUserBadges(u.ID)
-			ownedMap := make(map[int64]UserBadge, len(ownedBadges))
-			for _, ub := range ownedBadges {
-				ownedMap[ub.BadgeID] = ub
-			}
-			result := make([]UserBadge, 0, len(allBadges))
-			for _, b := range allBadges {
-				ub := UserBadge{
-					BadgeID: b.ID, Name: b.Name, Description: b.Description,
-					Info: b.Info, ImageURL: b.ImageURL, MaxLevel: b.MaxLevel,
-					Available: b.Available, Category: b.Category,
-				}
-				if owned, ok := ownedMap[b.ID]; ok {
-					ub.Level = owned.Level
-					ub.Owned = true
-				}
-				result = append(result, ub)
-			}
-			jsonResponse(w, http.StatusOK, map[string]interface{}{"badges": result})
-
-		case http.MethodPost:
-			var req struct {
-				Username string `json:"username"`
-				BadgeID  int64  `json:"badge_id"`
-				Level    int    `json:"level"`
-			}
-			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-				jsonError(w, http.StatusBadRequest, "invalid request body")
-				return
-			}
-			if req.Username == "" {
-				jsonError(w, http.StatusBadRequest, "username required")
-				return
-			}
-			if req.BadgeID == 0 {
-				jsonError(w, http.StatusBadRequest, "badge_id required")
-				return
-			}
-			if req.Level < 0 {
-				req.Level = 0
-			}
-			u, err := apx.GetUserByUsername(req.Username)
-			if err != nil {
-				jsonError(w, http.StatusNotFound, "user not found")
-				return
-			}
-			if err := apx.UpsertUserBadge(u.ID, req.BadgeID, req.Level); err != nil {
-				log.Printf("UpsertUserBadge error: %v", err)
-				jsonError(w, http.StatusInternalServerError, "internal error")
-				return
-			}
-			jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
-
-		case http.MethodDelete:
-			username := r.URL.Query().Get("username")
-			badgeIDStr := r.URL.Query().Get("badge_id")
-			if username == "" || badgeIDStr == "" {
-				jsonError(w, http.StatusBadRequest, "username and badge_id required")
-				return
-			}
-			badgeID, err := strconv.ParseInt(badgeIDStr, 10, 64)
-			if err != nil || badgeID == 0 {
-				jsonError(w, http.StatusBadRequest, "invalid badge_id")
-				return
-			}
-			u, err := apx.GetUserByUsername(username)
-			if err != nil {
-				jsonError(w, http.StatusNotFound, "user not found")
-				return
-			}
-			if err := apx.RemoveUserBadge(u.ID, badgeID); err != nil {
-				log.Printf("RemoveUserBadge error: %v", err)
-				jsonError(w, http.StatusInternalServerError, "internal error")
-				return
-			}
-			jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
-
-		default:
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+// ownedBadgeState is the audit snapshot of one badge a user owns, or nil if
+// the user does not own it.
+func ownedBadgeState(ctx context.Context, apx Store, userID, badgeID int64) map[string]any {
+	owned, err := apx.GetUserBadges(ctx, userID)
+	if err != nil {
+		return nil
+	}
+	for _, ub := range owned {
+		if ub.BadgeID == badgeID {
+			return map[string]any{"badge_id": ub.BadgeID, "level": ub.Level}
 		}
 	}
+	return nil
 }
 
-// handleAdminBadgeImage handles POST /api/admin/badges/image – uploads a badge image.
-func handleAdminBadgeImage(apx *ApxClient, uploadDir string) http.HandlerFunc {
+// handleAdminUserBadgeAssign serves POST /api/admin/user-badges with body
+// {username, badge_id, level} (upsert).
+func handleAdminUserBadgeAssign(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
+		var req struct {
+			Username string `json:"username"`
+			BadgeID  int64  `json:"badge_id"`
+			Level    int    `json:"level"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
 			return
 		}
-		if r.Method != http.MethodPost {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		if req.Username == "" {
+			jsonError(w, http.StatusBadRequest, "username required")
 			return
 		}
+		if req.BadgeID == 0 {
+			jsonError(w, http.StatusBadRequest, "badge_id required")
+			return
+		}
+		if req.Level < 0 {
+			req.Level = 0
+		}
+		u, err := apx.GetUserByUsername(r.Context(), req.Username)
+		if err != nil {
+			jsonError(w, http.StatusNotFound, "user not found")
+			return
+		}
+		before := ownedBadgeState(r.Context(), apx, u.ID, req.BadgeID)
+		if err := apx.UpsertUserBadge(r.Context(), u.ID, req.BadgeID, req.Level); err != nil {
+			log.Printf("UpsertUserBadge error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		recordAudit(apx, r, "user_badge.assign", "user", u.Username, before,
+			map[string]any{"badge_id": req.BadgeID, "level": req.Level})
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
 
-		cookie, err := r.Cookie("session")
+// handleAdminUserBadgeRemove serves DELETE /api/admin/user-badges?username=X&badge_id=Y.
+func handleAdminUserBadgeRemove(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := r.URL.Query().Get("username")
+		badgeIDStr := r.URL.Query().Get("badge_id")
+		if username == "" || badgeIDStr == "" {
+			jsonError(w, http.StatusBadRequest, "username and badge_id required")
+			return
+		}
+		badgeID, err := strconv.ParseInt(badgeIDStr, 10, 64)
+		if err != nil || badgeID == 0 {
+			jsonError(w, http.StatusBadRequest, "invalid badge_id")
+			return
+		}
+		u, err := apx.GetUserByUsername(r.Context(), username)
 		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
+			jsonError(w, http.StatusNotFound, "user not found")
 			return
 		}
-		user, err := apx.GetSessionUser(cookie.Value)
-		if err != nil || !user.IsAdmin {
-			jsonError(w, http.StatusForbidden, "Keine Berechtigung")
+		before := ownedBadgeState(r.Context(), apx, u.ID, badgeID)
+		if err := apx.RemoveUserBadge(r.Context(), u.ID, badgeID); err != nil {
+			log.Printf("RemoveUserBadge error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
 		}
+		recordAudit(apx, r, "user_badge.remove", "user", u.Username, before, nil)
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
 
+// handleAdminBadgeImage handles POST /api/admin/badges/image – uploads a badge image.
+func handleAdminBadgeImage(apx Store, uploadDir string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 		if err := r.ParseMultipartForm(10 << 20); err != nil {
 			jsonError(w, http.StatusBadRequest, "invalid form data")
 			return
@@ -328,29 +323,11 @@ func handleAdminBadgeImage(apx *ApxClient, uploadDir string) http.HandlerFunc {
 }
 
 // handleUserBadges handles GET /api/badges – returns the current user's badges.
-func handleUserBadges(apx *ApxClient) http.HandlerFunc {
+func handleUserBadges(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		if r.Method != http.MethodGet {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-			return
-		}
-
-		cookie, err := r.Cookie("session")
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
-			return
-		}
-		user, err := apx.GetSessionUser(cookie.Value)
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
-			return
-		}
+		user := sessionUser(r)
 
-		badges, err := apx.GetUserBadges(user.ID)
+		badges, err := apx.GetUserBadges(r.Context(), user.ID)
 		if err != nil {
 			log.Printf("GetUserBadges error: %v", err)
 			jsonError(w, http.StatusInternalServerError, "internal error")
@@ -44,24 +44,15 @@ func generatePKCE() (verifier, challenge string, err error) {
 }
 
 // GET /auth/challengermode
-func handleChallengerModeOAuth(apx *ApxClient) http.HandlerFunc {
+func handleChallengerModeOAuth(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-			return
-		}
 		if cmClientID() == "" {
 			jsonError(w, http.StatusServiceUnavailable, "Challengermode OAuth not configured")
 			return
 		}
 
-		cookie, err := r.Cookie("session")
-		if err != nil {
-			http.Redirect(w, r, "/login", http.StatusFound)
-			return
-		}
-		user, err := apx.GetSessionUser(cookie.Value)
-		if err != nil {
+		user := sessionUser(r)
+		if user == nil {
 			http.Redirect(w, r, "/login", http.StatusFound)
 			return
 		}
@@ -79,7 +70,7 @@ func handleChallengerModeOAuth(apx *ApxClient) http.HandlerFunc {
 		}
 		state := base64.RawURLEncoding.EncodeToString(stateBytes)
 
-		if err := apx.CreateOAuthState(state, user.ID, codeVerifier, time.Now().Add(10*time.Minute)); err != nil {
+		if err := apx.CreateOAuthState(r.Context(), state, user.ID, codeVerifier, time.Now().Add(10*time.Minute)); err != nil {
 			log.Printf("CreateOAuthState (cm) error: %v", err)
 			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
@@ -99,13 +90,8 @@ func handleChallengerModeOAuth(apx *ApxClient) http.HandlerFunc {
 }
 
 // GET /auth/challengermode/callback
-func handleChallengerModeCallback(apx *ApxClient) http.HandlerFunc {
+func handleChallengerModeCallback(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-			return
-		}
-
 		q := r.URL.Query()
 		code := q.Get("code")
 		state := q.Get("state")
@@ -124,7 +110,7 @@ func handleChallengerModeCallback(apx *ApxClient) http.HandlerFunc {
 			return
 		}
 
-		oauthState, err := apx.GetAndDeleteOAuthState(state)
+		oauthState, err := apx.GetAndDeleteOAuthState(r.Context(), state)
 		if err != nil {
 			redirectFail("invalid_state")
 			return
@@ -141,7 +127,7 @@ func handleChallengerModeCallback(apx *ApxClient) http.HandlerFunc {
 		if cmProfileURL == "" {
 			cmProfileURL = "https://www.challengermode.com/users/" + cmUser.id()
 		}
-		if err := apx.UpsertLinkedAccount(oauthState.UserID, "challengermode", cmUser.id(), cmUser.displayName(), cmUser.avatarURL(), cmProfileURL); err != nil {
+		if err := apx.UpsertLinkedAccount(r.Context(), oauthState.UserID, "challengermode", cmUser.id(), cmUser.displayName(), cmUser.avatarURL(), cmProfileURL); err != nil {
 			log.Printf("UpsertLinkedAccount (cm) error: %v", err)
 			redirectFail("db_error")
 			return
@@ -4,3 +4,15 @@ package main
 var TwitchMonitoredUsers = []string{
 	"lixhr6",
 }
+
+// Weights of the player rating engine (see rating.go). Impact and KOST
+// scores are 0–1; clutch points are scaled against the roster's best before
+// the mix turns the three into a 0–100 rating.
+var PlayerRatingWeights = RatingWeights{
+	Impact: ImpactWeights{
+		KillEntry: 1.5, KillTrade: 0.8, KillImpact: 1.2, KillLate: 0.7,
+		DeathEntry: 1.2, DeathTrade: 0.6, DeathLate: 1.0,
+	},
+	Clutch: [5]float64{1, 2, 3, 4, 5},
+	Mix:    RatingMix{Impact: 0.5, KOST: 0.3, Clutch: 0.2},
+}
@@ -6,23 +6,26 @@ import (
 )
 
 type User struct {
-	ID            int64    `json:"id"`
-	Username      string   `json:"username"`
-	Nickname      string   `json:"nickname"`
-	Email         string   `json:"email"`
-	Password      string   `json:"password"`
-	IsAdmin       bool     `json:"is_admin"`
-	IsActive      bool     `json:"is_active"`
-	TwoFAEnabled  bool     `json:"two_fa_enabled"`
-	TrustDevices  bool     `json:"trust_devices"`
-	EventAccess   bool     `json:"event_access"`
-	CreatedAt     string   `json:"created_at"`
-	AvatarURL     string   `json:"avatar_url"`
-	BannerURL     string   `json:"banner_url"`
-	Timezone      string   `json:"timezone"`
-	ShowLocalTime bool     `json:"show_local_time"`
+	ID            int64            `json:"id"`
+	Username      string           `json:"username"`
+	Nickname      string           `json:"nickname"`
+	Email         string           `json:"email"`
+	Password      string           `json:"password"`
+	IsAdmin       bool             `json:"is_admin"`
+	Role          string           `json:"role"`
+	IsActive      bool             `json:"is_active"`
+	TwoFAEnabled  bool             `json:"two_fa_enabled"`
+	TrustDevices  bool             `json:"trust_devices"`
+	EventAccess   bool             `json:"event_access"`
+	CreatedAt     string           `json:"created_at"`
+	AvatarURL     string           `json:"avatar_url"`
+	BannerURL     string           `json:"banner_url"`
+	Timezone      string           `json:"timezone"`
+	ShowLocalTime bool             `json:"show_local_time"`
 	SocialLinks   SocialLinksField `json:"social_links"`
-	Bio           string   `json:"bio"`
+	Bio           string           `json:"bio"`
+	MailLanguage  string           `json:"mail_language"`
+	MailOptOut    bool             `json:"mail_opt_out"`
 }
 
 type SocialLinksField []string
@@ -60,28 +63,100 @@ type TeamMember struct {
 	IsMainRoster bool   `json:"is_main_roster"`
 	PairedWith   *int64 `json:"paired_with"`
 	EventAccess  bool   `json:"event_access"`
-	KillEntry    int    `json:"kill_entry"`
-	KillTrade    int    `json:"kill_trade"`
-	KillImpact   int    `json:"kill_impact"`
-	KillLate     int    `json:"kill_late"`
-	DeathEntry   int    `json:"death_entry"`
-	DeathTrade   int    `json:"death_trade"`
-	DeathLate    int    `json:"death_late"`
-	Clutch1v1    int    `json:"clutch_1v1"`
-	Clutch1v2    int    `json:"clutch_1v2"`
-	Clutch1v3    int    `json:"clutch_1v3"`
-	Clutch1v4    int    `json:"clutch_1v4"`
-	Clutch1v5    int    `json:"clutch_1v5"`
-	ObjPlant     int    `json:"obj_plant"`
-	ObjDefuse    int    `json:"obj_defuse"`
+
+	// Rounds and KostPoints are entered by staff; match stat lines do not
+	// record rounds. KOST is KostPoints per round.
+	Rounds     int `json:"rounds"`
+	KostPoints int `json:"kost_points"`
+
+	// Rating is computed by handlers (see rateRoster); nil without stats.
+	Rating *PlayerRating `json:"rating"`
+
+	// The store keeps the totals from before match tracking; handlers add
+	// the sum of the player's match stat lines (see addMatchTotals).
+	PlayerStats
+}
+
+// PlayerStats are the per-player counters, both as a single match's stat
+// line and as cumulative totals.
+type PlayerStats struct {
+	KillEntry  int `json:"kill_entry"`
+	KillTrade  int `json:"kill_trade"`
+	KillImpact int `json:"kill_impact"`
+	KillLate   int `json:"kill_late"`
+	DeathEntry int `json:"death_entry"`
+	DeathTrade int `json:"death_trade"`
+	DeathLate  int `json:"death_late"`
+	Clutch1v1  int `json:"clutch_1v1"`
+	Clutch1v2  int `json:"clutch_1v2"`
+	Clutch1v3  int `json:"clutch_1v3"`
+	Clutch1v4  int `json:"clutch_1v4"`
+	Clutch1v5  int `json:"clutch_1v5"`
+	ObjPlant   int `json:"obj_plant"`
+	ObjDefuse  int `json:"obj_defuse"`
+}
+
+// Match is a played match with the stat lines of our players.
+type Match struct {
+	ID          int64              `json:"id"`
+	Opponent    string             `json:"opponent"`
+	PlayedAt    string             `json:"played_at"`
+	Map         string             `json:"map"`
+	Competition string             `json:"competition"`
+	ScoreUs     int                `json:"score_us"`
+	ScoreThem   int                `json:"score_them"`
+	CreatedAt   string             `json:"created_at"`
+	Players     []MatchPlayerStats `json:"players"`
+}
+
+// MatchPlayerStats is one team member's stat line in a match.
+type MatchPlayerStats struct {
+	TeamMemberID int64  `json:"team_member_id"`
+	Name         string `json:"name"` // team member name when the match was saved
+	PlayerStats
+}
+
+// PlayerMatchLine is a player's stat line together with the match it belongs
+// to, for per-player history.
+type PlayerMatchLine struct {
+	MatchID     int64  `json:"match_id"`
+	Opponent    string `json:"opponent"`
+	PlayedAt    string `json:"played_at"`
+	Map         string `json:"map"`
+	Competition string `json:"competition"`
+	ScoreUs     int    `json:"score_us"`
+	ScoreThem   int    `json:"score_them"`
+	PlayerStats
 }
 
 type StaffMember struct {
-	ID        int64  `json:"id"`
-	Name      string `json:"name"`
-	Role      string `json:"role"`
-	Username  string `json:"username"`
-	AvatarURL string `json:"avatar_url"`
+	ID           int64  `json:"id"`
+	Name         string `json:"name"`
+	Role         string `json:"role"`
+	Username     string `json:"username"`
+	AvatarURL    string `json:"avatar_url"`
+	DepartmentID *int64 `json:"department_id"` // nil: the role's department
+	ReportsTo    *int64 `json:"reports_to"`    // staff id of the manager
+}
+
+// OrgDepartment is a node of the organization chart. Departments nest via
+// ParentID.
+type OrgDepartment struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	ParentID *int64 `json:"parent_id"`
+	Position int    `json:"position"`
+}
+
+// StaffRole is an admin-managed staff role. Staff members without an own
+// department are placed in the role's department; IsCoach grants coach
+// rights in the scheduler and lineups.
+type StaffRole struct {
+	ID           int64  `json:"id"`
+	Name         string `json:"name"`
+	DepartmentID *int64 `json:"department_id"`
+	IsCoach      bool   `json:"is_coach"`
+	Position     int    `json:"position"`
 }
 
 type EmailVerification struct {
@@ -108,6 +183,173 @@ type ApplicationRecord struct {
 	Availability string `json:"availability"`
 	Status       string `json:"status"`
 	CreatedAt    string `json:"created_at"`
+
+	Username        string `json:"username"` // applicant's account, read-only
+	StatusReason    string `json:"status_reason"`
+	StatusUpdatedAt string `json:"status_updated_at"`
+
+	// Answers holds every answer of the game's form, keyed by field. The
+	// fields above are filled from the well-known keys.
+	Answers json.RawMessage `json:"answers,omitempty"`
+}
+
+// RosterEvent is one entry of the roster history. From and To hold the old
+// and new value of a change: "main"/"sub" for the roster status, the role for
+// role changes and the partner's name for pairings.
+type RosterEvent struct {
+	ID           int64  `json:"id"`
+	TeamMemberID int64  `json:"team_member_id"`
+	Username     string `json:"username"`
+	Name         string `json:"name"`
+	Kind         string `json:"kind"`
+	From         string `json:"from"`
+	To           string `json:"to"`
+	Reason       string `json:"reason"`
+	OccurredAt   string `json:"occurred_at"`
+}
+
+// MapLineup is the lineup sheet of one map: the five starters with their
+// roles on that map and the defensive site setups.
+type MapLineup struct {
+	Map       string         `json:"map"`
+	Players   []LineupPlayer `json:"players"`
+	Sites     []SiteSetup    `json:"sites"`
+	Notes     string         `json:"notes"`
+	UpdatedBy string         `json:"updated_by"`
+	UpdatedAt string         `json:"updated_at"`
+}
+
+type LineupPlayer struct {
+	TeamMemberID int64  `json:"team_member_id"`
+	Name         string `json:"name"`
+	AtkRole      string `json:"atk_role"`
+	DefRole      string `json:"def_role"`
+}
+
+// SiteSetup describes how a bomb site is held and who plays where.
+type SiteSetup struct {
+	Site      string         `json:"site"`
+	Notes     string         `json:"notes"`
+	Positions []SitePosition `json:"positions"`
+}
+
+type SitePosition struct {
+	TeamMemberID int64  `json:"team_member_id"`
+	Position     string `json:"position"`
+}
+
+// AvailabilitySlot is a weekly time range in which a player can play, in the
+// player's timezone. Weekday 0 is Monday; Start and End are "HH:MM" and End
+// is exclusive ("24:00" ends at midnight).
+type AvailabilitySlot struct {
+	Weekday int    `json:"weekday"`
+	Start   string `json:"start"`
+	End     string `json:"end"`
+}
+
+// ScheduleSession is a scrim or practice proposed by a coach.
+type ScheduleSession struct {
+	ID            int64         `json:"id"`
+	Kind          string        `json:"kind"`
+	Title         string        `json:"title"`
+	Opponent      string        `json:"opponent"`
+	StartsAt      string        `json:"starts_at"`
+	EndsAt        string        `json:"ends_at"`
+	Notes         string        `json:"notes"`
+	CreatedBy     int64         `json:"created_by"`
+	CreatedByName string        `json:"created_by_name"`
+	CreatedAt     string        `json:"created_at"`
+	RSVPs         []SessionRSVP `json:"rsvps"`
+}
+
+// SessionRSVP is a player's answer to a session.
+type SessionRSVP struct {
+	SessionID int64  `json:"session_id"`
+	UserID    int64  `json:"user_id"`
+	Username  string `json:"username"`
+	Response  string `json:"response"`
+	Comment   string `json:"comment"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// WeeklySnapshot is the statistics snapshot of one ISO week. StartsAt and
+// EndsAt bound the week (Monday 00:00 UTC, end exclusive).
+type WeeklySnapshot struct {
+	Week      string      `json:"week"`
+	StartsAt  string      `json:"starts_at"`
+	EndsAt    string      `json:"ends_at"`
+	Stats     WeeklyStats `json:"stats"`
+	CreatedAt string      `json:"created_at,omitempty"`
+}
+
+// ApplicationForm is the application form schema of one game.
+type ApplicationForm struct {
+	Game      string      `json:"game"`
+	Fields    []FormField `json:"fields"`
+	UpdatedAt string      `json:"updated_at,omitempty"`
+}
+
+// FormField is one question of an application form. Min and Max bound the
+// value of number fields, the length of text fields and the number of
+// choices of multiselect fields.
+type FormField struct {
+	Key      string       `json:"key"`
+	Type     string       `json:"type"`
+	LabelDe  string       `json:"label_de"`
+	LabelEn  string       `json:"label_en"`
+	Required bool         `json:"required"`
+	Min      *float64     `json:"min,omitempty"`
+	Max      *float64     `json:"max,omitempty"`
+	Options  []FormOption `json:"options,omitempty"`
+}
+
+// FormOption is one choice of a select or multiselect field.
+type FormOption struct {
+	Value   string `json:"value"`
+	LabelDe string `json:"label_de"`
+	LabelEn string `json:"label_en"`
+}
+
+// ApplicationStatusChange is one transition in an application's history.
+type ApplicationStatusChange struct {
+	ID            int64  `json:"id"`
+	ApplicationID int64  `json:"application_id"`
+	FromStatus    string `json:"from_status"`
+	ToStatus      string `json:"to_status"`
+	Reason        string `json:"reason"`
+	ActorID       int64  `json:"actor_id"`
+	ActorName     string `json:"actor_name"`
+	CreatedAt     string `json:"created_at"`
+}
+
+// ApplicationNote is an internal reviewer comment on an application.
+type ApplicationNote struct {
+	ID            int64  `json:"id"`
+	ApplicationID int64  `json:"application_id"`
+	AuthorID      int64  `json:"author_id"`
+	AuthorName    string `json:"author_name"`
+	Body          string `json:"body"`
+	CreatedAt     string `json:"created_at"`
+}
+
+// ApplicationRating is one reviewer's 1–5 scores for an application.
+type ApplicationRating struct {
+	ApplicationID int64  `json:"application_id"`
+	ReviewerID    int64  `json:"reviewer_id"`
+	ReviewerName  string `json:"reviewer_name"`
+	Rank          int    `json:"rank"`
+	Experience    int    `json:"experience"`
+	Availability  int    `json:"availability"`
+	Communication int    `json:"communication"`
+	UpdatedAt     string `json:"updated_at"`
+}
+
+// ApplicationScore is the mean of all criteria over all ratings of an
+// application.
+type ApplicationScore struct {
+	ApplicationID int64   `json:"application_id"`
+	Score         float64 `json:"score"`
+	RatingCount   int     `json:"rating_count"`
 }
 
 type EmailChangeRequest struct {
@@ -117,6 +359,13 @@ type EmailChangeRequest struct {
 	ExpiresAt time.Time `json:"expires_at"`
 }
 
+type PasswordReset struct {
+	UserID    int64     `json:"user_id"`
+	Email     string    `json:"email"`
+	Code      string    `json:"code"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
 type LinkedAccount struct {
 	Service    string `json:"service"`
 	ServiceID  string `json:"service_id"`
@@ -125,25 +125,15 @@ func linksPageURL() string {
 }
 
 // GET /auth/discord
-func handleDiscordOAuth(apx *ApxClient) http.HandlerFunc {
+func handleDiscordOAuth(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-			return
-		}
-
 		if discordClientID() == "" {
 			jsonError(w, http.StatusServiceUnavailable, "Discord OAuth not configured")
 			return
 		}
 
-		cookie, err := r.Cookie("session")
-		if err != nil {
-			http.Redirect(w, r, "/login", http.StatusFound)
-			return
-		}
-		user, err := apx.GetSessionUser(cookie.Value)
-		if err != nil {
+		user := sessionUser(r)
+		if user == nil {
 			http.Redirect(w, r, "/login", http.StatusFound)
 			return
 		}
@@ -155,7 +145,7 @@ func handleDiscordOAuth(apx *ApxClient) http.HandlerFunc {
 		}
 		state := hex.EncodeToString(b)
 
-		if err := apx.CreateOAuthState(state, user.ID, "", time.Now().Add(10*time.Minute)); err != nil {
+		if err := apx.CreateOAuthState(r.Context(), state, user.ID, "", time.Now().Add(10*time.Minute)); err != nil {
 			log.Printf("CreateOAuthState error: %v", err)
 			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
@@ -173,13 +163,8 @@ func handleDiscordOAuth(apx *ApxClient) http.HandlerFunc {
 }
 
 // GET /auth/discord/callback
-func handleDiscordCallback(apx *ApxClient) http.HandlerFunc {
+func handleDiscordCallback(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-			return
-		}
-
 		q := r.URL.Query()
 		code := q.Get("code")
 		state := q.Get("state")
@@ -198,7 +183,7 @@ func handleDiscordCallback(apx *ApxClient) http.HandlerFunc {
 			return
 		}
 
-		oauthState, err := apx.GetAndDeleteOAuthState(state)
+		oauthState, err := apx.GetAndDeleteOAuthState(r.Context(), state)
 		if err != nil {
 			redirectFail("invalid_state")
 			return
@@ -222,14 +207,14 @@ func handleDiscordCallback(apx *ApxClient) http.HandlerFunc {
 		if discordUser.GlobalName != "" {
 			displayName = discordUser.GlobalName
 		}
-		if err := apx.UpsertLinkedAccount(oauthState.UserID, "discord", discordUser.ID, displayName, discordUser.AvatarURL(), ""); err != nil {
+		if err := apx.UpsertLinkedAccount(r.Context(), oauthState.UserID, "discord", discordUser.ID, displayName, discordUser.AvatarURL(), ""); err != nil {
 			log.Printf("UpsertLinkedAccount error: %v", err)
 			redirectFail("db_error")
 			return
 		}
 
 		// Sync apx_id into bot_users
-		if err := apx.UpdateBotUserApxID(discordUser.ID, oauthState.UserID); err != nil {
+		if err := apx.UpdateBotUserApxID(r.Context(), discordUser.ID, oauthState.UserID); err != nil {
 			log.Printf("UpdateBotUserApxID error: %v", err)
 		}
 
@@ -238,15 +223,15 @@ func handleDiscordCallback(apx *ApxClient) http.HandlerFunc {
 
 		// Sync rank role to progression_users immediately on Discord link
 		if discordData.ApxCommunityGuild && discordData.Rank != "" {
-			if err := apx.UpdateProgressionUserRank(oauthState.UserID, discordUser.ID, discordData.Rank); err != nil {
+			if err := apx.UpdateProgressionUserRank(r.Context(), oauthState.UserID, discordUser.ID, discordData.Rank); err != nil {
 				log.Printf("UpdateProgressionUserRank at Discord link: %v", err)
 			}
 		}
 
 		// Award "APX MEMBER" badge if user is in the APX community guild
 		if discordData.ApxCommunityGuild {
-			if badgeID, err := apx.GetBadgeIDByName("APX MEMBER"); err == nil {
-				if err := apx.UpsertUserBadge(oauthState.UserID, badgeID, 1); err != nil {
+			if badgeID, err := apx.GetBadgeIDByName(r.Context(), "APX MEMBER"); err == nil {
+				if err := apx.UpsertUserBadge(r.Context(), oauthState.UserID, badgeID, 1); err != nil {
 					log.Printf("UpsertUserBadge APX MEMBER error: %v", err)
 				}
 			} else {
@@ -32,13 +32,9 @@ type EventParticipant struct {
 }
 
 // handlePublicEvents serves GET /api/events — public list.
-func handlePublicEvents(apx *ApxClient) http.HandlerFunc {
+func handlePublicEvents(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-			return
-		}
-		events, err := apx.GetAllEvents()
+		events, err := apx.GetAllEvents(r.Context())
 		if err != nil {
 			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
@@ -47,183 +43,160 @@ func handlePublicEvents(apx *ApxClient) http.HandlerFunc {
 			events = []Event{}
 		}
 		// Mark joined events for authenticated users
-		if cookie, err := r.Cookie("session"); err == nil {
-			if user, err := apx.GetSessionUser(cookie.Value); err == nil {
-				for i := range events {
-					events[i].IsJoined, _ = apx.IsEventParticipant(user.ID, events[i].ID)
-				}
+		if user := sessionUser(r); user != nil && len(events) > 0 {
+			ids := make([]string, len(events))
+			for i := range events {
+				ids[i] = events[i].ID
+			}
+			joined, _ := apx.JoinedEventIDs(r.Context(), user.ID, ids)
+			for i := range events {
+				events[i].IsJoined = joined[events[i].ID]
 			}
 		}
 		jsonResponse(w, http.StatusOK, map[string]interface{}{"events": events})
 	}
 }
 
-// handleEventRoutes handles:
-//
-//	GET  /api/events/{id}       — public event detail + participants
-//	POST /api/events/{id}/join  — authenticated join
-//	POST /api/events/{id}/leave — authenticated leave
-func handleEventRoutes(apx *ApxClient) http.HandlerFunc {
+// handleGetEvent serves GET /api/events/{id} — public event detail + participants.
+func handleGetEvent(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		path := strings.TrimPrefix(r.URL.Path, "/api/events/")
-		parts := strings.SplitN(path, "/", 2)
-		eventID := parts[0]
-		action := ""
-		if len(parts) == 2 {
-			action = parts[1]
+		eventID := r.PathValue("id")
+		ev, err := apx.GetEventByID(r.Context(), eventID)
+		if err != nil {
+			if err == errNotFound {
+				jsonError(w, http.StatusNotFound, "event not found")
+				return
+			}
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
 		}
-
-		if eventID == "" {
-			jsonError(w, http.StatusBadRequest, "missing event id")
+		participants, err := apx.GetEventParticipants(r.Context(), eventID)
+		if err != nil {
+			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
 		}
-
-		switch {
-		case r.Method == http.MethodGet && action == "":
-			ev, err := apx.GetEventByID(eventID)
-			if err != nil {
-				if err == errNotFound {
-					jsonError(w, http.StatusNotFound, "event not found")
-					return
-				}
-				jsonError(w, http.StatusInternalServerError, "internal error")
-				return
-			}
-			participants, err := apx.GetEventParticipants(eventID)
-			if err != nil {
-				jsonError(w, http.StatusInternalServerError, "internal error")
-				return
-			}
-			if participants == nil {
-				participants = []EventParticipant{}
-			}
-			if cookie, err := r.Cookie("session"); err == nil {
-				if user, err := apx.GetSessionUser(cookie.Value); err == nil {
-					ev.IsJoined, _ = apx.IsEventParticipant(user.ID, eventID)
-				}
-			}
-			jsonResponse(w, http.StatusOK, map[string]interface{}{
-				"event":        ev,
-				"participants": participants,
-			})
-
-		case r.Method == http.MethodPost && (action == "join" || action == "leave"):
-			cookie, err := r.Cookie("session")
-			if err != nil {
-				jsonError(w, http.StatusUnauthorized, "not logged in")
-				return
-			}
-			user, err := apx.GetSessionUser(cookie.Value)
-			if err != nil {
-				jsonError(w, http.StatusUnauthorized, "invalid session")
-				return
-			}
-
-			if action == "join" {
-				if !user.EventAccess {
-					jsonError(w, http.StatusForbidden, "no event access")
-					return
-				}
-				if err := apx.JoinEvent(user.ID, eventID); err != nil {
-					jsonError(w, http.StatusConflict, err.Error())
-					return
-				}
-			} else {
-				if err := apx.LeaveEvent(user.ID, eventID); err != nil {
-					jsonError(w, http.StatusInternalServerError, "internal error")
-					return
-				}
-			}
-			jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
-
-		default:
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		if participants == nil {
+			participants = []EventParticipant{}
+		}
+		if user := sessionUser(r); user != nil {
+			ev.IsJoined, _ = apx.IsEventParticipant(r.Context(), user.ID, eventID)
 		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"event":        ev,
+			"participants": participants,
+		})
 	}
 }
 
-// handleAdminEvents serves GET/POST/PUT/DELETE /api/admin/events — admin only.
-func handleAdminEvents(apx *ApxClient) http.HandlerFunc {
+// handleJoinEvent serves POST /api/events/{id}/join — requires event access.
+func handleJoinEvent(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		cookie, err := r.Cookie("session")
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
+		user := sessionUser(r)
+		if !user.EventAccess {
+			jsonError(w, http.StatusForbidden, "no event access")
 			return
 		}
-		user, err := apx.GetSessionUser(cookie.Value)
-		if err != nil || !user.IsAdmin {
-			jsonError(w, http.StatusForbidden, "Keine Berechtigung")
+		if err := apx.JoinEvent(r.Context(), user.ID, r.PathValue("id")); err != nil {
+			jsonError(w, http.StatusConflict, err.Error())
 			return
 		}
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
 
-		switch r.Method {
-		case http.MethodGet:
-			events, err := apx.GetAllEvents()
-			if err != nil {
-				jsonError(w, http.StatusInternalServerError, "internal error")
-				return
-			}
-			if events == nil {
-				events = []Event{}
-			}
-			jsonResponse(w, http.StatusOK, map[string]interface{}{"events": events})
+// handleLeaveEvent serves POST /api/events/{id}/leave
+func handleLeaveEvent(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := apx.LeaveEvent(r.Context(), sessionUser(r).ID, r.PathValue("id")); err != nil {
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
 
-		case http.MethodPost:
-			var req CreateEventInput
-			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-				jsonError(w, http.StatusBadRequest, "invalid body")
-				return
-			}
-			if strings.TrimSpace(req.Name) == "" || strings.TrimSpace(req.Date) == "" {
-				jsonError(w, http.StatusBadRequest, "name and date required")
-				return
-			}
-			if req.Status == "" {
-				req.Status = "upcoming"
-			}
-			ev, err := apx.CreateEvent(req)
-			if err != nil {
-				jsonError(w, http.StatusInternalServerError, "internal error")
-				return
-			}
-			jsonResponse(w, http.StatusCreated, map[string]string{"id": ev.ID})
+// handleAdminEventList serves GET /api/admin/events.
+func handleAdminEventList(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		events, err := apx.GetAllEvents(r.Context())
+		if err != nil {
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		if events == nil {
+			events = []Event{}
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"events": events})
+	}
+}
 
-		case http.MethodPut:
-			var ev Event
-			if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
-				jsonError(w, http.StatusBadRequest, "invalid body")
-				return
-			}
-			if ev.ID == "" {
-				jsonError(w, http.StatusBadRequest, "id required")
-				return
-			}
-			if err := apx.UpdateEvent(&ev); err != nil {
-				jsonError(w, http.StatusInternalServerError, "internal error")
-				return
-			}
-			jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+// handleAdminEventCreate serves POST /api/admin/events.
+func handleAdminEventCreate(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req CreateEventInput
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+		if strings.TrimSpace(req.Name) == "" || strings.TrimSpace(req.Date) == "" {
+			jsonError(w, http.StatusBadRequest, "name and date required")
+			return
+		}
+		if req.Status == "" {
+			req.Status = "upcoming"
+		}
+		ev, err := apx.CreateEvent(r.Context(), req)
+		if err != nil {
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		recordAudit(apx, r, "event.create", "event", ev.ID, nil, req)
+		jsonResponse(w, http.StatusCreated, map[string]string{"id": ev.ID})
+	}
+}
 
-		case http.MethodDelete:
-			var req struct {
-				ID string `json:"id"`
-			}
-			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-				jsonError(w, http.StatusBadRequest, "invalid body")
-				return
-			}
-			if req.ID == "" {
-				jsonError(w, http.StatusBadRequest, "id required")
-				return
-			}
-			if err := apx.DeleteEvent(req.ID); err != nil {
-				jsonError(w, http.StatusInternalServerError, "internal error")
-				return
-			}
-			jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+// handleAdminEventUpdate serves PUT /api/admin/events.
+func handleAdminEventUpdate(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var ev Event
+		if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+		if ev.ID == "" {
+			jsonError(w, http.StatusBadRequest, "id required")
+			return
+		}
+		before, _ := apx.GetEventByID(r.Context(), ev.ID)
+		if err := apx.UpdateEvent(r.Context(), &ev); err != nil {
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		recordAudit(apx, r, "event.update", "event", ev.ID, before, ev)
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
 
-		default:
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+// handleAdminEventDelete serves DELETE /api/admin/events.
+func handleAdminEventDelete(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID string `json:"id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+		if req.ID == "" {
+			jsonError(w, http.StatusBadRequest, "id required")
+			return
+		}
+		before, _ := apx.GetEventByID(r.Context(), req.ID)
+		if err := apx.DeleteEvent(r.Context(), req.ID); err != nil {
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
 		}
+		recordAudit(apx, r, "event.delete", "event", req.ID, before, nil)
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
 	}
 }
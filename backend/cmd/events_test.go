@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"teamapx-backend/cmd/apxfake"
+)
+
+func TestJoinAndLeaveEvent(t *testing.T) {
+	e := newTestEnv(t)
+	alice := e.addUser(apxfake.User{Username: "alice", Email: "alice@example.com", EventAccess: true}, "secret123")
+	bob := e.addUser(apxfake.User{Username: "bob", Email: "bob@example.com", EventAccess: true}, "secret123")
+	carol := e.addUser(apxfake.User{Username: "carol", Email: "carol@example.com"}, "secret123")
+	id := e.fake.AddEvent(apxfake.Event{Name: "Cup", Date: "2026-11-01", MaxParticipants: 1})
+	aliceSession, bobSession := e.session(alice), e.session(bob)
+
+	expectStatus(t, e.do("POST", "/api/events/"+id+"/join", nil), http.StatusUnauthorized)
+	expectStatus(t, e.do("POST", "/api/events/"+id+"/join", nil, e.session(carol)), http.StatusForbidden)
+
+	expectStatus(t, e.do("POST", "/api/events/"+id+"/join", nil, aliceSession), http.StatusOK)
+	if got := e.fake.Participants(id); !slices.Equal(got, []int64{alice}) {
+		t.Fatalf("participants = %v, want [%d]", got, alice)
+	}
+	expectStatus(t, e.do("POST", "/api/events/"+id+"/join", nil, aliceSession), http.StatusConflict)
+	expectStatus(t, e.do("POST", "/api/events/"+id+"/join", nil, bobSession), http.StatusConflict) // full
+
+	body := decodeBody(t, e.do("GET", "/api/events/"+id, nil, aliceSession))
+	if ev, _ := body["event"].(map[string]any); ev["is_joined"] != true {
+		t.Errorf("event = %v, want is_joined", body["event"])
+	}
+
+	expectStatus(t, e.do("POST", "/api/events/"+id+"/leave", nil, aliceSession), http.StatusOK)
+	if got := e.fake.Participants(id); len(got) != 0 {
+		t.Fatalf("participants after leave = %v", got)
+	}
+	expectStatus(t, e.do("POST", "/api/events/"+id+"/join", nil, bobSession), http.StatusOK)
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Form field types.
+const (
+	FieldText        = "text"
+	FieldTextarea    = "textarea"
+	FieldNumber      = "number"
+	FieldSelect      = "select"
+	FieldMultiselect = "multiselect"
+	FieldCheckbox    = "checkbox"
+)
+
+var formFieldTypes = []string{FieldText, FieldTextarea, FieldNumber, FieldSelect, FieldMultiselect, FieldCheckbox}
+
+var formFieldKeyRe = regexp.MustCompile(`^[a-z][a-z0-9_]{0,39}$`)
+
+const maxFormFields = 50
+
+func floatPtr(f float64) *float64 { return &f }
+
+// defaultApplicationForm is used for every game without a stored form. It
+// reproduces the original Rainbow Six application.
+var defaultApplicationForm = ApplicationForm{
+	Fields: []FormField{
+		{Key: "name", Type: FieldText, LabelDe: "Name", LabelEn: "Name", Required: true, Max: floatPtr(20)},
+		{Key: "age", Type: FieldNumber, LabelDe: "Alter", LabelEn: "Age", Required: true, Min: floatPtr(13), Max: floatPtr(30)},
+		{Key: "discord", Type: FieldText, LabelDe: "Discord", LabelEn: "Discord", Required: true, Min: floatPtr(2), Max: floatPtr(20)},
+		{Key: "rank", Type: FieldSelect, LabelDe: "Rang", LabelEn: "Rank", Required: true, Options: rankOptions()},
+		{Key: "attacker_role", Type: FieldText, LabelDe: "Angreifer-Rolle", LabelEn: "Attacker role", Required: true},
+		{Key: "defender_role", Type: FieldText, LabelDe: "Verteidiger-Rolle", LabelEn: "Defender role", Required: true},
+		{Key: "experience", Type: FieldTextarea, LabelDe: "Erfahrung", LabelEn: "Experience", Required: true},
+		{Key: "motivation", Type: FieldTextarea, LabelDe: "Motivation", LabelEn: "Motivation", Required: true},
+		{Key: "availability", Type: FieldTextarea, LabelDe: "Verfügbarkeit", LabelEn: "Availability"},
+	},
+}
+
+func rankOptions() []FormOption {
+	opts := make([]FormOption, 0, len(applicationRanks))
+	for _, r := range slices.Backward(applicationRanks) {
+		opts = append(opts, FormOption{Value: r, LabelDe: r, LabelEn: r})
+	}
+	return opts
+}
+
+// applicationFormFor returns the stored form of game, or the default form.
+func applicationFormFor(r *http.Request, apx Store, game string) (*ApplicationForm, error) {
+	form, err := apx.GetApplicationForm(r.Context(), game)
+	if errors.Is(err, errNotFound) {
+		def := defaultApplicationForm
+		def.Game = game
+		return &def, nil
+	}
+	return form, err
+}
+
+// checkFormSchema returns the problems of an admin-defined form, or nil.
+func checkFormSchema(fields []FormField) []string {
+	var problems []string
+	if len(fields) == 0 {
+		return []string{"Formular braucht mindestens ein Feld"}
+	}
+	if len(fields) > maxFormFields {
+		problems = append(problems, fmt.Sprintf("maximal %d Felder", maxFormFields))
+	}
+	seen := map[string]bool{}
+	for i, f := range fields {
+		name := f.Key
+		if name == "" {
+			name = "#" + strconv.Itoa(i+1)
+		}
+		if !formFieldKeyRe.MatchString(f.Key) || f.Key == "game" || f.Key == "answers" {
+			problems = append(problems, name+": ungültiger Schlüssel")
+		} else if seen[f.Key] {
+			problems = append(problems, name+": Schlüssel doppelt")
+		}
+		seen[f.Key] = true
+		if !slices.Contains(formFieldTypes, f.Type) {
+			problems = append(problems, name+": ungültiger Typ")
+		}
+		if strings.TrimSpace(f.LabelDe) == "" || strings.TrimSpace(f.LabelEn) == "" {
+			problems = append(problems, name+": Beschriftung DE/EN erforderlich")
+		}
+		isChoice := f.Type == FieldSelect || f.Type == FieldMultiselect
+		if isChoice && len(f.Options) == 0 {
+			problems = append(problems, name+": Optionen erforderlich")
+		}
+		if !isChoice && len(f.Options) > 0 {
+			problems = append(problems, name+": Optionen nur bei Auswahlfeldern")
+		}
+		values := map[string]bool{}
+		for _, o := range f.Options {
+			if o.Value == "" || values[o.Value] {
+				problems = append(problems, name+": Optionswerte müssen eindeutig und nicht leer sein")
+				break
+			}
+			values[o.Value] = true
+			if strings.TrimSpace(o.LabelDe) == "" || strings.TrimSpace(o.LabelEn) == "" {
+				problems = append(problems, name+": Beschriftung DE/EN für jede Option erforderlich")
+				break
+			}
+		}
+		if (f.Min != nil || f.Max != nil) && (f.Type == FieldSelect || f.Type == FieldCheckbox) {
+			problems = append(problems, name+": min/max nicht möglich")
+		}
+		if f.Min != nil && f.Max != nil && *f.Min > *f.Max {
+			problems = append(problems, name+": min größer als max")
+		}
+		if f.Type != FieldNumber && f.Min != nil && *f.Min < 0 {
+			problems = append(problems, name+": min darf nicht negativ sein")
+		}
+	}
+	return problems
+}
+
+// formatBound formats a min/max bound without trailing zeros.
+func formatBound(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func rangeHint(min, max *float64, unit string) string {
+	switch {
+	case min != nil && max != nil:
+		return fmt.Sprintf(" (%s–%s%s)", formatBound(*min), formatBound(*max), unit)
+	case max != nil:
+		return fmt.Sprintf(" (max %s%s)", formatBound(*max), unit)
+	case min != nil:
+		return fmt.Sprintf(" (min %s%s)", formatBound(*min), unit)
+	}
+	return ""
+}
+
+func outOfRange(v float64, min, max *float64) bool {
+	return (min != nil && v < *min) || (max != nil && v > *max)
+}
+
+// validateAnswers checks raw answers against form. It returns the normalised
+// answers (trimmed strings, only the form's keys, unanswered optional fields
+// left out) and the problems keyed by field.
+func validateAnswers(form *ApplicationForm, raw map[string]json.RawMessage) (map[string]any, map[string]string) {
+	answers := map[string]any{}
+	problems := map[string]string{}
+	for _, f := range form.Fields {
+		data, present := raw[f.Key]
+		if present && string(data) == "null" {
+			present = false
+		}
+		var value any
+		empty := !present
+		invalid := false
+		switch f.Type {
+		case FieldText, FieldTextarea:
+			var s string
+			if present && json.Unmarshal(data, &s) != nil {
+				invalid = true
+				break
+			}
+			s = strings.TrimSpace(s)
+			empty = s == ""
+			if !empty && outOfRange(float64(len([]rune(s))), f.Min, f.Max) {
+				problems[f.Key] = f.Key + rangeHint(f.Min, f.Max, " Zeichen")
+			}
+			value = s
+		case FieldNumber:
+			var n float64
+			if present && json.Unmarshal(data, &n) != nil {
+				invalid = true
+				break
+			}
+			if present && outOfRange(n, f.Min, f.Max) {
+				problems[f.Key] = f.Key + rangeHint(f.Min, f.Max, "")
+			}
+			value = n
+		case FieldSelect:
+			var s string
+			if present && json.Unmarshal(data, &s) != nil {
+				invalid = true
+				break
+			}
+			empty = s == ""
+			if !empty && !slices.ContainsFunc(f.Options, func(o FormOption) bool { return o.Value == s }) {
+				problems[f.Key] = f.Key + " (ungültige Auswahl)"
+			}
+			value = s
+		case FieldMultiselect:
+			var list []string
+			if present && json.Unmarshal(data, &list) != nil {
+				invalid = true
+				break
+			}
+			empty = len(list) == 0
+			for _, s := range list {
+				if !slices.ContainsFunc(f.Options, func(o FormOption) bool { return o.Value == s }) {
+					problems[f.Key] = f.Key + " (ungültige Auswahl)"
+				}
+			}
+			if !empty && outOfRange(float64(len(list)), f.Min, f.Max) {
+				problems[f.Key] = f.Key + rangeHint(f.Min, f.Max, " Optionen")
+			}
+			value = list
+		case FieldCheckbox:
+			var b bool
+			if present && json.Unmarshal(data, &b) != nil {
+				invalid = true
+				break
+			}
+			// A required checkbox has to be ticked (e.g. accepting rules).
+			empty = !b
+			value = b
+		}
+		switch {
+		case invalid:
+			problems[f.Key] = f.Key + " (ungültiger Wert)"
+		case empty && f.Required:
+			problems[f.Key] = f.Key
+		case !empty:
+			answers[f.Key] = value
+		}
+	}
+	return answers, problems
+}
+
+// parseApplicationSubmission reads a POST /api/apply or PUT
+// /api/auth/my-application body. Answers may be sent either nested under
+// "answers" or, like the original form, as top-level fields.
+func parseApplicationSubmission(r *http.Request) (game string, raw map[string]json.RawMessage, err error) {
+	var body map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return "", nil, err
+	}
+	if g, ok := body["game"]; ok {
+		if err := json.Unmarshal(g, &game); err != nil {
+			return "", nil, err
+		}
+	}
+	if nested, ok := body["answers"]; ok {
+		if err := json.Unmarshal(nested, &raw); err != nil {
+			return "", nil, err
+		}
+		return strings.TrimSpace(game), raw, nil
+	}
+	delete(body, "game")
+	return strings.TrimSpace(game), body, nil
+}
+
+// buildApplicationRecord validates a submission against the game's form and
+// fills the record: the answers as JSON plus the well-known columns. A
+// non-empty storedGame (editing an application) replaces the game of the
+// body, which cannot be changed. If the submission is invalid it writes the
+// 400 response and returns false.
+func buildApplicationRecord(w http.ResponseWriter, r *http.Request, apx Store, storedGame string) (ApplicationRecord, bool) {
+	game, raw, err := parseApplicationSubmission(r)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "invalid request body")
+		return ApplicationRecord{}, false
+	}
+	if storedGame != "" {
+		game = storedGame
+	}
+	if game == "" {
+		jsonResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"error":  "missing required fields: game",
+			"fields": map[string]string{"game": "game"},
+		})
+		return ApplicationRecord{}, false
+	}
+	form, err := applicationFormFor(r, apx, game)
+	if err != nil {
+		log.Printf("GetApplicationForm error: %v", err)
+		jsonError(w, http.StatusInternalServerError, "internal error")
+		return ApplicationRecord{}, false
+	}
+	answers, problems := validateAnswers(form, raw)
+	if len(problems) > 0 {
+		var list []string
+		for _, f := range form.Fields {
+			if p, ok := problems[f.Key]; ok {
+				list = append(list, p)
+			}
+		}
+		jsonResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"error":  "missing required fields: " + strings.Join(list, ", "),
+			"fields": problems,
+		})
+		return ApplicationRecord{}, false
+	}
+
+	encoded, err := json.Marshal(answers)
+	if err != nil {
+		jsonError(w, http.StatusInternalServerError, "internal error")
+		return ApplicationRecord{}, false
+	}
+	user := sessionUser(r)
+	text := func(key string) string {
+		switch v := answers[key].(type) {
+		case string:
+			return v
+		case []string:
+			return strings.Join(v, ", ")
+		case float64:
+			return formatBound(v)
+		}
+		return ""
+	}
+	record := ApplicationRecord{
+		UserID:       user.ID,
+		Name:         text("name"),
+		Discord:      text("discord"),
+		Game:         game,
+		Rank:         text("rank"),
+		AttackerRole: text("attacker_role"),
+		DefenderRole: text("defender_role"),
+		Experience:   text("experience"),
+		Motivation:   text("motivation"),
+		Availability: text("availability"),
+		Answers:      encoded,
+	}
+	if age, ok := answers["age"].(float64); ok {
+		record.Age = int(math.Round(age))
+	}
+	if record.Name == "" {
+		record.Name = cmp.Or(user.Nickname, user.Username)
+	}
+	return record, true
+}
+
+// handleApplicationForms serves GET /api/application-forms: the stored forms
+// and the default form used for every other game.
+func handleApplicationForms(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		forms, err := apx.GetApplicationForms(r.Context())
+		if err != nil {
+			log.Printf("GetApplicationForms error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"forms":       forms,
+			"default":     defaultApplicationForm,
+			"field_types": formFieldTypes,
+		})
+	}
+}
+
+// handleApplicationForm serves GET /api/application-forms/{game}.
+func handleApplicationForm(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		form, err := applicationFormFor(r, apx, r.PathValue("game"))
+		if err != nil {
+			log.Printf("GetApplicationForm error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"form": form})
+	}
+}
+
+// handleAdminSaveApplicationForm serves PUT /api/admin/application-forms/{game}.
+func handleAdminSaveApplicationForm(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		game := strings.TrimSpace(r.PathValue("game"))
+		if game == "" {
+			jsonError(w, http.StatusBadRequest, "game required")
+			return
+		}
+		var req struct {
+			Fields []FormField `json:"fields"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		if problems := checkFormSchema(req.Fields); len(problems) > 0 {
+			jsonResponse(w, http.StatusBadRequest, map[string]interface{}{
+				"error":    "Ungültiges Formular",
+				"problems": problems,
+			})
+			return
+		}
+
+		var before any
+		if old, err := apx.GetApplicationForm(r.Context(), game); err == nil {
+			before = old.Fields
+		}
+		form := ApplicationForm{Game: game, Fields: req.Fields}
+		if err := apx.SaveApplicationForm(r.Context(), form); err != nil {
+			log.Printf("SaveApplicationForm error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		recordAudit(apx, r, "application_form.update", "application_form", game,
+			map[string]any{"fields": before}, map[string]any{"fields": form.Fields})
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
+
+// handleAdminDeleteApplicationForm serves DELETE
+// /api/admin/application-forms/{game}; the game falls back to the default form.
+func handleAdminDeleteApplicationForm(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		game := r.PathValue("game")
+		old, err := apx.GetApplicationForm(r.Context(), game)
+		if errors.Is(err, errNotFound) {
+			jsonError(w, http.StatusNotFound, "Formular nicht gefunden")
+			return
+		} else if err != nil {
+			log.Printf("GetApplicationForm error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		if err := apx.DeleteApplicationForm(r.Context(), game); err != nil {
+			log.Printf("DeleteApplicationForm error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		recordAudit(apx, r, "application_form.delete", "application_form", game,
+			map[string]any{"fields": old.Fields}, nil)
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"teamapx-backend/cmd/apxfake"
+)
+
+const testInternalKey = "internal-test-key"
+
+// testEnv serves the routes of main (see registerRoutes) against ApxClient
+// talking to an apxfake server.
+type testEnv struct {
+	t    *testing.T
+	fake *apxfake.Server
+	apx  Store
+	mux  *http.ServeMux
+	addr string // client address of every request
+}
+
+// testClients numbers the test environments for their client addresses.
+var testClients atomic.Int32
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	t.Setenv("SMTP_HOST", "") // mails are logged, not sent
+	t.Setenv("INTERNAL_API_KEY", testInternalKey)
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(out) })
+
+	fake := apxfake.New()
+	t.Cleanup(fake.Close)
+	apx := NewApxClient(fake.URL, apxfake.APIKey)
+
+	mux := http.NewServeMux()
+	registerRoutes(mux, apx, filepath.Join(t.TempDir(), "uploads"))
+
+	n := testClients.Add(1)
+	addr := fmt.Sprintf("198.51.%d.%d:40000", n/256, n%256)
+	return &testEnv{t: t, fake: fake, apx: apx, mux: mux, addr: addr}
+}
+
+// do sends a JSON request. Each test environment has its own client address
+// so the login rate limiters do not carry over between tests.
+func (e *testEnv) do(method, path string, body any, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	e.t.Helper()
+	var rd io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			e.t.Fatal(err)
+		}
+		rd = strings.NewReader(string(b))
+	}
+	r := httptest.NewRequest(method, path, rd)
+	r.RemoteAddr = e.addr
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	e.mux.ServeHTTP(w, r)
+	return w
+}
+
+// addUser seeds an active user with the given password.
+func (e *testEnv) addUser(u apxfake.User, password string) int64 {
+	e.t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	u.Password, u.IsActive = string(hash), true
+	return e.fake.AddUser(u)
+}
+
+// session opens a session for userID and returns its cookie.
+func (e *testEnv) session(userID int64) *http.Cookie {
+	e.t.Helper()
+	token, err := e.apx.CreateSession(context.Background(), userID)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return &http.Cookie{Name: "session", Value: token}
+}
+
+// sessionCookie returns the session cookie set by a response, or nil.
+func sessionCookie(w *httptest.ResponseRecorder) *http.Cookie {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "session" && c.Value != "" {
+			return c
+		}
+	}
+	return nil
+}
+
+// decodeBody unmarshals the response body into a map.
+func decodeBody(t *testing.T, w *httptest.ResponseRecorder) map[string]any {
+	t.Helper()
+	var m map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &m); err != nil {
+		t.Fatalf("decode %q: %v", w.Body.String(), err)
+	}
+	return m
+}
+
+func expectStatus(t *testing.T, w *httptest.ResponseRecorder, want int) {
+	t.Helper()
+	if w.Code != want {
+		t.Fatalf("status = %d, want %d (body %s)", w.Code, want, w.Body.String())
+	}
+}
@@ -28,129 +28,106 @@ var validRarities = map[string]bool{
 	"B-Rank": true, "A-Rank": true, "S-Rank": true,
 }
 
-// handleAdminItems handles GET/POST/DELETE /api/admin/items
-func handleAdminItems(apx *ApxClient, uploadDir string) http.HandlerFunc {
+// handleAdminItemList serves GET /api/admin/items
+func handleAdminItemList(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
+		items, err := apx.GetAllItems(r.Context())
+		if err != nil {
+			log.Printf("GetAllItems error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
 		}
+		if items == nil {
+			items = []Item{}
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"items": items})
+	}
+}
 
-		cookie, err := r.Cookie("session")
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
+// handleAdminItemCreate serves POST /api/admin/items
+func handleAdminItemCreate(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Name     string   `json:"name"`
+			Rarity   string   `json:"rarity"`
+			ImageURL string   `json:"image_url"`
+			IsWeapon bool     `json:"is_weapon"`
+			IsArmor  bool     `json:"is_armor"`
+			IsItem   bool     `json:"is_item"`
+			IsAnimal bool     `json:"is_animal"`
+			Perks    []string `json:"perks"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
 			return
 		}
-		user, err := apx.GetSessionUser(cookie.Value)
-		if err != nil || !user.IsAdmin {
-			jsonError(w, http.StatusForbidden, "Keine Berechtigung")
+		if req.Name == "" {
+			jsonError(w, http.StatusBadRequest, "name required")
 			return
 		}
-
-		switch r.Method {
-		case http.MethodGet:
-			items, err := apx.GetAllItems()
-			if err != nil {
-				log.Printf("GetAllItems error: %v", err)
-				jsonError(w, http.StatusInternalServerError, "internal error")
-				return
-			}
-			if items == nil {
-				items = []Item{}
-			}
-			jsonResponse(w, http.StatusOK, map[string]interface{}{"items": items})
-
-		case http.MethodPost:
-			var req struct {
-				Name     string   `json:"name"`
-				Rarity   string   `json:"rarity"`
-				ImageURL string   `json:"image_url"`
-				IsWeapon bool     `json:"is_weapon"`
-				IsArmor  bool     `json:"is_armor"`
-				IsItem   bool     `json:"is_item"`
-				IsAnimal bool     `json:"is_animal"`
-				Perks    []string `json:"perks"`
-			}
-			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-				jsonError(w, http.StatusBadRequest, "invalid request body")
-				return
-			}
-			if req.Name == "" {
-				jsonError(w, http.StatusBadRequest, "name required")
-				return
-			}
-			if req.Rarity != "" && !validRarities[req.Rarity] {
-				jsonError(w, http.StatusBadRequest, "invalid rarity")
-				return
-			}
-			var rarityPtr *string
-			if req.Rarity != "" {
-				rarityPtr = &req.Rarity
-			}
-			var imagePtr *string
-			if req.ImageURL != "" {
-				imagePtr = &req.ImageURL
-			}
-			item := &Item{
-				Name: req.Name, Rarity: rarityPtr, ImageURL: imagePtr,
-				IsWeapon: req.IsWeapon, IsArmor: req.IsArmor,
-				IsItem: req.IsItem, IsAnimal: req.IsAnimal, Perks: req.Perks,
-			}
-			if err := apx.CreateItem(item); err != nil {
-				log.Printf("CreateItem error: %v", err)
-				jsonError(w, http.StatusInternalServerError, "internal error")
-				return
-			}
-			jsonResponse(w, http.StatusCreated, map[string]interface{}{"item": item})
-
-		case http.MethodDelete:
-			var req struct {
-				ItemID string `json:"item_id"`
-			}
-			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-				jsonError(w, http.StatusBadRequest, "invalid request body")
-				return
-			}
-			if req.ItemID == "" {
-				jsonError(w, http.StatusBadRequest, "item_id required")
-				return
-			}
-			if err := apx.DeleteItem(req.ItemID); err != nil {
-				log.Printf("DeleteItem error: %v", err)
-				jsonError(w, http.StatusInternalServerError, "internal error")
-				return
-			}
-			jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
-
-		default:
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		if req.Rarity != "" && !validRarities[req.Rarity] {
+			jsonError(w, http.StatusBadRequest, "invalid rarity")
+			return
 		}
+		var rarityPtr *string
+		if req.Rarity != "" {
+			rarityPtr = &req.Rarity
+		}
+		var imagePtr *string
+		if req.ImageURL != "" {
+			imagePtr = &req.ImageURL
+		}
+		item := &Item{
+			Name: req.Name, Rarity: rarityPtr, ImageURL: imagePtr,
+			IsWeapon: req.IsWeapon, IsArmor: req.IsArmor,
+			IsItem: req.IsItem, IsAnimal: req.IsAnimal, Perks: req.Perks,
+		}
+		if err := apx.CreateItem(r.Context(), item); err != nil {
+			log.Printf("CreateItem error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		recordAudit(apx, r, "item.create", "item", item.ItemID, nil, item)
+		jsonResponse(w, http.StatusCreated, map[string]interface{}{"item": item})
 	}
 }
 
-// handleAdminItemImage handles POST /api/admin/items/image — uploads an item image.
-func handleAdminItemImage(apx *ApxClient, uploadDir string) http.HandlerFunc {
+// handleAdminItemDelete serves DELETE /api/admin/items
+func handleAdminItemDelete(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
+		var req struct {
+			ItemID string `json:"item_id"`
 		}
-		if r.Method != http.MethodPost {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
 			return
 		}
-
-		cookie, err := r.Cookie("session")
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
+		if req.ItemID == "" {
+			jsonError(w, http.StatusBadRequest, "item_id required")
 			return
 		}
-		user, err := apx.GetSessionUser(cookie.Value)
-		if err != nil || !user.IsAdmin {
-			jsonError(w, http.StatusForbidden, "Keine Berechtigung")
+		var before *Item
+		if items, err := apx.GetAllItems(r.Context()); err == nil {
+			for i := range items {
+				if items[i].ItemID == req.ItemID {
+					before = &items[i]
+					break
+				}
+			}
+		}
+		if err := apx.DeleteItem(r.Context(), req.ItemID); err != nil {
+			log.Printf("DeleteItem error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
 		}
+		recordAudit(apx, r, "item.delete", "item", req.ItemID, before, nil)
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
 
+// handleAdminItemImage handles POST /api/admin/items/image — uploads an item image.
+func handleAdminItemImage(apx Store, uploadDir string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 		if err := r.ParseMultipartForm(10 << 20); err != nil {
 			jsonError(w, http.StatusBadRequest, "invalid form data")
 			return
@@ -174,29 +151,11 @@ func handleAdminItemImage(apx *ApxClient, uploadDir string) http.HandlerFunc {
 }
 
 // handleMyItems handles GET /api/items/my — returns the current user's progression inventory.
-func handleMyItems(apx *ApxClient) http.HandlerFunc {
+func handleMyItems(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		if r.Method != http.MethodGet {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-			return
-		}
-
-		cookie, err := r.Cookie("session")
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
-			return
-		}
-		user, err := apx.GetSessionUser(cookie.Value)
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
-			return
-		}
+		user := sessionUser(r)
 
-		items, err := apx.GetUserItems(user.ID)
+		items, err := apx.GetUserItems(r.Context(), user.ID)
 		if err != nil {
 			log.Printf("GetUserItems error: %v", err)
 			jsonError(w, http.StatusInternalServerError, "internal error")
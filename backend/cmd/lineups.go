@@ -0,0 +1,361 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+const lineupSize = 5
+
+// lineupView is a lineup as served to the team. Stale lists starters who are
+// no longer on the main roster, so coaches see which sheets need an update.
+type lineupView struct {
+	MapLineup
+	Stale []int64 `json:"stale"`
+}
+
+func viewLineup(l MapLineup, members []TeamMember) lineupView {
+	main := map[int64]TeamMember{}
+	for _, m := range members {
+		if m.IsMainRoster {
+			main[m.ID] = m
+		}
+	}
+	v := lineupView{MapLineup: l, Stale: []int64{}}
+	for i, p := range v.Players {
+		if m, ok := main[p.TeamMemberID]; ok {
+			v.Players[i].Name = m.Name
+		} else {
+			v.Stale = append(v.Stale, p.TeamMemberID)
+		}
+	}
+	return v
+}
+
+// poolMap returns the pool's spelling of name, or "" if it is not in the pool.
+func poolMap(pool []string, name string) string {
+	for _, m := range pool {
+		if strings.EqualFold(m, strings.TrimSpace(name)) {
+			return m
+		}
+	}
+	return ""
+}
+
+// lineupMapName resolves the {map} path segment case-insensitively to the
+// pool's spelling. Names no longer in the pool are used as given, so lineups
+// kept after a pool change stay reachable.
+func lineupMapName(r *http.Request, apx Store) (name string, inPool bool, err error) {
+	pool, err := apx.GetMapPool(r.Context())
+	if err != nil {
+		return "", false, err
+	}
+	if m := poolMap(pool, r.PathValue("map")); m != "" {
+		return m, true, nil
+	}
+	return strings.TrimSpace(r.PathValue("map")), false, nil
+}
+
+// validateLineup checks l against the current roster and fills in names and
+// default roles. It returns a user-facing message or "".
+func validateLineup(l *MapLineup, members []TeamMember) string {
+	if len(l.Players) != lineupSize {
+		return "Ein Lineup braucht genau 5 Spieler"
+	}
+	byID := map[int64]TeamMember{}
+	for _, m := range members {
+		byID[m.ID] = m
+	}
+	inLineup := map[int64]bool{}
+	for i := range l.Players {
+		p := &l.Players[i]
+		m, ok := byID[p.TeamMemberID]
+		if !ok {
+			return "Unbekannter Spieler im Lineup"
+		}
+		if !m.IsMainRoster {
+			return m.Name + " ist nicht im Main-Roster"
+		}
+		if inLineup[p.TeamMemberID] {
+			return m.Name + " steht doppelt im Lineup"
+		}
+		inLineup[p.TeamMemberID] = true
+		p.Name = m.Name
+		p.AtkRole, p.DefRole = strings.TrimSpace(p.AtkRole), strings.TrimSpace(p.DefRole)
+		if p.AtkRole == "" {
+			p.AtkRole = m.AtkRole
+		}
+		if p.DefRole == "" {
+			p.DefRole = m.DefRole
+		}
+		if utf8.RuneCountInString(p.AtkRole) > 30 || utf8.RuneCountInString(p.DefRole) > 30 {
+			return "Rollen dürfen maximal 30 Zeichen lang sein"
+		}
+	}
+
+	if l.Sites == nil {
+		l.Sites = []SiteSetup{}
+	}
+	sites := map[string]bool{}
+	for i := range l.Sites {
+		s := &l.Sites[i]
+		s.Site = strings.TrimSpace(s.Site)
+		if s.Site == "" || utf8.RuneCountInString(s.Site) > 40 {
+			return "Jeder Spot braucht einen Namen (maximal 40 Zeichen)"
+		}
+		if sites[strings.ToLower(s.Site)] {
+			return "Spot " + s.Site + " ist doppelt angegeben"
+		}
+		sites[strings.ToLower(s.Site)] = true
+		if utf8.RuneCountInString(s.Notes) > 500 {
+			return "Spot-Notizen dürfen maximal 500 Zeichen lang sein"
+		}
+		if s.Positions == nil {
+			s.Positions = []SitePosition{}
+		}
+		for j := range s.Positions {
+			pos := &s.Positions[j]
+			if !inLineup[pos.TeamMemberID] {
+				return "Positionen auf " + s.Site + " dürfen nur Spieler aus dem Lineup belegen"
+			}
+			pos.Position = strings.TrimSpace(pos.Position)
+			if utf8.RuneCountInString(pos.Position) > 60 {
+				return "Positionen dürfen maximal 60 Zeichen lang sein"
+			}
+		}
+	}
+	if utf8.RuneCountInString(l.Notes) > 2000 {
+		return "Notizen dürfen maximal 2000 Zeichen lang sein"
+	}
+	return ""
+}
+
+// handleMapPool serves GET /api/map-pool (team members).
+func handleMapPool(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requireTeamMember(w, r, apx) {
+			return
+		}
+		pool, err := apx.GetMapPool(r.Context())
+		if err != nil {
+			log.Printf("GetMapPool error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		jsonResponse(w, http.StatusOK, pool)
+	}
+}
+
+// handleSetMapPool serves PUT /api/map-pool (coaches) with the maps in
+// display order. Lineups of maps leaving the pool are kept.
+func handleSetMapPool(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requireCoach(w, r, apx) {
+			return
+		}
+		var maps []string
+		if err := json.NewDecoder(r.Body).Decode(&maps); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		if len(maps) == 0 || len(maps) > 30 {
+			jsonError(w, http.StatusBadRequest, "Der Map-Pool braucht 1 bis 30 Maps")
+			return
+		}
+		seen := map[string]bool{}
+		for i, m := range maps {
+			m = strings.TrimSpace(m)
+			if m == "" || utf8.RuneCountInString(m) > 40 {
+				jsonError(w, http.StatusBadRequest, "Map-Namen dürfen nicht leer und maximal 40 Zeichen lang sein")
+				return
+			}
+			if seen[strings.ToLower(m)] {
+				jsonError(w, http.StatusBadRequest, "Map "+m+" ist doppelt angegeben")
+				return
+			}
+			seen[strings.ToLower(m)] = true
+			maps[i] = m
+		}
+
+		before, err := apx.GetMapPool(r.Context())
+		if err != nil {
+			log.Printf("GetMapPool error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		if err := apx.SetMapPool(r.Context(), maps); err != nil {
+			log.Printf("SetMapPool error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		recordAudit(apx, r, "mappool.update", "map_pool", "", before, maps)
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
+
+// handleLineups serves GET /api/lineups (team members): the map pool with
+// each map's lineup, or null for maps without one.
+func handleLineups(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requireTeamMember(w, r, apx) {
+			return
+		}
+		pool, err := apx.GetMapPool(r.Context())
+		if err != nil {
+			log.Printf("GetMapPool error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		lineups, err := apx.GetMapLineups(r.Context())
+		if err != nil {
+			log.Printf("GetMapLineups error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		members, err := apx.GetTeamMembers(r.Context())
+		if err != nil {
+			log.Printf("Failed to get team members: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+
+		byMap := map[string]MapLineup{}
+		for _, l := range lineups {
+			byMap[l.Map] = l
+		}
+		type mapEntry struct {
+			Map    string      `json:"map"`
+			Lineup *lineupView `json:"lineup"`
+		}
+		maps := make([]mapEntry, 0, len(pool))
+		for _, m := range pool {
+			e := mapEntry{Map: m}
+			if l, ok := byMap[m]; ok {
+				v := viewLineup(l, members)
+				e.Lineup = &v
+			}
+			maps = append(maps, e)
+		}
+		_, coach := teamRole(r.Context(), apx, sessionUser(r))
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"maps": maps, "can_edit": coach})
+	}
+}
+
+// handleLineup serves GET /api/lineups/{map} (team members).
+func handleLineup(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requireTeamMember(w, r, apx) {
+			return
+		}
+		mapName, _, err := lineupMapName(r, apx)
+		if err != nil {
+			log.Printf("GetMapPool error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		l, err := apx.GetMapLineup(r.Context(), mapName)
+		if errors.Is(err, errNotFound) {
+			jsonError(w, http.StatusNotFound, "Kein Lineup für diese Map")
+			return
+		}
+		if err != nil {
+			log.Printf("GetMapLineup error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		members, err := apx.GetTeamMembers(r.Context())
+		if err != nil {
+			log.Printf("Failed to get team members: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		jsonResponse(w, http.StatusOK, viewLineup(*l, members))
+	}
+}
+
+// handleSaveLineup serves PUT /api/lineups/{map} (coaches). The map must be
+// in the pool and all five starters on the main roster.
+func handleSaveLineup(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requireCoach(w, r, apx) {
+			return
+		}
+		mapName, inPool, err := lineupMapName(r, apx)
+		if err != nil {
+			log.Printf("GetMapPool error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		if !inPool {
+			jsonError(w, http.StatusBadRequest, "Map ist nicht im Map-Pool")
+			return
+		}
+		var l MapLineup
+		if err := json.NewDecoder(r.Body).Decode(&l); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		members, err := apx.GetTeamMembers(r.Context())
+		if err != nil {
+			log.Printf("Failed to get team members: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		if msg := validateLineup(&l, members); msg != "" {
+			jsonError(w, http.StatusBadRequest, msg)
+			return
+		}
+		l.Map = mapName
+		l.UpdatedBy = sessionUser(r).Username
+
+		before, err := apx.GetMapLineup(r.Context(), mapName)
+		if err != nil && !errors.Is(err, errNotFound) {
+			log.Printf("GetMapLineup error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		if err := apx.SaveMapLineup(r.Context(), l); err != nil {
+			log.Printf("SaveMapLineup error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		recordAudit(apx, r, "lineup.update", "map_lineup", mapName, before, l)
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
+
+// handleDeleteLineup serves DELETE /api/lineups/{map} (coaches).
+func handleDeleteLineup(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requireCoach(w, r, apx) {
+			return
+		}
+		mapName, _, err := lineupMapName(r, apx)
+		if err != nil {
+			log.Printf("GetMapPool error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		before, err := apx.GetMapLineup(r.Context(), mapName)
+		if errors.Is(err, errNotFound) {
+			jsonError(w, http.StatusNotFound, "Kein Lineup für diese Map")
+			return
+		}
+		if err != nil {
+			log.Printf("GetMapLineup error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		if err := apx.DeleteMapLineup(r.Context(), before.Map); err != nil {
+			log.Printf("DeleteMapLineup error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		recordAudit(apx, r, "lineup.delete", "map_lineup", before.Map, before, nil)
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
+	"strconv"
 	"time"
 )
 
@@ -16,13 +18,38 @@ type LogEntry struct {
 }
 
 // handleLog serves GET /api/log — public, no auth required.
-func handleLog(apx *ApxClient) http.HandlerFunc {
+func handleLog(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		entries, err := apx.GetAllLogEntries(r.Context())
+		if err != nil {
+			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
 		}
-		entries, err := apx.GetAllLogEntries()
+		if entries == nil {
+			entries = []LogEntry{}
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"entries": entries})
+	}
+}
+
+// findLogEntry returns the news entry with the given id, or nil.
+func findLogEntry(ctx context.Context, apx Store, id int64) *LogEntry {
+	entries, err := apx.GetAllLogEntries(ctx)
+	if err != nil {
+		return nil
+	}
+	for i := range entries {
+		if entries[i].ID == id {
+			return &entries[i]
+		}
+	}
+	return nil
+}
+
+// handleAdminLogList serves GET /api/admin/log.
+func handleAdminLogList(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		entries, err := apx.GetAllLogEntries(r.Context())
 		if err != nil {
 			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
@@ -34,83 +61,68 @@ func handleLog(apx *ApxClient) http.HandlerFunc {
 	}
 }
 
-// handleAdminLog serves GET/POST/PUT/DELETE /api/admin/log — admin only.
-func handleAdminLog(apx *ApxClient) http.HandlerFunc {
+// handleAdminLogCreate serves POST /api/admin/log.
+func handleAdminLogCreate(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		cookie, err := r.Cookie("session")
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
+		var req struct {
+			Title   string `json:"title"`
+			Body    string `json:"body"`
+			LogDate string `json:"log_date"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
 			return
 		}
-		user, err := apx.GetSessionUser(cookie.Value)
-		if err != nil || !user.IsAdmin {
-			jsonError(w, http.StatusForbidden, "Keine Berechtigung")
+		entry := &LogEntry{Title: req.Title, Body: req.Body, LogDate: req.LogDate}
+		if err := apx.CreateLogEntry(r.Context(), entry); err != nil {
+			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
 		}
+		recordAudit(apx, r, "log.create", "log_entry", "", nil, req)
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": true})
+	}
+}
 
-		switch r.Method {
-		case http.MethodGet:
-			entries, err := apx.GetAllLogEntries()
-			if err != nil {
-				jsonError(w, http.StatusInternalServerError, "internal error")
-				return
-			}
-			if entries == nil {
-				entries = []LogEntry{}
-			}
-			jsonResponse(w, http.StatusOK, map[string]interface{}{"entries": entries})
-
-		case http.MethodPost:
-			var req struct {
-				Title   string `json:"title"`
-				Body    string `json:"body"`
-				LogDate string `json:"log_date"`
-			}
-			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-				jsonError(w, http.StatusBadRequest, "invalid request body")
-				return
-			}
-			entry := &LogEntry{Title: req.Title, Body: req.Body, LogDate: req.LogDate}
-			if err := apx.CreateLogEntry(entry); err != nil {
-				jsonError(w, http.StatusInternalServerError, "internal error")
-				return
-			}
-			jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": true})
-
-		case http.MethodPut:
-			var req struct {
-				ID      int64  `json:"id"`
-				Title   string `json:"title"`
-				Body    string `json:"body"`
-				LogDate string `json:"log_date"`
-			}
-			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-				jsonError(w, http.StatusBadRequest, "invalid request body")
-				return
-			}
-			entry := &LogEntry{ID: req.ID, Title: req.Title, Body: req.Body, LogDate: req.LogDate}
-			if err := apx.UpdateLogEntry(entry); err != nil {
-				jsonError(w, http.StatusInternalServerError, "internal error")
-				return
-			}
-			jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": true})
-
-		case http.MethodDelete:
-			var req struct {
-				ID int64 `json:"id"`
-			}
-			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-				jsonError(w, http.StatusBadRequest, "invalid request body")
-				return
-			}
-			if err := apx.DeleteLogEntry(req.ID); err != nil {
-				jsonError(w, http.StatusInternalServerError, "internal error")
-				return
-			}
-			jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": true})
+// handleAdminLogUpdate serves PUT /api/admin/log.
+func handleAdminLogUpdate(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID      int64  `json:"id"`
+			Title   string `json:"title"`
+			Body    string `json:"body"`
+			LogDate string `json:"log_date"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		entry := &LogEntry{ID: req.ID, Title: req.Title, Body: req.Body, LogDate: req.LogDate}
+		before := findLogEntry(r.Context(), apx, req.ID)
+		if err := apx.UpdateLogEntry(r.Context(), entry); err != nil {
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		recordAudit(apx, r, "log.update", "log_entry", strconv.FormatInt(req.ID, 10), before, req)
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": true})
+	}
+}
 
-		default:
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+// handleAdminLogDelete serves DELETE /api/admin/log.
+func handleAdminLogDelete(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID int64 `json:"id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		before := findLogEntry(r.Context(), apx, req.ID)
+		if err := apx.DeleteLogEntry(r.Context(), req.ID); err != nil {
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
 		}
+		recordAudit(apx, r, "log.delete", "log_entry", strconv.FormatInt(req.ID, 10), before, nil)
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"ok": true})
 	}
 }
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"log"
+	"mime"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// Mail languages. Users pick one in their mail settings; without a choice
+// mails are in English.
+const (
+	MailLangDE = "de"
+	MailLangEN = "en"
+)
+
+func mailLanguage(lang string) string {
+	if lang == MailLangDE {
+		return MailLangDE
+	}
+	return MailLangEN
+}
+
+// requestMailLanguage guesses the mail language for visitors without an
+// account from the Accept-Language header.
+func requestMailLanguage(r *http.Request) string {
+	if strings.HasPrefix(strings.ToLower(r.Header.Get("Accept-Language")), MailLangDE) {
+		return MailLangDE
+	}
+	return MailLangEN
+}
+
+// mailTemplate is one language version of a mail. Body is plain text;
+// paragraphs are separated by blank lines and a paragraph indented by four
+// spaces is a code that the HTML part highlights. Both fields are
+// text/template sources executed with the data passed to sendMail.
+type mailTemplate struct {
+	Subject string
+	Body    string
+}
+
+// mailTemplates holds every mail the backend sends, by name and language.
+var mailTemplates = map[string]map[string]mailTemplate{
+	"verification": {
+		MailLangDE: {
+			Subject: "Team Apx - E-Mail Verifizierung",
+			Body: "Dein Team Apx Bestätigungscode:\n\n" +
+				"    {{.Code}}\n\n" +
+				"Der Code ist 15 Minuten gültig.\n\n" +
+				"Falls du diesen Code nicht angefordert hast, kannst du diese E-Mail ignorieren.",
+		},
+		MailLangEN: {
+			Subject: "Team Apx - Email Verification",
+			Body: "Your Team Apx verification code:\n\n" +
+				"    {{.Code}}\n\n" +
+				"This code expires in 15 minutes.\n\n" +
+				"If you did not request this code, you can safely ignore this email.",
+		},
+	},
+	"password_reset": {
+		MailLangDE: {
+			Subject: "Team Apx - Passwort zurücksetzen",
+			Body: "für dein Team Apx Konto wurde ein neues Passwort angefordert. Dein Code:\n\n" +
+				"    {{.Code}}\n\n" +
+				"Der Code ist 15 Minuten gültig.\n\n" +
+				"Falls du kein neues Passwort angefordert hast, kannst du diese E-Mail ignorieren. Dein Passwort bleibt unverändert.",
+		},
+		MailLangEN: {
+			Subject: "Team Apx - Password reset",
+			Body: "a new password was requested for your Team Apx account. Your code:\n\n" +
+				"    {{.Code}}\n\n" +
+				"This code expires in 15 minutes.\n\n" +
+				"If you did not request a new password, you can safely ignore this email. Your password stays unchanged.",
+		},
+	},
+	"application_received": {
+		MailLangDE: {
+			Subject: "Team Apx - Bewerbung eingegangen",
+			Body: "danke für deine Bewerbung bei Team Apx ({{.Game}}). Wir haben sie erhalten und melden uns, sobald wir sie geprüft haben.\n\n" +
+				"Bis dahin kannst du deine Bewerbung in deinem Profil ansehen und bearbeiten.",
+		},
+		MailLangEN: {
+			Subject: "Team Apx - Application received",
+			Body: "thanks for applying to Team Apx ({{.Game}}). We have received your application and will get back to you once we have reviewed it.\n\n" +
+				"Until then you can view and edit your application in your profile.",
+		},
+	},
+	"application_trial": {
+		MailLangDE: {
+			Subject: "Team Apx - Einladung zum Probetraining",
+			Body: "gute Neuigkeiten: wir möchten dich zu einem Probetraining einladen.\n\n" +
+				"{{if .Reason}}{{.Reason}}\n\n{{end}}" +
+				"Die Details besprechen wir mit dir auf Discord ({{.Discord}}).",
+		},
+		MailLangEN: {
+			Subject: "Team Apx - Trial invitation",
+			Body: "good news: we would like to invite you to a trial.\n\n" +
+				"{{if .Reason}}{{.Reason}}\n\n{{end}}" +
+				"We will sort out the details with you on Discord ({{.Discord}}).",
+		},
+	},
+	"application_accepted": {
+		MailLangDE: {
+			Subject: "Team Apx - Willkommen im Team",
+			Body: "herzlichen Glückwunsch, deine Bewerbung wurde angenommen. Willkommen bei Team Apx!\n\n" +
+				"{{if .Reason}}{{.Reason}}\n\n{{end}}" +
+				"Wir melden uns mit allem Weiteren auf Discord.",
+		},
+		MailLangEN: {
+			Subject: "Team Apx - Welcome to the team",
+			Body: "congratulations, your application has been accepted. Welcome to Team Apx!\n\n" +
+				"{{if .Reason}}{{.Reason}}\n\n{{end}}" +
+				"We will reach out on Discord with everything else.",
+		},
+	},
+	"application_rejected": {
+		MailLangDE: {
+			Subject: "Team Apx - Deine Bewerbung",
+			Body: "danke für dein Interesse an Team Apx. Leider können wir dich im Moment nicht aufnehmen.\n\n" +
+				"Begründung: {{.Reason}}\n\n" +
+				"Wir wünschen dir viel Erfolg und freuen uns, wenn du es später noch einmal versuchst.",
+		},
+		MailLangEN: {
+			Subject: "Team Apx - Your application",
+			Body: "thanks for your interest in Team Apx. Unfortunately we cannot take you on at the moment.\n\n" +
+				"Reason: {{.Reason}}\n\n" +
+				"We wish you all the best and would be glad if you try again later.",
+		},
+	},
+}
+
+// Greeting, sign-off and opt-out hint shared by all templates.
+var mailFrame = map[string]struct{ Greeting, Footer, Contact, OptOut string }{
+	MailLangDE: {"Hallo %s,", "— Team Apx", "Kontakt", "Du kannst diese Benachrichtigungen in deinen Profileinstellungen abschalten."},
+	MailLangEN: {"Hi %s,", "— Team Apx", "Contact us", "You can turn these notifications off in your profile settings."},
+}
+
+const mailContact = "team.apx.r6@gmail.com"
+
+// mailMessage is a rendered mail, ready to send.
+type mailMessage struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// renderMail renders the named template for recipient (the greeting name);
+// notification adds the opt-out hint to the footer.
+func renderMail(name, lang, recipient string, data map[string]string, notification bool) (*mailMessage, error) {
+	lang = mailLanguage(lang)
+	tmpl, ok := mailTemplates[name][lang]
+	if !ok {
+		return nil, fmt.Errorf("unknown mail template %q", name)
+	}
+	subject, err := executeMailTemplate(tmpl.Subject, data)
+	if err != nil {
+		return nil, fmt.Errorf("mail template %q subject: %w", name, err)
+	}
+	body, err := executeMailTemplate(tmpl.Body, data)
+	if err != nil {
+		return nil, fmt.Errorf("mail template %q body: %w", name, err)
+	}
+
+	frame := mailFrame[lang]
+	greeting := fmt.Sprintf(frame.Greeting, recipient)
+	paragraphs := strings.Split(strings.TrimSpace(body), "\n\n")
+	if notification {
+		paragraphs = append(paragraphs, frame.OptOut)
+	}
+
+	var text strings.Builder
+	text.WriteString(greeting + "\r\n\r\n")
+	for _, p := range paragraphs {
+		text.WriteString(strings.ReplaceAll(p, "\n", "\r\n") + "\r\n\r\n")
+	}
+	text.WriteString(frame.Footer + "\r\n\r\n" + frame.Contact + ": " + mailContact)
+
+	var h strings.Builder
+	h.WriteString(`
+<html>
+<body style="font-family:Arial,sans-serif; line-height:1.6; color:#111;">
+
+<table>
+<tr>
+<td style="font-size:18px; font-weight:bold;">
+` + html.EscapeString(greeting) + `
+</td>
+
+<td style="padding-left:10px;">
+<img src="https://apx-team.com/assets/icons/TEAM_APX-120x120.png" width="50" alt="Team Apx">
+</td>
+</tr>
+</table>
+`)
+	for _, p := range paragraphs {
+		if code, ok := strings.CutPrefix(p, "    "); ok {
+			h.WriteString("\n<h2 style=\"letter-spacing:3px;\">" + html.EscapeString(code) + "</h2>\n")
+			continue
+		}
+		h.WriteString("\n<p>" + strings.ReplaceAll(html.EscapeString(p), "\n", "<br>") + "</p>\n")
+	}
+	h.WriteString("\n<p>" + html.EscapeString(frame.Footer) + "</p>\n\n<hr>\n\n" +
+		"<p><u>" + frame.Contact + ":</u> " + mailContact + "</p>\n\n</body>\n</html>\n")
+
+	return &mailMessage{Subject: subject, Text: text.String(), HTML: h.String()}, nil
+}
+
+func executeMailTemplate(src string, data map[string]string) (string, error) {
+	t, err := template.New("mail").Option("missingkey=zero").Parse(src)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// sendMail renders the named template and sends it via SMTP_HOST. Without
+// SMTP_HOST the plain-text mail is logged instead (development).
+func sendMail(to, name, lang, recipient string, data map[string]string, notification bool) error {
+	m, err := renderMail(name, lang, recipient, data, notification)
+	if err != nil {
+		return err
+	}
+
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		log.Printf("[DEV] E-Mail an %s: %s\n%s", to, m.Subject, m.Text)
+		return nil
+	}
+
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+	user := os.Getenv("SMTP_USER")
+	pass := os.Getenv("SMTP_PASS")
+	from := os.Getenv("SMTP_FROM")
+	if from == "" {
+		from = user
+	}
+
+	boundary := "apx-boundary-123"
+
+	msg := []byte(
+		"From: " + from + "\r\n" +
+			"To: " + to + "\r\n" +
+			"Subject: " + mime.QEncoding.Encode("UTF-8", m.Subject) + "\r\n" +
+			"MIME-Version: 1.0\r\n" +
+			"Content-Type: multipart/alternative; boundary=" + boundary + "\r\n\r\n" +
+			"--" + boundary + "\r\n" +
+			"Content-Type: text/plain; charset=UTF-8\r\n\r\n" +
+			m.Text + "\r\n\r\n" +
+			"--" + boundary + "\r\n" +
+			"Content-Type: text/html; charset=UTF-8\r\n\r\n" +
+			m.HTML + "\r\n\r\n" +
+			"--" + boundary + "--",
+	)
+
+	auth := smtp.PlainAuth("", user, pass, host)
+	return smtp.SendMail(host+":"+port, auth, from, []string{to}, msg)
+}
+
+func sendVerificationEmail(to, lang, username, code string) error {
+	return sendMail(to, "verification", lang, username, map[string]string{"Code": code}, false)
+}
+
+func sendPasswordResetEmail(to, lang, username, code string) error {
+	return sendMail(to, "password_reset", lang, username, map[string]string{"Code": code}, false)
+}
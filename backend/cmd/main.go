@@ -1,8 +1,9 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"io"
 	"log"
 	"net/http"
@@ -10,7 +11,10 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -22,33 +26,22 @@ import (
 //	    proxy_set_header Host $host;
 //	    proxy_set_header X-Real-IP $remote_addr;
 //	}
-
-type Application struct {
-	Name         string `json:"name"`
-	Age          int    `json:"age"`
-	Discord      string `json:"discord"`
-	Game         string `json:"game"`
-	Rank         string `json:"rank"`
-	AttackerRole string `json:"attacker_role"`
-	DefenderRole string `json:"defender_role"`
-	Experience   string `json:"experience"`
-	Motivation   string `json:"motivation"`
-	Availability string `json:"availability"`
-}
+//
+// X-Real-IP is only believed from TRUSTED_PROXIES (default: loopback).
 
 func main() {
 	loadDotEnv()
 
-	// Optional: External API client (for APX Stats backend)
-	var apx *ApxClient
-	apxAPIURL := os.Getenv("APX_API_URL")
-	apxAPIKey := os.Getenv("APX_API_KEY")
-	if apxAPIURL != "" && apxAPIKey != "" {
-		apx = NewApxClient(apxAPIURL, apxAPIKey)
-	} else {
-		// Create a dummy client for local-only operation
-		apx = NewApxClient("http://localhost:3000", "dummy-key")
-		log.Println("Running in local-only mode (APX_API_URL/APX_API_KEY not set)")
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrateCommand(os.Args[2:]))
+	}
+	if err := checkMigrations(); err != nil {
+		log.Fatalf("Migrations out of date: %v", err)
+	}
+
+	apx, err := openStore()
+	if err != nil {
+		log.Fatalf("Failed to open data store: %v", err)
 	}
 
 	// Ensure admin user exists
@@ -59,10 +52,12 @@ func main() {
 	hashedPw, err := bcrypt.GenerateFromPassword([]byte(adminPassword), 12)
 	if err != nil {
 		log.Printf("Failed to hash admin password: %v", err)
-	} else if err := apx.EnsureAdminUser(string(hashedPw)); err != nil {
+	} else if err := apx.EnsureAdminUser(context.Background(), string(hashedPw)); err != nil {
 		log.Printf("Failed to ensure admin user: %v", err)
 	}
 
+	go runWeeklyStatsJob(apx)
+
 	// Frontend directory – in production, point to the Vite build output (frontend/dist)
 	frontendDir := os.Getenv("FRONTEND_DIR")
 	if frontendDir == "" {
@@ -83,152 +78,325 @@ func main() {
 	}
 	log.Printf("Upload directory: %s", uploadDir)
 
-	// API routes
-	http.HandleFunc("/api/team", handlePublicTeam(apx))
-	http.HandleFunc("/api/staff", handlePublicStaff(apx))
-	http.HandleFunc("/api/admin/users", handleAdminUsers(apx))
-	http.HandleFunc("/api/apply", handleApply(apx))
-	http.HandleFunc("/api/auth/register", handleRegister(apx))
-	http.HandleFunc("/api/auth/verify-email", handleVerifyEmail(apx))
-	http.HandleFunc("/api/auth/change-email", handleChangeEmail(apx))
-	http.HandleFunc("/api/auth/verify-email-change", handleVerifyEmailChange(apx))
-	http.HandleFunc("/api/auth/login", handleLogin(apx))
-	http.HandleFunc("/api/auth/login-2fa", handleLoginVerify2FA(apx))
-	http.HandleFunc("/api/auth/trust-devices", handle2FASettings(apx))
-	http.HandleFunc("/api/auth/devices", handleDevices(apx))
-	http.HandleFunc("/api/auth/logout", handleLogout(apx))
-	http.HandleFunc("/api/auth/me", handleMe(apx))
-	http.HandleFunc("/api/auth/my-application", handleMyApplication(apx))
-	http.HandleFunc("/api/auth/profile", handleProfile(apx, uploadDir))
-	http.HandleFunc("/api/auth/links", handleLinks(apx))
-	http.HandleFunc("/api/auth/profile-settings", handleProfileSettings(apx))
-	http.HandleFunc("/api/auth/deactivate", handleDeactivateAccount(apx))
-	http.HandleFunc("/api/auth/delete", handleDeleteAccount(apx))
-	http.HandleFunc("/api/user", handleAdminPublicUser(apx))
-	http.HandleFunc("/api/users/search", handleUserSearch(apx))
-	http.HandleFunc("/auth/discord", handleDiscordOAuth(apx))
-	http.HandleFunc("/auth/discord/callback", handleDiscordCallback(apx))
-	http.HandleFunc("/auth/challengermode", handleChallengerModeOAuth(apx))
-	http.HandleFunc("/auth/challengermode/callback", handleChallengerModeCallback(apx))
-	http.HandleFunc("/auth/twitch", handleTwitchOAuth(apx))
-	http.HandleFunc("/auth/twitch/callback", handleTwitchCallback(apx))
-	http.HandleFunc("/auth/youtube", handleYouTubeOAuth(apx))
-	http.HandleFunc("/auth/youtube/callback", handleYouTubeCallback(apx))
-	http.HandleFunc("/api/admin/applications", handleAdminApplications(apx))
-	http.HandleFunc("/api/admin/team", handleAdminTeam(apx))
-	http.HandleFunc("/api/admin/staff", handleAdminStaff(apx))
-	http.HandleFunc("/api/admin/user/nickname", handleAdminUserNickname(apx))
-	http.HandleFunc("/api/admin/verify-master", handleAdminVerifyMaster(apx))
-	http.HandleFunc("/api/admin/users/", handleAdminUserActions(apx))
-	http.HandleFunc("/api/badges", handleUserBadges(apx))
-	http.HandleFunc("/api/admin/badges", handleAdminBadges(apx))
-	http.HandleFunc("/api/admin/user-badges", handleAdminUserBadges(apx))
-	http.HandleFunc("/api/admin/badges/image", handleAdminBadgeImage(apx, uploadDir))
-	http.HandleFunc("/api/admin/items", handleAdminItems(apx, uploadDir))
-	http.HandleFunc("/api/admin/items/image", handleAdminItemImage(apx, uploadDir))
-	http.HandleFunc("/api/log", handleLog(apx))
-	http.HandleFunc("/api/admin/log", handleAdminLog(apx))
-	http.HandleFunc("/api/items/my", handleMyItems(apx))
+	mux := http.NewServeMux()
+	registerRoutes(mux, apx, uploadDir)
+
+	// Proxy /dashboard/api/ → APX Stats backend
+	statsBackendURL, _ := url.Parse("http://apx-stats-backend:8080")
+	statsProxy := httputil.NewSingleHostReverseProxy(statsBackendURL)
+	proxyStats := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.URL.Path = strings.TrimPrefix(r.URL.Path, "/dashboard")
+		statsProxy.ServeHTTP(w, r)
+	})
+	// Method-qualified so the proxy does not conflict with "GET /" below.
+	for _, m := range []string{"GET", "POST", "PUT", "PATCH", "DELETE"} {
+		mux.Handle(m+" /dashboard/api/", proxyStats)
+	}
+
+	// Serve frontend files; fall back to /pages/<path> for clean URLs.
+	// GET-only so wrong methods on API routes get a 405 instead of the SPA.
+	mux.Handle("GET /", frontendHandler(frontendDir))
+	log.Printf("Serving frontend from %s", frontendDir)
+
+	addr := ":8080"
+	log.Printf("Backend listening on %s", addr)
+	log.Fatal(http.ListenAndServe(addr, withCORS(mux)))
+}
+
+// registerRoutes adds the API, OAuth and upload routes to mux. main adds
+// the stats proxy and the frontend; the handler tests use it as is.
+func registerRoutes(mux *http.ServeMux, apx Store, uploadDir string) {
+	session := loadSession(apx)
+	// Route helpers: public routes see the session user if there is one,
+	// authed routes require it and admin routes also require a permission.
+	public := func(pattern string, h http.HandlerFunc) { mux.Handle(pattern, chain(h, session)) }
+	authed := func(pattern string, h http.HandlerFunc) { mux.Handle(pattern, chain(h, session, requireAuth)) }
+	admin := func(pattern string, perm Permission, h http.HandlerFunc) {
+		mux.Handle(pattern, chain(h, session, requirePermission(perm)))
+	}
+	internal := func(pattern string, h http.HandlerFunc) { mux.Handle(pattern, chain(h, requireInternalKey)) }
+
+	// Public API
+	mux.HandleFunc("GET /api/team", handlePublicTeam(apx))
+	mux.HandleFunc("GET /api/staff", handlePublicStaff(apx))
+	mux.HandleFunc("GET /api/org", handleOrgChart(apx))
+	mux.HandleFunc("GET /api/team/ranking", handlePublicTeamRanking(apx))
+	mux.HandleFunc("GET /api/team/history", handleRosterHistory(apx))
+	mux.HandleFunc("GET /api/team/{id}/matches", handlePublicPlayerMatches(apx))
+	mux.HandleFunc("GET /api/matches", handlePublicMatches(apx))
+	mux.HandleFunc("GET /api/matches/{id}", handlePublicMatch(apx))
+	public("GET /api/user", handleAdminPublicUser(apx))
+	mux.HandleFunc("GET /api/users/search", handleUserSearch(apx))
+	authed("GET /api/badges", handleUserBadges(apx))
+	mux.HandleFunc("GET /api/log", handleLog(apx))
+	mux.HandleFunc("GET /api/stats/weekly", handleWeeklyStats(apx))
+
+	// Schedule (roster players and staff; coaches plan sessions)
+	authed("GET /api/schedule/availability", handleMyAvailability(apx))
+	authed("PUT /api/schedule/availability", handleSetMyAvailability(apx))
+	authed("GET /api/schedule/overlap", handleScheduleOverlap(apx))
+	authed("GET /api/schedule/sessions", handleScheduleSessions(apx))
+	authed("POST /api/schedule/sessions", handleCreateScheduleSession(apx))
+	authed("PUT /api/schedule/sessions/{id}", handleUpdateScheduleSession(apx))
+	authed("DELETE /api/schedule/sessions/{id}", handleDeleteScheduleSession(apx))
+	authed("POST /api/schedule/sessions/{id}/rsvp", handleSessionRSVP(apx))
+	authed("GET /api/map-pool", handleMapPool(apx))
+	authed("PUT /api/map-pool", handleSetMapPool(apx))
+	authed("GET /api/lineups", handleLineups(apx))
+	authed("GET /api/lineups/{map}", handleLineup(apx))
+	authed("PUT /api/lineups/{map}", handleSaveLineup(apx))
+	authed("DELETE /api/lineups/{map}", handleDeleteLineup(apx))
+
+	// Auth
+	mux.HandleFunc("POST /api/auth/register", handleRegister(apx))
+	mux.HandleFunc("POST /api/auth/verify-email", handleVerifyEmail(apx))
+	authed("POST /api/auth/change-email", handleChangeEmail(apx))
+	authed("POST /api/auth/verify-email-change", handleVerifyEmailChange(apx))
+	mux.HandleFunc("POST /api/auth/forgot-password", handleForgotPassword(apx))
+	mux.HandleFunc("POST /api/auth/reset-password", handleResetPassword(apx))
+	mux.HandleFunc("POST /api/auth/login", handleLogin(apx))
+	mux.HandleFunc("POST /api/auth/login-2fa", handleLoginVerify2FA(apx))
+	authed("GET /api/auth/trust-devices", handleGet2FASettings(apx))
+	authed("PUT /api/auth/trust-devices", handleUpdate2FASettings(apx))
+	authed("GET /api/auth/devices", handleListDevices(apx))
+	authed("DELETE /api/auth/devices", handleRevokeDevices(apx))
+	authed("GET /api/auth/totp", handleTOTPStatus(apx))
+	authed("POST /api/auth/totp", handleTOTPEnroll(apx))
+	authed("DELETE /api/auth/totp", handleTOTPDisable(apx))
+	authed("POST /api/auth/totp/confirm", handleTOTPConfirm(apx))
+	authed("POST /api/auth/totp/recovery-codes", handleTOTPRecoveryCodes(apx))
+	mux.HandleFunc("POST /api/auth/logout", handleLogout(apx))
+	public("GET /api/auth/me", handleMe(apx))
+	authed("GET /api/auth/my-application", handleGetMyApplication(apx))
+	authed("PUT /api/auth/my-application", handleUpdateMyApplication(apx))
+	authed("POST /api/auth/my-application/withdraw", handleWithdrawMyApplication(apx))
+	authed("PUT /api/auth/profile", handleProfile(apx, uploadDir))
+	authed("GET /api/auth/links", handleListLinks(apx))
+	authed("POST /api/auth/links", handleAddLink(apx))
+	authed("DELETE /api/auth/links", handleRemoveLink(apx))
+	authed("PUT /api/auth/profile-settings", handleProfileSettings(apx))
+	authed("PUT /api/auth/mail-settings", handleMailSettings(apx))
+	authed("POST /api/auth/deactivate", handleDeactivateAccount(apx))
+	authed("DELETE /api/auth/delete", handleDeleteAccount(apx))
+	authed("POST /api/apply", handleApply(apx))
+	public("GET /api/application-forms", handleApplicationForms(apx))
+	public("GET /api/application-forms/{game}", handleApplicationForm(apx))
+	authed("GET /api/items/my", handleMyItems(apx))
+
+	// OAuth account linking
+	public("GET /auth/discord", handleDiscordOAuth(apx))
+	mux.HandleFunc("GET /auth/discord/callback", handleDiscordCallback(apx))
+	public("GET /auth/challengermode", handleChallengerModeOAuth(apx))
+	mux.HandleFunc("GET /auth/challengermode/callback", handleChallengerModeCallback(apx))
+	public("GET /auth/twitch", handleTwitchOAuth(apx))
+	mux.HandleFunc("GET /auth/twitch/callback", handleTwitchCallback(apx))
+	public("GET /auth/youtube", handleYouTubeOAuth(apx))
+	mux.HandleFunc("GET /auth/youtube/callback", handleYouTubeCallback(apx))
+
+	// Admin
+	admin("GET /api/admin/users", PermViewUsers, handleAdminUsers(apx))
+	admin("GET /api/admin/users/{username}", PermViewUsers, handleAdminGetUser(apx))
+	admin("DELETE /api/admin/users/{username}", PermDeleteUsers, handleAdminDeleteUser(apx))
+	admin("POST /api/admin/users/{username}/deactivate", PermManageUsers, handleAdminDeactivateUser(apx))
+	admin("POST /api/admin/users/{username}/activate", PermManageUsers, handleAdminActivateUser(apx))
+	admin("POST /api/admin/users/{username}/toggle-2fa", PermManageUsers, handleAdminToggleUser2FA(apx))
+	admin("POST /api/admin/users/{username}/unlock", PermManageUsers, handleAdminUnlockUser(apx))
+	admin("POST /api/admin/users/{username}/event-access", PermManageEvents, handleAdminUserEventAccess(apx))
+	admin("POST /api/admin/users/{username}/role", PermManageRoles, handleAdminUserRole(apx))
+	admin("GET /api/admin/user/nickname", PermViewUsers, handleAdminUserNickname(apx))
+	admin("POST /api/admin/verify-master", PermManageUsers, handleAdminVerifyMaster(apx))
+	admin("GET /api/admin/roles", PermManageRoles, handleAdminRoles(apx))
+	admin("GET /api/admin/audit", PermViewAudit, handleAdminAudit(apx))
+	admin("GET /api/admin/audit/export", PermViewAudit, handleAdminAuditExport(apx))
+	admin("GET /api/admin/applications", PermReviewApplications, handleAdminApplications(apx))
+	admin("POST /api/admin/applications/{id}/status", PermReviewApplications, handleAdminApplicationStatus(apx))
+	admin("GET /api/admin/applications/{id}/history", PermReviewApplications, handleAdminApplicationHistory(apx))
+	admin("GET /api/admin/applications/{id}/reviews", PermReviewApplications, handleAdminApplicationReviews(apx))
+	admin("POST /api/admin/applications/{id}/notes", PermReviewApplications, handleAdminApplicationNote(apx))
+	admin("PUT /api/admin/applications/{id}/rating", PermReviewApplications, handleAdminApplicationRating(apx))
+	admin("PUT /api/admin/application-forms/{game}", PermManageForms, handleAdminSaveApplicationForm(apx))
+	admin("DELETE /api/admin/application-forms/{game}", PermManageForms, handleAdminDeleteApplicationForm(apx))
+	admin("GET /api/admin/team", PermManageRoster, handleAdminTeamList(apx))
+	admin("POST /api/admin/team", PermManageRoster, handleAdminTeamCreate(apx))
+	admin("PUT /api/admin/team", PermManageRoster, handleAdminTeamUpdate(apx))
+	admin("DELETE /api/admin/team", PermManageRoster, handleAdminTeamDelete(apx))
+	admin("POST /api/admin/team/import", PermManageRoster, handleAdminTeamImport(apx))
+	admin("POST /api/admin/matches", PermManageRoster, handleAdminMatchCreate(apx))
+	admin("PUT /api/admin/matches/{id}", PermManageRoster, handleAdminMatchUpdate(apx))
+	admin("DELETE /api/admin/matches/{id}", PermManageRoster, handleAdminMatchDelete(apx))
+	admin("POST /api/admin/stats/weekly/{week}", PermManageContent, handleAdminWeeklyStatsRebuild(apx))
+	admin("GET /api/admin/staff", PermManageRoster, handleAdminStaffList(apx))
+	admin("POST /api/admin/staff", PermManageRoster, handleAdminStaffCreate(apx))
+	admin("PUT /api/admin/staff", PermManageRoster, handleAdminStaffUpdate(apx))
+	admin("DELETE /api/admin/staff", PermManageRoster, handleAdminStaffDelete(apx))
+	admin("PUT /api/admin/org/staff/{id}", PermManageRoster, handleAdminStaffReporting(apx))
+	admin("GET /api/admin/org/roles", PermManageRoster, handleAdminStaffRoles(apx))
+	admin("POST /api/admin/org/roles", PermManageRoster, handleAdminStaffRoleCreate(apx))
+	admin("PUT /api/admin/org/roles/{id}", PermManageRoster, handleAdminStaffRoleUpdate(apx))
+	admin("DELETE /api/admin/org/roles/{id}", PermManageRoster, handleAdminStaffRoleDelete(apx))
+	admin("GET /api/admin/org/departments", PermManageRoster, handleAdminOrgDepartments(apx))
+	admin("POST /api/admin/org/departments", PermManageRoster, handleAdminOrgDepartmentCreate(apx))
+	admin("PUT /api/admin/org/departments/{id}", PermManageRoster, handleAdminOrgDepartmentUpdate(apx))
+	admin("DELETE /api/admin/org/departments/{id}", PermManageRoster, handleAdminOrgDepartmentDelete(apx))
+	admin("GET /api/admin/badges", PermManageBadges, handleAdminBadgeList(apx))
+	admin("POST /api/admin/badges", PermManageBadges, handleAdminBadgeCreate(apx))
+	admin("PUT /api/admin/badges", PermManageBadges, handleAdminBadgeUpdate(apx))
+	admin("DELETE /api/admin/badges", PermManageBadges, handleAdminBadgeDelete(apx))
+	admin("POST /api/admin/badges/image", PermManageBadges, handleAdminBadgeImage(apx, uploadDir))
+	admin("GET /api/admin/user-badges", PermManageBadges, handleAdminUserBadgeList(apx))
+	admin("POST /api/admin/user-badges", PermManageBadges, handleAdminUserBadgeAssign(apx))
+	admin("DELETE /api/admin/user-badges", PermManageBadges, handleAdminUserBadgeRemove(apx))
+	admin("GET /api/admin/items", PermManageItems, handleAdminItemList(apx))
+	admin("POST /api/admin/items", PermManageItems, handleAdminItemCreate(apx))
+	admin("DELETE /api/admin/items", PermManageItems, handleAdminItemDelete(apx))
+	admin("POST /api/admin/items/image", PermManageItems, handleAdminItemImage(apx, uploadDir))
+	admin("GET /api/admin/log", PermManageContent, handleAdminLogList(apx))
+	admin("POST /api/admin/log", PermManageContent, handleAdminLogCreate(apx))
+	admin("PUT /api/admin/log", PermManageContent, handleAdminLogUpdate(apx))
+	admin("DELETE /api/admin/log", PermManageContent, handleAdminLogDelete(apx))
 
 	// Events
-	http.HandleFunc("/api/events", handlePublicEvents(apx))
-	http.HandleFunc("/api/events/", handleEventRoutes(apx))
-	http.HandleFunc("/api/admin/events", handleAdminEvents(apx))
+	public("GET /api/events", handlePublicEvents(apx))
+	public("GET /api/events/{id}", handleGetEvent(apx))
+	authed("POST /api/events/{id}/join", handleJoinEvent(apx))
+	authed("POST /api/events/{id}/leave", handleLeaveEvent(apx))
+	admin("GET /api/admin/events", PermManageEvents, handleAdminEventList(apx))
+	admin("POST /api/admin/events", PermManageEvents, handleAdminEventCreate(apx))
+	admin("PUT /api/admin/events", PermManageEvents, handleAdminEventUpdate(apx))
+	admin("DELETE /api/admin/events", PermManageEvents, handleAdminEventDelete(apx))
 
 	// Twitch live status
-	http.HandleFunc("/api/twitch/live", handleTwitchLiveStatus)
+	mux.HandleFunc("GET /api/twitch/live", handleTwitchLiveStatus)
 
 	// Progression — public (Website → Go)
-	http.HandleFunc("/api/progression/profile", handleProgressionProfile(apx))
-	http.HandleFunc("/api/progression/leaderboard", handleProgressionLeaderboard(apx))
-	http.HandleFunc("/api/progression/me", handleProgressionMe(apx))
+	mux.HandleFunc("GET /api/progression/profile", handleProgressionProfile(apx))
+	mux.HandleFunc("GET /api/progression/leaderboard", handleProgressionLeaderboard(apx))
+	authed("GET /api/progression/me", handleProgressionMe(apx))
 
 	// Progression — internal (Bot → Go, secured via X-Api-Key header)
-	http.HandleFunc("/api/internal/progression/user-sync", handleInternalUserSync(apx))
-	http.HandleFunc("/api/internal/progression/inventory-add", handleInternalInventoryAdd(apx))
-	http.HandleFunc("/api/internal/progression/inventory-remove", handleInternalInventoryRemove(apx))
-	http.HandleFunc("/api/internal/progression/inventory-equip", handleInternalInventoryEquip(apx))
-	http.HandleFunc("/api/internal/progression/role-sync", handleInternalRoleSync(apx))
+	internal("POST /api/internal/progression/user-sync", handleInternalUserSync(apx))
+	internal("POST /api/internal/progression/inventory-add", handleInternalInventoryAdd(apx))
+	internal("POST /api/internal/progression/inventory-remove", handleInternalInventoryRemove(apx))
+	internal("POST /api/internal/progression/inventory-equip", handleInternalInventoryEquip(apx))
+	internal("POST /api/internal/progression/role-sync", handleInternalRoleSync(apx))
 
 	// Serve uploaded files at /public/uploads/...
 	publicDir := filepath.Dir(uploadDir) // …/public
-	http.Handle("/public/", http.StripPrefix("/public/", http.FileServer(http.Dir(publicDir))))
+	mux.Handle("GET /public/", http.StripPrefix("/public/", http.FileServer(http.Dir(publicDir))))
+}
 
-	// Proxy /dashboard/api/ → APX Stats backend
-	statsBackendURL, _ := url.Parse("http://apx-stats-backend:8080")
-	statsProxy := httputil.NewSingleHostReverseProxy(statsBackendURL)
-	http.Handle("/dashboard/api/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		r.URL.Path = strings.TrimPrefix(r.URL.Path, "/dashboard")
-		statsProxy.ServeHTTP(w, r)
-	}))
+// lookupRosterUsers fetches the linked accounts of roster or staff entries
+// in one call. Errors are logged and yield an empty result, so the roster
+// still renders with its stored names.
+func lookupRosterUsers(ctx context.Context, apx Store, usernames []string) map[string]*User {
+	var names []string
+	for _, u := range usernames {
+		if u != "" && !slices.Contains(names, u) {
+			names = append(names, u)
+		}
+	}
+	users, err := usersByUsernames(ctx, apx, names)
+	if err != nil {
+		log.Printf("GetUsersByUsernames error: %v", err)
+		return map[string]*User{}
+	}
+	return users
+}
 
-	// Serve frontend files; fall back to /pages/<path> for clean URLs
-	http.Handle("/", frontendHandler(frontendDir))
-	log.Printf("Serving frontend from %s", frontendDir)
+// usersByUsernames is GetUsersByUsernames, falling back to one lookup per
+// username when the store has no bulk lookup.
+func usersByUsernames(ctx context.Context, apx Store, usernames []string) (map[string]*User, error) {
+	users, err := apx.GetUsersByUsernames(ctx, usernames)
+	if !errors.Is(err, errUnsupported) {
+		return users, err
+	}
+	users = map[string]*User{}
+	for _, name := range usernames {
+		if name == "" {
+			continue
+		}
+		u, err := apx.GetUserByUsername(ctx, name)
+		if errors.Is(err, errNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		users[name] = u
+	}
+	return users, nil
+}
 
-	addr := ":8080"
-	log.Printf("Backend listening on %s", addr)
-	log.Fatal(http.ListenAndServe(addr, nil))
+// displayName is the nickname of u, falling back to the username.
+func displayName(u *User) string {
+	if u.Nickname != "" {
+		return u.Nickname
+	}
+	return u.Username
 }
 
-func enrichTeamAvatars(members []TeamMember, apx *ApxClient) {
+func enrichTeamAvatars(ctx context.Context, members []TeamMember, apx Store) {
+	usernames := make([]string, len(members))
 	for i := range members {
-		members[i].AvatarURL = apx.GetUserAvatarByUsername(members[i].Username)
-		if members[i].Username != "" {
-			if nick := apx.GetUserNicknameByUsername(members[i].Username); nick != "" {
-				members[i].Name = nick
-			}
+		usernames[i] = members[i].Username
+	}
+	users := lookupRosterUsers(ctx, apx, usernames)
+	for i := range members {
+		members[i].AvatarURL = ""
+		if u := users[members[i].Username]; u != nil {
+			members[i].AvatarURL = u.AvatarURL
+			members[i].Name = displayName(u)
 		}
 	}
 }
 
-func enrichStaffAvatars(staff []StaffMember, apx *ApxClient) {
+func enrichStaffAvatars(ctx context.Context, staff []StaffMember, apx Store) {
+	usernames := make([]string, len(staff))
 	for i := range staff {
-		staff[i].AvatarURL = apx.GetUserAvatarByUsername(staff[i].Username)
-		if staff[i].Username != "" {
-			if nick := apx.GetUserNicknameByUsername(staff[i].Username); nick != "" {
-				staff[i].Name = nick
-			}
+		usernames[i] = staff[i].Username
+	}
+	users := lookupRosterUsers(ctx, apx, usernames)
+	for i := range staff {
+		staff[i].AvatarURL = ""
+		if u := users[staff[i].Username]; u != nil {
+			staff[i].AvatarURL = u.AvatarURL
+			staff[i].Name = displayName(u)
 		}
 	}
 }
 
-func handlePublicTeam(apx *ApxClient) http.HandlerFunc {
+// handlePublicTeam serves GET /api/team
+func handlePublicTeam(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
+		if members, ok := publicRoster.getTeam(); ok {
+			jsonResponse(w, http.StatusOK, map[string]interface{}{"members": members})
 			return
 		}
-		if r.Method != http.MethodGet {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-			return
-		}
-		members, err := apx.GetTeamMembers()
+		gen := publicRoster.generation()
+		members, err := apx.GetTeamMembers(r.Context())
 		if err != nil {
 			log.Printf("Failed to get team members: %v", err)
 			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
 		}
-		enrichTeamAvatars(members, apx)
+		addMatchTotals(r.Context(), apx, members)
+		rateRoster(members, PlayerRatingWeights)
+		enrichTeamAvatars(r.Context(), members, apx)
+		publicRoster.setTeam(gen, members)
 		jsonResponse(w, http.StatusOK, map[string]interface{}{
 			"members": members,
 		})
 	}
 }
 
-func handlePublicStaff(apx *ApxClient) http.HandlerFunc {
+// handlePublicStaff serves GET /api/staff
+func handlePublicStaff(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		if r.Method != http.MethodGet {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		if staff, ok := publicRoster.getStaff(); ok {
+			jsonResponse(w, http.StatusOK, map[string]interface{}{"staff": staff})
 			return
 		}
-		staff, err := apx.GetStaffMembers()
+		gen := publicRoster.generation()
+		staff, err := apx.GetStaffMembers(r.Context())
 		if err != nil {
 			log.Printf("Failed to get staff: %v", err)
 			jsonError(w, http.StatusInternalServerError, "internal error")
@@ -237,584 +405,524 @@ func handlePublicStaff(apx *ApxClient) http.HandlerFunc {
 		if staff == nil {
 			staff = []StaffMember{}
 		}
-		enrichStaffAvatars(staff, apx)
+		enrichStaffAvatars(r.Context(), staff, apx)
+		publicRoster.setStaff(gen, staff)
 		jsonResponse(w, http.StatusOK, map[string]interface{}{
 			"staff": staff,
 		})
 	}
 }
 
-func handleAdminUsers(apx *ApxClient) http.HandlerFunc {
+// handleAdminUsers serves GET /api/admin/users
+func handleAdminUsers(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		if r.Method != http.MethodGet {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-			return
-		}
-		cookie, err := r.Cookie("session")
+		usernames, err := apx.GetAllUsernames(r.Context())
 		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
+			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
 		}
-		user, err := apx.GetSessionUser(cookie.Value)
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
-			return
+		if usernames == nil {
+			usernames = []string{}
 		}
-		if !user.IsAdmin {
-			jsonError(w, http.StatusForbidden, "Keine Berechtigung")
-			return
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"usernames": usernames})
+	}
+}
+
+// findTeamMember returns the roster entry with the given id, or nil.
+func findTeamMember(ctx context.Context, apx Store, id int64) *TeamMember {
+	members, err := apx.GetTeamMembers(ctx)
+	if err != nil {
+		return nil
+	}
+	for i := range members {
+		if members[i].ID == id {
+			return &members[i]
+		}
+	}
+	return nil
+}
+
+// findStaffMember returns the staff entry with the given id, or nil.
+func findStaffMember(ctx context.Context, apx Store, id int64) *StaffMember {
+	staff, err := apx.GetStaffMembers(ctx)
+	if err != nil {
+		return nil
+	}
+	for i := range staff {
+		if staff[i].ID == id {
+			return &staff[i]
 		}
-		usernames, err := apx.GetAllUsernames()
+	}
+	return nil
+}
+
+// handleAdminStaffList serves GET /api/admin/staff
+func handleAdminStaffList(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		staff, err := apx.GetStaffMembers(r.Context())
 		if err != nil {
+			log.Printf("Failed to get staff: %v", err)
 			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
 		}
-		if usernames == nil {
-			usernames = []string{}
+		if staff == nil {
+			staff = []StaffMember{}
 		}
-		jsonResponse(w, http.StatusOK, map[string]interface{}{"usernames": usernames})
+		enrichStaffAvatars(r.Context(), staff, apx)
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"staff": staff})
 	}
 }
 
-func handleAdminStaff(apx *ApxClient) http.HandlerFunc {
+// handleAdminStaffCreate serves POST /api/admin/staff
+func handleAdminStaffCreate(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
+		var req struct {
+			Name         string `json:"name"`
+			Role         string `json:"role"`
+			Username     string `json:"username"`
+			DepartmentID *int64 `json:"department_id"`
+			ReportsTo    *int64 `json:"reports_to"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
 			return
 		}
-
-		cookie, err := r.Cookie("session")
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" {
+			jsonError(w, http.StatusBadRequest, "name required")
 			return
 		}
-		user, err := apx.GetSessionUser(cookie.Value)
+		if valid, err := validStaffRole(r.Context(), apx, req.Role); err != nil {
+			log.Printf("GetStaffRoles error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		} else if !valid {
+			jsonError(w, http.StatusBadRequest, "invalid role")
+			return
+		}
+		msg, err := validateReporting(r.Context(), apx, 0, req.DepartmentID, req.ReportsTo)
 		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
+			log.Printf("validateReporting error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
 		}
-		if !user.IsAdmin {
-			jsonError(w, http.StatusForbidden, "Keine Berechtigung")
+		if msg != "" {
+			jsonError(w, http.StatusBadRequest, msg)
 			return
 		}
-
-		switch r.Method {
-		case http.MethodGet:
-			staff, err := apx.GetStaffMembers()
-			if err != nil {
-				log.Printf("Failed to get staff: %v", err)
-				jsonError(w, http.StatusInternalServerError, "internal error")
-				return
-			}
-			if staff == nil {
-				staff = []StaffMember{}
+		id, err := apx.AddStaffMember(r.Context(), req.Name, req.Role, strings.TrimSpace(req.Username))
+		if err != nil {
+			log.Printf("Failed to add staff: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		if req.DepartmentID != nil || req.ReportsTo != nil {
+			if err := apx.SetStaffReporting(r.Context(), id, req.DepartmentID, req.ReportsTo); err != nil {
+				log.Printf("SetStaffReporting error: %v", err)
 			}
-			enrichStaffAvatars(staff, apx)
-			jsonResponse(w, http.StatusOK, map[string]interface{}{"staff": staff})
+		}
+		publicRoster.invalidate()
+		recordAudit(apx, r, "staff.create", "staff", strconv.FormatInt(id, 10), nil, req)
+		jsonResponse(w, http.StatusCreated, map[string]interface{}{"id": id, "success": true})
+	}
+}
 
-		case http.MethodPost:
-			var req struct {
-				Name     string `json:"name"`
-				Role     string `json:"role"`
-				Username string `json:"username"`
-			}
-			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-				jsonError(w, http.StatusBadRequest, "invalid request body")
-				return
-			}
-			req.Name = strings.TrimSpace(req.Name)
-			if req.Name == "" {
-				jsonError(w, http.StatusBadRequest, "name required")
-				return
-			}
-			validRoles := map[string]bool{"Coach": true, "Analyst": true, "Manager": true, "Sub": true}
-			if !validRoles[req.Role] {
-				jsonError(w, http.StatusBadRequest, "invalid role")
-				return
-			}
-			id, err := apx.AddStaffMember(req.Name, req.Role, strings.TrimSpace(req.Username))
-			if err != nil {
-				log.Printf("Failed to add staff: %v", err)
+// handleAdminStaffUpdate serves PUT /api/admin/staff
+func handleAdminStaffUpdate(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID       int64  `json:"id"`
+			Name     string `json:"name"`
+			Role     string `json:"role"`
+			Username string `json:"username"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		if req.ID == 0 {
+			jsonError(w, http.StatusBadRequest, "id required")
+			return
+		}
+		if req.Role != "" {
+			if valid, err := validStaffRole(r.Context(), apx, req.Role); err != nil {
+				log.Printf("GetStaffRoles error: %v", err)
 				jsonError(w, http.StatusInternalServerError, "internal error")
 				return
-			}
-			jsonResponse(w, http.StatusCreated, map[string]interface{}{"id": id, "success": true})
-
-		case http.MethodPut:
-			var req struct {
-				ID       int64  `json:"id"`
-				Name     string `json:"name"`
-				Role     string `json:"role"`
-				Username string `json:"username"`
-			}
-			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-				jsonError(w, http.StatusBadRequest, "invalid request body")
-				return
-			}
-			if req.ID == 0 {
-				jsonError(w, http.StatusBadRequest, "id required")
-				return
-			}
-			validRoles := map[string]bool{"Coach": true, "Analyst": true, "Manager": true, "Sub": true}
-			if req.Role != "" && !validRoles[req.Role] {
+			} else if !valid {
 				jsonError(w, http.StatusBadRequest, "invalid role")
 				return
 			}
-			if err := apx.UpdateStaffMember(req.ID, strings.TrimSpace(req.Name), req.Role, strings.TrimSpace(req.Username)); err != nil {
-				log.Printf("Failed to update staff: %v", err)
-				jsonError(w, http.StatusInternalServerError, "internal error")
-				return
-			}
-			jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
-
-		case http.MethodDelete:
-			var req struct {
-				ID int64 `json:"id"`
-			}
-			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-				jsonError(w, http.StatusBadRequest, "invalid request body")
-				return
-			}
-			if req.ID == 0 {
-				jsonError(w, http.StatusBadRequest, "id required")
-				return
-			}
-			if err := apx.DeleteStaffMember(req.ID); err != nil {
-				log.Printf("Failed to delete staff: %v", err)
-				jsonError(w, http.StatusInternalServerError, "internal error")
-				return
-			}
-			jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
-
-		default:
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
 		}
+		before := findStaffMember(r.Context(), apx, req.ID)
+		if err := apx.UpdateStaffMember(r.Context(), req.ID, strings.TrimSpace(req.Name), req.Role, strings.TrimSpace(req.Username)); err != nil {
+			log.Printf("Failed to update staff: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		publicRoster.invalidate()
+		recordAudit(apx, r, "staff.update", "staff", strconv.FormatInt(req.ID, 10), before, findStaffMember(r.Context(), apx, req.ID))
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
 	}
 }
 
-func handleApply(apx *ApxClient) http.HandlerFunc {
+// handleAdminStaffDelete serves DELETE /api/admin/staff
+func handleAdminStaffDelete(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
+		var req struct {
+			ID int64 `json:"id"`
 		}
-
-		if r.Method != http.MethodPost {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
 			return
 		}
-
-		cookie, err := r.Cookie("session")
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Bitte zuerst einloggen")
+		if req.ID == 0 {
+			jsonError(w, http.StatusBadRequest, "id required")
 			return
 		}
-		user, err := apx.GetSessionUser(cookie.Value)
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Bitte zuerst einloggen")
+		before := findStaffMember(r.Context(), apx, req.ID)
+		if err := apx.DeleteStaffMember(r.Context(), req.ID); err != nil {
+			log.Printf("Failed to delete staff: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
 		}
+		publicRoster.invalidate()
+		recordAudit(apx, r, "staff.delete", "staff", strconv.FormatInt(req.ID, 10), before, nil)
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
 
-		var app Application
-		if err := json.NewDecoder(r.Body).Decode(&app); err != nil {
-			jsonError(w, http.StatusBadRequest, "invalid request body")
-			return
-		}
+// handleApply serves POST /api/apply. The submission is validated against
+// the game's application form.
+func handleApply(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := sessionUser(r)
 
-		var missing []string
-		name := strings.TrimSpace(app.Name)
-		if name == "" {
-			missing = append(missing, "name")
-		} else if len([]rune(name)) > 20 {
-			missing = append(missing, "name (max 20 Zeichen)")
-		}
-		if app.Age < 13 || app.Age > 30 {
-			missing = append(missing, "age (13–30)")
-		}
-		discord := strings.TrimSpace(app.Discord)
-		if len([]rune(discord)) < 2 || len([]rune(discord)) > 20 {
-			missing = append(missing, "discord (2–20 Zeichen)")
-		}
-		if strings.TrimSpace(app.Game) == "" {
-			missing = append(missing, "game")
-		}
-		if strings.TrimSpace(app.Rank) == "" {
-			missing = append(missing, "rank")
-		}
-		if strings.TrimSpace(app.AttackerRole) == "" {
-			missing = append(missing, "attacker_role")
-		}
-		if strings.TrimSpace(app.DefenderRole) == "" {
-			missing = append(missing, "defender_role")
-		}
-		if strings.TrimSpace(app.Experience) == "" {
-			missing = append(missing, "experience")
+		latest, err := apx.GetApplicationByUserID(r.Context(), user.ID)
+		if err != nil && !errors.Is(err, errNotFound) {
+			log.Printf("GetApplicationByUserID error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
 		}
-		if strings.TrimSpace(app.Motivation) == "" {
-			missing = append(missing, "motivation")
+		if latest != nil {
+			if msg := reapplyBlocked(latest, time.Now()); msg != "" {
+				jsonError(w, http.StatusConflict, msg)
+				return
+			}
 		}
 
-		if len(missing) > 0 {
-			msg := fmt.Sprintf(`{"error":"missing required fields: %s"}`, strings.Join(missing, ", "))
-			http.Error(w, msg, http.StatusBadRequest)
+		record, ok := buildApplicationRecord(w, r, apx, "")
+		if !ok {
 			return
 		}
-
-		record := ApplicationRecord{
-			UserID:       user.ID,
-			Name:         app.Name,
-			Age:          app.Age,
-			Discord:      app.Discord,
-			Game:         app.Game,
-			Rank:         app.Rank,
-			AttackerRole: app.AttackerRole,
-			DefenderRole: app.DefenderRole,
-			Experience:   app.Experience,
-			Motivation:   app.Motivation,
-			Availability: app.Availability,
-		}
-		if _, err := apx.CreateApplication(record); err != nil {
+		id, err := apx.CreateApplication(r.Context(), record)
+		if errors.Is(err, errApplicationOpen) {
+			jsonError(w, http.StatusConflict, "Du hast bereits eine offene Bewerbung")
+			return
+		} else if err != nil {
 			log.Printf("CreateApplication error: %v", err)
 			jsonError(w, http.StatusInternalServerError, "failed to save application")
 			return
 		}
+		record.ID = id
+		notifyApplicant(user, record, AppStatusPending, "")
 
 		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
 	}
 }
 
-func handleMyApplication(apx *ApxClient) http.HandlerFunc {
+// handleGetMyApplication serves GET /api/auth/my-application
+func handleGetMyApplication(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
+		app, err := apx.GetApplicationByUserID(r.Context(), sessionUser(r).ID)
+		if err != nil {
+			jsonResponse(w, http.StatusOK, map[string]interface{}{"application": nil})
 			return
 		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"application": app})
+	}
+}
 
-		cookie, err := r.Cookie("session")
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
+// handleUpdateMyApplication serves PUT /api/auth/my-application
+func handleUpdateMyApplication(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		current, err := apx.GetApplicationByUserID(r.Context(), sessionUser(r).ID)
+		if errors.Is(err, errNotFound) {
+			jsonError(w, http.StatusNotFound, "Keine Bewerbung gefunden")
+			return
+		} else if err != nil {
+			log.Printf("GetApplicationByUserID error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
 		}
-		user, err := apx.GetSessionUser(cookie.Value)
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
+		// Only pending applications can still be edited by the applicant.
+		if !applicationOpen(current.Status) {
+			jsonError(w, http.StatusConflict, "Bewerbung ist bereits abgeschlossen")
+			return
+		}
+		if current.Status != AppStatusPending {
+			jsonError(w, http.StatusConflict, "Bewerbung wird bereits bearbeitet und kann nicht mehr geändert werden")
 			return
 		}
 
-		switch r.Method {
-		case http.MethodGet:
-			app, err := apx.GetApplicationByUserID(user.ID)
-			if err != nil {
-				jsonResponse(w, http.StatusOK, map[string]interface{}{"application": nil})
-				return
-			}
-			jsonResponse(w, http.StatusOK, map[string]interface{}{"application": app})
-
-		case http.MethodPut:
-			var update Application
-			if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
-				jsonError(w, http.StatusBadRequest, "invalid request body")
-				return
-			}
+		record, ok := buildApplicationRecord(w, r, apx, current.Game)
+		if !ok {
+			return
+		}
+		// The store re-checks the status, so a review started meanwhile wins.
+		if err := apx.UpdateApplicationByUserID(r.Context(), sessionUser(r).ID, record); errors.Is(err, errApplicationStatusChanged) {
+			jsonError(w, http.StatusConflict, "Bewerbung wird bereits bearbeitet und kann nicht mehr geändert werden")
+			return
+		} else if err != nil {
+			log.Printf("Update application error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
 
-			record := ApplicationRecord{
-				Name:         update.Name,
-				Age:          update.Age,
-				Discord:      update.Discord,
-				Game:         update.Game,
-				Rank:         update.Rank,
-				AttackerRole: update.AttackerRole,
-				DefenderRole: update.DefenderRole,
-				Experience:   update.Experience,
-				Motivation:   update.Motivation,
-				Availability: update.Availability,
-			}
-			if err := apx.UpdateApplicationByUserID(user.ID, record); err != nil {
-				log.Printf("Update application error: %v", err)
-				jsonError(w, http.StatusInternalServerError, "internal error")
-				return
-			}
-			jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+var validLinkServices = map[string]bool{
+	"discord":        true,
+	"challengermode": true,
+	"twitch":         true,
+	"youtube":        true,
+}
 
-		default:
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+// handleListLinks serves GET /api/auth/links
+func handleListLinks(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accounts, err := apx.GetLinkedAccounts(r.Context(), sessionUser(r).ID)
+		if err != nil {
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
 		}
+		if accounts == nil {
+			accounts = []LinkedAccount{}
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"links": accounts})
 	}
 }
 
-// handleLinks serves GET /api/auth/links, POST /api/auth/links, DELETE /api/auth/links
-func handleLinks(apx *ApxClient) http.HandlerFunc {
-	validServices := map[string]bool{
-		"discord":        true,
-		"challengermode": true,
-		"twitch":         true,
-		"youtube":        true,
-	}
+// handleAddLink serves POST /api/auth/links
+func handleAddLink(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
+		var req struct {
+			Service  string `json:"service"`
+			Username string `json:"username"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
 			return
 		}
-
-		cookie, err := r.Cookie("session")
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
+		req.Service = strings.TrimSpace(req.Service)
+		req.Username = strings.TrimSpace(req.Username)
+		if !validLinkServices[req.Service] {
+			jsonError(w, http.StatusBadRequest, "invalid service")
 			return
 		}
-		user, err := apx.GetSessionUser(cookie.Value)
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
+		if req.Username == "" {
+			jsonError(w, http.StatusBadRequest, "username required")
 			return
 		}
-
-		switch r.Method {
-		case http.MethodGet:
-			accounts, err := apx.GetLinkedAccounts(user.ID)
-			if err != nil {
-				jsonError(w, http.StatusInternalServerError, "internal error")
-				return
-			}
-			if accounts == nil {
-				accounts = []LinkedAccount{}
-			}
-			jsonResponse(w, http.StatusOK, map[string]interface{}{"links": accounts})
-
-		case http.MethodPost:
-			var req struct {
-				Service  string `json:"service"`
-				Username string `json:"username"`
-			}
-			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-				jsonError(w, http.StatusBadRequest, "invalid request body")
-				return
-			}
-			req.Service = strings.TrimSpace(req.Service)
-			req.Username = strings.TrimSpace(req.Username)
-			if !validServices[req.Service] {
-				jsonError(w, http.StatusBadRequest, "invalid service")
-				return
-			}
-			if req.Username == "" {
-				jsonError(w, http.StatusBadRequest, "username required")
-				return
-			}
-			if err := apx.UpsertLinkedAccount(user.ID, req.Service, "", req.Username, "", ""); err != nil {
-				jsonError(w, http.StatusInternalServerError, "internal error")
-				return
-			}
-			jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
-
-		case http.MethodDelete:
-			service := r.URL.Query().Get("service")
-			if !validServices[service] {
-				jsonError(w, http.StatusBadRequest, "invalid service")
-				return
-			}
-			if err := apx.DeleteLinkedAccount(user.ID, service); err != nil {
-				jsonError(w, http.StatusInternalServerError, "internal error")
-				return
-			}
-			jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
-
-		default:
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		if err := apx.UpsertLinkedAccount(r.Context(), sessionUser(r).ID, req.Service, "", req.Username, "", ""); err != nil {
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
 		}
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
 	}
 }
 
-func handleAdminApplications(apx *ApxClient) http.HandlerFunc {
+// handleRemoveLink serves DELETE /api/auth/links?service=...
+func handleRemoveLink(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
+		service := r.URL.Query().Get("service")
+		if !validLinkServices[service] {
+			jsonError(w, http.StatusBadRequest, "invalid service")
 			return
 		}
-		if r.Method != http.MethodGet {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		if err := apx.DeleteLinkedAccount(r.Context(), sessionUser(r).ID, service); err != nil {
+			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
 		}
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
 
-		cookie, err := r.Cookie("session")
+// handleAdminUserNickname serves GET /api/admin/user/nickname?username=...
+func handleAdminUserNickname(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := r.URL.Query().Get("username")
+		nickname := apx.GetUserNicknameByUsername(r.Context(), username)
+		jsonResponse(w, http.StatusOK, map[string]string{"nickname": nickname})
+	}
+}
+
+// handleAdminTeamList serves GET /api/admin/team
+func handleAdminTeamList(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		members, err := apx.GetTeamMembers(r.Context())
 		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
+			log.Printf("Failed to get team members: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
 		}
+		addMatchTotals(r.Context(), apx, members)
+		rateRoster(members, PlayerRatingWeights)
+		enrichTeamAvatars(r.Context(), members, apx)
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"members": members,
+		})
+	}
+}
 
-		user, err := apx.GetSessionUser(cookie.Value)
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
+// handleAdminTeamCreate serves POST /api/admin/team
+func handleAdminTeamCreate(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Name         string `json:"name"`
+			Username     string `json:"username"`
+			AtkRole      string `json:"atk_role"`
+			DefRole      string `json:"def_role"`
+			IsMainRoster bool   `json:"is_main_roster"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
 			return
 		}
-
-		if !user.IsAdmin {
-			jsonError(w, http.StatusForbidden, "Keine Berechtigung")
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" {
+			jsonError(w, http.StatusBadRequest, "name required")
 			return
 		}
-
-		apps, err := apx.GetApplications()
+		if req.IsMainRoster {
+			count, _ := apx.CountMainRoster(r.Context(), 0)
+			if count >= 5 {
+				jsonError(w, http.StatusBadRequest, "Maximal 5 Main Roster Spieler erlaubt")
+				return
+			}
+		}
+		id, err := apx.AddTeamMember(r.Context(), req.Name, req.Username, req.AtkRole, req.DefRole, req.IsMainRoster)
 		if err != nil {
-			log.Printf("Failed to get applications: %v", err)
+			log.Printf("Failed to add team member: %v", err)
 			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
 		}
-
-		jsonResponse(w, http.StatusOK, map[string]interface{}{
-			"applications": apps,
-		})
+		publicRoster.invalidate()
+		recordRosterEvents(r.Context(), apx, rosterJoined(id, req.Name, req.Username, req.IsMainRoster))
+		recordAudit(apx, r, "team.create", "team_member", strconv.FormatInt(id, 10), nil, req)
+		jsonResponse(w, http.StatusCreated, map[string]interface{}{"id": id, "success": true})
 	}
 }
 
-func handleAdminUserNickname(apx *ApxClient) http.HandlerFunc {
+// handleAdminTeamUpdate serves PUT /api/admin/team
+func handleAdminTeamUpdate(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+		var body map[string]json.RawMessage
+		var m TeamMember
+		data, err := io.ReadAll(r.Body)
+		if err != nil || json.Unmarshal(data, &body) != nil || json.Unmarshal(data, &m) != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
 			return
 		}
-		cookie, err := r.Cookie("session")
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
+		if m.ID == 0 {
+			jsonError(w, http.StatusBadRequest, "missing player id")
 			return
 		}
-		user, err := apx.GetSessionUser(cookie.Value)
-		if err != nil || !user.IsAdmin {
-			jsonError(w, http.StatusForbidden, "Keine Berechtigung")
+		if m.IsMainRoster {
+			count, _ := apx.CountMainRoster(r.Context(), m.ID)
+			if count >= 5 {
+				jsonError(w, http.StatusBadRequest, "Maximal 5 Main Roster Spieler erlaubt")
+				return
+			}
+		}
+		before := findTeamMember(r.Context(), apx, m.ID)
+		if before == nil {
+			jsonError(w, http.StatusNotFound, "Spieler nicht gefunden")
 			return
 		}
-		username := r.URL.Query().Get("username")
-		nickname := apx.GetUserNicknameByUsername(username)
-		jsonResponse(w, http.StatusOK, map[string]string{"nickname": nickname})
+		// The counters are derived from match stat lines; the stored values
+		// are only the totals from before matches were recorded. Clients may
+		// echo the displayed totals back but not change them.
+		shown := []TeamMember{*before}
+		addMatchTotals(r.Context(), apx, shown)
+		submitted, current := m.counters(), shown[0].counters()
+		for f, name := range playerStatFields {
+			if _, ok := body[name]; ok && *submitted[f] != *current[f] {
+				jsonError(w, http.StatusBadRequest, "Statistiken werden aus den Matches berechnet und können hier nicht geändert werden")
+				return
+			}
+		}
+		m.PlayerStats = before.PlayerStats
+		if _, ok := body["rounds"]; !ok {
+			m.Rounds = before.Rounds
+		}
+		if _, ok := body["kost_points"]; !ok {
+			m.KostPoints = before.KostPoints
+		}
+		if m.Rounds < 0 || m.KostPoints < 0 || m.KostPoints > m.Rounds {
+			jsonError(w, http.StatusBadRequest, "KOST-Punkte müssen zwischen 0 und der Rundenzahl liegen")
+			return
+		}
+		if err := apx.UpdateTeamMember(r.Context(), m); err != nil {
+			log.Printf("Failed to update team member: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		publicRoster.invalidate()
+		members, _ := apx.GetTeamMembers(r.Context())
+		recordRosterEvents(r.Context(), apx, rosterChanges(*before, m, members)...)
+		recordAudit(apx, r, "team.update", "team_member", strconv.FormatInt(m.ID, 10), before, findTeamMember(r.Context(), apx, m.ID))
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
 	}
 }
 
-func handleAdminTeam(apx *ApxClient) http.HandlerFunc {
+// handleAdminTeamDelete serves DELETE /api/admin/team
+func handleAdminTeamDelete(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
+		var req struct {
+			ID     int64  `json:"id"`
+			Reason string `json:"reason"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
 			return
 		}
-
-		cookie, err := r.Cookie("session")
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
+		if req.ID == 0 {
+			jsonError(w, http.StatusBadRequest, "id required")
 			return
 		}
-		user, err := apx.GetSessionUser(cookie.Value)
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
+		req.Reason = strings.TrimSpace(req.Reason)
+		if len([]rune(req.Reason)) > 200 {
+			jsonError(w, http.StatusBadRequest, "Grund zu lang (max 200 Zeichen)")
 			return
 		}
-		if !user.IsAdmin {
-			jsonError(w, http.StatusForbidden, "Keine Berechtigung")
+		members, _ := apx.GetTeamMembers(r.Context())
+		before := findTeamMember(r.Context(), apx, req.ID)
+		if err := apx.DeleteTeamMember(r.Context(), req.ID); err != nil {
+			log.Printf("Failed to delete team member: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
 		}
-
-		switch r.Method {
-		case http.MethodPost:
-			var req struct {
-				Name         string `json:"name"`
-				Username     string `json:"username"`
-				AtkRole      string `json:"atk_role"`
-				DefRole      string `json:"def_role"`
-				IsMainRoster bool   `json:"is_main_roster"`
-			}
-			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-				jsonError(w, http.StatusBadRequest, "invalid request body")
-				return
-			}
-			req.Name = strings.TrimSpace(req.Name)
-			if req.Name == "" {
-				jsonError(w, http.StatusBadRequest, "name required")
-				return
-			}
-			if req.IsMainRoster {
-				count, _ := apx.CountMainRoster(0)
-				if count >= 5 {
-					jsonError(w, http.StatusBadRequest, "Maximal 5 Main Roster Spieler erlaubt")
-					return
+		publicRoster.invalidate()
+		if before != nil {
+			events := []RosterEvent{{
+				TeamMemberID: before.ID, Username: before.Username, Name: before.Name,
+				Kind: RosterLeft, From: rosterStatus(before.IsMainRoster), Reason: req.Reason,
+			}}
+			// The store unpairs the leaving player's partners.
+			for _, m := range members {
+				if m.PairedWith != nil && *m.PairedWith == before.ID {
+					events = append(events, RosterEvent{
+						TeamMemberID: m.ID, Username: m.Username, Name: m.Name,
+						Kind: RosterPairing, From: before.Name,
+					})
 				}
 			}
-			id, err := apx.AddTeamMember(req.Name, req.Username, req.AtkRole, req.DefRole, req.IsMainRoster)
-			if err != nil {
-				log.Printf("Failed to add team member: %v", err)
-				jsonError(w, http.StatusInternalServerError, "internal error")
-				return
-			}
-			jsonResponse(w, http.StatusCreated, map[string]interface{}{"id": id, "success": true})
-
-		case http.MethodGet:
-			members, err := apx.GetTeamMembers()
-			if err != nil {
-				log.Printf("Failed to get team members: %v", err)
-				jsonError(w, http.StatusInternalServerError, "internal error")
-				return
-			}
-			enrichTeamAvatars(members, apx)
-			jsonResponse(w, http.StatusOK, map[string]interface{}{
-				"members": members,
-			})
-
-		case http.MethodPut:
-			var m TeamMember
-			if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
-				jsonError(w, http.StatusBadRequest, "invalid request body")
-				return
-			}
-			if m.ID == 0 {
-				jsonError(w, http.StatusBadRequest, "missing player id")
-				return
-			}
-			if m.IsMainRoster {
-				count, _ := apx.CountMainRoster(m.ID)
-				if count >= 5 {
-					jsonError(w, http.StatusBadRequest, "Maximal 5 Main Roster Spieler erlaubt")
-					return
-				}
-			}
-			ratingFields := []*int{
-				&m.KillEntry, &m.KillTrade, &m.KillImpact, &m.KillLate,
-				&m.DeathEntry, &m.DeathTrade, &m.DeathLate,
-				&m.Clutch1v1, &m.Clutch1v2, &m.Clutch1v3, &m.Clutch1v4, &m.Clutch1v5,
-				&m.ObjPlant, &m.ObjDefuse,
-			}
-			for _, f := range ratingFields {
-				if *f < 0 {
-					*f = 0
-				}
-			}
-			if err := apx.UpdateTeamMember(m); err != nil {
-				log.Printf("Failed to update team member: %v", err)
-				jsonError(w, http.StatusInternalServerError, "internal error")
-				return
-			}
-			jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
-
-		case http.MethodDelete:
-			var req struct {
-				ID int64 `json:"id"`
-			}
-			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-				jsonError(w, http.StatusBadRequest, "invalid request body")
-				return
-			}
-			if req.ID == 0 {
-				jsonError(w, http.StatusBadRequest, "id required")
-				return
-			}
-			if err := apx.DeleteTeamMember(req.ID); err != nil {
-				log.Printf("Failed to delete team member: %v", err)
-				jsonError(w, http.StatusInternalServerError, "internal error")
-				return
-			}
-			jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
-
-		default:
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
+			recordRosterEvents(r.Context(), apx, events...)
 		}
+		recordAudit(apx, r, "team.delete", "team_member", strconv.FormatInt(req.ID, 10), before, nil)
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
 	}
 }
 
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// counters returns pointers to all counters, in the column order used by
+// the stores.
+func (p *PlayerStats) counters() []*int {
+	return []*int{
+		&p.KillEntry, &p.KillTrade, &p.KillImpact, &p.KillLate,
+		&p.DeathEntry, &p.DeathTrade, &p.DeathLate,
+		&p.Clutch1v1, &p.Clutch1v2, &p.Clutch1v3, &p.Clutch1v4, &p.Clutch1v5,
+		&p.ObjPlant, &p.ObjDefuse,
+	}
+}
+
+// add adds o's counters to p.
+func (p *PlayerStats) add(o PlayerStats) {
+	src := o.counters()
+	for i, c := range p.counters() {
+		*c += *src[i]
+	}
+}
+
+// clampNegative sets negative counters to 0.
+func (p *PlayerStats) clampNegative() {
+	for _, c := range p.counters() {
+		if *c < 0 {
+			*c = 0
+		}
+	}
+}
+
+func (p PlayerStats) kills() int {
+	return p.KillEntry + p.KillTrade + p.KillImpact + p.KillLate
+}
+
+func (p PlayerStats) deaths() int {
+	return p.DeathEntry + p.DeathTrade + p.DeathLate
+}
+
+// killDeathRatio is kills per death, rounded to two decimals; without deaths
+// it is the kill count.
+func killDeathRatio(kills, deaths int) float64 {
+	return math.Round(float64(kills)/float64(max(deaths, 1))*100) / 100
+}
+
+// addMatchTotals adds each member's summed match stat lines to the stored
+// counters. On error the stored counters are left as they are.
+func addMatchTotals(ctx context.Context, apx Store, members []TeamMember) {
+	totals, err := apx.GetMatchStatTotals(ctx)
+	if err != nil {
+		log.Printf("GetMatchStatTotals error: %v", err)
+		return
+	}
+	for i := range members {
+		if t, ok := totals[members[i].ID]; ok {
+			members[i].add(t)
+		}
+	}
+}
+
+// parseMatchTime accepts RFC 3339 as well as the date and datetime-local
+// values of HTML inputs (read as UTC) and returns RFC 3339 in UTC.
+func parseMatchTime(s string) (string, bool) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC().Format(time.RFC3339), true
+		}
+	}
+	return "", false
+}
+
+// validateMatch normalises m and checks it against the current roster.
+// Players in previous (the stored lines of an edited match) stay valid after
+// leaving the roster. It returns a user-facing error message, or "".
+func validateMatch(ctx context.Context, apx Store, m *Match, previous []MatchPlayerStats) (string, error) {
+	m.Opponent = strings.TrimSpace(m.Opponent)
+	m.Map = strings.TrimSpace(m.Map)
+	m.Competition = strings.TrimSpace(m.Competition)
+	if m.Opponent == "" || len([]rune(m.Opponent)) > 60 {
+		return "Gegner erforderlich (max 60 Zeichen)", nil
+	}
+	if len([]rune(m.Map)) > 40 || len([]rune(m.Competition)) > 60 {
+		return "Map (max 40) oder Wettbewerb (max 60 Zeichen) zu lang", nil
+	}
+	playedAt, ok := parseMatchTime(strings.TrimSpace(m.PlayedAt))
+	if !ok {
+		return "Ungültiges Datum", nil
+	}
+	m.PlayedAt = playedAt
+	if m.ScoreUs < 0 || m.ScoreThem < 0 || m.ScoreUs > 99 || m.ScoreThem > 99 {
+		return "Ungültiger Spielstand", nil
+	}
+	if len(m.Players) == 0 || len(m.Players) > 10 {
+		return "1 bis 10 Spieler erforderlich", nil
+	}
+
+	members, err := apx.GetTeamMembers(ctx)
+	if err != nil {
+		return "", err
+	}
+	names := make(map[int64]string, len(members))
+	for _, p := range previous {
+		names[p.TeamMemberID] = p.Name
+	}
+	for _, tm := range members {
+		names[tm.ID] = tm.Name
+	}
+	seen := map[int64]bool{}
+	for i := range m.Players {
+		p := &m.Players[i]
+		name, ok := names[p.TeamMemberID]
+		if !ok {
+			return "Unbekannter Spieler: " + strconv.FormatInt(p.TeamMemberID, 10), nil
+		}
+		if seen[p.TeamMemberID] {
+			return "Spieler doppelt: " + name, nil
+		}
+		seen[p.TeamMemberID] = true
+		p.Name = name
+		p.clampNegative()
+	}
+	return "", nil
+}
+
+// handlePublicMatches serves GET /api/matches?limit=&offset=, newest first.
+func handlePublicMatches(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit <= 0 || limit > 100 {
+			limit = 20
+		}
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		offset = max(offset, 0)
+
+		matches, err := apx.GetMatches(r.Context())
+		if err != nil {
+			log.Printf("GetMatches error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		total := len(matches)
+		matches = matches[min(offset, total):min(offset+limit, total)]
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"matches": matches,
+			"total":   total,
+		})
+	}
+}
+
+// handlePublicMatch serves GET /api/matches/{id}.
+func handlePublicMatch(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathID(w, r)
+		if !ok {
+			return
+		}
+		m, err := apx.GetMatch(r.Context(), id)
+		if errors.Is(err, errNotFound) {
+			jsonError(w, http.StatusNotFound, "Match nicht gefunden")
+			return
+		} else if err != nil {
+			log.Printf("GetMatch error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"match": m})
+	}
+}
+
+// trendWindow is the number of matches the rolling K/D averages over.
+const trendWindow = 5
+
+// playerMatchTrend is one match of a player's history with derived numbers.
+type playerMatchTrend struct {
+	PlayerMatchLine
+	Kills     int     `json:"kills"`
+	Deaths    int     `json:"deaths"`
+	KD        float64 `json:"kd"`
+	RollingKD float64 `json:"rolling_kd"` // over this and the previous trendWindow-1 matches
+}
+
+// handlePublicPlayerMatches serves GET /api/team/{id}/matches: the player's
+// stat lines, oldest first, with per-match and rolling K/D plus the summed
+// match totals.
+func handlePublicPlayerMatches(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathID(w, r)
+		if !ok {
+			return
+		}
+		if findTeamMember(r.Context(), apx, id) == nil {
+			jsonError(w, http.StatusNotFound, "Spieler nicht gefunden")
+			return
+		}
+		lines, err := apx.GetPlayerMatchLines(r.Context(), id)
+		if err != nil {
+			log.Printf("GetPlayerMatchLines error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		trend := make([]playerMatchTrend, len(lines))
+		var totals PlayerStats
+		for i, l := range lines {
+			totals.add(l.PlayerStats)
+			var kills, deaths int
+			for _, prev := range lines[max(0, i-trendWindow+1) : i+1] {
+				kills += prev.kills()
+				deaths += prev.deaths()
+			}
+			trend[i] = playerMatchTrend{
+				PlayerMatchLine: l,
+				Kills:           l.kills(),
+				Deaths:          l.deaths(),
+				KD:              killDeathRatio(l.kills(), l.deaths()),
+				RollingKD:       killDeathRatio(kills, deaths),
+			}
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"team_member_id": id,
+			"matches":        trend,
+			"totals":         totals,
+			"kd":             killDeathRatio(totals.kills(), totals.deaths()),
+		})
+	}
+}
+
+// handleAdminMatchCreate serves POST /api/admin/matches.
+func handleAdminMatchCreate(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var m Match
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		if msg, err := validateMatch(r.Context(), apx, &m, nil); err != nil {
+			log.Printf("validateMatch error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		} else if msg != "" {
+			jsonError(w, http.StatusBadRequest, msg)
+			return
+		}
+		id, err := apx.CreateMatch(r.Context(), m)
+		if err != nil {
+			log.Printf("CreateMatch error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		m.ID = id
+		publicRoster.invalidate()
+		recordAudit(apx, r, "match.create", "match", strconv.FormatInt(id, 10), nil, m)
+		jsonResponse(w, http.StatusCreated, map[string]interface{}{"id": id, "success": true})
+	}
+}
+
+// handleAdminMatchUpdate serves PUT /api/admin/matches/{id}. The stat lines
+// in the body replace all lines of the match.
+func handleAdminMatchUpdate(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathID(w, r)
+		if !ok {
+			return
+		}
+		var m Match
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		m.ID = id
+		before, err := apx.GetMatch(r.Context(), id)
+		if errors.Is(err, errNotFound) {
+			jsonError(w, http.StatusNotFound, "Match nicht gefunden")
+			return
+		} else if err != nil {
+			log.Printf("GetMatch error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		if msg, err := validateMatch(r.Context(), apx, &m, before.Players); err != nil {
+			log.Printf("validateMatch error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		} else if msg != "" {
+			jsonError(w, http.StatusBadRequest, msg)
+			return
+		}
+		if err := apx.UpdateMatch(r.Context(), m); err != nil {
+			log.Printf("UpdateMatch error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		m.CreatedAt = before.CreatedAt
+		publicRoster.invalidate()
+		recordAudit(apx, r, "match.update", "match", strconv.FormatInt(id, 10), before, m)
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
+
+// handleAdminMatchDelete serves DELETE /api/admin/matches/{id}.
+func handleAdminMatchDelete(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathID(w, r)
+		if !ok {
+			return
+		}
+		before, err := apx.GetMatch(r.Context(), id)
+		if errors.Is(err, errNotFound) {
+			jsonError(w, http.StatusNotFound, "Match nicht gefunden")
+			return
+		} else if err != nil {
+			log.Printf("GetMatch error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		if err := apx.DeleteMatch(r.Context(), id); err != nil {
+			log.Printf("DeleteMatch error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		publicRoster.invalidate()
+		recordAudit(apx, r, "match.delete", "match", strconv.FormatInt(id, 10), before, nil)
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
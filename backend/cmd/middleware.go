@@ -1,8 +1,12 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 )
 
 func jsonResponse(w http.ResponseWriter, status int, data interface{}) {
@@ -14,3 +18,120 @@ func jsonResponse(w http.ResponseWriter, status int, data interface{}) {
 func jsonError(w http.ResponseWriter, status int, message string) {
 	jsonResponse(w, status, map[string]string{"error": message})
 }
+
+// pathID parses the {id} path segment. On failure it writes 400 and returns
+// false.
+func pathID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		jsonError(w, http.StatusBadRequest, "invalid id")
+		return 0, false
+	}
+	return id, true
+}
+
+// middleware wraps a handler. Chains are applied outermost first.
+type middleware func(http.Handler) http.Handler
+
+func chain(h http.Handler, mws ...middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+type ctxKey int
+
+const ctxSessionUser ctxKey = iota
+
+// loadSession resolves the "session" cookie and stores the user in the
+// request context. Missing or invalid sessions leave the request anonymous.
+func loadSession(apx Store) middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if cookie, err := r.Cookie("session"); err == nil && cookie.Value != "" {
+				if user, err := apx.GetSessionUser(r.Context(), cookie.Value); err == nil {
+					r = r.WithContext(context.WithValue(r.Context(), ctxSessionUser, user))
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// sessionUser returns the user loaded by loadSession, or nil.
+func sessionUser(r *http.Request) *User {
+	u, _ := r.Context().Value(ctxSessionUser).(*User)
+	return u
+}
+
+// requireAuth rejects anonymous requests. Must run after loadSession.
+func requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if sessionUser(r) == nil {
+			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requirePermission rejects requests whose user lacks perm. Must run after
+// loadSession.
+func requirePermission(perm Permission) middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := sessionUser(r)
+			if user == nil {
+				jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
+				return
+			}
+			if !user.can(perm) {
+				jsonError(w, http.StatusForbidden, "Keine Berechtigung")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requireInternalKey guards bot → backend endpoints with the X-Api-Key header.
+func requireInternalKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != os.Getenv("INTERNAL_API_KEY") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withCORS answers preflight requests before routing and, for origins listed
+// in CORS_ALLOWED_ORIGINS (comma-separated), adds the CORS response headers.
+// Without the variable only same-origin requests (nginx / Vite proxy) work.
+func withCORS(next http.Handler) http.Handler {
+	allowed := map[string]bool{}
+	for _, o := range strings.Split(os.Getenv("CORS_ALLOWED_ORIGINS"), ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			allowed[o] = true
+		}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if origin := r.Header.Get("Origin"); origin != "" && allowed[origin] {
+			h := w.Header()
+			h.Set("Access-Control-Allow-Origin", origin)
+			h.Set("Access-Control-Allow-Credentials", "true")
+			h.Add("Vary", "Origin")
+			if r.Method == http.MethodOptions {
+				h.Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+				h.Set("Access-Control-Allow-Headers", "Content-Type, X-Api-Key")
+				h.Set("Access-Control-Max-Age", "600")
+			}
+		}
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -1 +1,301 @@
 package main
+
+import (
+	"database/sql"
+	"embed"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+//go:embed migrations/*/*.sql
+var migrationFS embed.FS
+
+// migration is one embedded .sql file. Version is "<dir>/<file>", e.g.
+// "users/001_init.sql", and is what schema_migrations records.
+type migration struct {
+	Version string
+	SQL     string
+}
+
+// migrationTarget is a database together with the migration dirs it runs.
+type migrationTarget struct {
+	Name     string // "sqlite" or "neon", used in messages
+	DB       *sql.DB
+	Dirs     []string
+	Postgres bool
+}
+
+func (t *migrationTarget) placeholder() string {
+	if t.Postgres {
+		return "$1"
+	}
+	return "?"
+}
+
+// migrationTargets opens every database this process is responsible for: the
+// local SQLite file when it is the data store, and the Neon database behind
+// ApxApi when NEON_DATABASE_URL is set. Callers must close the returned DBs.
+func migrationTargets() ([]*migrationTarget, error) {
+	var targets []*migrationTarget
+	kind, err := storeKind()
+	if err != nil {
+		return nil, err
+	}
+	if kind == "sqlite" {
+		db, err := openSQLiteDB(sqlitePath())
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, &migrationTarget{Name: "sqlite", DB: db, Dirs: []string{"users", "data"}})
+	}
+	if dsn := os.Getenv("NEON_DATABASE_URL"); dsn != "" {
+		db, err := sql.Open("pgx", dsn)
+		if err != nil {
+			closeTargets(targets)
+			return nil, err
+		}
+		targets = append(targets, &migrationTarget{Name: "neon", DB: db, Dirs: []string{"neon"}, Postgres: true})
+	}
+	return targets, nil
+}
+
+func closeTargets(targets []*migrationTarget) {
+	for _, t := range targets {
+		t.DB.Close()
+	}
+}
+
+// loadMigrations returns the migrations of dirs, dir by dir in the given
+// order and sorted by file name within each dir.
+func loadMigrations(dirs []string) ([]migration, error) {
+	var out []migration
+	for _, dir := range dirs {
+		entries, err := fs.ReadDir(migrationFS, "migrations/"+dir)
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, 0, len(entries))
+		for _, e := range entries {
+			if strings.HasSuffix(e.Name(), ".sql") {
+				names = append(names, e.Name())
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			body, err := migrationFS.ReadFile("migrations/" + dir + "/" + name)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, migration{Version: dir + "/" + name, SQL: string(body)})
+		}
+	}
+	return out, nil
+}
+
+// appliedMigrations creates schema_migrations if needed and returns the
+// recorded versions.
+func (t *migrationTarget) appliedMigrations() (map[string]bool, error) {
+	ddl := `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    TEXT PRIMARY KEY,
+		applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`
+	if t.Postgres {
+		ddl = `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    TEXT        PRIMARY KEY,
+		applied_at TIMESTAMPTZ DEFAULT NOW()
+	)`
+	}
+	if _, err := t.DB.Exec(ddl); err != nil {
+		return nil, fmt.Errorf("%s: create schema_migrations: %w", t.Name, err)
+	}
+	rows, err := t.DB.Query(`SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: read schema_migrations: %w", t.Name, err)
+	}
+	defer rows.Close()
+	applied := map[string]bool{}
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		applied[v] = true
+	}
+	return applied, rows.Err()
+}
+
+// pendingMigrations returns the migrations of t not yet recorded, in order.
+func (t *migrationTarget) pendingMigrations() ([]migration, error) {
+	all, err := loadMigrations(t.Dirs)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := t.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	var pending []migration
+	for _, m := range all {
+		if !applied[m.Version] {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// apply runs one migration and records it in the same transaction, so a
+// failing file leaves neither partial schema changes nor a version row.
+func (t *migrationTarget) apply(m migration) error {
+	tx, err := t.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(m.SQL); err != nil {
+		return fmt.Errorf("%s: migration %s: %w", t.Name, m.Version, err)
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES (`+t.placeholder()+`)`, m.Version); err != nil {
+		return fmt.Errorf("%s: record %s: %w", t.Name, m.Version, err)
+	}
+	return tx.Commit()
+}
+
+// migrateUp applies all pending migrations of t, stopping at the first error.
+func (t *migrationTarget) migrateUp() (int, error) {
+	pending, err := t.pendingMigrations()
+	if err != nil {
+		return 0, err
+	}
+	for i, m := range pending {
+		if err := t.apply(m); err != nil {
+			return i, err
+		}
+		log.Printf("%s: applied %s", t.Name, m.Version)
+	}
+	return len(pending), nil
+}
+
+// checkMigrations is run before the server starts. Pending migrations are a
+// fatal error unless AUTO_MIGRATE=1, in which case they are applied.
+func checkMigrations() error {
+	targets, err := migrationTargets()
+	if err != nil {
+		return err
+	}
+	defer closeTargets(targets)
+	auto := os.Getenv("AUTO_MIGRATE") == "1"
+	for _, t := range targets {
+		if auto {
+			if _, err := t.migrateUp(); err != nil {
+				return err
+			}
+			continue
+		}
+		pending, err := t.pendingMigrations()
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("%s: %d pending migration(s), starting with %s; run \"%s migrate up\" or set AUTO_MIGRATE=1",
+				t.Name, len(pending), pending[0].Version, os.Args[0])
+		}
+	}
+	return nil
+}
+
+// baseline records every migration of t as applied without running it. It is
+// meant for databases whose schema predates the runner.
+func (t *migrationTarget) baseline() (int, error) {
+	pending, err := t.pendingMigrations()
+	if err != nil {
+		return 0, err
+	}
+	for i, m := range pending {
+		if _, err := t.DB.Exec(`INSERT INTO schema_migrations (version) VALUES (`+t.placeholder()+`)`, m.Version); err != nil {
+			return i, fmt.Errorf("%s: record %s: %w", t.Name, m.Version, err)
+		}
+	}
+	return len(pending), nil
+}
+
+// runMigrateCommand implements "migrate status", "migrate up [-dry-run]" and
+// "migrate baseline".
+func runMigrateCommand(args []string) int {
+	usage := func() {
+		fmt.Fprintln(os.Stderr, "usage: migrate status | migrate up [-dry-run] | migrate baseline")
+	}
+	if len(args) == 0 {
+		usage()
+		return 2
+	}
+	fset := flag.NewFlagSet("migrate "+args[0], flag.ContinueOnError)
+	dryRun := fset.Bool("dry-run", false, "list pending migrations without applying them")
+	if err := fset.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if args[0] != "status" && args[0] != "up" && args[0] != "baseline" {
+		usage()
+		return 2
+	}
+
+	targets, err := migrationTargets()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer closeTargets(targets)
+	if len(targets) == 0 {
+		fmt.Println("no migration targets (ApxApi store without NEON_DATABASE_URL)")
+		return 0
+	}
+
+	for _, t := range targets {
+		if args[0] == "status" || *dryRun {
+			all, err := loadMigrations(t.Dirs)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+			applied, err := t.appliedMigrations()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+			pending := 0
+			for _, m := range all {
+				state := "applied"
+				if !applied[m.Version] {
+					state, pending = "pending", pending+1
+				}
+				if args[0] == "status" || state == "pending" {
+					fmt.Printf("%-6s %-8s %s\n", t.Name, state, m.Version)
+				}
+			}
+			fmt.Printf("%s: %d applied, %d pending\n", t.Name, len(all)-pending, pending)
+			continue
+		}
+		if args[0] == "baseline" {
+			n, err := t.baseline()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+			fmt.Printf("%s: %d migration(s) marked as applied\n", t.Name, n)
+			continue
+		}
+		n, err := t.migrateUp()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("%s: %d migration(s) applied\n", t.Name, n)
+	}
+	return 0
+}
@@ -0,0 +1,39 @@
+-- Match results with per-player stat lines. A player's public totals are the
+-- counters on team (kept from before match tracking) plus the sum of their
+-- lines here.
+
+CREATE TABLE IF NOT EXISTS matches (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    opponent    TEXT     NOT NULL,
+    played_at   DATETIME NOT NULL,
+    map         TEXT     NOT NULL DEFAULT '',
+    competition TEXT     NOT NULL DEFAULT '',
+    score_us    INTEGER  NOT NULL DEFAULT 0,
+    score_them  INTEGER  NOT NULL DEFAULT 0,
+    created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_matches_played_at ON matches(played_at);
+
+CREATE TABLE IF NOT EXISTS match_players (
+    match_id       INTEGER NOT NULL REFERENCES matches(id) ON DELETE CASCADE,
+    team_member_id INTEGER NOT NULL REFERENCES team(id) ON DELETE CASCADE,
+    name           TEXT    NOT NULL DEFAULT '',
+    kill_entry     INTEGER NOT NULL DEFAULT 0,
+    kill_trade     INTEGER NOT NULL DEFAULT 0,
+    kill_impact    INTEGER NOT NULL DEFAULT 0,
+    kill_late      INTEGER NOT NULL DEFAULT 0,
+    death_entry    INTEGER NOT NULL DEFAULT 0,
+    death_trade    INTEGER NOT NULL DEFAULT 0,
+    death_late     INTEGER NOT NULL DEFAULT 0,
+    clutch_1v1     INTEGER NOT NULL DEFAULT 0,
+    clutch_1v2     INTEGER NOT NULL DEFAULT 0,
+    clutch_1v3     INTEGER NOT NULL DEFAULT 0,
+    clutch_1v4     INTEGER NOT NULL DEFAULT 0,
+    clutch_1v5     INTEGER NOT NULL DEFAULT 0,
+    obj_plant      INTEGER NOT NULL DEFAULT 0,
+    obj_defuse     INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (match_id, team_member_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_match_players_member ON match_players(team_member_id);
@@ -0,0 +1,10 @@
+-- Weekly statistics snapshots, one per ISO week ("2026-W42"). stats is the
+-- JSON snapshot taken by the weekly job after the week has ended.
+
+CREATE TABLE IF NOT EXISTS weekly_stats (
+    week       TEXT     PRIMARY KEY,
+    starts_at  DATETIME NOT NULL,
+    ends_at    DATETIME NOT NULL,
+    stats      TEXT     NOT NULL DEFAULT '{}',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
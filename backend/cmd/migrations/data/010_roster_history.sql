@@ -0,0 +1,25 @@
+-- Roster history: one row per membership change (joined, left, role change,
+-- main-roster promotion/demotion, pairing change). Rows outlive the team
+-- entry they describe, so there is no foreign key. from_value/to_value hold
+-- the old and new value ("main"/"sub" for the roster status, the partner's
+-- name for pairings).
+
+CREATE TABLE IF NOT EXISTS roster_events (
+    id             INTEGER PRIMARY KEY AUTOINCREMENT,
+    team_member_id INTEGER  NOT NULL,
+    username       TEXT     NOT NULL DEFAULT '',
+    name           TEXT     NOT NULL,
+    kind           TEXT     NOT NULL,
+    from_value     TEXT     NOT NULL DEFAULT '',
+    to_value       TEXT     NOT NULL DEFAULT '',
+    reason         TEXT     NOT NULL DEFAULT '',
+    occurred_at    DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_roster_events_member ON roster_events(team_member_id);
+CREATE INDEX IF NOT EXISTS idx_roster_events_time ON roster_events(occurred_at);
+
+-- The current roster joined before history was tracked.
+INSERT INTO roster_events (team_member_id, username, name, kind, to_value, reason)
+SELECT id, username, name, 'joined', CASE WHEN is_main_roster THEN 'main' ELSE 'sub' END, 'Bestand vor der Roster-Historie'
+FROM team;
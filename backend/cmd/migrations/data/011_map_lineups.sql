@@ -0,0 +1,21 @@
+-- Map pool and per-map lineup sheets. The pool is ordered by position and
+-- edited by coaches. lineup holds the five starters with their roles and the
+-- site setups as JSON; only main-roster players are accepted.
+
+CREATE TABLE IF NOT EXISTS map_pool (
+    name     TEXT    PRIMARY KEY,
+    position INTEGER NOT NULL DEFAULT 0
+);
+
+INSERT OR IGNORE INTO map_pool (name, position) VALUES
+    ('Bank', 1), ('Border', 2), ('Chalet', 3), ('Clubhouse', 4), ('Consulate', 5),
+    ('Kafe Dostoyevsky', 6), ('Lair', 7), ('Nighthaven Labs', 8), ('Oregon', 9),
+    ('Skyscraper', 10), ('Theme Park', 11), ('Villa', 12);
+
+CREATE TABLE IF NOT EXISTS map_lineups (
+    map        TEXT     PRIMARY KEY,
+    lineup     TEXT     NOT NULL DEFAULT '{}',
+    notes      TEXT     NOT NULL DEFAULT '',
+    updated_by TEXT     NOT NULL DEFAULT '',
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
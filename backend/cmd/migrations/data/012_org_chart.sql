@@ -0,0 +1,28 @@
+-- Organization chart: departments (nestable via parent_id), admin-managed
+-- staff roles replacing the fixed Coach/Analyst/Manager/Sub list, and the
+-- department and reporting line of each staff member. A staff member without
+-- a department belongs to the default department of their role.
+
+CREATE TABLE IF NOT EXISTS org_departments (
+    id        INTEGER PRIMARY KEY AUTOINCREMENT,
+    name      TEXT    NOT NULL UNIQUE,
+    parent_id INTEGER REFERENCES org_departments(id) ON DELETE SET NULL,
+    position  INTEGER NOT NULL DEFAULT 0
+);
+
+INSERT OR IGNORE INTO org_departments (id, name, position) VALUES
+    (1, 'Management', 1), (2, 'Coaching', 2), (3, 'Roster', 3);
+
+CREATE TABLE IF NOT EXISTS staff_roles (
+    id            INTEGER PRIMARY KEY AUTOINCREMENT,
+    name          TEXT    NOT NULL UNIQUE,
+    department_id INTEGER REFERENCES org_departments(id) ON DELETE SET NULL,
+    is_coach      INTEGER NOT NULL DEFAULT 0,
+    position      INTEGER NOT NULL DEFAULT 0
+);
+
+INSERT OR IGNORE INTO staff_roles (name, department_id, is_coach, position) VALUES
+    ('Manager', 1, 0, 1), ('Coach', 2, 1, 2), ('Analyst', 2, 0, 3), ('Sub', 3, 0, 4);
+
+ALTER TABLE staff ADD COLUMN department_id INTEGER REFERENCES org_departments(id) ON DELETE SET NULL;
+ALTER TABLE staff ADD COLUMN reports_to INTEGER REFERENCES staff(id) ON DELETE SET NULL;
@@ -0,0 +1,37 @@
+-- Match stat lines outlive the roster entry they belong to (name is copied
+-- onto each line for that), so team_member_id no longer references team.
+-- SQLite cannot drop a foreign key, so the table is rebuilt.
+
+CREATE TABLE match_players_new (
+    match_id       INTEGER NOT NULL REFERENCES matches(id) ON DELETE CASCADE,
+    team_member_id INTEGER NOT NULL,
+    name           TEXT    NOT NULL DEFAULT '',
+    kill_entry     INTEGER NOT NULL DEFAULT 0,
+    kill_trade     INTEGER NOT NULL DEFAULT 0,
+    kill_impact    INTEGER NOT NULL DEFAULT 0,
+    kill_late      INTEGER NOT NULL DEFAULT 0,
+    death_entry    INTEGER NOT NULL DEFAULT 0,
+    death_trade    INTEGER NOT NULL DEFAULT 0,
+    death_late     INTEGER NOT NULL DEFAULT 0,
+    clutch_1v1     INTEGER NOT NULL DEFAULT 0,
+    clutch_1v2     INTEGER NOT NULL DEFAULT 0,
+    clutch_1v3     INTEGER NOT NULL DEFAULT 0,
+    clutch_1v4     INTEGER NOT NULL DEFAULT 0,
+    clutch_1v5     INTEGER NOT NULL DEFAULT 0,
+    obj_plant      INTEGER NOT NULL DEFAULT 0,
+    obj_defuse     INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (match_id, team_member_id)
+);
+
+INSERT INTO match_players_new SELECT
+    match_id, team_member_id, name,
+    kill_entry, kill_trade, kill_impact, kill_late,
+    death_entry, death_trade, death_late,
+    clutch_1v1, clutch_1v2, clutch_1v3, clutch_1v4, clutch_1v5,
+    obj_plant, obj_defuse
+FROM match_players;
+
+DROP TABLE match_players;
+ALTER TABLE match_players_new RENAME TO match_players;
+
+CREATE INDEX IF NOT EXISTS idx_match_players_member ON match_players(team_member_id);
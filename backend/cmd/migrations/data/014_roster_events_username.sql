@@ -0,0 +1,3 @@
+-- Tenure lookups on profiles select a user's roster entries by username.
+
+CREATE INDEX IF NOT EXISTS idx_roster_events_username ON roster_events(username COLLATE NOCASE);
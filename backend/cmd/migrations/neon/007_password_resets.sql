@@ -0,0 +1,9 @@
+-- Migration 007: Pending password-reset codes (one per email, expire after 15 minutes)
+
+CREATE TABLE IF NOT EXISTS apx_password_resets (
+    email      TEXT        PRIMARY KEY,
+    user_id    BIGINT      NOT NULL REFERENCES apx_users(id) ON DELETE CASCADE,
+    code       TEXT        NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
@@ -0,0 +1,15 @@
+-- Migration 008: Authenticator-app (TOTP) 2FA and one-time recovery codes
+
+ALTER TABLE apx_users ADD COLUMN IF NOT EXISTS two_fa_method  TEXT    NOT NULL DEFAULT 'email'
+    CHECK (two_fa_method IN ('email','totp'));
+ALTER TABLE apx_users ADD COLUMN IF NOT EXISTS totp_secret    TEXT    NOT NULL DEFAULT '';
+ALTER TABLE apx_users ADD COLUMN IF NOT EXISTS totp_confirmed BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Only SHA-256 hashes of the codes are stored; used_at is set once a code is consumed.
+CREATE TABLE IF NOT EXISTS apx_recovery_codes (
+    user_id    BIGINT      NOT NULL REFERENCES apx_users(id) ON DELETE CASCADE,
+    code_hash  TEXT        NOT NULL,
+    used_at    TIMESTAMPTZ DEFAULT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (user_id, code_hash)
+);
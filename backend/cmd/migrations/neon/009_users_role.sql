@@ -0,0 +1,6 @@
+-- Migration 009: Named admin roles (permission sets are defined in the backend).
+-- Existing admins become superadmins; an empty role grants no admin permissions.
+
+ALTER TABLE apx_users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT '';
+
+UPDATE apx_users SET role = 'superadmin' WHERE is_admin AND role = '';
@@ -0,0 +1,19 @@
+-- Migration 010: Audit trail of privileged admin actions.
+-- actor_name is denormalised so entries survive deletion of the actor.
+
+CREATE TABLE IF NOT EXISTS apx_audit_log (
+    id          BIGSERIAL   PRIMARY KEY,
+    actor_id    BIGINT,
+    actor_name  TEXT        NOT NULL DEFAULT '',
+    action      TEXT        NOT NULL,
+    target_type TEXT        NOT NULL DEFAULT '',
+    target_id   TEXT        NOT NULL DEFAULT '',
+    before      JSONB,
+    after       JSONB,
+    ip          TEXT        NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_apx_audit_log_created ON apx_audit_log (created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_apx_audit_log_actor ON apx_audit_log (actor_name, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_apx_audit_log_target ON apx_audit_log (target_type, target_id, created_at DESC);
@@ -0,0 +1,19 @@
+-- Migration 011: Application review workflow.
+-- status_reason holds the reason of the latest transition; the history table
+-- records every transition. actor_name is denormalised like in apx_audit_log.
+
+ALTER TABLE apx_applications ADD COLUMN IF NOT EXISTS status_reason     TEXT        NOT NULL DEFAULT '';
+ALTER TABLE apx_applications ADD COLUMN IF NOT EXISTS status_updated_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS apx_application_status_history (
+    id             BIGSERIAL   PRIMARY KEY,
+    application_id BIGINT      NOT NULL REFERENCES apx_applications(id) ON DELETE CASCADE,
+    from_status    TEXT        NOT NULL,
+    to_status      TEXT        NOT NULL,
+    reason         TEXT        NOT NULL DEFAULT '',
+    actor_id       BIGINT,
+    actor_name     TEXT        NOT NULL DEFAULT '',
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_apx_application_status_history_app ON apx_application_status_history (application_id, created_at);
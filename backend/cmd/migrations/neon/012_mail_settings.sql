@@ -0,0 +1,6 @@
+-- Migration 012: Notification mail settings.
+-- mail_language selects the template language (de/en); mail_opt_out disables
+-- application notifications but not security mails such as login codes.
+
+ALTER TABLE apx_users ADD COLUMN IF NOT EXISTS mail_language TEXT    NOT NULL DEFAULT 'de';
+ALTER TABLE apx_users ADD COLUMN IF NOT EXISTS mail_opt_out  BOOLEAN NOT NULL DEFAULT FALSE;
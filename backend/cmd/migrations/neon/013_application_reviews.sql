@@ -0,0 +1,26 @@
+-- Migration 013: Internal application reviews.
+-- Reviewer notes plus one 1–5 rating per criterion and reviewer; the admin
+-- list aggregates the ratings into a score. Never shown to applicants.
+
+CREATE TABLE IF NOT EXISTS apx_application_notes (
+    id             BIGSERIAL   PRIMARY KEY,
+    application_id BIGINT      NOT NULL REFERENCES apx_applications(id) ON DELETE CASCADE,
+    author_id      BIGINT,
+    author_name    TEXT        NOT NULL DEFAULT '',
+    body           TEXT        NOT NULL,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_apx_application_notes_app ON apx_application_notes (application_id, created_at);
+
+CREATE TABLE IF NOT EXISTS apx_application_ratings (
+    application_id BIGINT      NOT NULL REFERENCES apx_applications(id) ON DELETE CASCADE,
+    reviewer_id    BIGINT      NOT NULL,
+    reviewer_name  TEXT        NOT NULL DEFAULT '',
+    rank           SMALLINT    NOT NULL CHECK (rank BETWEEN 1 AND 5),
+    experience     SMALLINT    NOT NULL CHECK (experience BETWEEN 1 AND 5),
+    availability   SMALLINT    NOT NULL CHECK (availability BETWEEN 1 AND 5),
+    communication  SMALLINT    NOT NULL CHECK (communication BETWEEN 1 AND 5),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (application_id, reviewer_id)
+);
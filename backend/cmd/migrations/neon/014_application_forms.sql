@@ -0,0 +1,11 @@
+-- Migration 014: Per-game application forms.
+-- fields holds the form schema; games without a row use the backend's
+-- built-in default form. answers stores each submission keyed by field.
+
+CREATE TABLE IF NOT EXISTS apx_application_forms (
+    game       TEXT        PRIMARY KEY,
+    fields     JSONB       NOT NULL DEFAULT '[]',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE apx_applications ADD COLUMN IF NOT EXISTS answers JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,39 @@
+-- Migration 015: Match results and per-player stat lines.
+-- The counters on apx_team stay as the totals from before match tracking;
+-- the backend adds the sum of a player's match lines on top.
+
+CREATE TABLE IF NOT EXISTS apx_matches (
+    id          BIGSERIAL   PRIMARY KEY,
+    opponent    TEXT        NOT NULL,
+    played_at   TIMESTAMPTZ NOT NULL,
+    map         TEXT        NOT NULL DEFAULT '',
+    competition TEXT        NOT NULL DEFAULT '',
+    score_us    INTEGER     NOT NULL DEFAULT 0,
+    score_them  INTEGER     NOT NULL DEFAULT 0,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_apx_matches_played_at ON apx_matches (played_at);
+
+CREATE TABLE IF NOT EXISTS apx_match_players (
+    match_id       BIGINT  NOT NULL REFERENCES apx_matches(id) ON DELETE CASCADE,
+    team_member_id BIGINT  NOT NULL REFERENCES apx_team(id) ON DELETE CASCADE,
+    name           TEXT    NOT NULL DEFAULT '',
+    kill_entry     INTEGER NOT NULL DEFAULT 0,
+    kill_trade     INTEGER NOT NULL DEFAULT 0,
+    kill_impact    INTEGER NOT NULL DEFAULT 0,
+    kill_late      INTEGER NOT NULL DEFAULT 0,
+    death_entry    INTEGER NOT NULL DEFAULT 0,
+    death_trade    INTEGER NOT NULL DEFAULT 0,
+    death_late     INTEGER NOT NULL DEFAULT 0,
+    clutch_1v1     INTEGER NOT NULL DEFAULT 0,
+    clutch_1v2     INTEGER NOT NULL DEFAULT 0,
+    clutch_1v3     INTEGER NOT NULL DEFAULT 0,
+    clutch_1v4     INTEGER NOT NULL DEFAULT 0,
+    clutch_1v5     INTEGER NOT NULL DEFAULT 0,
+    obj_plant      INTEGER NOT NULL DEFAULT 0,
+    obj_defuse     INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (match_id, team_member_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_apx_match_players_member ON apx_match_players (team_member_id);
@@ -0,0 +1,10 @@
+-- Migration 016: Weekly statistics snapshots.
+-- One row per ISO week ("2026-W42"), written by the backend's weekly job.
+
+CREATE TABLE IF NOT EXISTS apx_weekly_stats (
+    week       TEXT        PRIMARY KEY,
+    starts_at  TIMESTAMPTZ NOT NULL,
+    ends_at    TIMESTAMPTZ NOT NULL,
+    stats      JSONB       NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
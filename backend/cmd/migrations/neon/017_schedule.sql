@@ -0,0 +1,35 @@
+-- Migration 017: Scrim and practice scheduling.
+-- Weekly availability slots in the player's timezone (weekday 0 = Monday,
+-- "HH:MM", end exclusive), scheduled sessions and per-player RSVPs.
+
+CREATE TABLE IF NOT EXISTS apx_availability_slots (
+    user_id    BIGINT   NOT NULL REFERENCES apx_users(id) ON DELETE CASCADE,
+    weekday    SMALLINT NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+    start_time TEXT     NOT NULL,
+    end_time   TEXT     NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_apx_availability_slots_user ON apx_availability_slots (user_id);
+
+CREATE TABLE IF NOT EXISTS apx_schedule_sessions (
+    id         BIGSERIAL   PRIMARY KEY,
+    kind       TEXT        NOT NULL,
+    title      TEXT        NOT NULL DEFAULT '',
+    opponent   TEXT        NOT NULL DEFAULT '',
+    starts_at  TIMESTAMPTZ NOT NULL,
+    ends_at    TIMESTAMPTZ NOT NULL,
+    notes      TEXT        NOT NULL DEFAULT '',
+    created_by BIGINT      REFERENCES apx_users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_apx_schedule_sessions_start ON apx_schedule_sessions (starts_at);
+
+CREATE TABLE IF NOT EXISTS apx_session_rsvps (
+    session_id BIGINT      NOT NULL REFERENCES apx_schedule_sessions(id) ON DELETE CASCADE,
+    user_id    BIGINT      NOT NULL REFERENCES apx_users(id) ON DELETE CASCADE,
+    response   TEXT        NOT NULL CHECK (response IN ('yes', 'no', 'maybe')),
+    comment    TEXT        NOT NULL DEFAULT '',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (session_id, user_id)
+);
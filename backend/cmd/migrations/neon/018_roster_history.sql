@@ -0,0 +1,22 @@
+-- Migration 018: Roster history.
+-- One row per membership change; rows outlive the team entry, so there is no
+-- foreign key. The current roster is recorded as joined at migration time.
+
+CREATE TABLE IF NOT EXISTS apx_roster_events (
+    id             BIGSERIAL   PRIMARY KEY,
+    team_member_id BIGINT      NOT NULL,
+    username       TEXT        NOT NULL DEFAULT '',
+    name           TEXT        NOT NULL,
+    kind           TEXT        NOT NULL,
+    from_value     TEXT        NOT NULL DEFAULT '',
+    to_value       TEXT        NOT NULL DEFAULT '',
+    reason         TEXT        NOT NULL DEFAULT '',
+    occurred_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_apx_roster_events_member ON apx_roster_events (team_member_id);
+CREATE INDEX IF NOT EXISTS idx_apx_roster_events_time ON apx_roster_events (occurred_at);
+
+INSERT INTO apx_roster_events (team_member_id, username, name, kind, to_value, reason)
+SELECT id, username, name, 'joined', CASE WHEN is_main_roster THEN 'main' ELSE 'sub' END, 'Bestand vor der Roster-Historie'
+FROM apx_team;
@@ -0,0 +1,21 @@
+-- Migration 019: Map pool and per-map lineup sheets.
+-- lineup holds the five starters with their roles and the site setups.
+
+CREATE TABLE IF NOT EXISTS apx_map_pool (
+    name     TEXT    PRIMARY KEY,
+    position INTEGER NOT NULL DEFAULT 0
+);
+
+INSERT INTO apx_map_pool (name, position) VALUES
+    ('Bank', 1), ('Border', 2), ('Chalet', 3), ('Clubhouse', 4), ('Consulate', 5),
+    ('Kafe Dostoyevsky', 6), ('Lair', 7), ('Nighthaven Labs', 8), ('Oregon', 9),
+    ('Skyscraper', 10), ('Theme Park', 11), ('Villa', 12)
+ON CONFLICT (name) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS apx_map_lineups (
+    map        TEXT        PRIMARY KEY,
+    lineup     JSONB       NOT NULL DEFAULT '{}',
+    notes      TEXT        NOT NULL DEFAULT '',
+    updated_by TEXT        NOT NULL DEFAULT '',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
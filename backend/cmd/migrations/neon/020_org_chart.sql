@@ -0,0 +1,34 @@
+-- Migration 020: Organization chart.
+-- Nestable departments, admin-managed staff roles (replacing the fixed
+-- Coach/Analyst/Manager/Sub list) and each staff member's department and
+-- reporting line.
+
+CREATE TABLE IF NOT EXISTS apx_org_departments (
+    id        BIGSERIAL PRIMARY KEY,
+    name      TEXT      NOT NULL UNIQUE,
+    parent_id BIGINT    REFERENCES apx_org_departments(id) ON DELETE SET NULL,
+    position  INTEGER   NOT NULL DEFAULT 0
+);
+
+INSERT INTO apx_org_departments (name, position) VALUES
+    ('Management', 1), ('Coaching', 2), ('Roster', 3)
+ON CONFLICT (name) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS apx_staff_roles (
+    id            BIGSERIAL PRIMARY KEY,
+    name          TEXT      NOT NULL UNIQUE,
+    department_id BIGINT    REFERENCES apx_org_departments(id) ON DELETE SET NULL,
+    is_coach      BOOLEAN   NOT NULL DEFAULT FALSE,
+    position      INTEGER   NOT NULL DEFAULT 0
+);
+
+INSERT INTO apx_staff_roles (name, department_id, is_coach, position)
+SELECT r.name, d.id, r.is_coach, r.position
+FROM (VALUES ('Manager', 'Management', FALSE, 1), ('Coach', 'Coaching', TRUE, 2),
+             ('Analyst', 'Coaching', FALSE, 3), ('Sub', 'Roster', FALSE, 4))
+    AS r(name, department, is_coach, position)
+JOIN apx_org_departments d ON d.name = r.department
+ON CONFLICT (name) DO NOTHING;
+
+ALTER TABLE apx_staff ADD COLUMN IF NOT EXISTS department_id BIGINT REFERENCES apx_org_departments(id) ON DELETE SET NULL;
+ALTER TABLE apx_staff ADD COLUMN IF NOT EXISTS reports_to BIGINT REFERENCES apx_staff(id) ON DELETE SET NULL;
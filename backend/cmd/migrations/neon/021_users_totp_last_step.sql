@@ -0,0 +1,4 @@
+-- Migration 021: Last accepted TOTP time step per user.
+-- Codes at or before it are refused, so an observed code cannot be replayed.
+
+ALTER TABLE apx_users ADD COLUMN IF NOT EXISTS totp_last_step BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,5 @@
+-- Migration 022: Keep match stat lines of removed players.
+-- Lines outlive the roster entry (name is copied onto each line), so the
+-- foreign key to apx_team and its ON DELETE CASCADE are dropped.
+
+ALTER TABLE apx_match_players DROP CONSTRAINT IF EXISTS apx_match_players_team_member_id_fkey;
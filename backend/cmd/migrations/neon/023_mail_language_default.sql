@@ -0,0 +1,7 @@
+-- Migration 023: No mail language preference by default.
+-- An empty mail_language means English. 012 stored 'de' for every existing
+-- user, so an explicit choice cannot be told apart from the default; reset
+-- them to no preference.
+
+ALTER TABLE apx_users ALTER COLUMN mail_language SET DEFAULT '';
+UPDATE apx_users SET mail_language = '' WHERE mail_language = 'de';
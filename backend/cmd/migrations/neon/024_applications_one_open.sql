@@ -0,0 +1,12 @@
+-- Migration 024: At most one open application per user.
+-- Older open duplicates are withdrawn so the partial unique index can be
+-- built; the newest open application is kept. ApxApi answers 409 when an
+-- insert hits the index.
+
+UPDATE apx_applications a SET status = 'withdrawn', status_updated_at = NOW()
+WHERE a.status IN ('pending', 'in_review', 'trial')
+  AND a.id < (SELECT MAX(b.id) FROM apx_applications b
+              WHERE b.user_id = a.user_id AND b.status IN ('pending', 'in_review', 'trial'));
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_apx_applications_one_open ON apx_applications (user_id)
+    WHERE status IN ('pending', 'in_review', 'trial');
@@ -0,0 +1,4 @@
+-- Migration 025: Roster history lookups by username.
+-- GET /team/history?username= selects a user's roster entries case-insensitively.
+
+CREATE INDEX IF NOT EXISTS idx_apx_roster_events_username ON apx_roster_events (lower(username));
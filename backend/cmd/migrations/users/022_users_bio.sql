@@ -0,0 +1,3 @@
+-- Add free-text profile bio to the users table.
+
+ALTER TABLE users ADD COLUMN bio TEXT NOT NULL DEFAULT '';
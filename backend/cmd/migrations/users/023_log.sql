@@ -0,0 +1,9 @@
+-- Team news / announcement entries shown on the public log page.
+
+CREATE TABLE IF NOT EXISTS log (
+    id         INTEGER  PRIMARY KEY AUTOINCREMENT,
+    title      TEXT     NOT NULL,
+    body       TEXT     NOT NULL DEFAULT '',
+    log_date   TEXT     NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,28 @@
+-- Add per-user event access and the events / participants tables.
+-- Event ids are random UUID strings generated by the backend.
+
+ALTER TABLE users ADD COLUMN event_access BOOLEAN NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS events (
+    id               TEXT     PRIMARY KEY,
+    name             TEXT     NOT NULL,
+    status           TEXT     NOT NULL DEFAULT 'upcoming' CHECK (status IN ('live','upcoming','past')),
+    date             TEXT     NOT NULL,
+    duration_de      TEXT     NOT NULL DEFAULT '',
+    duration_en      TEXT     NOT NULL DEFAULT '',
+    description_de   TEXT     NOT NULL DEFAULT '',
+    description_en   TEXT     NOT NULL DEFAULT '',
+    max_participants INTEGER  NOT NULL DEFAULT 0,
+    created_at       DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS event_participants (
+    user_id   INTEGER  NOT NULL,
+    event_id  TEXT     NOT NULL,
+    joined_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, event_id),
+    FOREIGN KEY (user_id)  REFERENCES users(id)  ON DELETE CASCADE,
+    FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_participants_event ON event_participants(event_id);
@@ -0,0 +1,10 @@
+-- Pending password-reset codes, one per email. Expire after 15 minutes.
+
+CREATE TABLE IF NOT EXISTS password_resets (
+    email      TEXT     PRIMARY KEY,
+    user_id    INTEGER  NOT NULL,
+    code       TEXT     NOT NULL,
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,15 @@
+-- Authenticator-app (TOTP) 2FA and one-time recovery codes.
+-- Only SHA-256 hashes of the codes are stored; used_at is set once consumed.
+
+ALTER TABLE users ADD COLUMN two_fa_method  TEXT    NOT NULL DEFAULT 'email';
+ALTER TABLE users ADD COLUMN totp_secret    TEXT    NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN totp_confirmed BOOLEAN NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    user_id    INTEGER  NOT NULL,
+    code_hash  TEXT     NOT NULL,
+    used_at    DATETIME DEFAULT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, code_hash),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,6 @@
+-- Named admin roles (permission sets are defined in the backend).
+-- Existing admins become superadmins; an empty role grants no admin permissions.
+
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT '';
+
+UPDATE users SET role = 'superadmin' WHERE is_admin = 1 AND role = '';
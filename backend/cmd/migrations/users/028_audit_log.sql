@@ -0,0 +1,19 @@
+-- Audit trail of privileged admin actions.
+-- actor_name is denormalised so entries survive deletion of the actor.
+
+CREATE TABLE IF NOT EXISTS audit_log (
+    id          INTEGER  PRIMARY KEY AUTOINCREMENT,
+    actor_id    INTEGER,
+    actor_name  TEXT     NOT NULL DEFAULT '',
+    action      TEXT     NOT NULL,
+    target_type TEXT     NOT NULL DEFAULT '',
+    target_id   TEXT     NOT NULL DEFAULT '',
+    before      TEXT,
+    after       TEXT,
+    ip          TEXT     NOT NULL DEFAULT '',
+    created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log(created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor   ON audit_log(actor_name, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_target  ON audit_log(target_type, target_id, created_at);
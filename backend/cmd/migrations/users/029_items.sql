@@ -0,0 +1,16 @@
+-- Item catalog. item_id is "<seq_id>-<8 hex chars>", assigned by the backend.
+
+CREATE TABLE IF NOT EXISTS items (
+    item_id    TEXT     PRIMARY KEY,
+    seq_id     INTEGER  NOT NULL UNIQUE,
+    name       TEXT     NOT NULL,
+    rarity     TEXT     CHECK (rarity IS NULL OR rarity IN
+                   ('E-Rank','D-Rank','C-Rank','B-Rank','A-Rank','S-Rank')),
+    image_url  TEXT,
+    is_weapon  BOOLEAN  NOT NULL DEFAULT 0,
+    is_armor   BOOLEAN  NOT NULL DEFAULT 0,
+    is_item    BOOLEAN  NOT NULL DEFAULT 0,
+    is_animal  BOOLEAN  NOT NULL DEFAULT 0,
+    perks      TEXT     NOT NULL DEFAULT '[]',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,15 @@
+-- Local copy of the Discord bot's per-guild user table (see discord-bot-schema.sql).
+-- The bot owns this data; the website only reads it for leaderboards and ranks.
+
+CREATE TABLE IF NOT EXISTS bot_users (
+    user_id          TEXT    NOT NULL,
+    guild_id         TEXT    NOT NULL,
+    apx_id           TEXT,
+    xp               INTEGER NOT NULL DEFAULT 0,
+    level            INTEGER NOT NULL DEFAULT 1,
+    gold             INTEGER NOT NULL DEFAULT 0,
+    total_earned     INTEGER NOT NULL DEFAULT 0,
+    rank_role_id     TEXT,
+    discord_username TEXT    NOT NULL DEFAULT '',
+    PRIMARY KEY (user_id, guild_id)
+);
@@ -0,0 +1,19 @@
+-- Application review workflow: latest status reason plus the full history of
+-- status transitions. actor_name is denormalised like in audit_log.
+
+ALTER TABLE applications ADD COLUMN status_reason TEXT NOT NULL DEFAULT '';
+ALTER TABLE applications ADD COLUMN status_updated_at DATETIME;
+
+CREATE TABLE IF NOT EXISTS application_status_history (
+    id             INTEGER  PRIMARY KEY AUTOINCREMENT,
+    application_id INTEGER  NOT NULL,
+    from_status    TEXT     NOT NULL,
+    to_status      TEXT     NOT NULL,
+    reason         TEXT     NOT NULL DEFAULT '',
+    actor_id       INTEGER,
+    actor_name     TEXT     NOT NULL DEFAULT '',
+    created_at     DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (application_id) REFERENCES applications(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_application_status_history_app ON application_status_history(application_id, created_at);
@@ -0,0 +1,6 @@
+-- Notification mail settings: language of outgoing mails (de/en) and an
+-- opt-out for application notifications. Security mails (codes) ignore the
+-- opt-out.
+
+ALTER TABLE users ADD COLUMN mail_language TEXT    NOT NULL DEFAULT 'de';
+ALTER TABLE users ADD COLUMN mail_opt_out  BOOLEAN NOT NULL DEFAULT 0;
@@ -0,0 +1,25 @@
+-- Internal application reviews: free-form reviewer notes and one 1–5 rating
+-- per criterion and reviewer. Only exposed through admin endpoints.
+
+CREATE TABLE IF NOT EXISTS application_notes (
+    id             INTEGER PRIMARY KEY AUTOINCREMENT,
+    application_id INTEGER  NOT NULL REFERENCES applications(id) ON DELETE CASCADE,
+    author_id      INTEGER  REFERENCES users(id) ON DELETE SET NULL,
+    author_name    TEXT     NOT NULL DEFAULT '',
+    body           TEXT     NOT NULL,
+    created_at     DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_application_notes_app ON application_notes(application_id, created_at);
+
+CREATE TABLE IF NOT EXISTS application_ratings (
+    application_id INTEGER  NOT NULL REFERENCES applications(id) ON DELETE CASCADE,
+    reviewer_id    INTEGER  NOT NULL,
+    reviewer_name  TEXT     NOT NULL DEFAULT '',
+    rank           INTEGER  NOT NULL CHECK (rank BETWEEN 1 AND 5),
+    experience     INTEGER  NOT NULL CHECK (experience BETWEEN 1 AND 5),
+    availability   INTEGER  NOT NULL CHECK (availability BETWEEN 1 AND 5),
+    communication  INTEGER  NOT NULL CHECK (communication BETWEEN 1 AND 5),
+    updated_at     DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (application_id, reviewer_id)
+);
@@ -0,0 +1,11 @@
+-- Per-game application forms. fields is the JSON form schema; games without a
+-- row use the built-in default form. answers keeps every submission as JSON
+-- keyed by field; the fixed columns stay filled for the well-known fields.
+
+CREATE TABLE IF NOT EXISTS application_forms (
+    game       TEXT     PRIMARY KEY,
+    fields     TEXT     NOT NULL DEFAULT '[]',
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE applications ADD COLUMN answers TEXT NOT NULL DEFAULT '{}';
@@ -0,0 +1,35 @@
+-- Scrim and practice scheduling. Availability slots are weekly and in the
+-- player's own timezone (users.timezone); weekday 0 is Monday and times are
+-- "HH:MM", end exclusive. Sessions are stored in UTC.
+
+CREATE TABLE IF NOT EXISTS availability_slots (
+    user_id    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    weekday    INTEGER NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+    start_time TEXT    NOT NULL,
+    end_time   TEXT    NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_availability_slots_user ON availability_slots(user_id);
+
+CREATE TABLE IF NOT EXISTS schedule_sessions (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind       TEXT     NOT NULL,
+    title      TEXT     NOT NULL DEFAULT '',
+    opponent   TEXT     NOT NULL DEFAULT '',
+    starts_at  DATETIME NOT NULL,
+    ends_at    DATETIME NOT NULL,
+    notes      TEXT     NOT NULL DEFAULT '',
+    created_by INTEGER  REFERENCES users(id) ON DELETE SET NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_schedule_sessions_start ON schedule_sessions(starts_at);
+
+CREATE TABLE IF NOT EXISTS session_rsvps (
+    session_id INTEGER  NOT NULL REFERENCES schedule_sessions(id) ON DELETE CASCADE,
+    user_id    INTEGER  NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    response   TEXT     NOT NULL CHECK (response IN ('yes', 'no', 'maybe')),
+    comment    TEXT     NOT NULL DEFAULT '',
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (session_id, user_id)
+);
@@ -0,0 +1,4 @@
+-- Last accepted TOTP time step per user. Codes at or before it are refused,
+-- so an observed code cannot be replayed within its validity window.
+
+ALTER TABLE users ADD COLUMN totp_last_step INTEGER NOT NULL DEFAULT 0;
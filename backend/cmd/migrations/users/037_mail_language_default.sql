@@ -0,0 +1,6 @@
+-- Users without a mail language preference get English mails. 032 stored
+-- 'de' for every existing user, so an explicit choice cannot be told apart
+-- from the default; reset them to no preference (''). SQLite cannot change
+-- a column default, so new rows get '' from the INSERTs in the store.
+
+UPDATE users SET mail_language = '' WHERE mail_language = 'de';
@@ -0,0 +1,11 @@
+-- At most one open (pending, in_review, trial) application per user. Older
+-- duplicates from before the check was enforced in the store are withdrawn
+-- so the index can be built; the newest open application is kept.
+
+UPDATE applications SET status = 'withdrawn', status_updated_at = CURRENT_TIMESTAMP
+WHERE status IN ('pending', 'in_review', 'trial')
+  AND id < (SELECT MAX(b.id) FROM applications b
+            WHERE b.user_id = applications.user_id AND b.status IN ('pending', 'in_review', 'trial'));
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_applications_one_open ON applications (user_id)
+    WHERE status IN ('pending', 'in_review', 'trial');
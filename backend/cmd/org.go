@@ -0,0 +1,591 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// findStaffRole returns the role called name, or nil.
+func findStaffRole(roles []StaffRole, name string) *StaffRole {
+	for i := range roles {
+		if roles[i].Name == name {
+			return &roles[i]
+		}
+	}
+	return nil
+}
+
+// validStaffRole reports whether name is one of the admin-managed roles.
+func validStaffRole(ctx context.Context, apx Store, name string) (bool, error) {
+	roles, err := apx.GetStaffRoles(ctx)
+	if err != nil {
+		return false, err
+	}
+	return findStaffRole(roles, name) != nil, nil
+}
+
+// staffDepartment is the department s is shown in: their own, else their
+// role's.
+func staffDepartment(s StaffMember, roles []StaffRole) *int64 {
+	if s.DepartmentID != nil {
+		return s.DepartmentID
+	}
+	if role := findStaffRole(roles, s.Role); role != nil {
+		return role.DepartmentID
+	}
+	return nil
+}
+
+// departmentExists reports whether id (if set) names an existing department.
+func departmentExists(departments []OrgDepartment, id *int64) bool {
+	if id == nil {
+		return true
+	}
+	for _, d := range departments {
+		if d.ID == *id {
+			return true
+		}
+	}
+	return false
+}
+
+// departmentCycle reports whether making parent the parent of id would close
+// a loop.
+func departmentCycle(departments []OrgDepartment, id int64, parent *int64) bool {
+	parents := map[int64]*int64{}
+	for _, d := range departments {
+		parents[d.ID] = d.ParentID
+	}
+	for p, steps := parent, 0; p != nil && steps <= len(departments); p, steps = parents[*p], steps+1 {
+		if *p == id {
+			return true
+		}
+	}
+	return false
+}
+
+// reportingCycle reports whether letting id report to manager would close a
+// loop.
+func reportingCycle(staff []StaffMember, id int64, manager *int64) bool {
+	managers := map[int64]*int64{}
+	for _, s := range staff {
+		managers[s.ID] = s.ReportsTo
+	}
+	for m, steps := manager, 0; m != nil && steps <= len(staff); m, steps = managers[*m], steps+1 {
+		if *m == id {
+			return true
+		}
+	}
+	return false
+}
+
+// orgStaffNode is a staff member with the people reporting to them inside
+// the same department. Reporting lines across departments stay visible via
+// reports_to.
+type orgStaffNode struct {
+	StaffMember
+	Reports []*orgStaffNode `json:"reports"`
+}
+
+type orgDepartmentNode struct {
+	ID          int64                `json:"id"`
+	Name        string               `json:"name"`
+	Staff       []*orgStaffNode      `json:"staff"`
+	Departments []*orgDepartmentNode `json:"departments"`
+}
+
+// orgTree arranges departments and staff into the chart. Staff without a
+// department are returned separately.
+func orgTree(departments []OrgDepartment, roles []StaffRole, staff []StaffMember) ([]*orgDepartmentNode, []*orgStaffNode) {
+	deptNodes := map[int64]*orgDepartmentNode{}
+	for _, d := range departments {
+		deptNodes[d.ID] = &orgDepartmentNode{ID: d.ID, Name: d.Name, Staff: []*orgStaffNode{}, Departments: []*orgDepartmentNode{}}
+	}
+	roots := []*orgDepartmentNode{}
+	for _, d := range departments {
+		node := deptNodes[d.ID]
+		if parent := d.ParentID; parent != nil && deptNodes[*parent] != nil && !departmentCycle(departments, d.ID, parent) {
+			deptNodes[*parent].Departments = append(deptNodes[*parent].Departments, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+
+	staffNodes := map[int64]*orgStaffNode{}
+	deptOf := map[int64]int64{} // staff id → department id, 0 if none
+	for _, s := range staff {
+		staffNodes[s.ID] = &orgStaffNode{StaffMember: s, Reports: []*orgStaffNode{}}
+		if d := staffDepartment(s, roles); d != nil && deptNodes[*d] != nil {
+			deptOf[s.ID] = *d
+		}
+	}
+	unassigned := []*orgStaffNode{}
+	for _, s := range staff {
+		node := staffNodes[s.ID]
+		if m := s.ReportsTo; m != nil && staffNodes[*m] != nil && deptOf[*m] == deptOf[s.ID] && !reportingCycle(staff, s.ID, m) {
+			staffNodes[*m].Reports = append(staffNodes[*m].Reports, node)
+		} else if d := deptOf[s.ID]; d != 0 {
+			deptNodes[d].Staff = append(deptNodes[d].Staff, node)
+		} else {
+			unassigned = append(unassigned, node)
+		}
+	}
+	return roots, unassigned
+}
+
+// handleOrgChart serves GET /api/org: departments with their staff by
+// reporting line, staff without a department, and the playing roster.
+func handleOrgChart(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		departments, err := apx.GetOrgDepartments(ctx)
+		if err != nil {
+			log.Printf("GetOrgDepartments error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		roles, err := apx.GetStaffRoles(ctx)
+		if err != nil {
+			log.Printf("GetStaffRoles error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		staff, err := apx.GetStaffMembers(ctx)
+		if err != nil {
+			log.Printf("Failed to get staff: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		members, err := apx.GetTeamMembers(ctx)
+		if err != nil {
+			log.Printf("Failed to get team members: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		enrichStaffAvatars(ctx, staff, apx)
+		enrichTeamAvatars(ctx, members, apx)
+
+		tree, unassigned := orgTree(departments, roles, staff)
+		type player struct {
+			ID        int64  `json:"id"`
+			Name      string `json:"name"`
+			AvatarURL string `json:"avatar_url"`
+			AtkRole   string `json:"atk_role"`
+			DefRole   string `json:"def_role"`
+		}
+		mainRoster, subs := []player{}, []player{}
+		for _, m := range members {
+			p := player{ID: m.ID, Name: m.Name, AvatarURL: m.AvatarURL, AtkRole: m.AtkRole, DefRole: m.DefRole}
+			if m.IsMainRoster {
+				mainRoster = append(mainRoster, p)
+			} else {
+				subs = append(subs, p)
+			}
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"departments": tree,
+			"unassigned":  unassigned,
+			"team":        map[string]interface{}{"main": mainRoster, "subs": subs},
+		})
+	}
+}
+
+// ── Admin: departments ───────────────────────────────────────────────────────
+
+// handleAdminOrgDepartments serves GET /api/admin/org/departments
+func handleAdminOrgDepartments(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		departments, err := apx.GetOrgDepartments(r.Context())
+		if err != nil {
+			log.Printf("GetOrgDepartments error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"departments": departments})
+	}
+}
+
+// validateDepartment checks d against the existing departments; d.ID is 0
+// for new ones. It returns a user-facing message or "".
+func validateDepartment(d *OrgDepartment, departments []OrgDepartment) string {
+	d.Name = strings.TrimSpace(d.Name)
+	if d.Name == "" || utf8.RuneCountInString(d.Name) > 60 {
+		return "Name fehlt oder ist länger als 60 Zeichen"
+	}
+	for _, other := range departments {
+		if other.ID != d.ID && strings.EqualFold(other.Name, d.Name) {
+			return "Eine Abteilung mit diesem Namen existiert bereits"
+		}
+	}
+	if !departmentExists(departments, d.ParentID) {
+		return "Übergeordnete Abteilung existiert nicht"
+	}
+	if d.ParentID != nil && (*d.ParentID == d.ID || departmentCycle(departments, d.ID, d.ParentID)) {
+		return "Eine Abteilung kann nicht sich selbst untergeordnet sein"
+	}
+	return ""
+}
+
+// handleAdminOrgDepartmentCreate serves POST /api/admin/org/departments
+func handleAdminOrgDepartmentCreate(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var d OrgDepartment
+		if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		departments, err := apx.GetOrgDepartments(r.Context())
+		if err != nil {
+			log.Printf("GetOrgDepartments error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		d.ID = 0
+		if msg := validateDepartment(&d, departments); msg != "" {
+			jsonError(w, http.StatusBadRequest, msg)
+			return
+		}
+		id, err := apx.CreateOrgDepartment(r.Context(), d)
+		if err != nil {
+			log.Printf("CreateOrgDepartment error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		d.ID = id
+		recordAudit(apx, r, "org.department.create", "org_department", strconv.FormatInt(id, 10), nil, d)
+		jsonResponse(w, http.StatusCreated, map[string]interface{}{"id": id, "success": true})
+	}
+}
+
+// findDepartment returns the department with the given id, or nil.
+func findDepartment(departments []OrgDepartment, id int64) *OrgDepartment {
+	for i := range departments {
+		if departments[i].ID == id {
+			return &departments[i]
+		}
+	}
+	return nil
+}
+
+// handleAdminOrgDepartmentUpdate serves PUT /api/admin/org/departments/{id}
+func handleAdminOrgDepartmentUpdate(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathID(w, r)
+		if !ok {
+			return
+		}
+		var d OrgDepartment
+		if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		departments, err := apx.GetOrgDepartments(r.Context())
+		if err != nil {
+			log.Printf("GetOrgDepartments error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		before := findDepartment(departments, id)
+		if before == nil {
+			jsonError(w, http.StatusNotFound, "Abteilung nicht gefunden")
+			return
+		}
+		d.ID = id
+		if msg := validateDepartment(&d, departments); msg != "" {
+			jsonError(w, http.StatusBadRequest, msg)
+			return
+		}
+		if err := apx.UpdateOrgDepartment(r.Context(), d); err != nil {
+			log.Printf("UpdateOrgDepartment error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		recordAudit(apx, r, "org.department.update", "org_department", strconv.FormatInt(id, 10), before, d)
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
+
+// handleAdminOrgDepartmentDelete serves DELETE /api/admin/org/departments/{id}.
+// Sub-departments move to the top level; staff fall back to their role's
+// department.
+func handleAdminOrgDepartmentDelete(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathID(w, r)
+		if !ok {
+			return
+		}
+		departments, err := apx.GetOrgDepartments(r.Context())
+		if err != nil {
+			log.Printf("GetOrgDepartments error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		before := findDepartment(departments, id)
+		if before == nil {
+			jsonError(w, http.StatusNotFound, "Abteilung nicht gefunden")
+			return
+		}
+		if err := apx.DeleteOrgDepartment(r.Context(), id); err != nil {
+			log.Printf("DeleteOrgDepartment error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		recordAudit(apx, r, "org.department.delete", "org_department", strconv.FormatInt(id, 10), before, nil)
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
+
+// ── Admin: staff roles ───────────────────────────────────────────────────────
+
+// handleAdminStaffRoles serves GET /api/admin/org/roles
+func handleAdminStaffRoles(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		roles, err := apx.GetStaffRoles(r.Context())
+		if err != nil {
+			log.Printf("GetStaffRoles error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"roles": roles})
+	}
+}
+
+// validateStaffRole checks role against the existing roles; role.ID is 0 for
+// new ones. It returns a user-facing message or "".
+func validateStaffRole(ctx context.Context, apx Store, role *StaffRole, roles []StaffRole) (string, error) {
+	role.Name = strings.TrimSpace(role.Name)
+	if role.Name == "" || utf8.RuneCountInString(role.Name) > 40 {
+		return "Name fehlt oder ist länger als 40 Zeichen", nil
+	}
+	for _, other := range roles {
+		if other.ID != role.ID && strings.EqualFold(other.Name, role.Name) {
+			return "Eine Rolle mit diesem Namen existiert bereits", nil
+		}
+	}
+	departments, err := apx.GetOrgDepartments(ctx)
+	if err != nil {
+		return "", err
+	}
+	if !departmentExists(departments, role.DepartmentID) {
+		return "Abteilung existiert nicht", nil
+	}
+	return "", nil
+}
+
+// findRoleByID returns the role with the given id, or nil.
+func findRoleByID(roles []StaffRole, id int64) *StaffRole {
+	for i := range roles {
+		if roles[i].ID == id {
+			return &roles[i]
+		}
+	}
+	return nil
+}
+
+// handleAdminStaffRoleCreate serves POST /api/admin/org/roles
+func handleAdminStaffRoleCreate(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var role StaffRole
+		if err := json.NewDecoder(r.Body).Decode(&role); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		roles, err := apx.GetStaffRoles(r.Context())
+		if err != nil {
+			log.Printf("GetStaffRoles error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		role.ID = 0
+		msg, err := validateStaffRole(r.Context(), apx, &role, roles)
+		if err != nil {
+			log.Printf("GetOrgDepartments error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		if msg != "" {
+			jsonError(w, http.StatusBadRequest, msg)
+			return
+		}
+		id, err := apx.CreateStaffRole(r.Context(), role)
+		if err != nil {
+			log.Printf("CreateStaffRole error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		role.ID = id
+		recordAudit(apx, r, "staff.role.create", "staff_role", strconv.FormatInt(id, 10), nil, role)
+		jsonResponse(w, http.StatusCreated, map[string]interface{}{"id": id, "success": true})
+	}
+}
+
+// handleAdminStaffRoleUpdate serves PUT /api/admin/org/roles/{id}. Renaming
+// a role renames it for every staff member holding it.
+func handleAdminStaffRoleUpdate(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathID(w, r)
+		if !ok {
+			return
+		}
+		var role StaffRole
+		if err := json.NewDecoder(r.Body).Decode(&role); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		roles, err := apx.GetStaffRoles(r.Context())
+		if err != nil {
+			log.Printf("GetStaffRoles error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		before := findRoleByID(roles, id)
+		if before == nil {
+			jsonError(w, http.StatusNotFound, "Rolle nicht gefunden")
+			return
+		}
+		role.ID = id
+		msg, err := validateStaffRole(r.Context(), apx, &role, roles)
+		if err != nil {
+			log.Printf("GetOrgDepartments error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		if msg != "" {
+			jsonError(w, http.StatusBadRequest, msg)
+			return
+		}
+		if err := apx.UpdateStaffRole(r.Context(), role); err != nil {
+			log.Printf("UpdateStaffRole error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		publicRoster.invalidate()
+		recordAudit(apx, r, "staff.role.update", "staff_role", strconv.FormatInt(id, 10), before, role)
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
+
+// handleAdminStaffRoleDelete serves DELETE /api/admin/org/roles/{id}. Roles
+// still held by staff members cannot be deleted.
+func handleAdminStaffRoleDelete(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathID(w, r)
+		if !ok {
+			return
+		}
+		roles, err := apx.GetStaffRoles(r.Context())
+		if err != nil {
+			log.Printf("GetStaffRoles error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		before := findRoleByID(roles, id)
+		if before == nil {
+			jsonError(w, http.StatusNotFound, "Rolle nicht gefunden")
+			return
+		}
+		staff, err := apx.GetStaffMembers(r.Context())
+		if err != nil {
+			log.Printf("Failed to get staff: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		for _, s := range staff {
+			if s.Role == before.Name {
+				jsonError(w, http.StatusConflict, "Die Rolle ist noch vergeben")
+				return
+			}
+		}
+		if err := apx.DeleteStaffRole(r.Context(), id); err != nil {
+			log.Printf("DeleteStaffRole error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		recordAudit(apx, r, "staff.role.delete", "staff_role", strconv.FormatInt(id, 10), before, nil)
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
+
+// ── Admin: reporting lines ───────────────────────────────────────────────────
+
+// validateReporting checks a department and manager for staff member id. It
+// returns a user-facing message or "".
+func validateReporting(ctx context.Context, apx Store, id int64, departmentID, reportsTo *int64) (string, error) {
+	departments, err := apx.GetOrgDepartments(ctx)
+	if err != nil {
+		return "", err
+	}
+	if !departmentExists(departments, departmentID) {
+		return "Abteilung existiert nicht", nil
+	}
+	if reportsTo == nil {
+		return "", nil
+	}
+	staff, err := apx.GetStaffMembers(ctx)
+	if err != nil {
+		return "", err
+	}
+	found := false
+	for _, s := range staff {
+		found = found || s.ID == *reportsTo
+	}
+	if !found {
+		return "Vorgesetzter existiert nicht", nil
+	}
+	if *reportsTo == id || reportingCycle(staff, id, reportsTo) {
+		return "Die Berichtslinie darf keinen Kreis bilden", nil
+	}
+	return "", nil
+}
+
+// handleAdminStaffReporting serves PUT /api/admin/org/staff/{id} with
+// {department_id, reports_to}; null clears either.
+func handleAdminStaffReporting(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := pathID(w, r)
+		if !ok {
+			return
+		}
+		var req struct {
+			DepartmentID *int64 `json:"department_id"`
+			ReportsTo    *int64 `json:"reports_to"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		before := findStaffMember(r.Context(), apx, id)
+		if before == nil {
+			jsonError(w, http.StatusNotFound, "Staff-Mitglied nicht gefunden")
+			return
+		}
+		msg, err := validateReporting(r.Context(), apx, id, req.DepartmentID, req.ReportsTo)
+		if err != nil {
+			log.Printf("validateReporting error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		if msg != "" {
+			jsonError(w, http.StatusBadRequest, msg)
+			return
+		}
+		if err := apx.SetStaffReporting(r.Context(), id, req.DepartmentID, req.ReportsTo); err != nil {
+			if errors.Is(err, errNotFound) {
+				jsonError(w, http.StatusNotFound, "Staff-Mitglied nicht gefunden")
+				return
+			}
+			log.Printf("SetStaffReporting error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		publicRoster.invalidate()
+		recordAudit(apx, r, "staff.reporting", "staff", strconv.FormatInt(id, 10), before, findStaffMember(r.Context(), apx, id))
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
 )
@@ -23,15 +22,6 @@ type ProgressionInventoryItem struct {
 
 // ── Helpers ──
 
-func checkInternalAPIKey(w http.ResponseWriter, r *http.Request) bool {
-	key := r.Header.Get("X-Api-Key")
-	if key != os.Getenv("INTERNAL_API_KEY") {
-		w.WriteHeader(http.StatusUnauthorized)
-		return false
-	}
-	return true
-}
-
 func rankFromRoleID(roleID *string) string {
 	if roleID == nil {
 		return ""
@@ -62,15 +52,8 @@ func levelToRank(level int) string {
 // ── Internal Handlers (Bot → Go) ──
 
 // POST /api/internal/progression/user-sync
-func handleInternalUserSync(apx *ApxClient) http.HandlerFunc {
+func handleInternalUserSync(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-			return
-		}
-		if !checkInternalAPIKey(w, r) {
-			return
-		}
 		var req struct {
 			UserID          string `json:"user_id"`
 			Level           int    `json:"level"`
@@ -85,12 +68,12 @@ func handleInternalUserSync(apx *ApxClient) http.HandlerFunc {
 			jsonError(w, http.StatusBadRequest, "user_id required")
 			return
 		}
-		userID, err := apx.ResolveUserIDByDiscord(req.UserID)
+		userID, err := apx.ResolveUserIDByDiscord(r.Context(), req.UserID)
 		if err != nil {
 			jsonResponse(w, http.StatusOK, map[string]interface{}{"success": true, "linked": false})
 			return
 		}
-		if err := apx.UpsertProgressionUser(userID, req.UserID, req.Level, req.XP, req.CurrencyBalance); err != nil {
+		if err := apx.UpsertProgressionUser(r.Context(), userID, req.UserID, req.Level, req.XP, req.CurrencyBalance); err != nil {
 			log.Printf("user-sync: %v", err)
 			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
@@ -100,15 +83,8 @@ func handleInternalUserSync(apx *ApxClient) http.HandlerFunc {
 }
 
 // POST /api/internal/progression/inventory-add
-func handleInternalInventoryAdd(apx *ApxClient) http.HandlerFunc {
+func handleInternalInventoryAdd(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-			return
-		}
-		if !checkInternalAPIKey(w, r) {
-			return
-		}
 		var req struct {
 			UserID      string `json:"user_id"`
 			InventoryID int    `json:"inventory_id"`
@@ -123,12 +99,12 @@ func handleInternalInventoryAdd(apx *ApxClient) http.HandlerFunc {
 			jsonError(w, http.StatusBadRequest, "invalid body")
 			return
 		}
-		userID, err := apx.ResolveUserIDByDiscord(req.UserID)
+		userID, err := apx.ResolveUserIDByDiscord(r.Context(), req.UserID)
 		if err != nil {
 			jsonResponse(w, http.StatusOK, map[string]interface{}{"success": true, "linked": false})
 			return
 		}
-		if err := apx.InsertInventoryItem(userID, req.InventoryID, req.ItemID, req.Name, req.Rarity, req.ItemType, req.AssetKey, req.SellPrice); err != nil {
+		if err := apx.InsertInventoryItem(r.Context(), userID, req.InventoryID, req.ItemID, req.Name, req.Rarity, req.ItemType, req.AssetKey, req.SellPrice); err != nil {
 			log.Printf("inventory-add: %v", err)
 			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
@@ -138,15 +114,8 @@ func handleInternalInventoryAdd(apx *ApxClient) http.HandlerFunc {
 }
 
 // POST /api/internal/progression/inventory-remove
-func handleInternalInventoryRemove(apx *ApxClient) http.HandlerFunc {
+func handleInternalInventoryRemove(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-			return
-		}
-		if !checkInternalAPIKey(w, r) {
-			return
-		}
 		var req struct {
 			UserID      string `json:"user_id"`
 			InventoryID int    `json:"inventory_id"`
@@ -155,12 +124,12 @@ func handleInternalInventoryRemove(apx *ApxClient) http.HandlerFunc {
 			jsonError(w, http.StatusBadRequest, "invalid body")
 			return
 		}
-		userID, err := apx.ResolveUserIDByDiscord(req.UserID)
+		userID, err := apx.ResolveUserIDByDiscord(r.Context(), req.UserID)
 		if err != nil {
 			jsonResponse(w, http.StatusOK, map[string]interface{}{"success": true, "linked": false})
 			return
 		}
-		if err := apx.DeleteInventoryItem(userID, req.InventoryID); err != nil {
+		if err := apx.DeleteInventoryItem(r.Context(), userID, req.InventoryID); err != nil {
 			log.Printf("inventory-remove: %v", err)
 			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
@@ -170,15 +139,8 @@ func handleInternalInventoryRemove(apx *ApxClient) http.HandlerFunc {
 }
 
 // POST /api/internal/progression/inventory-equip
-func handleInternalInventoryEquip(apx *ApxClient) http.HandlerFunc {
+func handleInternalInventoryEquip(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-			return
-		}
-		if !checkInternalAPIKey(w, r) {
-			return
-		}
 		var req struct {
 			UserID      string `json:"user_id"`
 			InventoryID int    `json:"inventory_id"`
@@ -189,12 +151,12 @@ func handleInternalInventoryEquip(apx *ApxClient) http.HandlerFunc {
 			jsonError(w, http.StatusBadRequest, "invalid body")
 			return
 		}
-		userID, err := apx.ResolveUserIDByDiscord(req.UserID)
+		userID, err := apx.ResolveUserIDByDiscord(r.Context(), req.UserID)
 		if err != nil {
 			jsonResponse(w, http.StatusOK, map[string]interface{}{"success": true, "linked": false})
 			return
 		}
-		if err := apx.EquipInventoryItem(userID, req.InventoryID, req.ItemType, req.Equipped); err != nil {
+		if err := apx.EquipInventoryItem(r.Context(), userID, req.InventoryID, req.ItemType, req.Equipped); err != nil {
 			log.Printf("inventory-equip: %v", err)
 			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
@@ -204,15 +166,8 @@ func handleInternalInventoryEquip(apx *ApxClient) http.HandlerFunc {
 }
 
 // POST /api/internal/progression/role-sync
-func handleInternalRoleSync(apx *ApxClient) http.HandlerFunc {
+func handleInternalRoleSync(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-			return
-		}
-		if !checkInternalAPIKey(w, r) {
-			return
-		}
 		var req struct {
 			UserID  string `json:"user_id"`
 			GuildID string `json:"guild_id"`
@@ -237,12 +192,12 @@ func handleInternalRoleSync(apx *ApxClient) http.HandlerFunc {
 		} else if len(rank) == 6 && strings.HasSuffix(rank[1:], "-rank") {
 			rank = string(rank[0])
 		}
-		userID, err := apx.ResolveUserIDByDiscord(req.UserID)
+		userID, err := apx.ResolveUserIDByDiscord(r.Context(), req.UserID)
 		if err != nil {
 			jsonResponse(w, http.StatusOK, map[string]interface{}{"success": true, "linked": false})
 			return
 		}
-		if err := apx.UpdateProgressionUserRank(userID, req.UserID, rank); err != nil {
+		if err := apx.UpdateProgressionUserRank(r.Context(), userID, req.UserID, rank); err != nil {
 			log.Printf("role-sync: %v", err)
 			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
@@ -254,26 +209,22 @@ func handleInternalRoleSync(apx *ApxClient) http.HandlerFunc {
 // ── Public Handlers (Website → Go) ──
 
 // GET /api/progression/profile?u=<username>
-func handleProgressionProfile(apx *ApxClient) http.HandlerFunc {
+func handleProgressionProfile(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-			return
-		}
 		username := r.URL.Query().Get("u")
 		if username == "" {
 			jsonError(w, http.StatusBadRequest, "username required")
 			return
 		}
 
-		u, err := apx.GetUserByUsername(username)
+		u, err := apx.GetUserByUsername(r.Context(), username)
 		if err != nil {
 			jsonError(w, http.StatusNotFound, "user not found")
 			return
 		}
 
 		var discordID string
-		links, _ := apx.GetLinkedAccounts(u.ID)
+		links, _ := apx.GetLinkedAccounts(r.Context(), u.ID)
 		for _, l := range links {
 			if l.Service == "discord" {
 				discordID = l.ServiceID
@@ -284,14 +235,14 @@ func handleProgressionProfile(apx *ApxClient) http.HandlerFunc {
 		var level, balance int
 		rank := ""
 		if discordID != "" {
-			if botUser, err := apx.GetBotUser(discordID, apxGuildID); err == nil {
+			if botUser, err := apx.GetBotUser(r.Context(), discordID, apxGuildID); err == nil {
 				level = botUser.Level
 				balance = botUser.Gold
 				rank = rankFromRoleID(botUser.RankRoleID)
 			}
 		}
 
-		equippedItems, err := apx.GetEquippedItems(u.ID)
+		equippedItems, err := apx.GetEquippedItems(r.Context(), u.ID)
 		if err != nil {
 			equippedItems = []ProgressionInventoryItem{}
 		}
@@ -307,25 +258,12 @@ func handleProgressionProfile(apx *ApxClient) http.HandlerFunc {
 }
 
 // GET /api/progression/me  (session cookie required)
-func handleProgressionMe(apx *ApxClient) http.HandlerFunc {
+func handleProgressionMe(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-			return
-		}
-		cookie, err := r.Cookie("session")
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
-			return
-		}
-		user, err := apx.GetSessionUser(cookie.Value)
-		if err != nil {
-			jsonError(w, http.StatusUnauthorized, "Nicht angemeldet")
-			return
-		}
+		user := sessionUser(r)
 
 		var discordID string
-		links, _ := apx.GetLinkedAccounts(user.ID)
+		links, _ := apx.GetLinkedAccounts(r.Context(), user.ID)
 		for _, l := range links {
 			if l.Service == "discord" {
 				discordID = l.ServiceID
@@ -336,14 +274,14 @@ func handleProgressionMe(apx *ApxClient) http.HandlerFunc {
 		var level, balance int
 		rank := ""
 		if discordID != "" {
-			if botUser, err := apx.GetBotUser(discordID, apxGuildID); err == nil {
+			if botUser, err := apx.GetBotUser(r.Context(), discordID, apxGuildID); err == nil {
 				level = botUser.Level
 				balance = botUser.Gold
 				rank = rankFromRoleID(botUser.RankRoleID)
 			}
 		}
 
-		inventory, err := apx.GetUserItems(user.ID)
+		inventory, err := apx.GetUserItems(r.Context(), user.ID)
 		if err != nil {
 			inventory = []ProgressionInventoryItem{}
 		}
@@ -360,13 +298,8 @@ func handleProgressionMe(apx *ApxClient) http.HandlerFunc {
 
 // GET /api/progression/leaderboard?limit=10
 // Returns { entries: [...], my_position: null }
-func handleProgressionLeaderboard(apx *ApxClient) http.HandlerFunc {
+func handleProgressionLeaderboard(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-			return
-		}
-
 		limit := 10
 		if v := r.URL.Query().Get("limit"); v != "" {
 			if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 50 {
@@ -374,7 +307,7 @@ func handleProgressionLeaderboard(apx *ApxClient) http.HandlerFunc {
 			}
 		}
 
-		users, err := apx.GetBotLeaderboard(apxGuildID, limit)
+		users, err := apx.GetBotLeaderboard(r.Context(), apxGuildID, limit)
 		if err != nil {
 			log.Printf("leaderboard query: %v", err)
 			jsonError(w, http.StatusInternalServerError, "internal error")
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"teamapx-backend/cmd/apxfake"
+)
+
+func TestInternalUserSync(t *testing.T) {
+	e := newTestEnv(t)
+	uid := e.addUser(apxfake.User{Username: "gamer", Email: "gamer@example.com"}, "secret123")
+	e.fake.LinkAccount(uid, apxfake.LinkedAccount{Service: "discord", ServiceID: "4242", Username: "gamer"})
+
+	sync := func(key, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/api/internal/progression/user-sync", strings.NewReader(body))
+		r.Header.Set("X-Api-Key", key)
+		w := httptest.NewRecorder()
+		e.mux.ServeHTTP(w, r)
+		return w
+	}
+
+	expectStatus(t, sync("wrong", `{"user_id":"4242","level":3}`), http.StatusUnauthorized)
+	expectStatus(t, sync(testInternalKey, `{"level":3}`), http.StatusBadRequest)
+
+	w := sync(testInternalKey, `{"user_id":"9999","level":3}`)
+	expectStatus(t, w, http.StatusOK)
+	if body := decodeBody(t, w); body["linked"] != false {
+		t.Errorf("unlinked sync = %v", body)
+	}
+
+	w = sync(testInternalKey, `{"user_id":"4242","level":7,"xp":1234,"currency_balance":50}`)
+	expectStatus(t, w, http.StatusOK)
+	if body := decodeBody(t, w); body["linked"] != true {
+		t.Errorf("linked sync = %v", body)
+	}
+	p, ok := e.fake.ProgressionUser(uid)
+	if !ok {
+		t.Fatal("progression not stored")
+	}
+	if p.DiscordID != "4242" || p.Level != 7 || p.XP != 1234 || p.CurrencyBalance != 50 {
+		t.Errorf("progression = %+v", p)
+	}
+
+	// A later sync overwrites the values.
+	expectStatus(t, sync(testInternalKey, `{"user_id":"4242","level":8,"xp":10,"currency_balance":0}`), http.StatusOK)
+	if p, _ := e.fake.ProgressionUser(uid); p.Level != 8 || p.XP != 10 || p.CurrencyBalance != 0 {
+		t.Errorf("progression after resync = %+v", p)
+	}
+}
@@ -6,26 +6,21 @@ import (
 )
 
 // GET /api/user?u=<username>  (also used as handleAdminPublicUser via separate route)
-func handlePublicUser(apx *ApxClient) http.HandlerFunc {
+func handlePublicUser(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-			return
-		}
-
 		username := r.URL.Query().Get("u")
 		if username == "" {
 			jsonError(w, http.StatusBadRequest, "missing username")
 			return
 		}
 
-		u, err := apx.GetUserByUsername(username)
+		u, err := apx.GetUserByUsername(r.Context(), username)
 		if err != nil {
 			jsonError(w, http.StatusNotFound, "user not found")
 			return
 		}
 
-		links, err := apx.GetLinkedAccounts(u.ID)
+		links, err := apx.GetLinkedAccounts(r.Context(), u.ID)
 		if err != nil {
 			log.Printf("handlePublicUser GetLinkedAccounts: %v", err)
 			links = nil
@@ -63,7 +58,7 @@ func handlePublicUser(apx *ApxClient) http.HandlerFunc {
 			MaxLevel int    `json:"max_level"`
 		}
 		pubBadges := []publicBadge{}
-		if userBadges, err := apx.GetUserBadges(u.ID); err == nil {
+		if userBadges, err := apx.GetUserBadges(r.Context(), u.ID); err == nil {
 			for _, b := range userBadges {
 				if b.Owned {
 					pubBadges = append(pubBadges, publicBadge{b.Name, b.ImageURL, b.Level, b.MaxLevel})
@@ -86,25 +81,23 @@ func handlePublicUser(apx *ApxClient) http.HandlerFunc {
 		if u.Timezone != "" {
 			resp["timezone"] = u.Timezone
 		}
+		if tenure := tenureFor(r.Context(), apx, u.Username); tenure != nil {
+			resp["tenure"] = tenure
+		}
 		jsonResponse(w, http.StatusOK, resp)
 	}
 }
 
 // GET /api/users/search?q=<query>
-func handleUserSearch(apx *ApxClient) http.HandlerFunc {
+func handleUserSearch(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-			return
-		}
-
 		q := r.URL.Query().Get("q")
 		if q == "" {
 			jsonResponse(w, http.StatusOK, map[string]interface{}{"users": []struct{}{}})
 			return
 		}
 
-		users, err := apx.SearchUsers(q)
+		users, err := apx.SearchUsers(r.Context(), q)
 		if err != nil {
 			log.Printf("handleUserSearch: %v", err)
 			jsonError(w, http.StatusInternalServerError, "internal error")
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// attemptPolicy describes how failed attempts for one key are throttled.
+// The first Free failures are not delayed; after that every failure blocks
+// the key for Base·2^(n-Free), capped at Max. Reaching LockAfter failures
+// locks the key for LockFor. Keys are forgotten after Window without failures.
+type attemptPolicy struct {
+	Free      int
+	Base      time.Duration
+	Max       time.Duration
+	LockAfter int
+	LockFor   time.Duration
+	Window    time.Duration
+}
+
+type attemptEntry struct {
+	failures     int
+	lastFailure  time.Time
+	blockedUntil time.Time
+	locked       bool
+}
+
+// attemptLimiter is an in-memory failure counter. State is per process and is
+// lost on restart, which is acceptable for a single backend instance.
+type attemptLimiter struct {
+	policy    attemptPolicy
+	mu        sync.Mutex
+	entries   map[string]*attemptEntry
+	lastSweep time.Time
+}
+
+func newAttemptLimiter(p attemptPolicy) *attemptLimiter {
+	return &attemptLimiter{policy: p, entries: make(map[string]*attemptEntry)}
+}
+
+var (
+	// loginIPLimiter throttles all password and 2FA attempts from one client IP.
+	loginIPLimiter = newAttemptLimiter(attemptPolicy{
+		Free: 5, Base: 2 * time.Second, Max: 5 * time.Minute,
+		LockAfter: 50, LockFor: time.Hour, Window: time.Hour,
+	})
+	// loginAccountLimiter throttles attempts against one account regardless
+	// of the source IP. Locks can be lifted by an admin.
+	loginAccountLimiter = newAttemptLimiter(attemptPolicy{
+		Free: 3, Base: time.Second, Max: 2 * time.Minute,
+		LockAfter: 10, LockFor: 30 * time.Minute, Window: time.Hour,
+	})
+	// codeAttemptLimiter counts wrong codes per pending 2FA token or password
+	// reset; the caller burns the token once maxCodeAttempts is reached.
+	// Keys without a token to burn (TOTP settings) stay locked for LockFor.
+	codeAttemptLimiter = newAttemptLimiter(attemptPolicy{
+		LockAfter: maxCodeAttempts, LockFor: 15 * time.Minute, Window: 30 * time.Minute,
+	})
+	// resetRequestLimiter counts every password reset request per client IP
+	// and per email, so the endpoint cannot be used to flood an inbox.
+	resetRequestLimiter = newAttemptLimiter(attemptPolicy{
+		Free: 3, Base: 30 * time.Second, Max: 15 * time.Minute, Window: time.Hour,
+	})
+)
+
+const maxCodeAttempts = 5
+
+func accountKey(userID int64) string { return fmt.Sprintf("acct:%d", userID) }
+
+// check reports how long key must wait before the next attempt and whether
+// the wait is an account lockout rather than plain backoff.
+func (l *attemptLimiter) check(key string) (wait time.Duration, locked bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.entries[key]
+	if !ok {
+		return 0, false
+	}
+	now := time.Now()
+	if now.Before(e.blockedUntil) {
+		return e.blockedUntil.Sub(now), e.locked
+	}
+	return 0, false
+}
+
+// fail records a failed attempt and returns the new failure count.
+func (l *attemptLimiter) fail(key string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.sweep(now)
+
+	e, ok := l.entries[key]
+	if !ok || (l.policy.Window > 0 && now.Sub(e.lastFailure) > l.policy.Window && !now.Before(e.blockedUntil)) {
+		e = &attemptEntry{}
+		l.entries[key] = e
+	}
+	e.failures++
+	e.lastFailure = now
+
+	p := l.policy
+	switch {
+	case p.LockAfter > 0 && e.failures >= p.LockAfter:
+		e.blockedUntil = now.Add(p.LockFor)
+		e.locked = true
+	case p.Base > 0 && e.failures > p.Free:
+		d := time.Duration(float64(p.Base) * math.Pow(2, float64(e.failures-p.Free-1)))
+		if d > p.Max || d <= 0 {
+			d = p.Max
+		}
+		e.blockedUntil = now.Add(d)
+	}
+	return e.failures
+}
+
+// reset forgets all failures for key (successful login or admin unlock).
+func (l *attemptLimiter) reset(key string) {
+	l.mu.Lock()
+	delete(l.entries, key)
+	l.mu.Unlock()
+}
+
+// lockedUntil returns the end of an active lockout for key, if any.
+func (l *attemptLimiter) lockedUntil(key string) (time.Time, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.entries[key]
+	if !ok || !e.locked || !time.Now().Before(e.blockedUntil) {
+		return time.Time{}, false
+	}
+	return e.blockedUntil, true
+}
+
+// sweep drops stale entries at most once per minute. Caller holds l.mu.
+func (l *attemptLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute || l.policy.Window == 0 {
+		return
+	}
+	l.lastSweep = now
+	for k, e := range l.entries {
+		if now.Sub(e.lastFailure) > l.policy.Window && !now.Before(e.blockedUntil) {
+			delete(l.entries, k)
+		}
+	}
+}
+
+// rejectThrottled writes a 429 response if key is currently blocked and
+// reports whether it did.
+func rejectThrottled(w http.ResponseWriter, l *attemptLimiter, key string) bool {
+	wait, locked := l.check(key)
+	if wait <= 0 {
+		return false
+	}
+	secs := int(math.Ceil(wait.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	if locked {
+		jsonError(w, http.StatusTooManyRequests, fmt.Sprintf("Zu viele Fehlversuche – vorübergehend gesperrt. Bitte in %d Minuten erneut versuchen", (secs+59)/60))
+		return true
+	}
+	jsonError(w, http.StatusTooManyRequests, fmt.Sprintf("Zu viele Anmeldeversuche. Bitte in %d Sekunden erneut versuchen", secs))
+	return true
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// RatingWeights configures the rating engine. The values in use are
+// PlayerRatingWeights in config.go.
+type RatingWeights struct {
+	Impact ImpactWeights
+	Clutch [5]float64 // points per won 1v1 … 1v5
+	Mix    RatingMix
+}
+
+// ImpactWeights value kills and deaths by situation. The impact score is the
+// weighted kills' share of weighted kills plus weighted deaths.
+type ImpactWeights struct {
+	KillEntry, KillTrade, KillImpact, KillLate float64
+	DeathEntry, DeathTrade, DeathLate          float64
+}
+
+// RatingMix is the share of each score in the overall 0–100 rating. The clutch
+// score enters relative to the roster's best clutch player. Players without
+// recorded rounds have no KOST; their rating is mixed from the other two.
+type RatingMix struct {
+	Impact, KOST, Clutch float64
+}
+
+// PlayerRating is the computed rating of one player.
+type PlayerRating struct {
+	Impact float64  `json:"impact"` // 0–1, 0.5 is even
+	KOST   *float64 `json:"kost"`   // 0–1, nil without recorded rounds
+	Clutch float64  `json:"clutch"` // clutch points
+	Rating float64  `json:"rating"` // 0–100
+	Rank   int      `json:"rank"`   // 1 is best; tied players share a rank
+}
+
+func round(v float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Round(v*p) / p
+}
+
+// ratePlayer computes the impact, KOST and clutch scores of a player from
+// the match counters s and the staff-entered rounds and KOST points. It
+// returns nil for players without any recorded engagement or round.
+func ratePlayer(s PlayerStats, rounds, kostPoints int, w RatingWeights) *PlayerRating {
+	kills, deaths, objectives := s.kills(), s.deaths(), s.ObjPlant+s.ObjDefuse
+	if kills+deaths+objectives == 0 && rounds == 0 {
+		return nil
+	}
+	iw := w.Impact
+	weightedKills := iw.KillEntry*float64(s.KillEntry) + iw.KillTrade*float64(s.KillTrade) +
+		iw.KillImpact*float64(s.KillImpact) + iw.KillLate*float64(s.KillLate)
+	weightedDeaths := iw.DeathEntry*float64(s.DeathEntry) + iw.DeathTrade*float64(s.DeathTrade) +
+		iw.DeathLate*float64(s.DeathLate)
+	var impact float64
+	if weightedKills+weightedDeaths > 0 {
+		impact = weightedKills / (weightedKills + weightedDeaths)
+	}
+
+	var kost *float64
+	if rounds > 0 {
+		k := round(math.Min(1, float64(kostPoints)/float64(rounds)), 3)
+		kost = &k
+	}
+
+	var clutch float64
+	for i, n := range []int{s.Clutch1v1, s.Clutch1v2, s.Clutch1v3, s.Clutch1v4, s.Clutch1v5} {
+		clutch += w.Clutch[i] * float64(n)
+	}
+
+	return &PlayerRating{Impact: round(impact, 3), KOST: kost, Clutch: round(clutch, 2)}
+}
+
+// rateRoster sets Rating on every member with recorded stats and ranks them.
+func rateRoster(members []TeamMember, w RatingWeights) {
+	var rated []*PlayerRating
+	var bestClutch float64
+	for i := range members {
+		m := &members[i]
+		m.Rating = ratePlayer(m.PlayerStats, m.Rounds, m.KostPoints, w)
+		if pr := members[i].Rating; pr != nil {
+			rated = append(rated, pr)
+			bestClutch = math.Max(bestClutch, pr.Clutch)
+		}
+	}
+
+	mix := w.Mix
+	for _, pr := range rated {
+		var clutch float64
+		if bestClutch > 0 {
+			clutch = pr.Clutch / bestClutch
+		}
+		score, total := mix.Impact*pr.Impact+mix.Clutch*clutch, mix.Impact+mix.Clutch
+		if pr.KOST != nil {
+			score += mix.KOST * *pr.KOST
+			total += mix.KOST
+		}
+		if total > 0 {
+			pr.Rating = round(100*score/total, 1)
+		}
+	}
+
+	sort.SliceStable(rated, func(i, j int) bool { return rated[i].Rating > rated[j].Rating })
+	for i, pr := range rated {
+		pr.Rank = i + 1
+		if i > 0 && pr.Rating == rated[i-1].Rating {
+			pr.Rank = rated[i-1].Rank
+		}
+	}
+}
+
+// matchTotalsBetween sums each member's stat lines of the matches played in
+// [from, to); a zero to leaves the range open.
+func matchTotalsBetween(matches []Match, from, to time.Time) map[int64]PlayerStats {
+	totals := map[int64]PlayerStats{}
+	for _, m := range matches {
+		playedAt, err := time.Parse(time.RFC3339, m.PlayedAt)
+		if err != nil || playedAt.Before(from) || (!to.IsZero() && !playedAt.Before(to)) {
+			continue
+		}
+		for _, p := range m.Players {
+			t := totals[p.TeamMemberID]
+			t.add(p.PlayerStats)
+			totals[p.TeamMemberID] = t
+		}
+	}
+	return totals
+}
+
+// rankedPlayer is one entry of the roster ranking.
+type rankedPlayer struct {
+	TeamMemberID int64  `json:"team_member_id"`
+	Name         string `json:"name"`
+	AvatarURL    string `json:"avatar_url"`
+	IsMainRoster bool   `json:"is_main_roster"`
+	PlayerStats
+	PlayerRating
+}
+
+// rankRoster rates members and returns the rated ones, best first.
+func rankRoster(members []TeamMember) []rankedPlayer {
+	rateRoster(members, PlayerRatingWeights)
+	ranking := []rankedPlayer{}
+	for _, m := range members {
+		if m.Rating == nil {
+			continue
+		}
+		ranking = append(ranking, rankedPlayer{
+			TeamMemberID: m.ID,
+			Name:         m.Name,
+			AvatarURL:    m.AvatarURL,
+			IsMainRoster: m.IsMainRoster,
+			PlayerStats:  m.PlayerStats,
+			PlayerRating: *m.Rating,
+		})
+	}
+	sort.SliceStable(ranking, func(i, j int) bool { return ranking[i].Rank < ranking[j].Rank })
+	return ranking
+}
+
+// handlePublicTeamRanking serves GET /api/team/ranking?days=. Without days
+// the all-time totals are ranked; with days only matches of the last days
+// days count.
+func handlePublicTeamRanking(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		days, _ := strconv.Atoi(r.URL.Query().Get("days"))
+		if days < 0 || days > 366 {
+			jsonError(w, http.StatusBadRequest, "days muss zwischen 0 und 366 liegen")
+			return
+		}
+		members, err := apx.GetTeamMembers(r.Context())
+		if err != nil {
+			log.Printf("Failed to get team members: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+
+		resp := map[string]interface{}{}
+		if days == 0 {
+			addMatchTotals(r.Context(), apx, members)
+		} else {
+			since := time.Now().UTC().AddDate(0, 0, -days)
+			matches, err := apx.GetMatches(r.Context())
+			if err != nil {
+				log.Printf("GetMatches error: %v", err)
+				jsonError(w, http.StatusInternalServerError, "internal error")
+				return
+			}
+			totals := matchTotalsBetween(matches, since, time.Time{})
+			// Rounds are only kept as all-time totals.
+			for i := range members {
+				members[i].PlayerStats = totals[members[i].ID]
+				members[i].Rounds, members[i].KostPoints = 0, 0
+			}
+			resp["since"] = since.Format(time.RFC3339)
+		}
+		enrichTeamAvatars(r.Context(), members, apx)
+		resp["ranking"] = rankRoster(members)
+		jsonResponse(w, http.StatusOK, resp)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+)
+
+// Permission names a single privileged capability in the admin area.
+type Permission string
+
+const (
+	PermViewUsers          Permission = "users.view"
+	PermManageUsers        Permission = "users.manage"
+	PermDeleteUsers        Permission = "users.delete"
+	PermManageRoles        Permission = "roles.manage"
+	PermManageContent      Permission = "content.manage"
+	PermManageBadges       Permission = "badges.manage"
+	PermManageItems        Permission = "items.manage"
+	PermManageEvents       Permission = "events.manage"
+	PermManageRoster       Permission = "roster.manage"
+	PermReviewApplications Permission = "applications.review"
+	PermManageForms        Permission = "applications.forms"
+	PermViewAudit          Permission = "audit.view"
+	PermManageSchedule     Permission = "schedule.manage"
+)
+
+const RoleSuperadmin = "superadmin"
+
+// rolePermissions is the permission set of every assignable role. Users with
+// the legacy is_admin flag are treated as superadmin.
+var rolePermissions = map[string][]Permission{
+	RoleSuperadmin: {
+		PermViewUsers, PermManageUsers, PermDeleteUsers, PermManageRoles,
+		PermManageContent, PermManageBadges, PermManageItems,
+		PermManageEvents, PermManageRoster, PermReviewApplications,
+		PermManageForms, PermViewAudit, PermManageSchedule,
+	},
+	"moderator":      {PermViewUsers, PermManageUsers, PermManageBadges, PermViewAudit},
+	"content_editor": {PermManageContent, PermManageBadges, PermManageItems},
+	"event_manager":  {PermViewUsers, PermManageEvents},
+	"roster_manager": {PermViewUsers, PermManageRoster, PermReviewApplications, PermManageForms, PermManageSchedule},
+}
+
+func (u *User) effectiveRole() string {
+	if u.IsAdmin {
+		return RoleSuperadmin
+	}
+	return u.Role
+}
+
+// can reports whether the user's role grants perm.
+func (u *User) can(perm Permission) bool {
+	for _, p := range rolePermissions[u.effectiveRole()] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// covers reports whether u holds every permission of other's role, so that
+// acting on other cannot hand u more power than they already have.
+func (u *User) covers(other *User) bool {
+	for _, p := range rolePermissions[other.effectiveRole()] {
+		if !u.can(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// permissions returns the user's permission names, sorted.
+func (u *User) permissions() []string {
+	perms := make([]string, 0, len(rolePermissions[u.effectiveRole()]))
+	for _, p := range rolePermissions[u.effectiveRole()] {
+		perms = append(perms, string(p))
+	}
+	sort.Strings(perms)
+	return perms
+}
+
+// handleAdminRoles serves GET /api/admin/roles — the assignable roles and
+// their permissions.
+func handleAdminRoles(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type roleInfo struct {
+			Name        string       `json:"name"`
+			Permissions []Permission `json:"permissions"`
+		}
+		roles := make([]roleInfo, 0, len(rolePermissions))
+		for name, perms := range rolePermissions {
+			roles = append(roles, roleInfo{Name: name, Permissions: perms})
+		}
+		sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"roles": roles})
+	}
+}
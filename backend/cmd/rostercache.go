@@ -0,0 +1,71 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+const rosterCacheTTL = 5 * time.Minute
+
+// rosterCache holds the enriched public /api/team and /api/staff payloads.
+// Admin team and staff mutations invalidate it; avatar and nickname changes
+// made by the players themselves show up after rosterCacheTTL.
+type rosterCache struct {
+	mu      sync.Mutex
+	gen     uint64 // bumped on every invalidation
+	team    []TeamMember
+	teamAt  time.Time
+	staff   []StaffMember
+	staffAt time.Time
+}
+
+var publicRoster rosterCache
+
+// generation must be read before loading the data passed to setTeam or
+// setStaff, so a load that races with an invalidation is not cached.
+func (c *rosterCache) generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen
+}
+
+func (c *rosterCache) getTeam() ([]TeamMember, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.team == nil || time.Since(c.teamAt) > rosterCacheTTL {
+		return nil, false
+	}
+	return c.team, true
+}
+
+func (c *rosterCache) setTeam(gen uint64, members []TeamMember) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen == c.gen && members != nil {
+		c.team, c.teamAt = members, time.Now()
+	}
+}
+
+func (c *rosterCache) getStaff() ([]StaffMember, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.staff == nil || time.Since(c.staffAt) > rosterCacheTTL {
+		return nil, false
+	}
+	return c.staff, true
+}
+
+func (c *rosterCache) setStaff(gen uint64, staff []StaffMember) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen == c.gen {
+		c.staff, c.staffAt = staff, time.Now()
+	}
+}
+
+func (c *rosterCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	c.team, c.staff = nil, nil
+}
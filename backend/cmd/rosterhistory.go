@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Roster event kinds.
+const (
+	RosterJoined   = "joined"
+	RosterLeft     = "left"
+	RosterPromoted = "promoted" // sub → main roster
+	RosterDemoted  = "demoted"  // main roster → sub
+	RosterAtkRole  = "atk_role"
+	RosterDefRole  = "def_role"
+	RosterPairing  = "paired_with"
+)
+
+func rosterStatus(isMainRoster bool) string {
+	if isMainRoster {
+		return "main"
+	}
+	return "sub"
+}
+
+// recordRosterEvents stores roster history entries. Like the audit log it
+// never fails the request; errors are only logged.
+func recordRosterEvents(ctx context.Context, apx Store, events ...RosterEvent) {
+	if len(events) == 0 {
+		return
+	}
+	if err := apx.AddRosterEvents(ctx, events); err != nil {
+		log.Printf("AddRosterEvents error: %v", err)
+	}
+}
+
+// rosterJoined is the history entry of a new team member.
+func rosterJoined(id int64, name, username string, isMainRoster bool) RosterEvent {
+	return RosterEvent{TeamMemberID: id, Username: username, Name: name, Kind: RosterJoined, To: rosterStatus(isMainRoster)}
+}
+
+// rosterChanges returns the history entries for an update from before to
+// after. members resolves pairing partners to names.
+func rosterChanges(before, after TeamMember, members []TeamMember) []RosterEvent {
+	var events []RosterEvent
+	add := func(kind, from, to string) {
+		events = append(events, RosterEvent{
+			TeamMemberID: after.ID, Username: after.Username, Name: after.Name,
+			Kind: kind, From: from, To: to,
+		})
+	}
+	if before.IsMainRoster != after.IsMainRoster {
+		kind := RosterDemoted
+		if after.IsMainRoster {
+			kind = RosterPromoted
+		}
+		add(kind, rosterStatus(before.IsMainRoster), rosterStatus(after.IsMainRoster))
+	}
+	if before.AtkRole != after.AtkRole {
+		add(RosterAtkRole, before.AtkRole, after.AtkRole)
+	}
+	if before.DefRole != after.DefRole {
+		add(RosterDefRole, before.DefRole, after.DefRole)
+	}
+	partner := func(id *int64) string {
+		if id == nil {
+			return ""
+		}
+		for _, m := range members {
+			if m.ID == *id {
+				return m.Name
+			}
+		}
+		return "#" + strconv.FormatInt(*id, 10)
+	}
+	if from, to := partner(before.PairedWith), partner(after.PairedWith); from != to {
+		add(RosterPairing, from, to)
+	}
+	return events
+}
+
+// tenurePeriod is one stint on the roster; a new team entry starts a new one.
+type tenurePeriod struct {
+	TeamMemberID   int64  `json:"team_member_id"`
+	Name           string `json:"name"`
+	JoinedAt       string `json:"joined_at"`         // for earlier players: when history tracking started
+	LeftAt         string `json:"left_at,omitempty"` // empty while still on the roster
+	LeaveReason    string `json:"leave_reason,omitempty"`
+	Days           int    `json:"days"`
+	MainRosterDays int    `json:"main_roster_days"`
+}
+
+// rosterTenure is a player's time on the roster.
+type rosterTenure struct {
+	Current        bool           `json:"current"`
+	Days           int            `json:"days"`
+	MainRosterDays int            `json:"main_roster_days"`
+	Periods        []tenurePeriod `json:"periods"`
+}
+
+// rosterEntriesOf returns the team entries that were ever linked to username.
+func rosterEntriesOf(events []RosterEvent, username string) map[int64]bool {
+	ids := map[int64]bool{}
+	for _, e := range events {
+		if username != "" && strings.EqualFold(e.Username, username) {
+			ids[e.TeamMemberID] = true
+		}
+	}
+	return ids
+}
+
+// playerTenure builds username's tenure from the history. It returns nil for
+// users who never were on the roster.
+func playerTenure(events []RosterEvent, username string, now time.Time) *rosterTenure {
+	ids := rosterEntriesOf(events, username)
+	if len(ids) == 0 {
+		return nil
+	}
+
+	type stint struct {
+		period           tenurePeriod
+		start, mainSince time.Time
+		end              time.Time
+		left, onMain     bool
+		mainDuration     time.Duration
+	}
+	stints := map[int64]*stint{}
+	var order []int64
+	for _, e := range events {
+		if !ids[e.TeamMemberID] {
+			continue
+		}
+		at, _ := parseStoreTime(e.OccurredAt)
+		s := stints[e.TeamMemberID]
+		if s == nil {
+			s = &stint{period: tenurePeriod{TeamMemberID: e.TeamMemberID}, start: at}
+			stints[e.TeamMemberID] = s
+			order = append(order, e.TeamMemberID)
+		}
+		s.period.Name = e.Name
+		switch e.Kind {
+		case RosterJoined:
+			s.start = at
+			s.period.JoinedAt = e.OccurredAt
+			if e.To == "main" {
+				s.onMain, s.mainSince = true, at
+			}
+		case RosterPromoted:
+			s.onMain, s.mainSince = true, at
+		case RosterDemoted:
+			if s.onMain {
+				s.mainDuration += at.Sub(s.mainSince)
+			}
+			s.onMain = false
+		case RosterLeft:
+			s.left, s.end = true, at
+			s.period.LeftAt, s.period.LeaveReason = e.OccurredAt, e.Reason
+			if s.onMain {
+				s.mainDuration += at.Sub(s.mainSince)
+			}
+			s.onMain = false
+		}
+	}
+
+	const day = 24 * time.Hour
+	t := &rosterTenure{Periods: []tenurePeriod{}}
+	for _, id := range order {
+		s := stints[id]
+		end := s.end
+		if !s.left {
+			end = now
+			t.Current = true
+		}
+		if s.onMain {
+			s.mainDuration += end.Sub(s.mainSince)
+		}
+		s.period.Days = int(end.Sub(s.start) / day)
+		s.period.MainRosterDays = int(s.mainDuration / day)
+		t.Days += s.period.Days
+		t.MainRosterDays += s.period.MainRosterDays
+		t.Periods = append(t.Periods, s.period)
+	}
+	return t
+}
+
+// tenureFor loads username's history and returns their tenure, or nil.
+func tenureFor(ctx context.Context, apx Store, username string) *rosterTenure {
+	events, err := apx.GetUserRosterEvents(ctx, username)
+	if err != nil {
+		log.Printf("GetUserRosterEvents error: %v", err)
+		return nil
+	}
+	return playerTenure(events, username, time.Now().UTC())
+}
+
+// handleRosterHistory serves GET /api/team/history?username=&limit=: the
+// roster timeline, newest first.
+func handleRosterHistory(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit <= 0 || limit > 500 {
+			limit = 100
+		}
+		username := r.URL.Query().Get("username")
+
+		var events []RosterEvent
+		var err error
+		if username != "" {
+			events, err = apx.GetUserRosterEvents(r.Context(), username)
+		} else {
+			events, err = apx.GetRosterEvents(r.Context())
+		}
+		if err != nil {
+			log.Printf("GetRosterEvents error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		ids := rosterEntriesOf(events, username)
+		timeline := []RosterEvent{}
+		for i := len(events) - 1; i >= 0 && len(timeline) < limit; i-- {
+			if username == "" || ids[events[i].TeamMemberID] {
+				timeline = append(timeline, events[i])
+			}
+		}
+		resp := map[string]interface{}{"events": timeline}
+		if username != "" {
+			resp["tenure"] = playerTenure(events, username, time.Now().UTC())
+		}
+		jsonResponse(w, http.StatusOK, resp)
+	}
+}
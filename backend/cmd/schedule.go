@@ -0,0 +1,614 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // the production image has no zoneinfo
+)
+
+// Session kinds and RSVP answers.
+const (
+	SessionScrim    = "scrim"
+	SessionPractice = "practice"
+
+	RSVPYes   = "yes"
+	RSVPNo    = "no"
+	RSVPMaybe = "maybe"
+)
+
+const (
+	maxAvailabilitySlots = 42
+	maxSessionDuration   = 12 * time.Hour
+)
+
+// userLocation returns the user's timezone, or UTC when unset or unknown.
+func userLocation(u *User) *time.Location {
+	if u == nil || u.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(u.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// teamRole reports what u may do in the team tools (scheduler, lineups):
+// members (roster players and staff) get read access, keep their
+// availability and RSVP; coaches (a staff role marked is_coach, or
+// schedule.manage) also plan sessions and edit lineups.
+func teamRole(ctx context.Context, apx Store, u *User) (member, coach bool) {
+	if u.can(PermManageSchedule) {
+		member, coach = true, true
+	}
+	if staff, err := apx.GetStaffMembers(ctx); err == nil {
+		roles, err := apx.GetStaffRoles(ctx)
+		if err != nil {
+			log.Printf("GetStaffRoles error: %v", err)
+		}
+		for _, s := range staff {
+			if s.Username != "" && strings.EqualFold(s.Username, u.Username) {
+				member = true
+				if role := findStaffRole(roles, s.Role); role != nil && role.IsCoach {
+					coach = true
+				}
+			}
+		}
+	}
+	if !member {
+		if members, err := apx.GetTeamMembers(ctx); err == nil {
+			for _, m := range members {
+				if m.Username != "" && strings.EqualFold(m.Username, u.Username) {
+					member = true
+				}
+			}
+		}
+	}
+	return member, coach
+}
+
+// requireTeamMember writes 403 and returns false for users outside the
+// team.
+func requireTeamMember(w http.ResponseWriter, r *http.Request, apx Store) bool {
+	if member, _ := teamRole(r.Context(), apx, sessionUser(r)); !member {
+		jsonError(w, http.StatusForbidden, "Nur für Spieler und Staff")
+		return false
+	}
+	return true
+}
+
+func requireCoach(w http.ResponseWriter, r *http.Request, apx Store) bool {
+	if _, coach := teamRole(r.Context(), apx, sessionUser(r)); !coach {
+		jsonError(w, http.StatusForbidden, "Nur für Coaches")
+		return false
+	}
+	return true
+}
+
+// parseClock parses "HH:MM" (up to "24:00") into minutes after midnight.
+func parseClock(s string) (int, bool) {
+	var h, m int
+	if len(s) != 5 || s[2] != ':' {
+		return 0, false
+	}
+	if _, err := fmt.Sscanf(s, "%2d:%2d", &h, &m); err != nil {
+		return 0, false
+	}
+	if h < 0 || m < 0 || m > 59 || h > 24 || (h == 24 && m != 0) {
+		return 0, false
+	}
+	return h*60 + m, true
+}
+
+// validateAvailability checks and sorts slots. Slots of one day must not
+// overlap; ranges past midnight are entered as two slots.
+func validateAvailability(slots []AvailabilitySlot) string {
+	if len(slots) > maxAvailabilitySlots {
+		return fmt.Sprintf("Maximal %d Zeitfenster erlaubt", maxAvailabilitySlots)
+	}
+	for _, s := range slots {
+		start, ok1 := parseClock(s.Start)
+		end, ok2 := parseClock(s.End)
+		if s.Weekday < 0 || s.Weekday > 6 || !ok1 || !ok2 || start >= end {
+			return "Ungültiges Zeitfenster"
+		}
+	}
+	sort.Slice(slots, func(i, j int) bool {
+		if slots[i].Weekday != slots[j].Weekday {
+			return slots[i].Weekday < slots[j].Weekday
+		}
+		return slots[i].Start < slots[j].Start
+	})
+	for i := 1; i < len(slots); i++ {
+		if slots[i].Weekday == slots[i-1].Weekday && slots[i].Start < slots[i-1].End {
+			return "Zeitfenster überschneiden sich"
+		}
+	}
+	return ""
+}
+
+// timeRange is a half-open [Start, End) interval.
+type timeRange struct {
+	Start, End time.Time
+}
+
+// slotRanges places weekly slots on the calendar week starting at monday (a
+// date; only year, month and day are used) in loc.
+func slotRanges(slots []AvailabilitySlot, monday time.Time, loc *time.Location) []timeRange {
+	ranges := make([]timeRange, 0, len(slots))
+	for _, s := range slots {
+		start, _ := parseClock(s.Start)
+		end, _ := parseClock(s.End)
+		day := monday.Day() + s.Weekday
+		ranges = append(ranges, timeRange{
+			Start: time.Date(monday.Year(), monday.Month(), day, start/60, start%60, 0, 0, loc),
+			End:   time.Date(monday.Year(), monday.Month(), day, end/60, end%60, 0, 0, loc),
+		})
+	}
+	return ranges
+}
+
+// overlapPlayer is a main-roster player taken into account for overlaps.
+type overlapPlayer struct {
+	TeamMemberID int64  `json:"team_member_id"`
+	Name         string `json:"name"`
+	Timezone     string `json:"timezone"`
+	ranges       []timeRange
+}
+
+// overlapWindow is a stretch in which at least the requested number of
+// players is available. Players are available for the whole window; Partial
+// for part of it.
+type overlapWindow struct {
+	StartsAt     string   `json:"starts_at"`
+	EndsAt       string   `json:"ends_at"`
+	Minutes      int      `json:"minutes"`
+	MinAvailable int      `json:"min_available"`
+	Players      []string `json:"players"`
+	Partial      []string `json:"partial"`
+}
+
+// overlapWindows returns the maximal windows of at least minMinutes in which
+// at least minPlayers players are available, formatted in loc.
+func overlapWindows(players []overlapPlayer, minPlayers, minMinutes int, loc *time.Location) []overlapWindow {
+	var bounds []time.Time
+	for _, p := range players {
+		for _, r := range p.ranges {
+			bounds = append(bounds, r.Start, r.End)
+		}
+	}
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i].Before(bounds[j]) })
+
+	available := func(p overlapPlayer, from, to time.Time) bool {
+		for _, r := range p.ranges {
+			if !r.Start.After(from) && !r.End.Before(to) {
+				return true
+			}
+		}
+		return false
+	}
+
+	windows := []overlapWindow{}
+	var cur *timeRange
+	var always, sometimes map[int]bool
+	minAvail := 0
+	flush := func() {
+		if cur == nil {
+			return
+		}
+		if minutes := int(cur.End.Sub(cur.Start).Minutes()); minutes >= minMinutes {
+			w := overlapWindow{
+				StartsAt:     cur.Start.In(loc).Format(time.RFC3339),
+				EndsAt:       cur.End.In(loc).Format(time.RFC3339),
+				Minutes:      minutes,
+				MinAvailable: minAvail,
+				Players:      []string{},
+				Partial:      []string{},
+			}
+			for i, p := range players {
+				if always[i] {
+					w.Players = append(w.Players, p.Name)
+				} else if sometimes[i] {
+					w.Partial = append(w.Partial, p.Name)
+				}
+			}
+			windows = append(windows, w)
+		}
+		cur = nil
+	}
+
+	for i := 0; i+1 < len(bounds); i++ {
+		from, to := bounds[i], bounds[i+1]
+		if !from.Before(to) {
+			continue
+		}
+		here := map[int]bool{}
+		for j, p := range players {
+			if available(p, from, to) {
+				here[j] = true
+			}
+		}
+		if len(here) < minPlayers {
+			flush()
+			continue
+		}
+		if cur != nil && !cur.End.Equal(from) {
+			flush()
+		}
+		if cur == nil {
+			cur = &timeRange{Start: from, End: to}
+			always, sometimes, minAvail = here, map[int]bool{}, len(here)
+			for j := range here {
+				sometimes[j] = true
+			}
+			continue
+		}
+		cur.End = to
+		minAvail = min(minAvail, len(here))
+		for j := range always {
+			if !here[j] {
+				delete(always, j)
+			}
+		}
+		for j := range here {
+			sometimes[j] = true
+		}
+	}
+	flush()
+	return windows
+}
+
+// handleMyAvailability serves GET /api/schedule/availability.
+func handleMyAvailability(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requireTeamMember(w, r, apx) {
+			return
+		}
+		user := sessionUser(r)
+		slots, err := apx.GetAvailability(r.Context(), user.ID)
+		if err != nil {
+			log.Printf("GetAvailability error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"slots":    slots,
+			"timezone": userLocation(user).String(),
+		})
+	}
+}
+
+// handleSetMyAvailability serves PUT /api/schedule/availability. The slots
+// replace the previous ones and are read in the user's profile timezone.
+func handleSetMyAvailability(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requireTeamMember(w, r, apx) {
+			return
+		}
+		var req struct {
+			Slots []AvailabilitySlot `json:"slots"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		if req.Slots == nil {
+			req.Slots = []AvailabilitySlot{}
+		}
+		if msg := validateAvailability(req.Slots); msg != "" {
+			jsonError(w, http.StatusBadRequest, msg)
+			return
+		}
+		if err := apx.SetAvailability(r.Context(), sessionUser(r).ID, req.Slots); err != nil {
+			log.Printf("SetAvailability error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
+
+// handleScheduleOverlap serves GET /api/schedule/overlap?week=&min_players=
+// &min_minutes=: the windows of an ISO week (default: the current one) in
+// which enough main-roster players are available, in the viewer's timezone.
+// min_players defaults to the whole main roster.
+func handleScheduleOverlap(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requireTeamMember(w, r, apx) {
+			return
+		}
+		q := r.URL.Query()
+		start := weekStart(time.Now())
+		if week := q.Get("week"); week != "" {
+			var err error
+			if start, err = parseISOWeek(week); err != nil {
+				jsonError(w, http.StatusBadRequest, "Ungültige Woche (Format: 2026-W42)")
+				return
+			}
+		}
+		minMinutes := 60
+		if v := q.Get("min_minutes"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 15 || n > 24*60 {
+				jsonError(w, http.StatusBadRequest, "min_minutes muss zwischen 15 und 1440 liegen")
+				return
+			}
+			minMinutes = n
+		}
+
+		members, err := apx.GetTeamMembers(r.Context())
+		if err != nil {
+			log.Printf("Failed to get team members: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		var mainRoster []TeamMember
+		var usernames []string
+		for _, m := range members {
+			if m.IsMainRoster {
+				mainRoster = append(mainRoster, m)
+				usernames = append(usernames, m.Username)
+			}
+		}
+		users, err := usersByUsernames(r.Context(), apx, usernames)
+		if err != nil {
+			log.Printf("GetUsersByUsernames error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		availability, err := apx.GetAllAvailability(r.Context())
+		if err != nil {
+			log.Printf("GetAllAvailability error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+
+		var players []overlapPlayer
+		missing := []string{}
+		for _, m := range mainRoster {
+			u := users[m.Username]
+			if u == nil || len(availability[u.ID]) == 0 {
+				missing = append(missing, m.Name)
+				continue
+			}
+			loc := userLocation(u)
+			players = append(players, overlapPlayer{
+				TeamMemberID: m.ID,
+				Name:         displayName(u),
+				Timezone:     loc.String(),
+				ranges:       slotRanges(availability[u.ID], start, loc),
+			})
+		}
+
+		minPlayers := len(mainRoster)
+		if v := q.Get("min_players"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				jsonError(w, http.StatusBadRequest, "Ungültige min_players")
+				return
+			}
+			minPlayers = n
+		}
+		minPlayers = max(minPlayers, 1)
+
+		viewer := userLocation(sessionUser(r))
+		if players == nil {
+			players = []overlapPlayer{}
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"week":        isoWeek(start),
+			"timezone":    viewer.String(),
+			"min_players": minPlayers,
+			"min_minutes": minMinutes,
+			"players":     players,
+			"missing":     missing,
+			"windows":     overlapWindows(players, minPlayers, minMinutes, viewer),
+		})
+	}
+}
+
+// parseSessionTime accepts RFC 3339 or a datetime-local value, which is read
+// in loc (the planner's timezone).
+func parseSessionTime(s string, loc *time.Location) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC(), true
+	}
+	if t, err := time.ParseInLocation("2006-01-02T15:04", s, loc); err == nil {
+		return t.UTC(), true
+	}
+	return time.Time{}, false
+}
+
+// validateSession normalises s and returns a user-facing error message, or "".
+func validateSession(s *ScheduleSession, loc *time.Location) string {
+	s.Kind = strings.TrimSpace(s.Kind)
+	s.Title = strings.TrimSpace(s.Title)
+	s.Opponent = strings.TrimSpace(s.Opponent)
+	s.Notes = strings.TrimSpace(s.Notes)
+	if s.Kind != SessionScrim && s.Kind != SessionPractice {
+		return "Art muss scrim oder practice sein"
+	}
+	if s.Kind == SessionScrim && s.Opponent == "" {
+		return "Gegner für Scrims erforderlich"
+	}
+	if len([]rune(s.Title)) > 80 || len([]rune(s.Opponent)) > 60 || len([]rune(s.Notes)) > 1000 {
+		return "Titel (max 80), Gegner (max 60) oder Notizen (max 1000 Zeichen) zu lang"
+	}
+	start, ok1 := parseSessionTime(strings.TrimSpace(s.StartsAt), loc)
+	end, ok2 := parseSessionTime(strings.TrimSpace(s.EndsAt), loc)
+	if !ok1 || !ok2 {
+		return "Ungültige Start- oder Endzeit"
+	}
+	if !end.After(start) || end.Sub(start) > maxSessionDuration {
+		return "Die Session muss zwischen 1 Minute und 12 Stunden dauern"
+	}
+	s.StartsAt, s.EndsAt = start.Format(time.RFC3339), end.Format(time.RFC3339)
+	return ""
+}
+
+func loadScheduleSession(w http.ResponseWriter, r *http.Request, apx Store) *ScheduleSession {
+	id, ok := pathID(w, r)
+	if !ok {
+		return nil
+	}
+	s, err := apx.GetScheduleSession(r.Context(), id)
+	if errors.Is(err, errNotFound) {
+		jsonError(w, http.StatusNotFound, "Session nicht gefunden")
+		return nil
+	} else if err != nil {
+		log.Printf("GetScheduleSession error: %v", err)
+		jsonError(w, http.StatusInternalServerError, "internal error")
+		return nil
+	}
+	return s
+}
+
+// handleScheduleSessions serves GET /api/schedule/sessions: sessions that
+// have not ended yet, soonest first, with RSVPs.
+func handleScheduleSessions(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requireTeamMember(w, r, apx) {
+			return
+		}
+		sessions, err := apx.GetScheduleSessions(r.Context(), time.Now())
+		if err != nil {
+			log.Printf("GetScheduleSessions error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"sessions": sessions})
+	}
+}
+
+// handleCreateScheduleSession serves POST /api/schedule/sessions (coaches).
+func handleCreateScheduleSession(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requireCoach(w, r, apx) {
+			return
+		}
+		user := sessionUser(r)
+		var s ScheduleSession
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		if msg := validateSession(&s, userLocation(user)); msg != "" {
+			jsonError(w, http.StatusBadRequest, msg)
+			return
+		}
+		s.CreatedBy = user.ID
+		id, err := apx.CreateScheduleSession(r.Context(), s)
+		if err != nil {
+			log.Printf("CreateScheduleSession error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		s.ID = id
+		recordAudit(apx, r, "schedule.create", "schedule_session", strconv.FormatInt(id, 10), nil, s)
+		jsonResponse(w, http.StatusCreated, map[string]interface{}{"id": id, "success": true})
+	}
+}
+
+// handleUpdateScheduleSession serves PUT /api/schedule/sessions/{id}
+// (coaches). Existing RSVPs are kept.
+func handleUpdateScheduleSession(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requireCoach(w, r, apx) {
+			return
+		}
+		before := loadScheduleSession(w, r, apx)
+		if before == nil {
+			return
+		}
+		var s ScheduleSession
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		if msg := validateSession(&s, userLocation(sessionUser(r))); msg != "" {
+			jsonError(w, http.StatusBadRequest, msg)
+			return
+		}
+		s.ID = before.ID
+		if err := apx.UpdateScheduleSession(r.Context(), s); err != nil {
+			log.Printf("UpdateScheduleSession error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		before.RSVPs = nil
+		recordAudit(apx, r, "schedule.update", "schedule_session", strconv.FormatInt(s.ID, 10), before, s)
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
+
+// handleDeleteScheduleSession serves DELETE /api/schedule/sessions/{id}
+// (coaches).
+func handleDeleteScheduleSession(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requireCoach(w, r, apx) {
+			return
+		}
+		before := loadScheduleSession(w, r, apx)
+		if before == nil {
+			return
+		}
+		if err := apx.DeleteScheduleSession(r.Context(), before.ID); err != nil {
+			log.Printf("DeleteScheduleSession error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		recordAudit(apx, r, "schedule.delete", "schedule_session", strconv.FormatInt(before.ID, 10), before, nil)
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
+
+// handleSessionRSVP serves POST /api/schedule/sessions/{id}/rsvp with
+// {response: yes|no|maybe, comment}. Answers can be changed until the
+// session starts.
+func handleSessionRSVP(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requireTeamMember(w, r, apx) {
+			return
+		}
+		s := loadScheduleSession(w, r, apx)
+		if s == nil {
+			return
+		}
+		var req struct {
+			Response string `json:"response"`
+			Comment  string `json:"comment"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		req.Comment = strings.TrimSpace(req.Comment)
+		if req.Response != RSVPYes && req.Response != RSVPNo && req.Response != RSVPMaybe {
+			jsonError(w, http.StatusBadRequest, "Antwort muss yes, no oder maybe sein")
+			return
+		}
+		if len([]rune(req.Comment)) > 200 {
+			jsonError(w, http.StatusBadRequest, "Kommentar zu lang (max 200 Zeichen)")
+			return
+		}
+		if start, err := time.Parse(time.RFC3339, s.StartsAt); err == nil && !time.Now().Before(start) {
+			jsonError(w, http.StatusConflict, "Die Session hat bereits begonnen")
+			return
+		}
+		rsvp := SessionRSVP{SessionID: s.ID, UserID: sessionUser(r).ID, Response: req.Response, Comment: req.Comment}
+		if err := apx.SetSessionRSVP(r.Context(), rsvp); err != nil {
+			log.Printf("SetSessionRSVP error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
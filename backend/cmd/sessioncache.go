@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	sessionCacheTTL = 30 * time.Second
+	sessionCacheMax = 10000
+)
+
+// sessionCache is a Store decorator that remembers validated sessions for
+// sessionCacheTTL, so authenticated requests don't each cost an upstream
+// round-trip. Store methods that end sessions or change user fields drop
+// the affected entries. Invalidation is per process: with several backend
+// instances, a change made on another one shows up after at most the TTL.
+type sessionCache struct {
+	Store
+
+	mu      sync.Mutex
+	gen     uint64 // bumped on every invalidation
+	entries map[string]sessionCacheEntry
+}
+
+type sessionCacheEntry struct {
+	user    User
+	expires time.Time
+}
+
+func newSessionCache(s Store) *sessionCache {
+	return &sessionCache{Store: s, entries: map[string]sessionCacheEntry{}}
+}
+
+// GetSessionUser returns a copy of the cached user, validating the token
+// upstream on a miss. Failed validations are not cached.
+func (c *sessionCache) GetSessionUser(ctx context.Context, token string) (*User, error) {
+	c.mu.Lock()
+	e, ok := c.entries[token]
+	gen := c.gen
+	c.mu.Unlock()
+	if ok && time.Now().Before(e.expires) {
+		u := e.user
+		u.SocialLinks = slices.Clone(e.user.SocialLinks)
+		return &u, nil
+	}
+
+	u, err := c.Store.GetSessionUser(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// An invalidation while the lookup was in flight may have targeted this
+	// very user; don't resurrect the result.
+	if c.gen != gen {
+		return u, nil
+	}
+	if len(c.entries) >= sessionCacheMax {
+		c.evictLocked()
+	}
+	cached := *u
+	cached.SocialLinks = slices.Clone(u.SocialLinks)
+	c.entries[token] = sessionCacheEntry{user: cached, expires: time.Now().Add(sessionCacheTTL)}
+	return u, nil
+}
+
+// evictLocked drops expired entries, or an arbitrary tenth of the cache if
+// none have expired.
+func (c *sessionCache) evictLocked() {
+	now := time.Now()
+	for token, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, token)
+		}
+	}
+	for token := range c.entries {
+		if len(c.entries) < sessionCacheMax*9/10 {
+			break
+		}
+		delete(c.entries, token)
+	}
+}
+
+func (c *sessionCache) invalidate(match func(token string, u *User) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	for token, e := range c.entries {
+		if match(token, &e.user) {
+			delete(c.entries, token)
+		}
+	}
+}
+
+func (c *sessionCache) forgetUser(userID int64) {
+	c.invalidate(func(_ string, u *User) bool { return u.ID == userID })
+}
+
+func (c *sessionCache) forgetUsername(username string) {
+	c.invalidate(func(_ string, u *User) bool { return u.Username == username })
+}
+
+// Sessions
+
+func (c *sessionCache) DeleteSession(ctx context.Context, token string) error {
+	err := c.Store.DeleteSession(ctx, token)
+	c.invalidate(func(t string, _ *User) bool { return t == token })
+	return err
+}
+
+func (c *sessionCache) DeleteUserSessions(ctx context.Context, userID int64) error {
+	err := c.Store.DeleteUserSessions(ctx, userID)
+	c.forgetUser(userID)
+	return err
+}
+
+// User changes by id
+
+func (c *sessionCache) UpdateUserProfile(ctx context.Context, userID int64, username, nickname, email, avatarURL, bannerURL, bio string) error {
+	err := c.Store.UpdateUserProfile(ctx, userID, username, nickname, email, avatarURL, bannerURL, bio)
+	c.forgetUser(userID)
+	return err
+}
+
+func (c *sessionCache) UpdateProfileSettings(ctx context.Context, userID int64, timezone string, showLocalTime bool, socialLinks []string) error {
+	err := c.Store.UpdateProfileSettings(ctx, userID, timezone, showLocalTime, socialLinks)
+	c.forgetUser(userID)
+	return err
+}
+
+func (c *sessionCache) UpdateMailSettings(ctx context.Context, userID int64, language string, optOut bool) error {
+	err := c.Store.UpdateMailSettings(ctx, userID, language, optOut)
+	c.forgetUser(userID)
+	return err
+}
+
+func (c *sessionCache) DeactivateUser(ctx context.Context, userID int64) error {
+	err := c.Store.DeactivateUser(ctx, userID)
+	c.forgetUser(userID)
+	return err
+}
+
+func (c *sessionCache) SetUserEventAccess(ctx context.Context, userID int64, enabled bool) error {
+	err := c.Store.SetUserEventAccess(ctx, userID, enabled)
+	c.forgetUser(userID)
+	return err
+}
+
+func (c *sessionCache) SetUserRole(ctx context.Context, userID int64, role string) error {
+	err := c.Store.SetUserRole(ctx, userID, role)
+	c.forgetUser(userID)
+	return err
+}
+
+func (c *sessionCache) SetUser2FAEnabled(ctx context.Context, userID int64, enabled bool) error {
+	err := c.Store.SetUser2FAEnabled(ctx, userID, enabled)
+	c.forgetUser(userID)
+	return err
+}
+
+func (c *sessionCache) SetUserTrustDevices(ctx context.Context, userID int64, enabled bool) error {
+	err := c.Store.SetUserTrustDevices(ctx, userID, enabled)
+	c.forgetUser(userID)
+	return err
+}
+
+func (c *sessionCache) UpdateUserEmail(ctx context.Context, userID int64, newEmail string) error {
+	err := c.Store.UpdateUserEmail(ctx, userID, newEmail)
+	c.forgetUser(userID)
+	return err
+}
+
+func (c *sessionCache) UpdateUserPassword(ctx context.Context, userID int64, hashedPw string) error {
+	err := c.Store.UpdateUserPassword(ctx, userID, hashedPw)
+	c.forgetUser(userID)
+	return err
+}
+
+// User changes by username
+
+func (c *sessionCache) DeactivateUserByUsername(ctx context.Context, username string) error {
+	err := c.Store.DeactivateUserByUsername(ctx, username)
+	c.forgetUsername(username)
+	return err
+}
+
+func (c *sessionCache) DeleteUserByUsername(ctx context.Context, username string) error {
+	err := c.Store.DeleteUserByUsername(ctx, username)
+	c.forgetUsername(username)
+	return err
+}
+
+func (c *sessionCache) SetUserEventAccessByUsername(ctx context.Context, username string, enabled bool) error {
+	err := c.Store.SetUserEventAccessByUsername(ctx, username, enabled)
+	c.forgetUsername(username)
+	return err
+}
+
+func (c *sessionCache) ToggleUser2FA(ctx context.Context, username string) (bool, error) {
+	enabled, err := c.Store.ToggleUser2FA(ctx, username)
+	c.forgetUsername(username)
+	return enabled, err
+}
+
+func (c *sessionCache) ActivateByUsername(ctx context.Context, username string) error {
+	err := c.Store.ActivateByUsername(ctx, username)
+	c.forgetUsername(username)
+	return err
+}
@@ -0,0 +1,2386 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteStore implements Store on a local SQLite database whose schema comes
+// from the users/ and data/ migrations (see migrate.go).
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// openSQLiteDB opens (or creates) the database file at path.
+func openSQLiteDB(path string) (*sql.DB, error) {
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer; one connection avoids SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+// NewSQLiteStore opens the database at path. Migrations must already be
+// applied.
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := openSQLiteDB(path)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// ── Helpers ──────────────────────────────────────────────────────────────────
+
+// noRows maps sql.ErrNoRows to errNotFound so callers can treat both stores alike.
+func noRows(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return errNotFound
+	}
+	return err
+}
+
+// expectRow returns errNotFound when an UPDATE/DELETE touched nothing.
+func expectRow(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errNotFound
+	}
+	return nil
+}
+
+func newUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// rowScanner is satisfied by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// ── Auth / Sessions ──────────────────────────────────────────────────────────
+
+const sessionTTL = 7 * 24 * time.Hour
+
+func (s *SQLiteStore) GetSessionUser(ctx context.Context, token string) (*User, error) {
+	var expiresAt time.Time
+	var userID int64
+	err := s.db.QueryRowContext(ctx, `SELECT user_id, expires_at FROM sessions WHERE token = ?`, token).Scan(&userID, &expiresAt)
+	if err != nil {
+		return nil, noRows(err)
+	}
+	if time.Now().After(expiresAt) {
+		_ = s.DeleteSession(ctx, token)
+		return nil, errNotFound
+	}
+	return s.queryUser(ctx, `WHERE id = ? AND is_active = 1`, userID)
+}
+
+func (s *SQLiteStore) CreateSession(ctx context.Context, userID int64) (string, error) {
+	token := randHex(32)
+	_, err := s.db.ExecContext(ctx, `INSERT INTO sessions (token, user_id, expires_at) VALUES (?, ?, ?)`,
+		token, userID, time.Now().Add(sessionTTL).UTC())
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (s *SQLiteStore) DeleteSession(ctx context.Context, token string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE token = ?`, token)
+	return err
+}
+
+func (s *SQLiteStore) DeleteUserSessions(ctx context.Context, userID int64) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = ?`, userID)
+	return err
+}
+
+// ── Email Verifications ──────────────────────────────────────────────────────
+
+func (s *SQLiteStore) CreateEmailVerification(ctx context.Context, email, username, nickname, hashedPw, code string, expiresAt time.Time) error {
+	_, err := s.db.ExecContext(ctx, `INSERT OR REPLACE INTO email_verifications
+		(email, username, nickname, password, code, expires_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		email, username, nickname, hashedPw, code, expiresAt.UTC())
+	return err
+}
+
+func (s *SQLiteStore) GetEmailVerification(ctx context.Context, email string) (*EmailVerification, error) {
+	var v EmailVerification
+	err := s.db.QueryRowContext(ctx, `SELECT email, username, nickname, password, code, expires_at
+		FROM email_verifications WHERE email = ?`, email).
+		Scan(&v.Email, &v.Username, &v.Nickname, &v.Password, &v.Code, &v.ExpiresAt)
+	if err != nil {
+		return nil, noRows(err)
+	}
+	return &v, nil
+}
+
+func (s *SQLiteStore) DeleteEmailVerification(ctx context.Context, email string) {
+	_, _ = s.db.ExecContext(ctx, `DELETE FROM email_verifications WHERE email = ?`, email)
+}
+
+// ── Email Change Requests ────────────────────────────────────────────────────
+
+func (s *SQLiteStore) CreateEmailChangeRequest(ctx context.Context, userID int64, newEmail, code string, expiresAt time.Time) error {
+	_, err := s.db.ExecContext(ctx, `INSERT OR REPLACE INTO email_change_requests
+		(user_id, new_email, code, expires_at) VALUES (?, ?, ?, ?)`,
+		userID, newEmail, code, expiresAt.UTC())
+	return err
+}
+
+func (s *SQLiteStore) GetEmailChangeRequest(ctx context.Context, userID int64) (*EmailChangeRequest, error) {
+	var r EmailChangeRequest
+	err := s.db.QueryRowContext(ctx, `SELECT user_id, new_email, code, expires_at
+		FROM email_change_requests WHERE user_id = ?`, userID).
+		Scan(&r.UserID, &r.NewEmail, &r.Code, &r.ExpiresAt)
+	if err != nil {
+		return nil, noRows(err)
+	}
+	return &r, nil
+}
+
+func (s *SQLiteStore) DeleteEmailChangeRequest(ctx context.Context, userID int64) {
+	_, _ = s.db.ExecContext(ctx, `DELETE FROM email_change_requests WHERE user_id = ?`, userID)
+}
+
+// ── Password Resets ──────────────────────────────────────────────────────────
+
+func (s *SQLiteStore) CreatePasswordReset(ctx context.Context, userID int64, email, code string, expiresAt time.Time) error {
+	_, err := s.db.ExecContext(ctx, `INSERT OR REPLACE INTO password_resets
+		(email, user_id, code, expires_at) VALUES (?, ?, ?, ?)`,
+		email, userID, code, expiresAt.UTC())
+	return err
+}
+
+func (s *SQLiteStore) GetPasswordReset(ctx context.Context, email string) (*PasswordReset, error) {
+	var pr PasswordReset
+	err := s.db.QueryRowContext(ctx, `SELECT user_id, email, code, expires_at FROM password_resets WHERE email = ?`, email).
+		Scan(&pr.UserID, &pr.Email, &pr.Code, &pr.ExpiresAt)
+	if err != nil {
+		return nil, noRows(err)
+	}
+	return &pr, nil
+}
+
+func (s *SQLiteStore) DeletePasswordReset(ctx context.Context, email string) {
+	_, _ = s.db.ExecContext(ctx, `DELETE FROM password_resets WHERE email = ?`, email)
+}
+
+// ── 2FA Pending ──────────────────────────────────────────────────────────────
+
+func (s *SQLiteStore) CreateLogin2FAPending(ctx context.Context, userID int64, code string) (string, error) {
+	token := randHex(16)
+	_, err := s.db.ExecContext(ctx, `INSERT INTO login_2fa_pending (token, user_id, code, expires_at) VALUES (?, ?, ?, ?)`,
+		token, userID, code, time.Now().Add(10*time.Minute).UTC())
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (s *SQLiteStore) GetLogin2FAPending(ctx context.Context, token string) (int64, string, error) {
+	var userID int64
+	var code string
+	var expiresAt time.Time
+	err := s.db.QueryRowContext(ctx, `SELECT user_id, code, expires_at FROM login_2fa_pending WHERE token = ?`, token).
+		Scan(&userID, &code, &expiresAt)
+	if err != nil {
+		return 0, "", noRows(err)
+	}
+	if time.Now().After(expiresAt) {
+		s.DeleteLogin2FAPending(ctx, token)
+		return 0, "", errNotFound
+	}
+	return userID, code, nil
+}
+
+func (s *SQLiteStore) DeleteLogin2FAPending(ctx context.Context, token string) {
+	_, _ = s.db.ExecContext(ctx, `DELETE FROM login_2fa_pending WHERE token = ?`, token)
+}
+
+// ── TOTP / Recovery Codes ────────────────────────────────────────────────────
+
+func (s *SQLiteStore) GetUser2FAConfig(ctx context.Context, userID int64) (*TwoFAConfig, error) {
+	var cfg TwoFAConfig
+	err := s.db.QueryRowContext(ctx, `SELECT two_fa_method, totp_secret, totp_confirmed,
+		(SELECT COUNT(*) FROM recovery_codes WHERE user_id = users.id AND used_at IS NULL)
+		FROM users WHERE id = ?`, userID).
+		Scan(&cfg.Method, &cfg.Secret, &cfg.Confirmed, &cfg.RecoveryCodesLeft)
+	if err != nil {
+		return nil, noRows(err)
+	}
+	if cfg.Method == "" {
+		cfg.Method = "email"
+	}
+	return &cfg, nil
+}
+
+func (s *SQLiteStore) SetUser2FAMethod(ctx context.Context, userID int64, method string) error {
+	return expectRow(s.db.ExecContext(ctx, `UPDATE users SET two_fa_method = ? WHERE id = ?`, method, userID))
+}
+
+func (s *SQLiteStore) SetUserTOTPSecret(ctx context.Context, userID int64, secret string) error {
+	return expectRow(s.db.ExecContext(ctx, `UPDATE users SET totp_secret = ?, totp_confirmed = 0 WHERE id = ?`, secret, userID))
+}
+
+func (s *SQLiteStore) ConfirmUserTOTP(ctx context.Context, userID int64) error {
+	return expectRow(s.db.ExecContext(ctx, `UPDATE users SET totp_confirmed = 1 WHERE id = ?`, userID))
+}
+
+func (s *SQLiteStore) DeleteUserTOTP(ctx context.Context, userID int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET totp_secret = '', totp_confirmed = 0, two_fa_method = 'email' WHERE id = ?`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) SetRecoveryCodes(ctx context.Context, userID int64, hashes []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	for _, h := range hashes {
+		if _, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO recovery_codes (user_id, code_hash) VALUES (?, ?)`, userID, h); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) ConsumeRecoveryCode(ctx context.Context, userID int64, hash string) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `UPDATE recovery_codes SET used_at = ?
+		WHERE user_id = ? AND code_hash = ? AND used_at IS NULL`, time.Now().UTC(), userID, hash)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// UseTOTPStep records step as the last accepted TOTP step. It returns false
+// if a code of this or a later step was already accepted.
+func (s *SQLiteStore) UseTOTPStep(ctx context.Context, userID int64, step int64) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `UPDATE users SET totp_last_step = ? WHERE id = ? AND totp_last_step < ?`, step, userID, step)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// ── Trusted Devices ──────────────────────────────────────────────────────────
+
+func (s *SQLiteStore) CreateTrustedDevice(ctx context.Context, userID int64, deviceName, ip, location string) (string, error) {
+	token := randHex(32)
+	_, err := s.db.ExecContext(ctx, `INSERT INTO trusted_devices (token, user_id, device_name, ip, location, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		token, userID, deviceName, ip, location, time.Now().Add(30*24*time.Hour).UTC())
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (s *SQLiteStore) GetTrustedDeviceUserID(ctx context.Context, token string) (int64, error) {
+	var userID int64
+	var expiresAt time.Time
+	err := s.db.QueryRowContext(ctx, `SELECT user_id, expires_at FROM trusted_devices WHERE token = ?`, token).
+		Scan(&userID, &expiresAt)
+	if err != nil {
+		return 0, noRows(err)
+	}
+	if time.Now().After(expiresAt) {
+		return 0, errNotFound
+	}
+	return userID, nil
+}
+
+func (s *SQLiteStore) GetTrustedDevices(ctx context.Context, userID int64) ([]TrustedDevice, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT token, device_name, location, created_at FROM trusted_devices
+		WHERE user_id = ? AND expires_at > ? ORDER BY created_at DESC`, userID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	devices := []TrustedDevice{}
+	for rows.Next() {
+		var d TrustedDevice
+		if err := rows.Scan(&d.Token, &d.DeviceName, &d.Location, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		devices = append(devices, d)
+	}
+	return devices, rows.Err()
+}
+
+func (s *SQLiteStore) DeleteTrustedDevice(ctx context.Context, token string, userID int64) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM trusted_devices WHERE token = ? AND user_id = ?`, token, userID)
+	return err
+}
+
+func (s *SQLiteStore) DeleteAllTrustedDevices(ctx context.Context, userID int64) {
+	_, _ = s.db.ExecContext(ctx, `DELETE FROM trusted_devices WHERE user_id = ?`, userID)
+}
+
+// ── OAuth States ─────────────────────────────────────────────────────────────
+
+func (s *SQLiteStore) CreateOAuthState(ctx context.Context, state string, userID int64, codeVerifier string, expiresAt time.Time) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO oauth_states (state, user_id, code_verifier, expires_at) VALUES (?, ?, ?, ?)`,
+		state, userID, codeVerifier, expiresAt.UTC())
+	return err
+}
+
+func (s *SQLiteStore) GetAndDeleteOAuthState(ctx context.Context, state string) (*OAuthState, error) {
+	var st OAuthState
+	var expiresAt time.Time
+	err := s.db.QueryRowContext(ctx, `DELETE FROM oauth_states WHERE state = ? RETURNING user_id, code_verifier, expires_at`, state).
+		Scan(&st.UserID, &st.CodeVerifier, &expiresAt)
+	if err != nil {
+		return nil, noRows(err)
+	}
+	if time.Now().After(expiresAt) {
+		return nil, errNotFound
+	}
+	return &st, nil
+}
+
+// ── Users ────────────────────────────────────────────────────────────────────
+
+const userColumns = `id, username, nickname, email, password, is_admin, role, is_active,
+	two_fa_enabled, trust_devices, event_access, created_at, avatar_url, banner_url,
+	timezone, show_local_time, social_links, bio, mail_language, mail_opt_out`
+
+func scanUser(row rowScanner) (*User, error) {
+	var u User
+	var links string
+	if err := row.Scan(&u.ID, &u.Username, &u.Nickname, &u.Email, &u.Password, &u.IsAdmin, &u.Role, &u.IsActive,
+		&u.TwoFAEnabled, &u.TrustDevices, &u.EventAccess, &u.CreatedAt, &u.AvatarURL, &u.BannerURL,
+		&u.Timezone, &u.ShowLocalTime, &links, &u.Bio, &u.MailLanguage, &u.MailOptOut); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(links), &u.SocialLinks); err != nil || u.SocialLinks == nil {
+		u.SocialLinks = []string{}
+	}
+	return &u, nil
+}
+
+// queryUser returns the single user matching where (e.g. "WHERE id = ?").
+func (s *SQLiteStore) queryUser(ctx context.Context, where string, args ...any) (*User, error) {
+	u, err := scanUser(s.db.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users `+where, args...))
+	if err != nil {
+		return nil, noRows(err)
+	}
+	return u, nil
+}
+
+func (s *SQLiteStore) CreateUser(ctx context.Context, username, nickname, email, hashedPw string) (int64, error) {
+	// mail_language is set explicitly: the column default is still 'de' from
+	// migration 032.
+	res, err := s.db.ExecContext(ctx, `INSERT INTO users (username, nickname, email, password, mail_language) VALUES (?, ?, ?, ?, '')`,
+		username, nickname, email, hashedPw)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (s *SQLiteStore) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	return s.queryUser(ctx, `WHERE email = ? COLLATE NOCASE AND is_active = 1`, email)
+}
+
+func (s *SQLiteStore) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	return s.queryUser(ctx, `WHERE username = ? AND is_active = 1`, username)
+}
+
+func (s *SQLiteStore) GetUserByUsernameAny(ctx context.Context, username string) (*User, error) {
+	return s.queryUser(ctx, `WHERE username = ?`, username)
+}
+
+func (s *SQLiteStore) GetUsersByUsernames(ctx context.Context, usernames []string) (map[string]*User, error) {
+	result := map[string]*User{}
+	if len(usernames) == 0 {
+		return result, nil
+	}
+	args := make([]any, len(usernames))
+	for i, name := range usernames {
+		args[i] = name
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT `+userColumns+` FROM users
+		WHERE is_active = 1 AND username IN (?`+strings.Repeat(", ?", len(usernames)-1)+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		result[u.Username] = u
+	}
+	return result, rows.Err()
+}
+
+func (s *SQLiteStore) GetLinkedAccounts(ctx context.Context, userID int64) ([]LinkedAccount, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT service, service_id, username, avatar_url, profile_url
+		FROM linked_accounts WHERE user_id = ? ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	accounts := []LinkedAccount{}
+	for rows.Next() {
+		var a LinkedAccount
+		if err := rows.Scan(&a.Service, &a.ServiceID, &a.Username, &a.AvatarURL, &a.ProfileURL); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, a)
+	}
+	return accounts, rows.Err()
+}
+
+func (s *SQLiteStore) UpsertLinkedAccount(ctx context.Context, userID int64, service, serviceID, username, avatarURL, profileURL string) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO linked_accounts (user_id, service, service_id, username, avatar_url, profile_url)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, service) DO UPDATE SET
+			service_id = excluded.service_id, username = excluded.username,
+			avatar_url = excluded.avatar_url, profile_url = excluded.profile_url`,
+		userID, service, serviceID, username, avatarURL, profileURL)
+	return err
+}
+
+func (s *SQLiteStore) DeleteLinkedAccount(ctx context.Context, userID int64, service string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM linked_accounts WHERE user_id = ? AND service = ?`, userID, service)
+	return err
+}
+
+// linkedAccountFields are the columns UpdateLinkedAccountField may write.
+var linkedAccountFields = map[string]bool{
+	"username": true, "avatar_url": true, "profile_url": true, "discord_data": true, "cm_data": true,
+}
+
+func (s *SQLiteStore) UpdateLinkedAccountField(ctx context.Context, userID int64, service, field, value string) error {
+	if !linkedAccountFields[field] {
+		return fmt.Errorf("invalid linked account field %q", field)
+	}
+	return expectRow(s.db.ExecContext(ctx, `UPDATE linked_accounts SET `+field+` = ? WHERE user_id = ? AND service = ?`,
+		value, userID, service))
+}
+
+func (s *SQLiteStore) UpdateUserProfile(ctx context.Context, userID int64, username, nickname, email, avatarURL, bannerURL, bio string) error {
+	sets := []string{"avatar_url = ?", "banner_url = ?", "bio = ?"}
+	args := []any{avatarURL, bannerURL, bio}
+	if username != "" {
+		sets, args = append(sets, "username = ?"), append(args, username)
+	}
+	if nickname != "" {
+		sets, args = append(sets, "nickname = ?"), append(args, nickname)
+	}
+	if email != "" {
+		sets, args = append(sets, "email = ?"), append(args, email)
+	}
+	args = append(args, userID)
+	return expectRow(s.db.ExecContext(ctx, `UPDATE users SET `+strings.Join(sets, ", ")+` WHERE id = ?`, args...))
+}
+
+func (s *SQLiteStore) UpdateProfileSettings(ctx context.Context, userID int64, timezone string, showLocalTime bool, socialLinks []string) error {
+	if socialLinks == nil {
+		socialLinks = []string{}
+	}
+	linksJSON, _ := json.Marshal(socialLinks)
+	return expectRow(s.db.ExecContext(ctx, `UPDATE users SET timezone = ?, show_local_time = ?, social_links = ? WHERE id = ?`,
+		timezone, showLocalTime, string(linksJSON), userID))
+}
+
+func (s *SQLiteStore) UpdateMailSettings(ctx context.Context, userID int64, language string, optOut bool) error {
+	return expectRow(s.db.ExecContext(ctx, `UPDATE users SET mail_language = ?, mail_opt_out = ? WHERE id = ?`,
+		language, optOut, userID))
+}
+
+func (s *SQLiteStore) GetAllUsernames(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT username FROM users ORDER BY username`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	names := []string{}
+	for rows.Next() {
+		var n string
+		if err := rows.Scan(&n); err != nil {
+			return nil, err
+		}
+		names = append(names, n)
+	}
+	return names, rows.Err()
+}
+
+func (s *SQLiteStore) SearchUsers(ctx context.Context, query string) ([]User, error) {
+	like := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(query) + "%"
+	rows, err := s.db.QueryContext(ctx, `SELECT `+userColumns+` FROM users
+		WHERE is_active = 1 AND (username LIKE ? ESCAPE '\' OR nickname LIKE ? ESCAPE '\')
+		ORDER BY username LIMIT 20`, like, like)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	users := []User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *u)
+	}
+	return users, rows.Err()
+}
+
+func (s *SQLiteStore) CheckUserConflict(ctx context.Context, username, email string) (bool, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM users WHERE username = ? OR email = ? COLLATE NOCASE`, username, email).Scan(&n)
+	return n > 0, err
+}
+
+// EnsureAdminUser creates the "admin" superadmin, or resets its password to
+// hashedPw so ADMIN_PASSWORD stays authoritative.
+func (s *SQLiteStore) EnsureAdminUser(ctx context.Context, hashedPw string) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO users (username, nickname, email, password, is_admin, role, two_fa_enabled, mail_language)
+		VALUES ('admin', 'Admin', 'admin@localhost', ?, 1, 'superadmin', 0, '')
+		ON CONFLICT (username) DO UPDATE SET password = excluded.password, is_admin = 1`, hashedPw)
+	return err
+}
+
+func (s *SQLiteStore) DeactivateUser(ctx context.Context, userID int64) error {
+	if err := expectRow(s.db.ExecContext(ctx, `UPDATE users SET is_active = 0 WHERE id = ?`, userID)); err != nil {
+		return err
+	}
+	return s.DeleteUserSessions(ctx, userID)
+}
+
+func (s *SQLiteStore) DeactivateUserByUsername(ctx context.Context, username string) error {
+	u, err := s.GetUserByUsernameAny(ctx, username)
+	if err != nil {
+		return err
+	}
+	return s.DeactivateUser(ctx, u.ID)
+}
+
+func (s *SQLiteStore) DeleteUserByUsername(ctx context.Context, username string) error {
+	if err := expectRow(s.db.ExecContext(ctx, `DELETE FROM users WHERE username = ?`, username)); err != nil {
+		if err == errNotFound {
+			return fmt.Errorf("user not found")
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *SQLiteStore) SetUserEventAccess(ctx context.Context, userID int64, enabled bool) error {
+	return expectRow(s.db.ExecContext(ctx, `UPDATE users SET event_access = ? WHERE id = ?`, enabled, userID))
+}
+
+func (s *SQLiteStore) SetUserEventAccessByUsername(ctx context.Context, username string, enabled bool) error {
+	u, err := s.GetUserByUsernameAny(ctx, username)
+	if err != nil {
+		return fmt.Errorf("user not found: %w", err)
+	}
+	return s.SetUserEventAccess(ctx, u.ID, enabled)
+}
+
+func (s *SQLiteStore) SetUserRole(ctx context.Context, userID int64, role string) error {
+	return expectRow(s.db.ExecContext(ctx, `UPDATE users SET role = ? WHERE id = ?`, role, userID))
+}
+
+func (s *SQLiteStore) SetUser2FAEnabled(ctx context.Context, userID int64, enabled bool) error {
+	return expectRow(s.db.ExecContext(ctx, `UPDATE users SET two_fa_enabled = ? WHERE id = ?`, enabled, userID))
+}
+
+func (s *SQLiteStore) SetUserTrustDevices(ctx context.Context, userID int64, enabled bool) error {
+	return expectRow(s.db.ExecContext(ctx, `UPDATE users SET trust_devices = ? WHERE id = ?`, enabled, userID))
+}
+
+func (s *SQLiteStore) ToggleUser2FA(ctx context.Context, username string) (bool, error) {
+	var enabled bool
+	err := s.db.QueryRowContext(ctx, `UPDATE users SET two_fa_enabled = NOT two_fa_enabled WHERE username = ?
+		RETURNING two_fa_enabled`, username).Scan(&enabled)
+	return enabled, noRows(err)
+}
+
+func (s *SQLiteStore) GetUserTwoFASettings(ctx context.Context, userID int64) (twoFAEnabled, trustDevices bool) {
+	_ = s.db.QueryRowContext(ctx, `SELECT two_fa_enabled, trust_devices FROM users WHERE id = ?`, userID).
+		Scan(&twoFAEnabled, &trustDevices)
+	return twoFAEnabled, trustDevices
+}
+
+func (s *SQLiteStore) UpdateUserEmail(ctx context.Context, userID int64, newEmail string) error {
+	return expectRow(s.db.ExecContext(ctx, `UPDATE users SET email = ? WHERE id = ?`, newEmail, userID))
+}
+
+func (s *SQLiteStore) UpdateUserPassword(ctx context.Context, userID int64, hashedPw string) error {
+	return expectRow(s.db.ExecContext(ctx, `UPDATE users SET password = ? WHERE id = ?`, hashedPw, userID))
+}
+
+func (s *SQLiteStore) ActivateByUsername(ctx context.Context, username string) error {
+	return expectRow(s.db.ExecContext(ctx, `UPDATE users SET is_active = 1 WHERE username = ?`, username))
+}
+
+func (s *SQLiteStore) GetUserAvatarByUsername(ctx context.Context, username string) string {
+	u, err := s.GetUserByUsername(ctx, username)
+	if err != nil {
+		return ""
+	}
+	return u.AvatarURL
+}
+
+func (s *SQLiteStore) GetUserNicknameByUsername(ctx context.Context, username string) string {
+	u, err := s.GetUserByUsername(ctx, username)
+	if err != nil {
+		return ""
+	}
+	if u.Nickname != "" {
+		return u.Nickname
+	}
+	return u.Username
+}
+
+func (s *SQLiteStore) GetUserIDByUsername(ctx context.Context, username string) (int64, error) {
+	u, err := s.GetUserByUsername(ctx, username)
+	if err != nil {
+		return 0, err
+	}
+	return u.ID, nil
+}
+
+// CountUsersCreatedBetween counts the accounts registered in [from, to).
+func (s *SQLiteStore) CountUsersCreatedBetween(ctx context.Context, from, to time.Time) (int, error) {
+	const layout = "2006-01-02 15:04:05"
+	var n int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM users WHERE created_at >= ? AND created_at < ?`,
+		from.UTC().Format(layout), to.UTC().Format(layout)).Scan(&n)
+	return n, err
+}
+
+func (s *SQLiteStore) UpdateBotUserApxID(ctx context.Context, discordID string, apxID int64) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE bot_users SET apx_id = ? WHERE user_id = ?`, fmt.Sprint(apxID), discordID)
+	return err
+}
+
+// ── Team ─────────────────────────────────────────────────────────────────────
+
+const teamColumns = `t.id, t.name, t.username, t.atk_role, t.def_role, t.is_main_roster, t.paired_with,
+	COALESCE(u.event_access, 0), t.rounds, t.kost_points,
+	t.kill_entry, t.kill_trade, t.kill_impact, t.kill_late,
+	t.death_entry, t.death_trade, t.death_late,
+	t.clutch_1v1, t.clutch_1v2, t.clutch_1v3, t.clutch_1v4, t.clutch_1v5,
+	t.obj_plant, t.obj_defuse`
+
+func (s *SQLiteStore) GetTeamMembers(ctx context.Context) ([]TeamMember, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+teamColumns+` FROM team t
+		LEFT JOIN users u ON u.username = t.username AND t.username != ''
+		ORDER BY t.is_main_roster DESC, t.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	members := []TeamMember{}
+	for rows.Next() {
+		var m TeamMember
+		if err := rows.Scan(&m.ID, &m.Name, &m.Username, &m.AtkRole, &m.DefRole, &m.IsMainRoster, &m.PairedWith,
+			&m.EventAccess, &m.Rounds, &m.KostPoints,
+			&m.KillEntry, &m.KillTrade, &m.KillImpact, &m.KillLate,
+			&m.DeathEntry, &m.DeathTrade, &m.DeathLate,
+			&m.Clutch1v1, &m.Clutch1v2, &m.Clutch1v3, &m.Clutch1v4, &m.Clutch1v5,
+			&m.ObjPlant, &m.ObjDefuse); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+func (s *SQLiteStore) AddTeamMember(ctx context.Context, name, username, atkRole, defRole string, isMainRoster bool) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `INSERT INTO team (name, username, atk_role, def_role, is_main_roster) VALUES (?, ?, ?, ?, ?)`,
+		name, username, atkRole, defRole, isMainRoster)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (s *SQLiteStore) UpdateTeamMember(ctx context.Context, m TeamMember) error {
+	return expectRow(s.db.ExecContext(ctx, `UPDATE team SET
+		name = ?, username = ?, atk_role = ?, def_role = ?, is_main_roster = ?, paired_with = ?,
+		rounds = ?, kost_points = ?,
+		kill_entry = ?, kill_trade = ?, kill_impact = ?, kill_late = ?,
+		death_entry = ?, death_trade = ?, death_late = ?,
+		clutch_1v1 = ?, clutch_1v2 = ?, clutch_1v3 = ?, clutch_1v4 = ?, clutch_1v5 = ?,
+		obj_plant = ?, obj_defuse = ?
+		WHERE id = ?`,
+		m.Name, m.Username, m.AtkRole, m.DefRole, m.IsMainRoster, m.PairedWith,
+		m.Rounds, m.KostPoints,
+		m.KillEntry, m.KillTrade, m.KillImpact, m.KillLate,
+		m.DeathEntry, m.DeathTrade, m.DeathLate,
+		m.Clutch1v1, m.Clutch1v2, m.Clutch1v3, m.Clutch1v4, m.Clutch1v5,
+		m.ObjPlant, m.ObjDefuse,
+		m.ID))
+}
+
+// SetTeamStats replaces the stored counters of several members. Either all
+// rows are updated or none.
+func (s *SQLiteStore) SetTeamStats(ctx context.Context, stats map[int64]PlayerStats) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for id, p := range stats {
+		res, err := tx.ExecContext(ctx, `UPDATE team SET
+			kill_entry = ?, kill_trade = ?, kill_impact = ?, kill_late = ?,
+			death_entry = ?, death_trade = ?, death_late = ?,
+			clutch_1v1 = ?, clutch_1v2 = ?, clutch_1v3 = ?, clutch_1v4 = ?, clutch_1v5 = ?,
+			obj_plant = ?, obj_defuse = ?
+			WHERE id = ?`,
+			p.KillEntry, p.KillTrade, p.KillImpact, p.KillLate,
+			p.DeathEntry, p.DeathTrade, p.DeathLate,
+			p.Clutch1v1, p.Clutch1v2, p.Clutch1v3, p.Clutch1v4, p.Clutch1v5,
+			p.ObjPlant, p.ObjDefuse,
+			id)
+		if err := expectRow(res, err); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) DeleteTeamMember(ctx context.Context, id int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `UPDATE team SET paired_with = NULL WHERE paired_with = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM team WHERE id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) CountMainRoster(ctx context.Context, excludeID int64) (int, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM team WHERE is_main_roster = 1 AND id != ?`, excludeID).Scan(&n)
+	return n, err
+}
+
+// defaultMainRoster is seeded by EnsureTeamPlayers on an empty install.
+var defaultMainRoster = []string{"LIXH", "AQUA", "KLE", "DEVIN", "SLASH"}
+
+func (s *SQLiteStore) EnsureTeamPlayers(ctx context.Context) error {
+	for _, name := range defaultMainRoster {
+		if _, err := s.db.ExecContext(ctx, `INSERT INTO team (name, is_main_roster)
+			SELECT ?, 1 WHERE NOT EXISTS (SELECT 1 FROM team WHERE name = ?)`, name, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteStore) AddRosterEvents(ctx context.Context, events []RosterEvent) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, e := range events {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO roster_events
+			(team_member_id, username, name, kind, from_value, to_value, reason) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			e.TeamMemberID, e.Username, e.Name, e.Kind, e.From, e.To, e.Reason); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetRosterEvents returns the whole roster history, oldest first.
+const rosterEventColumns = `id, team_member_id, username, name, kind, from_value, to_value, reason,
+	strftime('%Y-%m-%dT%H:%M:%SZ', occurred_at)`
+
+func (s *SQLiteStore) GetRosterEvents(ctx context.Context) ([]RosterEvent, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+rosterEventColumns+` FROM roster_events ORDER BY occurred_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	return scanRosterEvents(rows)
+}
+
+func (s *SQLiteStore) GetUserRosterEvents(ctx context.Context, username string) ([]RosterEvent, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+rosterEventColumns+` FROM roster_events
+		WHERE team_member_id IN (SELECT team_member_id FROM roster_events WHERE username = ? COLLATE NOCASE)
+		ORDER BY occurred_at, id`, username)
+	if err != nil {
+		return nil, err
+	}
+	return scanRosterEvents(rows)
+}
+
+func scanRosterEvents(rows *sql.Rows) ([]RosterEvent, error) {
+	defer rows.Close()
+	events := []RosterEvent{}
+	for rows.Next() {
+		var e RosterEvent
+		if err := rows.Scan(&e.ID, &e.TeamMemberID, &e.Username, &e.Name, &e.Kind, &e.From, &e.To, &e.Reason, &e.OccurredAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// ── Matches ──────────────────────────────────────────────────────────────────
+
+const statColumns = `kill_entry, kill_trade, kill_impact, kill_late,
+	death_entry, death_trade, death_late,
+	clutch_1v1, clutch_1v2, clutch_1v3, clutch_1v4, clutch_1v5,
+	obj_plant, obj_defuse`
+
+// statArgs returns pointers to the counters in statColumns order, for Scan.
+func statArgs(p *PlayerStats) []any {
+	counters := p.counters()
+	args := make([]any, len(counters))
+	for i, c := range counters {
+		args[i] = c
+	}
+	return args
+}
+
+// statValues returns the counters in statColumns order, for Exec.
+func statValues(p PlayerStats) []any {
+	counters := p.counters()
+	values := make([]any, len(counters))
+	for i, c := range counters {
+		values[i] = *c
+	}
+	return values
+}
+
+const matchColumns = `id, opponent, played_at, map, competition, score_us, score_them, created_at`
+
+func (s *SQLiteStore) GetMatches(ctx context.Context) ([]Match, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+matchColumns+` FROM matches ORDER BY played_at DESC, id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	matches := []Match{}
+	byID := map[int64]int{}
+	for rows.Next() {
+		var m Match
+		if err := rows.Scan(&m.ID, &m.Opponent, &m.PlayedAt, &m.Map, &m.Competition, &m.ScoreUs, &m.ScoreThem, &m.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		m.Players = []MatchPlayerStats{}
+		byID[m.ID] = len(matches)
+		matches = append(matches, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.QueryContext(ctx, `SELECT match_id, team_member_id, name, `+statColumns+`
+		FROM match_players ORDER BY match_id, team_member_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var matchID int64
+		var p MatchPlayerStats
+		if err := rows.Scan(append([]any{&matchID, &p.TeamMemberID, &p.Name}, statArgs(&p.PlayerStats)...)...); err != nil {
+			return nil, err
+		}
+		if i, ok := byID[matchID]; ok {
+			matches[i].Players = append(matches[i].Players, p)
+		}
+	}
+	return matches, rows.Err()
+}
+
+func (s *SQLiteStore) GetMatch(ctx context.Context, id int64) (*Match, error) {
+	var m Match
+	if err := s.db.QueryRowContext(ctx, `SELECT `+matchColumns+` FROM matches WHERE id = ?`, id).
+		Scan(&m.ID, &m.Opponent, &m.PlayedAt, &m.Map, &m.Competition, &m.ScoreUs, &m.ScoreThem, &m.CreatedAt); err != nil {
+		return nil, noRows(err)
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT team_member_id, name, `+statColumns+`
+		FROM match_players WHERE match_id = ? ORDER BY team_member_id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	m.Players = []MatchPlayerStats{}
+	for rows.Next() {
+		var p MatchPlayerStats
+		if err := rows.Scan(append([]any{&p.TeamMemberID, &p.Name}, statArgs(&p.PlayerStats)...)...); err != nil {
+			return nil, err
+		}
+		m.Players = append(m.Players, p)
+	}
+	return &m, rows.Err()
+}
+
+func insertMatchPlayers(ctx context.Context, tx *sql.Tx, matchID int64, players []MatchPlayerStats) error {
+	for _, p := range players {
+		args := append([]any{matchID, p.TeamMemberID, p.Name}, statValues(p.PlayerStats)...)
+		if _, err := tx.ExecContext(ctx, `INSERT INTO match_players (match_id, team_member_id, name, `+statColumns+`)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteStore) CreateMatch(ctx context.Context, m Match) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	res, err := tx.ExecContext(ctx, `INSERT INTO matches (opponent, played_at, map, competition, score_us, score_them)
+		VALUES (?, ?, ?, ?, ?, ?)`, m.Opponent, m.PlayedAt, m.Map, m.Competition, m.ScoreUs, m.ScoreThem)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if err := insertMatchPlayers(ctx, tx, id, m.Players); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+func (s *SQLiteStore) UpdateMatch(ctx context.Context, m Match) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := expectRow(tx.ExecContext(ctx, `UPDATE matches SET opponent = ?, played_at = ?, map = ?, competition = ?,
+		score_us = ?, score_them = ? WHERE id = ?`,
+		m.Opponent, m.PlayedAt, m.Map, m.Competition, m.ScoreUs, m.ScoreThem, m.ID)); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM match_players WHERE match_id = ?`, m.ID); err != nil {
+		return err
+	}
+	if err := insertMatchPlayers(ctx, tx, m.ID, m.Players); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) DeleteMatch(ctx context.Context, id int64) error {
+	return expectRow(s.db.ExecContext(ctx, `DELETE FROM matches WHERE id = ?`, id))
+}
+
+func (s *SQLiteStore) GetMatchStatTotals(ctx context.Context) (map[int64]PlayerStats, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT team_member_id,
+		SUM(kill_entry), SUM(kill_trade), SUM(kill_impact), SUM(kill_late),
+		SUM(death_entry), SUM(death_trade), SUM(death_late),
+		SUM(clutch_1v1), SUM(clutch_1v2), SUM(clutch_1v3), SUM(clutch_1v4), SUM(clutch_1v5),
+		SUM(obj_plant), SUM(obj_defuse)
+		FROM match_players GROUP BY team_member_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	totals := map[int64]PlayerStats{}
+	for rows.Next() {
+		var id int64
+		var p PlayerStats
+		if err := rows.Scan(append([]any{&id}, statArgs(&p)...)...); err != nil {
+			return nil, err
+		}
+		totals[id] = p
+	}
+	return totals, rows.Err()
+}
+
+func (s *SQLiteStore) GetPlayerMatchLines(ctx context.Context, teamMemberID int64) ([]PlayerMatchLine, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT m.id, m.opponent, m.played_at, m.map, m.competition, m.score_us, m.score_them,
+		p.kill_entry, p.kill_trade, p.kill_impact, p.kill_late,
+		p.death_entry, p.death_trade, p.death_late,
+		p.clutch_1v1, p.clutch_1v2, p.clutch_1v3, p.clutch_1v4, p.clutch_1v5,
+		p.obj_plant, p.obj_defuse
+		FROM match_players p JOIN matches m ON m.id = p.match_id
+		WHERE p.team_member_id = ? ORDER BY m.played_at, m.id`, teamMemberID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	lines := []PlayerMatchLine{}
+	for rows.Next() {
+		var l PlayerMatchLine
+		if err := rows.Scan(append([]any{&l.MatchID, &l.Opponent, &l.PlayedAt, &l.Map, &l.Competition, &l.ScoreUs, &l.ScoreThem},
+			statArgs(&l.PlayerStats)...)...); err != nil {
+			return nil, err
+		}
+		lines = append(lines, l)
+	}
+	return lines, rows.Err()
+}
+
+// ── Staff ─────────────────────────────────────────────────────────────────────
+
+func (s *SQLiteStore) GetStaffMembers(ctx context.Context) ([]StaffMember, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, name, role, username, department_id, reports_to FROM staff ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	staff := []StaffMember{}
+	for rows.Next() {
+		var m StaffMember
+		if err := rows.Scan(&m.ID, &m.Name, &m.Role, &m.Username, &m.DepartmentID, &m.ReportsTo); err != nil {
+			return nil, err
+		}
+		staff = append(staff, m)
+	}
+	return staff, rows.Err()
+}
+
+func (s *SQLiteStore) AddStaffMember(ctx context.Context, name, role, username string) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `INSERT INTO staff (name, role, username) VALUES (?, ?, ?)`, name, role, username)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (s *SQLiteStore) UpdateStaffMember(ctx context.Context, id int64, name, role, username string) error {
+	return expectRow(s.db.ExecContext(ctx, `UPDATE staff SET name = ?, role = ?, username = ? WHERE id = ?`, name, role, username, id))
+}
+
+func (s *SQLiteStore) DeleteStaffMember(ctx context.Context, id int64) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM staff WHERE id = ?`, id)
+	return err
+}
+
+func (s *SQLiteStore) SetStaffReporting(ctx context.Context, id int64, departmentID, reportsTo *int64) error {
+	return expectRow(s.db.ExecContext(ctx, `UPDATE staff SET department_id = ?, reports_to = ? WHERE id = ?`, departmentID, reportsTo, id))
+}
+
+// ── Organization chart ───────────────────────────────────────────────────────
+
+func (s *SQLiteStore) GetOrgDepartments(ctx context.Context) ([]OrgDepartment, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, name, parent_id, position FROM org_departments ORDER BY position, name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	departments := []OrgDepartment{}
+	for rows.Next() {
+		var d OrgDepartment
+		if err := rows.Scan(&d.ID, &d.Name, &d.ParentID, &d.Position); err != nil {
+			return nil, err
+		}
+		departments = append(departments, d)
+	}
+	return departments, rows.Err()
+}
+
+func (s *SQLiteStore) CreateOrgDepartment(ctx context.Context, d OrgDepartment) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `INSERT INTO org_departments (name, parent_id, position) VALUES (?, ?, ?)`, d.Name, d.ParentID, d.Position)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (s *SQLiteStore) UpdateOrgDepartment(ctx context.Context, d OrgDepartment) error {
+	return expectRow(s.db.ExecContext(ctx, `UPDATE org_departments SET name = ?, parent_id = ?, position = ? WHERE id = ?`,
+		d.Name, d.ParentID, d.Position, d.ID))
+}
+
+// DeleteOrgDepartment removes a department. Sub-departments, roles and staff
+// placed in it are detached by the foreign keys.
+func (s *SQLiteStore) DeleteOrgDepartment(ctx context.Context, id int64) error {
+	return expectRow(s.db.ExecContext(ctx, `DELETE FROM org_departments WHERE id = ?`, id))
+}
+
+func (s *SQLiteStore) GetStaffRoles(ctx context.Context) ([]StaffRole, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, name, department_id, is_coach, position FROM staff_roles ORDER BY position, name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	roles := []StaffRole{}
+	for rows.Next() {
+		var r StaffRole
+		if err := rows.Scan(&r.ID, &r.Name, &r.DepartmentID, &r.IsCoach, &r.Position); err != nil {
+			return nil, err
+		}
+		roles = append(roles, r)
+	}
+	return roles, rows.Err()
+}
+
+func (s *SQLiteStore) CreateStaffRole(ctx context.Context, role StaffRole) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `INSERT INTO staff_roles (name, department_id, is_coach, position) VALUES (?, ?, ?, ?)`,
+		role.Name, role.DepartmentID, role.IsCoach, role.Position)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// UpdateStaffRole saves role; a rename is carried over to the staff members
+// holding it.
+func (s *SQLiteStore) UpdateStaffRole(ctx context.Context, role StaffRole) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var oldName string
+	if err := tx.QueryRowContext(ctx, `SELECT name FROM staff_roles WHERE id = ?`, role.ID).Scan(&oldName); err != nil {
+		return noRows(err)
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE staff_roles SET name = ?, department_id = ?, is_coach = ?, position = ? WHERE id = ?`,
+		role.Name, role.DepartmentID, role.IsCoach, role.Position, role.ID); err != nil {
+		return err
+	}
+	if oldName != role.Name {
+		if _, err := tx.ExecContext(ctx, `UPDATE staff SET role = ? WHERE role = ?`, role.Name, oldName); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) DeleteStaffRole(ctx context.Context, id int64) error {
+	return expectRow(s.db.ExecContext(ctx, `DELETE FROM staff_roles WHERE id = ?`, id))
+}
+
+// ── Badges ───────────────────────────────────────────────────────────────────
+
+func (s *SQLiteStore) GetAllBadges(ctx context.Context) ([]Badge, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, name, description, info, image_url, max_level, available, category
+		FROM badges ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	badges := []Badge{}
+	for rows.Next() {
+		var b Badge
+		if err := rows.Scan(&b.ID, &b.Name, &b.Description, &b.Info, &b.ImageURL, &b.MaxLevel, &b.Available, &b.Category); err != nil {
+			return nil, err
+		}
+		badges = append(badges, b)
+	}
+	return badges, rows.Err()
+}
+
+func (s *SQLiteStore) CreateBadge(ctx context.Context, name, description, info, imageURL, category string, maxLevel int) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `INSERT INTO badges (name, description, info, image_url, category, max_level)
+		VALUES (?, ?, ?, ?, ?, ?)`, name, description, info, imageURL, category, maxLevel)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (s *SQLiteStore) UpdateBadge(ctx context.Context, id int64, name, description, info, imageURL, category string, maxLevel int, available bool) error {
+	return expectRow(s.db.ExecContext(ctx, `UPDATE badges SET name = ?, description = ?, info = ?, image_url = ?,
+		category = ?, max_level = ?, available = ? WHERE id = ?`,
+		name, description, info, imageURL, category, maxLevel, available, id))
+}
+
+func (s *SQLiteStore) DeleteBadge(ctx context.Context, id int64) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM badges WHERE id = ?`, id)
+	return err
+}
+
+func (s *SQLiteStore) GetUserBadges(ctx context.Context, userID int64) ([]UserBadge, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT b.id, b.name, b.description, b.info, b.image_url, b.max_level,
+		b.available, b.category, ub.level
+		FROM user_badges ub JOIN badges b ON b.id = ub.badge_id
+		WHERE ub.user_id = ? ORDER BY b.id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	badges := []UserBadge{}
+	for rows.Next() {
+		b := UserBadge{Owned: true}
+		if err := rows.Scan(&b.BadgeID, &b.Name, &b.Description, &b.Info, &b.ImageURL, &b.MaxLevel,
+			&b.Available, &b.Category, &b.Level); err != nil {
+			return nil, err
+		}
+		badges = append(badges, b)
+	}
+	return badges, rows.Err()
+}
+
+func (s *SQLiteStore) EnsureApxMemberBadge(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO badges (name, description, max_level, category)
+		SELECT 'APX MEMBER', 'Mitglied der APX Community', 0, 'community'
+		WHERE NOT EXISTS (SELECT 1 FROM badges WHERE name = 'APX MEMBER')`)
+	return err
+}
+
+func (s *SQLiteStore) GetBadgeIDByName(ctx context.Context, name string) (int64, error) {
+	var id int64
+	err := s.db.QueryRowContext(ctx, `SELECT id FROM badges WHERE name = ? ORDER BY id LIMIT 1`, name).Scan(&id)
+	return id, noRows(err)
+}
+
+func (s *SQLiteStore) UpsertUserBadge(ctx context.Context, userID, badgeID int64, level int) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO user_badges (user_id, badge_id, level) VALUES (?, ?, ?)
+		ON CONFLICT (user_id, badge_id) DO UPDATE SET level = excluded.level`, userID, badgeID, level)
+	return err
+}
+
+func (s *SQLiteStore) RemoveUserBadge(ctx context.Context, userID, badgeID int64) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM user_badges WHERE user_id = ? AND badge_id = ?`, userID, badgeID)
+	return err
+}
+
+// ── Items ─────────────────────────────────────────────────────────────────────
+
+func (s *SQLiteStore) GetAllItems(ctx context.Context) ([]Item, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT item_id, seq_id, name, rarity, image_url,
+		is_weapon, is_armor, is_item, is_animal, perks, created_at FROM items ORDER BY seq_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Item{}
+	for rows.Next() {
+		var it Item
+		var perks string
+		if err := rows.Scan(&it.ItemID, &it.SeqID, &it.Name, &it.Rarity, &it.ImageURL,
+			&it.IsWeapon, &it.IsArmor, &it.IsItem, &it.IsAnimal, &perks, &it.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(perks), &it.Perks); err != nil || it.Perks == nil {
+			it.Perks = []string{}
+		}
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
+
+// CreateItem assigns the next seq_id and an item_id of the form
+// "<seq_id>-<8 hex chars>" (as ApxApi does) and writes them back to item.
+func (s *SQLiteStore) CreateItem(ctx context.Context, item *Item) error {
+	perks := item.Perks
+	if perks == nil {
+		perks = []string{}
+	}
+	perksJSON, _ := json.Marshal(perks)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var seq int64
+	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(seq_id), 0) + 1 FROM items`).Scan(&seq); err != nil {
+		return err
+	}
+	itemID := fmt.Sprintf("%d-%s", seq, randHex(4))
+	created := time.Now().UTC()
+	if _, err := tx.ExecContext(ctx, `INSERT INTO items (item_id, seq_id, name, rarity, image_url,
+		is_weapon, is_armor, is_item, is_animal, perks, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		itemID, seq, item.Name, item.Rarity, item.ImageURL,
+		item.IsWeapon, item.IsArmor, item.IsItem, item.IsAnimal, string(perksJSON), created); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	item.ItemID, item.SeqID, item.Perks = itemID, seq, perks
+	item.CreatedAt = created.Format(time.RFC3339)
+	return nil
+}
+
+func (s *SQLiteStore) DeleteItem(ctx context.Context, itemID string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM items WHERE item_id = ?`, itemID)
+	return err
+}
+
+func (s *SQLiteStore) GetUserItems(ctx context.Context, userID int64) ([]ProgressionInventoryItem, error) {
+	return s.queryInventory(ctx, `WHERE user_id = ?`, userID)
+}
+
+func (s *SQLiteStore) queryInventory(ctx context.Context, where string, args ...any) ([]ProgressionInventoryItem, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT inventory_id, name, rarity, item_type, asset_key, sell_price, equipped
+		FROM progression_inventory `+where+` ORDER BY obtained_at DESC, inventory_id DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ProgressionInventoryItem{}
+	for rows.Next() {
+		var it ProgressionInventoryItem
+		if err := rows.Scan(&it.InventoryID, &it.Name, &it.Rarity, &it.ItemType, &it.AssetKey, &it.SellPrice, &it.Equipped); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
+
+// ── Events ───────────────────────────────────────────────────────────────────
+
+const eventColumns = `e.id, e.name, e.status, e.date, e.duration_de, e.duration_en,
+	e.description_de, e.description_en, e.max_participants,
+	(SELECT COUNT(*) FROM event_participants p WHERE p.event_id = e.id), e.created_at`
+
+func scanEvent(row rowScanner) (*Event, error) {
+	var e Event
+	if err := row.Scan(&e.ID, &e.Name, &e.Status, &e.Date, &e.DurationDe, &e.DurationEn,
+		&e.DescriptionDe, &e.DescriptionEn, &e.MaxParticipants, &e.ParticipantCount, &e.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (s *SQLiteStore) GetAllEvents(ctx context.Context) ([]Event, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+eventColumns+` FROM events e ORDER BY e.date DESC, e.created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	events := []Event{}
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *e)
+	}
+	return events, rows.Err()
+}
+
+func (s *SQLiteStore) GetEventByID(ctx context.Context, id string) (*Event, error) {
+	e, err := scanEvent(s.db.QueryRowContext(ctx, `SELECT `+eventColumns+` FROM events e WHERE e.id = ?`, id))
+	if err != nil {
+		return nil, noRows(err)
+	}
+	return e, nil
+}
+
+func (s *SQLiteStore) IsEventParticipant(ctx context.Context, userID int64, eventID string) (bool, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM event_participants WHERE user_id = ? AND event_id = ?`, userID, eventID).Scan(&n)
+	return n > 0, err
+}
+
+func (s *SQLiteStore) JoinedEventIDs(ctx context.Context, userID int64, eventIDs []string) (map[string]bool, error) {
+	joined := map[string]bool{}
+	if len(eventIDs) == 0 {
+		return joined, nil
+	}
+	args := []any{userID}
+	for _, id := range eventIDs {
+		args = append(args, id)
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT event_id FROM event_participants
+		WHERE user_id = ? AND event_id IN (?`+strings.Repeat(", ?", len(eventIDs)-1)+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		joined[id] = true
+	}
+	return joined, rows.Err()
+}
+
+// JoinEvent errors are shown to the user as-is.
+func (s *SQLiteStore) JoinEvent(ctx context.Context, userID int64, eventID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var status string
+	var maxParticipants, count, joined int
+	err = tx.QueryRowContext(ctx, `SELECT status, max_participants,
+		(SELECT COUNT(*) FROM event_participants WHERE event_id = e.id),
+		(SELECT COUNT(*) FROM event_participants WHERE event_id = e.id AND user_id = ?)
+		FROM events e WHERE id = ?`, userID, eventID).Scan(&status, &maxParticipants, &count, &joined)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("event not found")
+	}
+	if err != nil {
+		return err
+	}
+	switch {
+	case joined > 0:
+		return fmt.Errorf("already joined")
+	case status == "past":
+		return fmt.Errorf("event is over")
+	case maxParticipants > 0 && count >= maxParticipants:
+		return fmt.Errorf("event is full")
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO event_participants (user_id, event_id) VALUES (?, ?)`, userID, eventID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) LeaveEvent(ctx context.Context, userID int64, eventID string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM event_participants WHERE user_id = ? AND event_id = ?`, userID, eventID)
+	return err
+}
+
+func (s *SQLiteStore) CreateEvent(ctx context.Context, in CreateEventInput) (*Event, error) {
+	id := newUUID()
+	if in.Status == "" {
+		in.Status = "upcoming"
+	}
+	_, err := s.db.ExecContext(ctx, `INSERT INTO events (id, name, status, date, duration_de, duration_en,
+		description_de, description_en, max_participants) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, in.Name, in.Status, in.Date, in.DurationDE, in.DurationEN,
+		in.DescriptionDE, in.DescriptionEN, in.MaxParticipants)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetEventByID(ctx, id)
+}
+
+func (s *SQLiteStore) UpdateEvent(ctx context.Context, e *Event) error {
+	return expectRow(s.db.ExecContext(ctx, `UPDATE events SET name = ?, status = ?, date = ?, duration_de = ?, duration_en = ?,
+		description_de = ?, description_en = ?, max_participants = ? WHERE id = ?`,
+		e.Name, e.Status, e.Date, e.DurationDe, e.DurationEn,
+		e.DescriptionDe, e.DescriptionEn, e.MaxParticipants, e.ID))
+}
+
+func (s *SQLiteStore) DeleteEvent(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM events WHERE id = ?`, id)
+	return err
+}
+
+func (s *SQLiteStore) GetEventParticipants(ctx context.Context, eventID string) ([]EventParticipant, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT u.id, u.username, u.nickname, u.avatar_url, p.joined_at
+		FROM event_participants p JOIN users u ON u.id = p.user_id
+		WHERE p.event_id = ? ORDER BY p.joined_at`, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	participants := []EventParticipant{}
+	for rows.Next() {
+		var p EventParticipant
+		if err := rows.Scan(&p.UserID, &p.Username, &p.Nickname, &p.AvatarURL, &p.JoinedAt); err != nil {
+			return nil, err
+		}
+		participants = append(participants, p)
+	}
+	return participants, rows.Err()
+}
+
+// ── Log (Team News) ───────────────────────────────────────────────────────────
+
+func (s *SQLiteStore) GetAllLogEntries(ctx context.Context) ([]LogEntry, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, title, body, log_date, created_at FROM log ORDER BY log_date DESC, id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entries := []LogEntry{}
+	for rows.Next() {
+		var e LogEntry
+		if err := rows.Scan(&e.ID, &e.Title, &e.Body, &e.LogDate, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (s *SQLiteStore) CreateLogEntry(ctx context.Context, entry *LogEntry) error {
+	entry.CreatedAt = time.Now().UTC()
+	res, err := s.db.ExecContext(ctx, `INSERT INTO log (title, body, log_date, created_at) VALUES (?, ?, ?, ?)`,
+		entry.Title, entry.Body, entry.LogDate, entry.CreatedAt)
+	if err != nil {
+		return err
+	}
+	entry.ID, err = res.LastInsertId()
+	return err
+}
+
+func (s *SQLiteStore) UpdateLogEntry(ctx context.Context, entry *LogEntry) error {
+	return expectRow(s.db.ExecContext(ctx, `UPDATE log SET title = ?, body = ?, log_date = ? WHERE id = ?`,
+		entry.Title, entry.Body, entry.LogDate, entry.ID))
+}
+
+func (s *SQLiteStore) DeleteLogEntry(ctx context.Context, id int64) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM log WHERE id = ?`, id)
+	return err
+}
+
+// ── Applications ─────────────────────────────────────────────────────────────
+
+const applicationColumns = `a.id, a.user_id, a.name, a.age, a.discord, a.game, a.rank, a.attacker_role,
+	a.defender_role, a.experience, a.motivation, a.availability, a.status, a.created_at,
+	COALESCE(u.username, ''), a.status_reason, COALESCE(strftime('%Y-%m-%dT%H:%M:%SZ', a.status_updated_at), ''),
+	a.answers`
+
+const applicationFrom = ` FROM applications a LEFT JOIN users u ON u.id = a.user_id `
+
+func scanApplication(row rowScanner) (*ApplicationRecord, error) {
+	var a ApplicationRecord
+	var answers string
+	if err := row.Scan(&a.ID, &a.UserID, &a.Name, &a.Age, &a.Discord, &a.Game, &a.Rank, &a.AttackerRole, &a.DefenderRole,
+		&a.Experience, &a.Motivation, &a.Availability, &a.Status, &a.CreatedAt,
+		&a.Username, &a.StatusReason, &a.StatusUpdatedAt, &answers); err != nil {
+		return nil, err
+	}
+	a.Answers = json.RawMessage(answers)
+	return &a, nil
+}
+
+func (s *SQLiteStore) CreateApplication(ctx context.Context, app ApplicationRecord) (int64, error) {
+	if app.Status == "" {
+		app.Status = "pending"
+	}
+	// The insert is skipped if the user already has an open application;
+	// idx_applications_one_open backs this up.
+	res, err := s.db.ExecContext(ctx, `INSERT INTO applications (user_id, name, age, discord, game, rank, attacker_role,
+		defender_role, experience, motivation, availability, status, answers)
+		SELECT ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+		WHERE NOT EXISTS (SELECT 1 FROM applications WHERE user_id = ? AND status IN (?, ?, ?))`,
+		app.UserID, app.Name, app.Age, app.Discord, app.Game, app.Rank, app.AttackerRole,
+		app.DefenderRole, app.Experience, app.Motivation, app.Availability, app.Status, answersJSON(app.Answers),
+		app.UserID, AppStatusPending, AppStatusInReview, AppStatusTrial)
+	if err != nil {
+		return 0, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return 0, err
+	} else if n == 0 {
+		return 0, errApplicationOpen
+	}
+	return res.LastInsertId()
+}
+
+func (s *SQLiteStore) GetApplications(ctx context.Context) ([]ApplicationRecord, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+applicationColumns+applicationFrom+`ORDER BY a.created_at DESC, a.id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	apps := []ApplicationRecord{}
+	for rows.Next() {
+		a, err := scanApplication(rows)
+		if err != nil {
+			return nil, err
+		}
+		apps = append(apps, *a)
+	}
+	return apps, rows.Err()
+}
+
+func (s *SQLiteStore) GetApplicationByUserID(ctx context.Context, userID int64) (*ApplicationRecord, error) {
+	a, err := scanApplication(s.db.QueryRowContext(ctx, `SELECT `+applicationColumns+applicationFrom+`
+		WHERE a.user_id = ? ORDER BY a.id DESC LIMIT 1`, userID))
+	if err != nil {
+		return nil, noRows(err)
+	}
+	return a, nil
+}
+
+// UpdateApplicationByUserID overwrites the form fields of the user's latest
+// application while it is still pending; the status is left alone.
+func (s *SQLiteStore) UpdateApplicationByUserID(ctx context.Context, userID int64, app ApplicationRecord) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE applications SET name = ?, age = ?, discord = ?, game = ?, rank = ?,
+		attacker_role = ?, defender_role = ?, experience = ?, motivation = ?, availability = ?, answers = ?
+		WHERE id = (SELECT MAX(id) FROM applications WHERE user_id = ?) AND status = ?`,
+		app.Name, app.Age, app.Discord, app.Game, app.Rank,
+		app.AttackerRole, app.DefenderRole, app.Experience, app.Motivation, app.Availability,
+		answersJSON(app.Answers), userID, AppStatusPending)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		var exists int
+		if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM applications WHERE user_id = ?`, userID).Scan(&exists); err != nil {
+			return err
+		}
+		if exists == 0 {
+			return errNotFound
+		}
+		return errApplicationStatusChanged
+	}
+	return nil
+}
+
+func answersJSON(answers json.RawMessage) string {
+	if len(answers) == 0 {
+		return "{}"
+	}
+	return string(answers)
+}
+
+func (s *SQLiteStore) GetApplicationByID(ctx context.Context, id int64) (*ApplicationRecord, error) {
+	a, err := scanApplication(s.db.QueryRowContext(ctx, `SELECT `+applicationColumns+applicationFrom+`WHERE a.id = ?`, id))
+	if err != nil {
+		return nil, noRows(err)
+	}
+	return a, nil
+}
+
+// ChangeApplicationStatus updates the status only if it still equals
+// change.FromStatus, so concurrent reviewers cannot skip a transition.
+func (s *SQLiteStore) ChangeApplicationStatus(ctx context.Context, change ApplicationStatusChange) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	res, err := tx.ExecContext(ctx, `UPDATE applications SET status = ?, status_reason = ?, status_updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ?`, change.ToStatus, change.Reason, change.ApplicationID, change.FromStatus)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		var exists int
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM applications WHERE id = ?`, change.ApplicationID).Scan(&exists); err != nil {
+			return err
+		}
+		if exists == 0 {
+			return errNotFound
+		}
+		return errApplicationStatusChanged
+	}
+	var actorID any
+	if change.ActorID != 0 {
+		actorID = change.ActorID
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO application_status_history
+		(application_id, from_status, to_status, reason, actor_id, actor_name) VALUES (?, ?, ?, ?, ?, ?)`,
+		change.ApplicationID, change.FromStatus, change.ToStatus, change.Reason, actorID, change.ActorName); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) GetApplicationHistory(ctx context.Context, applicationID int64) ([]ApplicationStatusChange, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, application_id, from_status, to_status, reason,
+		COALESCE(actor_id, 0), actor_name, created_at
+		FROM application_status_history WHERE application_id = ? ORDER BY created_at, id`, applicationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	history := []ApplicationStatusChange{}
+	for rows.Next() {
+		var c ApplicationStatusChange
+		if err := rows.Scan(&c.ID, &c.ApplicationID, &c.FromStatus, &c.ToStatus, &c.Reason,
+			&c.ActorID, &c.ActorName, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, c)
+	}
+	return history, rows.Err()
+}
+
+func (s *SQLiteStore) AddApplicationNote(ctx context.Context, note ApplicationNote) (int64, error) {
+	var authorID any
+	if note.AuthorID != 0 {
+		authorID = note.AuthorID
+	}
+	res, err := s.db.ExecContext(ctx, `INSERT INTO application_notes (application_id, author_id, author_name, body)
+		VALUES (?, ?, ?, ?)`, note.ApplicationID, authorID, note.AuthorName, note.Body)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (s *SQLiteStore) GetApplicationNotes(ctx context.Context, applicationID int64) ([]ApplicationNote, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, application_id, COALESCE(author_id, 0), author_name, body, created_at
+		FROM application_notes WHERE application_id = ? ORDER BY created_at, id`, applicationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	notes := []ApplicationNote{}
+	for rows.Next() {
+		var n ApplicationNote
+		if err := rows.Scan(&n.ID, &n.ApplicationID, &n.AuthorID, &n.AuthorName, &n.Body, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		notes = append(notes, n)
+	}
+	return notes, rows.Err()
+}
+
+func (s *SQLiteStore) UpsertApplicationRating(ctx context.Context, rating ApplicationRating) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO application_ratings
+		(application_id, reviewer_id, reviewer_name, rank, experience, availability, communication)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (application_id, reviewer_id) DO UPDATE SET reviewer_name = excluded.reviewer_name,
+			rank = excluded.rank, experience = excluded.experience, availability = excluded.availability,
+			communication = excluded.communication, updated_at = CURRENT_TIMESTAMP`,
+		rating.ApplicationID, rating.ReviewerID, rating.ReviewerName,
+		rating.Rank, rating.Experience, rating.Availability, rating.Communication)
+	return err
+}
+
+func (s *SQLiteStore) GetApplicationRatings(ctx context.Context, applicationID int64) ([]ApplicationRating, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT application_id, reviewer_id, reviewer_name,
+		rank, experience, availability, communication, updated_at
+		FROM application_ratings WHERE application_id = ? ORDER BY updated_at, reviewer_id`, applicationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ratings := []ApplicationRating{}
+	for rows.Next() {
+		var r ApplicationRating
+		if err := rows.Scan(&r.ApplicationID, &r.ReviewerID, &r.ReviewerName,
+			&r.Rank, &r.Experience, &r.Availability, &r.Communication, &r.UpdatedAt); err != nil {
+			return nil, err
+		}
+		ratings = append(ratings, r)
+	}
+	return ratings, rows.Err()
+}
+
+func (s *SQLiteStore) GetApplicationScores(ctx context.Context) ([]ApplicationScore, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT application_id,
+		AVG((rank + experience + availability + communication) / 4.0), COUNT(*)
+		FROM application_ratings GROUP BY application_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	scores := []ApplicationScore{}
+	for rows.Next() {
+		var sc ApplicationScore
+		if err := rows.Scan(&sc.ApplicationID, &sc.Score, &sc.RatingCount); err != nil {
+			return nil, err
+		}
+		scores = append(scores, sc)
+	}
+	return scores, rows.Err()
+}
+
+// ── Application forms ────────────────────────────────────────────────────────
+
+func scanApplicationForm(row rowScanner) (*ApplicationForm, error) {
+	var f ApplicationForm
+	var fields string
+	if err := row.Scan(&f.Game, &fields, &f.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(fields), &f.Fields); err != nil {
+		return nil, fmt.Errorf("application form %q: %w", f.Game, err)
+	}
+	return &f, nil
+}
+
+func (s *SQLiteStore) GetApplicationForms(ctx context.Context) ([]ApplicationForm, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT game, fields, updated_at FROM application_forms ORDER BY game`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	forms := []ApplicationForm{}
+	for rows.Next() {
+		f, err := scanApplicationForm(rows)
+		if err != nil {
+			return nil, err
+		}
+		forms = append(forms, *f)
+	}
+	return forms, rows.Err()
+}
+
+func (s *SQLiteStore) GetApplicationForm(ctx context.Context, game string) (*ApplicationForm, error) {
+	f, err := scanApplicationForm(s.db.QueryRowContext(ctx,
+		`SELECT game, fields, updated_at FROM application_forms WHERE game = ?`, game))
+	if err != nil {
+		return nil, noRows(err)
+	}
+	return f, nil
+}
+
+func (s *SQLiteStore) SaveApplicationForm(ctx context.Context, form ApplicationForm) error {
+	fields, err := json.Marshal(form.Fields)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `INSERT INTO application_forms (game, fields) VALUES (?, ?)
+		ON CONFLICT (game) DO UPDATE SET fields = excluded.fields, updated_at = CURRENT_TIMESTAMP`,
+		form.Game, string(fields))
+	return err
+}
+
+func (s *SQLiteStore) DeleteApplicationForm(ctx context.Context, game string) error {
+	return expectRow(s.db.ExecContext(ctx, `DELETE FROM application_forms WHERE game = ?`, game))
+}
+
+// ── Schedule ─────────────────────────────────────────────────────────────────
+
+func (s *SQLiteStore) GetAvailability(ctx context.Context, userID int64) ([]AvailabilitySlot, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT weekday, start_time, end_time FROM availability_slots
+		WHERE user_id = ? ORDER BY weekday, start_time`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	slots := []AvailabilitySlot{}
+	for rows.Next() {
+		var sl AvailabilitySlot
+		if err := rows.Scan(&sl.Weekday, &sl.Start, &sl.End); err != nil {
+			return nil, err
+		}
+		slots = append(slots, sl)
+	}
+	return slots, rows.Err()
+}
+
+func (s *SQLiteStore) GetAllAvailability(ctx context.Context) (map[int64][]AvailabilitySlot, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT user_id, weekday, start_time, end_time FROM availability_slots
+		ORDER BY user_id, weekday, start_time`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	all := map[int64][]AvailabilitySlot{}
+	for rows.Next() {
+		var userID int64
+		var sl AvailabilitySlot
+		if err := rows.Scan(&userID, &sl.Weekday, &sl.Start, &sl.End); err != nil {
+			return nil, err
+		}
+		all[userID] = append(all[userID], sl)
+	}
+	return all, rows.Err()
+}
+
+// SetAvailability replaces all of the user's slots.
+func (s *SQLiteStore) SetAvailability(ctx context.Context, userID int64, slots []AvailabilitySlot) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM availability_slots WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	for _, sl := range slots {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO availability_slots (user_id, weekday, start_time, end_time)
+			VALUES (?, ?, ?, ?)`, userID, sl.Weekday, sl.Start, sl.End); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+const scheduleSessionColumns = `s.id, s.kind, s.title, s.opponent, s.starts_at, s.ends_at, s.notes,
+	COALESCE(s.created_by, 0), COALESCE(u.username, ''), strftime('%Y-%m-%dT%H:%M:%SZ', s.created_at)
+	FROM schedule_sessions s LEFT JOIN users u ON u.id = s.created_by`
+
+func scanScheduleSession(sc interface{ Scan(...any) error }) (*ScheduleSession, error) {
+	var ss ScheduleSession
+	if err := sc.Scan(&ss.ID, &ss.Kind, &ss.Title, &ss.Opponent, &ss.StartsAt, &ss.EndsAt, &ss.Notes,
+		&ss.CreatedBy, &ss.CreatedByName, &ss.CreatedAt); err != nil {
+		return nil, err
+	}
+	ss.RSVPs = []SessionRSVP{}
+	return &ss, nil
+}
+
+// loadSessionRSVPs fills in the RSVPs of sessions.
+func (s *SQLiteStore) loadSessionRSVPs(ctx context.Context, sessions []*ScheduleSession) error {
+	if len(sessions) == 0 {
+		return nil
+	}
+	byID := make(map[int64]*ScheduleSession, len(sessions))
+	placeholders := make([]string, len(sessions))
+	args := make([]any, len(sessions))
+	for i, ss := range sessions {
+		byID[ss.ID] = ss
+		placeholders[i] = "?"
+		args[i] = ss.ID
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT r.session_id, r.user_id, COALESCE(u.username, ''), r.response, r.comment,
+		strftime('%Y-%m-%dT%H:%M:%SZ', r.updated_at)
+		FROM session_rsvps r LEFT JOIN users u ON u.id = r.user_id
+		WHERE r.session_id IN (`+strings.Join(placeholders, ",")+`) ORDER BY r.updated_at`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r SessionRSVP
+		if err := rows.Scan(&r.SessionID, &r.UserID, &r.Username, &r.Response, &r.Comment, &r.UpdatedAt); err != nil {
+			return err
+		}
+		byID[r.SessionID].RSVPs = append(byID[r.SessionID].RSVPs, r)
+	}
+	return rows.Err()
+}
+
+// GetScheduleSessions returns the sessions ending after from, soonest first.
+func (s *SQLiteStore) GetScheduleSessions(ctx context.Context, from time.Time) ([]ScheduleSession, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+scheduleSessionColumns+`
+		WHERE s.ends_at > ? ORDER BY s.starts_at, s.id`, from.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	var list []*ScheduleSession
+	for rows.Next() {
+		ss, err := scanScheduleSession(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		list = append(list, ss)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := s.loadSessionRSVPs(ctx, list); err != nil {
+		return nil, err
+	}
+	sessions := make([]ScheduleSession, len(list))
+	for i, ss := range list {
+		sessions[i] = *ss
+	}
+	return sessions, nil
+}
+
+func (s *SQLiteStore) GetScheduleSession(ctx context.Context, id int64) (*ScheduleSession, error) {
+	ss, err := scanScheduleSession(s.db.QueryRowContext(ctx, `SELECT `+scheduleSessionColumns+` WHERE s.id = ?`, id))
+	if err != nil {
+		return nil, noRows(err)
+	}
+	if err := s.loadSessionRSVPs(ctx, []*ScheduleSession{ss}); err != nil {
+		return nil, err
+	}
+	return ss, nil
+}
+
+func (s *SQLiteStore) CreateScheduleSession(ctx context.Context, ss ScheduleSession) (int64, error) {
+	var createdBy any
+	if ss.CreatedBy != 0 {
+		createdBy = ss.CreatedBy
+	}
+	res, err := s.db.ExecContext(ctx, `INSERT INTO schedule_sessions (kind, title, opponent, starts_at, ends_at, notes, created_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`, ss.Kind, ss.Title, ss.Opponent, ss.StartsAt, ss.EndsAt, ss.Notes, createdBy)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (s *SQLiteStore) UpdateScheduleSession(ctx context.Context, ss ScheduleSession) error {
+	return expectRow(s.db.ExecContext(ctx, `UPDATE schedule_sessions SET kind = ?, title = ?, opponent = ?,
+		starts_at = ?, ends_at = ?, notes = ? WHERE id = ?`,
+		ss.Kind, ss.Title, ss.Opponent, ss.StartsAt, ss.EndsAt, ss.Notes, ss.ID))
+}
+
+func (s *SQLiteStore) DeleteScheduleSession(ctx context.Context, id int64) error {
+	return expectRow(s.db.ExecContext(ctx, `DELETE FROM schedule_sessions WHERE id = ?`, id))
+}
+
+func (s *SQLiteStore) SetSessionRSVP(ctx context.Context, rsvp SessionRSVP) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO session_rsvps (session_id, user_id, response, comment) VALUES (?, ?, ?, ?)
+		ON CONFLICT (session_id, user_id) DO UPDATE SET response = excluded.response, comment = excluded.comment,
+			updated_at = CURRENT_TIMESTAMP`,
+		rsvp.SessionID, rsvp.UserID, rsvp.Response, rsvp.Comment)
+	return err
+}
+
+// ── Lineups ──────────────────────────────────────────────────────────────────
+
+// GetMapPool returns the map pool in display order.
+func (s *SQLiteStore) GetMapPool(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT name FROM map_pool ORDER BY position, name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	maps := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		maps = append(maps, name)
+	}
+	return maps, rows.Err()
+}
+
+// SetMapPool replaces the map pool. Lineups of removed maps are kept.
+func (s *SQLiteStore) SetMapPool(ctx context.Context, maps []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM map_pool`); err != nil {
+		return err
+	}
+	for i, name := range maps {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO map_pool (name, position) VALUES (?, ?)`, name, i+1); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// mapLineupData is the JSON stored in map_lineups.lineup.
+type mapLineupData struct {
+	Players []LineupPlayer `json:"players"`
+	Sites   []SiteSetup    `json:"sites"`
+}
+
+func scanMapLineup(sc interface{ Scan(...any) error }) (*MapLineup, error) {
+	var l MapLineup
+	var data string
+	if err := sc.Scan(&l.Map, &data, &l.Notes, &l.UpdatedBy, &l.UpdatedAt); err != nil {
+		return nil, err
+	}
+	var d mapLineupData
+	if err := json.Unmarshal([]byte(data), &d); err != nil {
+		return nil, err
+	}
+	l.Players, l.Sites = d.Players, d.Sites
+	return &l, nil
+}
+
+const mapLineupColumns = `map, lineup, notes, updated_by, strftime('%Y-%m-%dT%H:%M:%SZ', updated_at)`
+
+func (s *SQLiteStore) GetMapLineups(ctx context.Context) ([]MapLineup, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+mapLineupColumns+` FROM map_lineups ORDER BY map`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	lineups := []MapLineup{}
+	for rows.Next() {
+		l, err := scanMapLineup(rows)
+		if err != nil {
+			return nil, err
+		}
+		lineups = append(lineups, *l)
+	}
+	return lineups, rows.Err()
+}
+
+func (s *SQLiteStore) GetMapLineup(ctx context.Context, mapName string) (*MapLineup, error) {
+	l, err := scanMapLineup(s.db.QueryRowContext(ctx, `SELECT `+mapLineupColumns+` FROM map_lineups WHERE map = ?`, mapName))
+	if err != nil {
+		return nil, noRows(err)
+	}
+	return l, nil
+}
+
+func (s *SQLiteStore) SaveMapLineup(ctx context.Context, l MapLineup) error {
+	data, err := json.Marshal(mapLineupData{Players: l.Players, Sites: l.Sites})
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `INSERT INTO map_lineups (map, lineup, notes, updated_by) VALUES (?, ?, ?, ?)
+		ON CONFLICT (map) DO UPDATE SET lineup = excluded.lineup, notes = excluded.notes,
+			updated_by = excluded.updated_by, updated_at = CURRENT_TIMESTAMP`,
+		l.Map, string(data), l.Notes, l.UpdatedBy)
+	return err
+}
+
+func (s *SQLiteStore) DeleteMapLineup(ctx context.Context, mapName string) error {
+	return expectRow(s.db.ExecContext(ctx, `DELETE FROM map_lineups WHERE map = ?`, mapName))
+}
+
+// ── Weekly statistics ────────────────────────────────────────────────────────
+
+func (s *SQLiteStore) SaveWeeklySnapshot(ctx context.Context, snap WeeklySnapshot) error {
+	stats, err := json.Marshal(snap.Stats)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `INSERT INTO weekly_stats (week, starts_at, ends_at, stats) VALUES (?, ?, ?, ?)
+		ON CONFLICT (week) DO UPDATE SET starts_at = excluded.starts_at, ends_at = excluded.ends_at,
+			stats = excluded.stats, created_at = CURRENT_TIMESTAMP`,
+		snap.Week, snap.StartsAt, snap.EndsAt, string(stats))
+	return err
+}
+
+func (s *SQLiteStore) GetWeeklySnapshot(ctx context.Context, week string) (*WeeklySnapshot, error) {
+	var snap WeeklySnapshot
+	var stats string
+	err := s.db.QueryRowContext(ctx, `SELECT week, starts_at, ends_at, stats, strftime('%Y-%m-%dT%H:%M:%SZ', created_at)
+		FROM weekly_stats WHERE week = ?`, week).Scan(&snap.Week, &snap.StartsAt, &snap.EndsAt, &stats, &snap.CreatedAt)
+	if err != nil {
+		return nil, noRows(err)
+	}
+	if err := json.Unmarshal([]byte(stats), &snap.Stats); err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
+
+// GetWeeklySnapshotWeeks returns the weeks with a snapshot, newest first.
+func (s *SQLiteStore) GetWeeklySnapshotWeeks(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT week FROM weekly_stats ORDER BY starts_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var weeks []string
+	for rows.Next() {
+		var w string
+		if err := rows.Scan(&w); err != nil {
+			return nil, err
+		}
+		weeks = append(weeks, w)
+	}
+	return weeks, rows.Err()
+}
+
+// ── Audit log ────────────────────────────────────────────────────────────────
+
+func (s *SQLiteStore) CreateAuditEntry(ctx context.Context, e AuditEntry) error {
+	var actorID any
+	if e.ActorID != 0 {
+		actorID = e.ActorID
+	}
+	var before, after any
+	if e.Before != nil {
+		before = string(e.Before)
+	}
+	if e.After != nil {
+		after = string(e.After)
+	}
+	_, err := s.db.ExecContext(ctx, `INSERT INTO audit_log (actor_id, actor_name, action, target_type, target_id, before, after, ip, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		actorID, e.ActorName, e.Action, e.TargetType, e.TargetID, before, after, e.IP, time.Now().UTC())
+	return err
+}
+
+func (s *SQLiteStore) GetAuditEntries(ctx context.Context, f AuditFilter) ([]AuditEntry, int, error) {
+	var where []string
+	var args []any
+	if f.ActorName != "" {
+		where, args = append(where, "actor_name = ?"), append(args, f.ActorName)
+	}
+	if f.Action != "" {
+		where, args = append(where, "action = ?"), append(args, f.Action)
+	}
+	if f.TargetType != "" {
+		where, args = append(where, "target_type = ?"), append(args, f.TargetType)
+	}
+	if f.TargetID != "" {
+		where, args = append(where, "target_id = ?"), append(args, f.TargetID)
+	}
+	if !f.From.IsZero() {
+		where, args = append(where, "created_at >= ?"), append(args, f.From.UTC())
+	}
+	if !f.To.IsZero() {
+		where, args = append(where, "created_at < ?"), append(args, f.To.UTC())
+	}
+	cond := ""
+	if len(where) > 0 {
+		cond = "WHERE " + strings.Join(where, " AND ")
+	}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM audit_log `+cond, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT id, COALESCE(actor_id, 0), actor_name, action, target_type, target_id,
+		before, after, ip, created_at FROM audit_log `+cond+` ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?`,
+		append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var e AuditEntry
+		var before, after sql.NullString
+		if err := rows.Scan(&e.ID, &e.ActorID, &e.ActorName, &e.Action, &e.TargetType, &e.TargetID,
+			&before, &after, &e.IP, &e.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		if before.Valid {
+			e.Before = json.RawMessage(before.String)
+		}
+		if after.Valid {
+			e.After = json.RawMessage(after.String)
+		}
+		entries = append(entries, e)
+	}
+	return entries, total, rows.Err()
+}
+
+// ── Progression ───────────────────────────────────────────────────────────────
+
+// ResolveUserIDByDiscord maps a Discord user id to the site user that linked it.
+func (s *SQLiteStore) ResolveUserIDByDiscord(ctx context.Context, discordID string) (int64, error) {
+	var userID int64
+	err := s.db.QueryRowContext(ctx, `SELECT user_id FROM linked_accounts WHERE service = 'discord' AND service_id = ?`, discordID).Scan(&userID)
+	return userID, noRows(err)
+}
+
+func (s *SQLiteStore) UpsertProgressionUser(ctx context.Context, userID int64, discordID string, level, xp, balance int) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO progression_users (user_id, discord_id, level, xp, currency_balance, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET discord_id = excluded.discord_id, level = excluded.level,
+			xp = excluded.xp, currency_balance = excluded.currency_balance, updated_at = excluded.updated_at`,
+		userID, discordID, level, xp, balance, time.Now().UTC())
+	return err
+}
+
+func (s *SQLiteStore) InsertInventoryItem(ctx context.Context, userID int64, invID, itemID int, name, rarity, itemType, assetKey string, sellPrice int) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO progression_inventory
+		(user_id, inventory_id, item_id, name, rarity, item_type, asset_key, sell_price)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, inventory_id) DO UPDATE SET item_id = excluded.item_id, name = excluded.name,
+			rarity = excluded.rarity, item_type = excluded.item_type, asset_key = excluded.asset_key,
+			sell_price = excluded.sell_price`,
+		userID, invID, itemID, name, rarity, itemType, assetKey, sellPrice)
+	return err
+}
+
+func (s *SQLiteStore) DeleteInventoryItem(ctx context.Context, userID int64, inventoryID int) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM progression_inventory WHERE user_id = ? AND inventory_id = ?`, userID, inventoryID)
+	return err
+}
+
+func (s *SQLiteStore) UpdateProgressionUserRank(ctx context.Context, userID int64, discordID, rank string) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO progression_users (user_id, discord_id, discord_rank, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET discord_id = excluded.discord_id,
+			discord_rank = excluded.discord_rank, updated_at = excluded.updated_at`,
+		userID, discordID, rank, time.Now().UTC())
+	return err
+}
+
+// EquipInventoryItem equips or unequips one item. Equipping unequips any
+// other item of the same type first.
+func (s *SQLiteStore) EquipInventoryItem(ctx context.Context, userID int64, inventoryID int, itemType string, equipped bool) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if equipped {
+		if _, err := tx.ExecContext(ctx, `UPDATE progression_inventory SET equipped = 0 WHERE user_id = ? AND item_type = ?`, userID, itemType); err != nil {
+			return err
+		}
+	}
+	if err := expectRow(tx.ExecContext(ctx, `UPDATE progression_inventory SET equipped = ? WHERE user_id = ? AND inventory_id = ?`,
+		equipped, userID, inventoryID)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) GetProgressionUser(ctx context.Context, userID int64) (*ProgressionUser, error) {
+	var u ProgressionUser
+	var rank sql.NullString
+	err := s.db.QueryRowContext(ctx, `SELECT user_id, discord_id, level, xp, currency_balance, discord_rank
+		FROM progression_users WHERE user_id = ?`, userID).
+		Scan(&u.UserID, &u.DiscordID, &u.Level, &u.XP, &u.CurrencyBalance, &rank)
+	if err != nil {
+		return nil, noRows(err)
+	}
+	u.DiscordRank = rank.String
+	return &u, nil
+}
+
+func (s *SQLiteStore) GetEquippedItems(ctx context.Context, userID int64) ([]ProgressionInventoryItem, error) {
+	return s.queryInventory(ctx, `WHERE user_id = ? AND equipped = 1`, userID)
+}
+
+const botUserColumns = `user_id, guild_id, apx_id, xp, level, gold, total_earned, rank_role_id, discord_username`
+
+func scanBotUser(row rowScanner) (*BotUser, error) {
+	var u BotUser
+	if err := row.Scan(&u.UserID, &u.GuildID, &u.ApxID, &u.XP, &u.Level, &u.Gold, &u.TotalEarned,
+		&u.RankRoleID, &u.DiscordUsername); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (s *SQLiteStore) GetBotLeaderboard(ctx context.Context, guildID string, limit int) ([]BotUser, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+botUserColumns+` FROM bot_users WHERE guild_id = ?
+		ORDER BY level DESC, xp DESC LIMIT ?`, guildID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	users := []BotUser{}
+	for rows.Next() {
+		u, err := scanBotUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *u)
+	}
+	return users, rows.Err()
+}
+
+func (s *SQLiteStore) GetBotUser(ctx context.Context, discordID, guildID string) (*BotUser, error) {
+	u, err := scanBotUser(s.db.QueryRowContext(ctx, `SELECT `+botUserColumns+` FROM bot_users
+		WHERE user_id = ? AND guild_id = ?`, discordID, guildID))
+	if err != nil {
+		return nil, noRows(err)
+	}
+	return u, nil
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const maxStatImportSize = 1 << 20
+
+// playerStatFields are the import column names, in the order of
+// PlayerStats.counters().
+var playerStatFields = []string{
+	"kill_entry", "kill_trade", "kill_impact", "kill_late",
+	"death_entry", "death_trade", "death_late",
+	"clutch_1v1", "clutch_1v2", "clutch_1v3", "clutch_1v4", "clutch_1v5",
+	"obj_plant", "obj_defuse",
+}
+
+func statFieldIndex(name string) int {
+	for i, f := range playerStatFields {
+		if f == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// statImportRow is one parsed row: the counters it sets, by field index.
+// Fields missing from the export keep their current value.
+type statImportRow struct {
+	Line     int
+	Username string
+	Values   map[int]int
+}
+
+type statImportError struct {
+	Row     int    `json:"row"`
+	Message string `json:"message"`
+}
+
+// statChange is the old and new total of one counter.
+type statChange struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+type statImportDiff struct {
+	TeamMemberID int64                 `json:"team_member_id"`
+	Username     string                `json:"username"`
+	Name         string                `json:"name"`
+	Changes      map[string]statChange `json:"changes"`
+}
+
+// parseStatCSV reads a CSV export with a header row. Comma and semicolon
+// separated files are accepted; unknown columns are returned as ignored.
+func parseStatCSV(data []byte) ([]statImportRow, []string, []statImportError) {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	header, _, _ := bytes.Cut(data, []byte("\n"))
+	rd := csv.NewReader(bytes.NewReader(data))
+	if bytes.Count(header, []byte(";")) > bytes.Count(header, []byte(",")) {
+		rd.Comma = ';'
+	}
+	rd.TrimLeadingSpace = true
+	rd.FieldsPerRecord = -1
+
+	records, err := rd.ReadAll()
+	if err != nil {
+		return nil, nil, []statImportError{{Message: "CSV konnte nicht gelesen werden: " + err.Error()}}
+	}
+	if len(records) == 0 {
+		return nil, nil, []statImportError{{Message: "Die Datei ist leer"}}
+	}
+	userCol := -1
+	columns := map[int]int{} // csv column → stat field
+	var ignored []string
+	for i, h := range records[0] {
+		h = strings.ToLower(strings.TrimSpace(h))
+		switch f := statFieldIndex(h); {
+		case h == "username" || h == "user":
+			userCol = i
+		case f >= 0:
+			columns[i] = f
+		case h != "":
+			ignored = append(ignored, h)
+		}
+	}
+	if userCol < 0 {
+		return nil, ignored, []statImportError{{Row: 1, Message: "Spalte username fehlt"}}
+	}
+
+	var rows []statImportRow
+	var errs []statImportError
+	for n, rec := range records[1:] {
+		line := n + 2
+		row := statImportRow{Line: line, Values: map[int]int{}}
+		if userCol < len(rec) {
+			row.Username = strings.TrimSpace(rec[userCol])
+		}
+		if row.Username == "" && strings.TrimSpace(strings.Join(rec, "")) == "" {
+			continue // blank line
+		}
+		for col, f := range columns {
+			if col >= len(rec) || strings.TrimSpace(rec[col]) == "" {
+				continue
+			}
+			v, err := strconv.Atoi(strings.TrimSpace(rec[col]))
+			if err != nil {
+				errs = append(errs, statImportError{Row: line, Message: playerStatFields[f] + " ist keine ganze Zahl"})
+				continue
+			}
+			row.Values[f] = v
+		}
+		rows = append(rows, row)
+	}
+	return rows, ignored, errs
+}
+
+// parseStatJSON reads a JSON array of objects with a username and stat
+// fields. Unknown keys are returned as ignored.
+func parseStatJSON(data []byte) ([]statImportRow, []string, []statImportError) {
+	var objects []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &objects); err != nil {
+		return nil, nil, []statImportError{{Message: "JSON muss ein Array von Objekten sein"}}
+	}
+	seenIgnored := map[string]bool{}
+	var ignored []string
+	var rows []statImportRow
+	var errs []statImportError
+	for n, obj := range objects {
+		line := n + 1
+		row := statImportRow{Line: line, Values: map[int]int{}}
+		for key, raw := range obj {
+			k := strings.ToLower(strings.TrimSpace(key))
+			if k == "username" || k == "user" {
+				if err := json.Unmarshal(raw, &row.Username); err != nil {
+					errs = append(errs, statImportError{Row: line, Message: "username muss ein String sein"})
+				}
+				row.Username = strings.TrimSpace(row.Username)
+				continue
+			}
+			f := statFieldIndex(k)
+			if f < 0 {
+				if !seenIgnored[k] {
+					seenIgnored[k] = true
+					ignored = append(ignored, k)
+				}
+				continue
+			}
+			var v int
+			if err := json.Unmarshal(raw, &v); err != nil {
+				errs = append(errs, statImportError{Row: line, Message: k + " ist keine ganze Zahl"})
+				continue
+			}
+			row.Values[f] = v
+		}
+		rows = append(rows, row)
+	}
+	sort.Strings(ignored)
+	return rows, ignored, errs
+}
+
+// statImportPlan maps the rows onto the roster. members carry the displayed
+// totals (stored counters plus match lines), matchTotals the match part.
+// With add the values are added to the totals, otherwise they replace them.
+// It returns the diffs and the new stored counters of changed members.
+func statImportPlan(rows []statImportRow, members []TeamMember, matchTotals map[int64]PlayerStats, add bool) ([]statImportDiff, map[int64]PlayerStats, []statImportError) {
+	byUsername := map[string][]int{}
+	for i, m := range members {
+		if m.Username != "" {
+			key := strings.ToLower(m.Username)
+			byUsername[key] = append(byUsername[key], i)
+		}
+	}
+
+	diffs := []statImportDiff{}
+	stored := map[int64]PlayerStats{}
+	var errs []statImportError
+	seen := map[string]int{}
+	for _, row := range rows {
+		key := strings.ToLower(row.Username)
+		switch {
+		case row.Username == "":
+			errs = append(errs, statImportError{Row: row.Line, Message: "username fehlt"})
+			continue
+		case seen[key] != 0:
+			errs = append(errs, statImportError{Row: row.Line, Message: fmt.Sprintf("%s steht bereits in Zeile %d", row.Username, seen[key])})
+			continue
+		case len(byUsername[key]) == 0:
+			errs = append(errs, statImportError{Row: row.Line, Message: row.Username + " ist nicht im Roster"})
+			continue
+		case len(byUsername[key]) > 1:
+			errs = append(errs, statImportError{Row: row.Line, Message: row.Username + " ist mehreren Roster-Einträgen zugeordnet"})
+			continue
+		}
+		seen[key] = row.Line
+
+		m := members[byUsername[key][0]]
+		total, matched := m.PlayerStats, matchTotals[m.ID]
+		current, totals, fromMatches := m.PlayerStats.counters(), total.counters(), matched.counters()
+		d := statImportDiff{TeamMemberID: m.ID, Username: m.Username, Name: m.Name, Changes: map[string]statChange{}}
+		valid := true
+		for f, v := range row.Values {
+			if v < 0 {
+				errs = append(errs, statImportError{Row: row.Line, Message: playerStatFields[f] + " darf nicht negativ sein"})
+				valid = false
+				continue
+			}
+			if add {
+				v += *current[f]
+			}
+			if v < *fromMatches[f] {
+				errs = append(errs, statImportError{Row: row.Line, Message: fmt.Sprintf(
+					"%s liegt mit %d unter der Summe der erfassten Matches (%d)", playerStatFields[f], v, *fromMatches[f])})
+				valid = false
+				continue
+			}
+			if v != *current[f] {
+				d.Changes[playerStatFields[f]] = statChange{From: *current[f], To: v}
+			}
+			*totals[f] = v
+		}
+		if !valid || len(d.Changes) == 0 {
+			continue
+		}
+		// Match lines are added on read, so only the rest is stored.
+		var base PlayerStats
+		for i, c := range base.counters() {
+			*c = *totals[i] - *fromMatches[i]
+		}
+		stored[m.ID] = base
+		diffs = append(diffs, d)
+	}
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Row < errs[j].Row })
+	return diffs, stored, errs
+}
+
+// statTotalsHash fingerprints the displayed totals of members, so an import
+// can be applied only against the totals its dry run was computed from.
+func statTotalsHash(members []TeamMember) string {
+	sorted := make([]TeamMember, len(members))
+	copy(sorted, members)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	h := sha256.New()
+	for _, m := range sorted {
+		fmt.Fprintf(h, "%d", m.ID)
+		for _, c := range m.counters() {
+			fmt.Fprintf(h, ",%d", *c)
+		}
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// handleAdminTeamImport serves POST /api/admin/team/import?mode=set|add&dry_run=1.
+// The body is a CSV (text/csv) or JSON export with a username per row. The
+// dry run returns the diff against the current totals and their totals_hash;
+// applying requires that hash (?totals_hash=) and is refused with 409 if the
+// totals have changed since. All changes are applied in one step, and
+// nothing is applied if any row has an error.
+func handleAdminTeamImport(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		mode := q.Get("mode")
+		if mode == "" {
+			mode = "set"
+		}
+		if mode != "set" && mode != "add" {
+			jsonError(w, http.StatusBadRequest, "mode muss set oder add sein")
+			return
+		}
+		dryRun := q.Get("dry_run") == "1" || q.Get("dry_run") == "true"
+
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxStatImportSize))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				jsonError(w, http.StatusRequestEntityTooLarge, "Datei zu groß (max 1 MB)")
+				return
+			}
+			jsonError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		var rows []statImportRow
+		var ignored []string
+		var errs []statImportError
+		trimmed := bytes.TrimSpace(data)
+		if strings.Contains(r.Header.Get("Content-Type"), "json") || bytes.HasPrefix(trimmed, []byte("[")) {
+			rows, ignored, errs = parseStatJSON(trimmed)
+		} else {
+			rows, ignored, errs = parseStatCSV(data)
+		}
+
+		members, err := apx.GetTeamMembers(r.Context())
+		if err != nil {
+			log.Printf("Failed to get team members: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		matchTotals, err := apx.GetMatchStatTotals(r.Context())
+		if err != nil {
+			log.Printf("GetMatchStatTotals error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		for i := range members {
+			members[i].add(matchTotals[members[i].ID])
+		}
+		diffs, stored, planErrs := statImportPlan(rows, members, matchTotals, mode == "add")
+		errs = append(errs, planErrs...)
+		if errs == nil {
+			errs = []statImportError{}
+		}
+		if ignored == nil {
+			ignored = []string{}
+		}
+		totalsHash := statTotalsHash(members)
+		report := map[string]interface{}{
+			"totals_hash":     totalsHash,
+			"dry_run":         dryRun,
+			"mode":            mode,
+			"rows":            len(rows),
+			"changes":         diffs,
+			"errors":          errs,
+			"ignored_columns": ignored,
+		}
+		if dryRun {
+			jsonResponse(w, http.StatusOK, report)
+			return
+		}
+		if len(errs) > 0 {
+			report["error"] = "Import enthält Fehler, es wurde nichts übernommen"
+			jsonResponse(w, http.StatusBadRequest, report)
+			return
+		}
+		if h := q.Get("totals_hash"); h == "" {
+			jsonError(w, http.StatusBadRequest, "totals_hash fehlt, bitte zuerst eine Vorschau (dry_run) laden")
+			return
+		} else if h != totalsHash {
+			report["error"] = "Die Statistiken wurden seit der Vorschau geändert, bitte erneut prüfen"
+			jsonResponse(w, http.StatusConflict, report)
+			return
+		}
+		if len(stored) > 0 {
+			err := apx.SetTeamStats(r.Context(), stored)
+			if errors.Is(err, errUnsupported) {
+				jsonError(w, http.StatusNotImplemented, "Der Stat-Import wird vom Datenbackend nicht unterstützt")
+				return
+			} else if err != nil {
+				log.Printf("SetTeamStats error: %v", err)
+				jsonError(w, http.StatusInternalServerError, "internal error")
+				return
+			}
+			publicRoster.invalidate()
+			recordAudit(apx, r, "team.import", "team", "", nil, diffs)
+		}
+		jsonResponse(w, http.StatusOK, report)
+	}
+}
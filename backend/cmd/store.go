@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// Store is the data layer behind the HTTP handlers. ApxClient implements it
+// against the external ApxApi; SQLiteStore keeps everything in a local
+// database file so the site can run without the API.
+type Store interface {
+	// Auth / Sessions
+	GetSessionUser(ctx context.Context, token string) (*User, error)
+	CreateSession(ctx context.Context, userID int64) (string, error)
+	DeleteSession(ctx context.Context, token string) error
+	DeleteUserSessions(ctx context.Context, userID int64) error
+
+	// Email Verifications
+	CreateEmailVerification(ctx context.Context, email, username, nickname, hashedPw, code string, expiresAt time.Time) error
+	GetEmailVerification(ctx context.Context, email string) (*EmailVerification, error)
+	DeleteEmailVerification(ctx context.Context, email string)
+
+	// Email Change Requests
+	CreateEmailChangeRequest(ctx context.Context, userID int64, newEmail, code string, expiresAt time.Time) error
+	GetEmailChangeRequest(ctx context.Context, userID int64) (*EmailChangeRequest, error)
+	DeleteEmailChangeRequest(ctx context.Context, userID int64)
+
+	// Password Resets
+	CreatePasswordReset(ctx context.Context, userID int64, email, code string, expiresAt time.Time) error
+	GetPasswordReset(ctx context.Context, email string) (*PasswordReset, error)
+	DeletePasswordReset(ctx context.Context, email string)
+
+	// 2FA Pending
+	CreateLogin2FAPending(ctx context.Context, userID int64, code string) (string, error)
+	GetLogin2FAPending(ctx context.Context, token string) (int64, string, error)
+	DeleteLogin2FAPending(ctx context.Context, token string)
+
+	// TOTP / Recovery Codes
+	GetUser2FAConfig(ctx context.Context, userID int64) (*TwoFAConfig, error)
+	SetUser2FAMethod(ctx context.Context, userID int64, method string) error
+	SetUserTOTPSecret(ctx context.Context, userID int64, secret string) error
+	ConfirmUserTOTP(ctx context.Context, userID int64) error
+	DeleteUserTOTP(ctx context.Context, userID int64) error
+	SetRecoveryCodes(ctx context.Context, userID int64, hashes []string) error
+	ConsumeRecoveryCode(ctx context.Context, userID int64, hash string) (bool, error)
+	UseTOTPStep(ctx context.Context, userID int64, step int64) (bool, error)
+
+	// Trusted Devices
+	CreateTrustedDevice(ctx context.Context, userID int64, deviceName, ip, location string) (string, error)
+	GetTrustedDeviceUserID(ctx context.Context, token string) (int64, error)
+	GetTrustedDevices(ctx context.Context, userID int64) ([]TrustedDevice, error)
+	DeleteTrustedDevice(ctx context.Context, token string, userID int64) error
+	DeleteAllTrustedDevices(ctx context.Context, userID int64)
+
+	// OAuth States
+	CreateOAuthState(ctx context.Context, state string, userID int64, codeVerifier string, expiresAt time.Time) error
+	GetAndDeleteOAuthState(ctx context.Context, state string) (*OAuthState, error)
+
+	// Users
+	CreateUser(ctx context.Context, username, nickname, email, hashedPw string) (int64, error)
+	GetUserByEmail(ctx context.Context, email string) (*User, error)
+	GetUserByUsername(ctx context.Context, username string) (*User, error)
+	GetUserByUsernameAny(ctx context.Context, username string) (*User, error)
+	GetUsersByUsernames(ctx context.Context, usernames []string) (map[string]*User, error)
+	GetLinkedAccounts(ctx context.Context, userID int64) ([]LinkedAccount, error)
+	UpsertLinkedAccount(ctx context.Context, userID int64, service, serviceID, username, avatarURL, profileURL string) error
+	DeleteLinkedAccount(ctx context.Context, userID int64, service string) error
+	UpdateLinkedAccountField(ctx context.Context, userID int64, service, field, value string) error
+	UpdateUserProfile(ctx context.Context, userID int64, username, nickname, email, avatarURL, bannerURL, bio string) error
+	UpdateProfileSettings(ctx context.Context, userID int64, timezone string, showLocalTime bool, socialLinks []string) error
+	UpdateMailSettings(ctx context.Context, userID int64, language string, optOut bool) error
+	GetAllUsernames(ctx context.Context) ([]string, error)
+	SearchUsers(ctx context.Context, query string) ([]User, error)
+	CheckUserConflict(ctx context.Context, username, email string) (bool, error)
+	EnsureAdminUser(ctx context.Context, hashedPw string) error
+	DeactivateUser(ctx context.Context, userID int64) error
+	DeactivateUserByUsername(ctx context.Context, username string) error
+	DeleteUserByUsername(ctx context.Context, username string) error
+	SetUserEventAccess(ctx context.Context, userID int64, enabled bool) error
+	SetUserEventAccessByUsername(ctx context.Context, username string, enabled bool) error
+	SetUserRole(ctx context.Context, userID int64, role string) error
+	SetUser2FAEnabled(ctx context.Context, userID int64, enabled bool) error
+	SetUserTrustDevices(ctx context.Context, userID int64, enabled bool) error
+	ToggleUser2FA(ctx context.Context, username string) (bool, error)
+	GetUserTwoFASettings(ctx context.Context, userID int64) (twoFAEnabled, trustDevices bool)
+	UpdateUserEmail(ctx context.Context, userID int64, newEmail string) error
+	UpdateUserPassword(ctx context.Context, userID int64, hashedPw string) error
+	ActivateByUsername(ctx context.Context, username string) error
+	GetUserAvatarByUsername(ctx context.Context, username string) string
+	GetUserNicknameByUsername(ctx context.Context, username string) string
+	GetUserIDByUsername(ctx context.Context, username string) (int64, error)
+	UpdateBotUserApxID(ctx context.Context, discordID string, apxID int64) error
+	CountUsersCreatedBetween(ctx context.Context, from, to time.Time) (int, error)
+
+	// Team
+	GetTeamMembers(ctx context.Context) ([]TeamMember, error)
+	AddTeamMember(ctx context.Context, name, username, atkRole, defRole string, isMainRoster bool) (int64, error)
+	UpdateTeamMember(ctx context.Context, m TeamMember) error
+	SetTeamStats(ctx context.Context, stats map[int64]PlayerStats) error
+	DeleteTeamMember(ctx context.Context, id int64) error
+	CountMainRoster(ctx context.Context, excludeID int64) (int, error)
+	EnsureTeamPlayers(ctx context.Context) error
+	AddRosterEvents(ctx context.Context, events []RosterEvent) error
+	GetRosterEvents(ctx context.Context) ([]RosterEvent, error)
+	// GetUserRosterEvents returns the history of every team entry that was
+	// ever linked to username, oldest first.
+	GetUserRosterEvents(ctx context.Context, username string) ([]RosterEvent, error)
+
+	// Matches
+	GetMatches(ctx context.Context) ([]Match, error)
+	GetMatch(ctx context.Context, id int64) (*Match, error)
+	CreateMatch(ctx context.Context, m Match) (int64, error)
+	UpdateMatch(ctx context.Context, m Match) error
+	DeleteMatch(ctx context.Context, id int64) error
+	GetMatchStatTotals(ctx context.Context) (map[int64]PlayerStats, error)
+	GetPlayerMatchLines(ctx context.Context, teamMemberID int64) ([]PlayerMatchLine, error)
+
+	// Staff
+	GetStaffMembers(ctx context.Context) ([]StaffMember, error)
+	AddStaffMember(ctx context.Context, name, role, username string) (int64, error)
+	UpdateStaffMember(ctx context.Context, id int64, name, role, username string) error
+	DeleteStaffMember(ctx context.Context, id int64) error
+	SetStaffReporting(ctx context.Context, id int64, departmentID, reportsTo *int64) error
+
+	// Organization chart
+	GetOrgDepartments(ctx context.Context) ([]OrgDepartment, error)
+	CreateOrgDepartment(ctx context.Context, d OrgDepartment) (int64, error)
+	UpdateOrgDepartment(ctx context.Context, d OrgDepartment) error
+	DeleteOrgDepartment(ctx context.Context, id int64) error
+	GetStaffRoles(ctx context.Context) ([]StaffRole, error)
+	CreateStaffRole(ctx context.Context, role StaffRole) (int64, error)
+	UpdateStaffRole(ctx context.Context, role StaffRole) error
+	DeleteStaffRole(ctx context.Context, id int64) error
+
+	// Badges
+	GetAllBadges(ctx context.Context) ([]Badge, error)
+	CreateBadge(ctx context.Context, name, description, info, imageURL, category string, maxLevel int) (int64, error)
+	UpdateBadge(ctx context.Context, id int64, name, description, info, imageURL, category string, maxLevel int, available bool) error
+	DeleteBadge(ctx context.Context, id int64) error
+	GetUserBadges(ctx context.Context, userID int64) ([]UserBadge, error)
+	EnsureApxMemberBadge(ctx context.Context) error
+	GetBadgeIDByName(ctx context.Context, name string) (int64, error)
+	UpsertUserBadge(ctx context.Context, userID, badgeID int64, level int) error
+	RemoveUserBadge(ctx context.Context, userID, badgeID int64) error
+
+	// Items
+	GetAllItems(ctx context.Context) ([]Item, error)
+	CreateItem(ctx context.Context, item *Item) error
+	DeleteItem(ctx context.Context, itemID string) error
+	GetUserItems(ctx context.Context, userID int64) ([]ProgressionInventoryItem, error)
+
+	// Events
+	GetAllEvents(ctx context.Context) ([]Event, error)
+	GetEventByID(ctx context.Context, id string) (*Event, error)
+	IsEventParticipant(ctx context.Context, userID int64, eventID string) (bool, error)
+	JoinedEventIDs(ctx context.Context, userID int64, eventIDs []string) (map[string]bool, error)
+	JoinEvent(ctx context.Context, userID int64, eventID string) error
+	LeaveEvent(ctx context.Context, userID int64, eventID string) error
+	CreateEvent(ctx context.Context, in CreateEventInput) (*Event, error)
+	UpdateEvent(ctx context.Context, e *Event) error
+	DeleteEvent(ctx context.Context, id string) error
+	GetEventParticipants(ctx context.Context, eventID string) ([]EventParticipant, error)
+
+	// Log (Team News)
+	GetAllLogEntries(ctx context.Context) ([]LogEntry, error)
+	CreateLogEntry(ctx context.Context, entry *LogEntry) error
+	UpdateLogEntry(ctx context.Context, entry *LogEntry) error
+	DeleteLogEntry(ctx context.Context, id int64) error
+
+	// Applications
+	CreateApplication(ctx context.Context, app ApplicationRecord) (int64, error)
+	GetApplications(ctx context.Context) ([]ApplicationRecord, error)
+	GetApplicationByUserID(ctx context.Context, userID int64) (*ApplicationRecord, error)
+	UpdateApplicationByUserID(ctx context.Context, userID int64, app ApplicationRecord) error
+	GetApplicationByID(ctx context.Context, id int64) (*ApplicationRecord, error)
+	ChangeApplicationStatus(ctx context.Context, change ApplicationStatusChange) error
+	GetApplicationHistory(ctx context.Context, applicationID int64) ([]ApplicationStatusChange, error)
+	AddApplicationNote(ctx context.Context, note ApplicationNote) (int64, error)
+	GetApplicationNotes(ctx context.Context, applicationID int64) ([]ApplicationNote, error)
+	UpsertApplicationRating(ctx context.Context, rating ApplicationRating) error
+	GetApplicationRatings(ctx context.Context, applicationID int64) ([]ApplicationRating, error)
+	GetApplicationScores(ctx context.Context) ([]ApplicationScore, error)
+
+	// Application forms
+	GetApplicationForms(ctx context.Context) ([]ApplicationForm, error)
+	GetApplicationForm(ctx context.Context, game string) (*ApplicationForm, error)
+	SaveApplicationForm(ctx context.Context, form ApplicationForm) error
+	DeleteApplicationForm(ctx context.Context, game string) error
+
+	// Schedule
+	GetAvailability(ctx context.Context, userID int64) ([]AvailabilitySlot, error)
+	GetAllAvailability(ctx context.Context) (map[int64][]AvailabilitySlot, error)
+	SetAvailability(ctx context.Context, userID int64, slots []AvailabilitySlot) error
+	GetScheduleSessions(ctx context.Context, from time.Time) ([]ScheduleSession, error)
+	GetScheduleSession(ctx context.Context, id int64) (*ScheduleSession, error)
+	CreateScheduleSession(ctx context.Context, s ScheduleSession) (int64, error)
+	UpdateScheduleSession(ctx context.Context, s ScheduleSession) error
+	DeleteScheduleSession(ctx context.Context, id int64) error
+	SetSessionRSVP(ctx context.Context, rsvp SessionRSVP) error
+
+	// Lineups
+	GetMapPool(ctx context.Context) ([]string, error)
+	SetMapPool(ctx context.Context, maps []string) error
+	GetMapLineups(ctx context.Context) ([]MapLineup, error)
+	GetMapLineup(ctx context.Context, mapName string) (*MapLineup, error)
+	SaveMapLineup(ctx context.Context, l MapLineup) error
+	DeleteMapLineup(ctx context.Context, mapName string) error
+
+	// Weekly statistics
+	SaveWeeklySnapshot(ctx context.Context, s WeeklySnapshot) error
+	GetWeeklySnapshot(ctx context.Context, week string) (*WeeklySnapshot, error)
+	GetWeeklySnapshotWeeks(ctx context.Context) ([]string, error)
+
+	// Audit log
+	CreateAuditEntry(ctx context.Context, e AuditEntry) error
+	GetAuditEntries(ctx context.Context, f AuditFilter) ([]AuditEntry, int, error)
+
+	// Progression
+	ResolveUserIDByDiscord(ctx context.Context, discordID string) (int64, error)
+	UpsertProgressionUser(ctx context.Context, userID int64, discordID string, level, xp, balance int) error
+	InsertInventoryItem(ctx context.Context, userID int64, invID, itemID int, name, rarity, itemType, assetKey string, sellPrice int) error
+	DeleteInventoryItem(ctx context.Context, userID int64, inventoryID int) error
+	UpdateProgressionUserRank(ctx context.Context, userID int64, discordID, rank string) error
+	EquipInventoryItem(ctx context.Context, userID int64, inventoryID int, itemType string, equipped bool) error
+	GetProgressionUser(ctx context.Context, userID int64) (*ProgressionUser, error)
+	GetEquippedItems(ctx context.Context, userID int64) ([]ProgressionInventoryItem, error)
+	GetBotLeaderboard(ctx context.Context, guildID string, limit int) ([]BotUser, error)
+	GetBotUser(ctx context.Context, discordID, guildID string) (*BotUser, error)
+}
+
+var (
+	_ Store = (*ApxClient)(nil)
+	_ Store = (*SQLiteStore)(nil)
+	_ Store = (*sessionCache)(nil)
+)
+
+// storeKind returns the configured data store: DATA_STORE ("apx" or
+// "sqlite") or, when unset, "apx" if APX_API_URL and APX_API_KEY are
+// configured and "sqlite" otherwise.
+func storeKind() (string, error) {
+	kind := os.Getenv("DATA_STORE")
+	if kind == "" {
+		if os.Getenv("APX_API_URL") != "" && os.Getenv("APX_API_KEY") != "" {
+			return "apx", nil
+		}
+		return "sqlite", nil
+	}
+	if kind != "apx" && kind != "sqlite" {
+		return "", fmt.Errorf("unknown DATA_STORE %q (want apx or sqlite)", kind)
+	}
+	return kind, nil
+}
+
+// sqlitePath is SQLITE_PATH, defaulting to teamapx.db.
+func sqlitePath() string {
+	if p := os.Getenv("SQLITE_PATH"); p != "" {
+		return p
+	}
+	return "teamapx.db"
+}
+
+// openStore opens the configured data store behind a session cache.
+func openStore() (Store, error) {
+	s, err := openBaseStore()
+	if err != nil {
+		return nil, err
+	}
+	return newSessionCache(s), nil
+}
+
+func openBaseStore() (Store, error) {
+	kind, err := storeKind()
+	if err != nil {
+		return nil, err
+	}
+	if kind == "sqlite" {
+		log.Printf("Data store: SQLite at %s", sqlitePath())
+		return NewSQLiteStore(sqlitePath())
+	}
+	apxAPIURL := os.Getenv("APX_API_URL")
+	apxAPIKey := os.Getenv("APX_API_KEY")
+	if apxAPIURL == "" || apxAPIKey == "" {
+		return nil, fmt.Errorf("DATA_STORE=apx requires APX_API_URL and APX_API_KEY")
+	}
+	log.Printf("Data store: ApxApi at %s", apxAPIURL)
+	return NewApxClient(apxAPIURL, apxAPIKey), nil
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// RFC 6238 parameters. These are the defaults every authenticator app
+// understands, so they are not configurable.
+const (
+	totpIssuer    = "Team Apx"
+	totpPeriod    = 30
+	totpDigits    = 6
+	totpSkew      = 1 // accepted time steps before/after the current one
+	recoveryCount = 10
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TwoFAConfig is a user's second-factor setup as stored by ApxApi.
+type TwoFAConfig struct {
+	Method            string `json:"method"` // "email" or "totp"
+	Secret            string `json:"secret"`
+	Confirmed         bool   `json:"confirmed"`
+	RecoveryCodesLeft int    `json:"recovery_codes_left"`
+}
+
+func (c *TwoFAConfig) usesTOTP() bool {
+	return c != nil && c.Method == "totp" && c.Confirmed && c.Secret != ""
+}
+
+func generateTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// totpCode returns the code for the given secret at time step counter.
+func totpCode(secret string, counter uint64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", fmt.Errorf("decode secret: %w", err)
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	bin := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, bin%mod), nil
+}
+
+// matchTOTP checks code against the secret, tolerating totpSkew steps of
+// clock drift in either direction, and returns the matching time step.
+func matchTOTP(secret, code string, now time.Time) (int64, bool) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	counter := now.Unix() / totpPeriod
+	for d := int64(-totpSkew); d <= totpSkew; d++ {
+		expected, err := totpCode(secret, uint64(counter+d))
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return counter + d, true
+		}
+	}
+	return 0, false
+}
+
+// verifyTOTP checks code for userID and marks its time step as used, so each
+// code is accepted once and never after a later one.
+func verifyTOTP(ctx context.Context, apx Store, userID int64, secret, code string) bool {
+	step, ok := matchTOTP(secret, code, time.Now())
+	if !ok {
+		return false
+	}
+	fresh, err := apx.UseTOTPStep(ctx, userID, step)
+	if err != nil {
+		log.Printf("UseTOTPStep error: %v", err)
+		return false
+	}
+	return fresh
+}
+
+// totpURI builds the otpauth:// URI that authenticator apps read from a QR code.
+func totpURI(secret, account string) string {
+	label := url.PathEscape(totpIssuer + ":" + account)
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", totpIssuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprintf("%d", totpDigits))
+	q.Set("period", fmt.Sprintf("%d", totpPeriod))
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// generateRecoveryCodes returns n fresh codes (xxxxx-xxxxx) and their hashes.
+// Only the hashes are stored; the plain codes are shown to the user once.
+func generateRecoveryCodes(n int) (codes, hashes []string, err error) {
+	for i := 0; i < n; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		h := hex.EncodeToString(b)
+		code := h[:5] + "-" + h[5:]
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+func hashRecoveryCode(code string) string {
+	norm := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code)))
+	sum := sha256.Sum256([]byte(norm))
+	return hex.EncodeToString(sum[:])
+}
+
+// verifySecondFactor checks a login 2FA code for userID. For TOTP users the
+// code may be an authenticator code or a recovery code; for email users it
+// must match the emailed code stored with the pending login.
+func verifySecondFactor(ctx context.Context, apx Store, userID int64, expectedEmailCode, code string) bool {
+	cfg, err := apx.GetUser2FAConfig(ctx, userID)
+	if err != nil || !cfg.usesTOTP() {
+		return expectedEmailCode != "" && subtle.ConstantTimeCompare([]byte(expectedEmailCode), []byte(code)) == 1
+	}
+	if verifyTOTP(ctx, apx, userID, cfg.Secret, code) {
+		return true
+	}
+	if cfg.RecoveryCodesLeft > 0 {
+		ok, err := apx.ConsumeRecoveryCode(ctx, userID, hashRecoveryCode(code))
+		if err != nil {
+			log.Printf("ConsumeRecoveryCode error: %v", err)
+			return false
+		}
+		return ok
+	}
+	return false
+}
+
+// totpAttemptKey is the codeAttemptLimiter key shared by the TOTP settings
+// endpoints, so wrong codes and passwords add up per account.
+func totpAttemptKey(userID int64) string { return fmt.Sprintf("totp:%d", userID) }
+
+// handleTOTPStatus serves GET /api/auth/totp — the enrollment status.
+func handleTOTPStatus(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := sessionUser(r)
+		cfg, err := apx.GetUser2FAConfig(r.Context(), user.ID)
+		if err != nil {
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"method":              cfg.Method,
+			"enrolled":            cfg.Confirmed,
+			"recovery_codes_left": cfg.RecoveryCodesLeft,
+		})
+	}
+}
+
+// handleTOTPEnroll serves POST /api/auth/totp — starts enrollment and returns
+// the secret plus otpauth URI.
+func handleTOTPEnroll(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := sessionUser(r)
+		cfg, err := apx.GetUser2FAConfig(r.Context(), user.ID)
+		if err != nil {
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		if cfg.Confirmed {
+			jsonError(w, http.StatusConflict, "Authenticator-App ist bereits eingerichtet")
+			return
+		}
+		secret, err := generateTOTPSecret()
+		if err != nil {
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		if err := apx.SetUserTOTPSecret(r.Context(), user.ID, secret); err != nil {
+			log.Printf("SetUserTOTPSecret error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		jsonResponse(w, http.StatusOK, map[string]string{
+			"secret":      secret,
+			"otpauth_uri": totpURI(secret, user.Username),
+		})
+	}
+}
+
+// handleTOTPDisable serves DELETE /api/auth/totp — removes TOTP (password
+// required); login falls back to email codes.
+func handleTOTPDisable(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := sessionUser(r)
+		var req struct {
+			Password string `json:"password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		key := totpAttemptKey(user.ID)
+		if rejectThrottled(w, codeAttemptLimiter, key) {
+			return
+		}
+		u2, err := apx.GetUserByUsernameAny(r.Context(), user.Username)
+		if err != nil {
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(u2.Password), []byte(req.Password)); err != nil {
+			codeAttemptLimiter.fail(key)
+			jsonError(w, http.StatusUnauthorized, "Passwort ist falsch")
+			return
+		}
+		codeAttemptLimiter.reset(key)
+		if err := apx.DeleteUserTOTP(r.Context(), user.ID); err != nil {
+			log.Printf("DeleteUserTOTP error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		jsonResponse(w, http.StatusOK, map[string]bool{"success": true})
+	}
+}
+
+// handleTOTPConfirm serves POST /api/auth/totp/confirm. The first valid code
+// from the app completes enrollment, switches the 2FA method to TOTP and
+// returns a fresh set of recovery codes.
+func handleTOTPConfirm(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := sessionUser(r)
+
+		var req struct {
+			Code string `json:"code"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		key := totpAttemptKey(user.ID)
+		if rejectThrottled(w, codeAttemptLimiter, key) {
+			return
+		}
+
+		cfg, err := apx.GetUser2FAConfig(r.Context(), user.ID)
+		if err != nil || cfg.Secret == "" {
+			jsonError(w, http.StatusBadRequest, "Keine Einrichtung gestartet")
+			return
+		}
+		if cfg.Confirmed {
+			jsonError(w, http.StatusConflict, "Authenticator-App ist bereits eingerichtet")
+			return
+		}
+		if !verifyTOTP(r.Context(), apx, user.ID, cfg.Secret, req.Code) {
+			codeAttemptLimiter.fail(key)
+			jsonError(w, http.StatusBadRequest, "Ungültiger Code")
+			return
+		}
+		codeAttemptLimiter.reset(key)
+
+		codes, hashes, err := generateRecoveryCodes(recoveryCount)
+		if err != nil {
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		if err := apx.ConfirmUserTOTP(r.Context(), user.ID); err != nil {
+			log.Printf("ConfirmUserTOTP error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		if err := apx.SetRecoveryCodes(r.Context(), user.ID, hashes); err != nil {
+			log.Printf("SetRecoveryCodes error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		if err := apx.SetUser2FAMethod(r.Context(), user.ID, "totp"); err != nil {
+			log.Printf("SetUser2FAMethod error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"success":        true,
+			"recovery_codes": codes,
+		})
+	}
+}
+
+// handleTOTPRecoveryCodes serves POST /api/auth/totp/recovery-codes and
+// replaces all recovery codes. A current authenticator code is required.
+func handleTOTPRecoveryCodes(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := sessionUser(r)
+
+		var req struct {
+			Code string `json:"code"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		key := totpAttemptKey(user.ID)
+		if rejectThrottled(w, codeAttemptLimiter, key) {
+			return
+		}
+
+		cfg, err := apx.GetUser2FAConfig(r.Context(), user.ID)
+		if err != nil || !cfg.Confirmed {
+			jsonError(w, http.StatusBadRequest, "Authenticator-App ist nicht eingerichtet")
+			return
+		}
+		if !verifyTOTP(r.Context(), apx, user.ID, cfg.Secret, req.Code) {
+			codeAttemptLimiter.fail(key)
+			jsonError(w, http.StatusBadRequest, "Ungültiger Code")
+			return
+		}
+		codeAttemptLimiter.reset(key)
+
+		codes, hashes, err := generateRecoveryCodes(recoveryCount)
+		if err != nil {
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		if err := apx.SetRecoveryCodes(r.Context(), user.ID, hashes); err != nil {
+			log.Printf("SetRecoveryCodes error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"recovery_codes": codes})
+	}
+}
@@ -70,24 +70,15 @@ func getTwitchAppToken() (string, error) {
 }
 
 // GET /auth/twitch
-func handleTwitchOAuth(apx *ApxClient) http.HandlerFunc {
+func handleTwitchOAuth(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-			return
-		}
 		if twitchClientID() == "" {
 			jsonError(w, http.StatusServiceUnavailable, "Twitch OAuth not configured")
 			return
 		}
 
-		cookie, err := r.Cookie("session")
-		if err != nil {
-			http.Redirect(w, r, "/login", http.StatusFound)
-			return
-		}
-		user, err := apx.GetSessionUser(cookie.Value)
-		if err != nil {
+		user := sessionUser(r)
+		if user == nil {
 			http.Redirect(w, r, "/login", http.StatusFound)
 			return
 		}
@@ -99,7 +90,7 @@ func handleTwitchOAuth(apx *ApxClient) http.HandlerFunc {
 		}
 		state := hex.EncodeToString(b)
 
-		if err := apx.CreateOAuthState(state, user.ID, "", time.Now().Add(10*time.Minute)); err != nil {
+		if err := apx.CreateOAuthState(r.Context(), state, user.ID, "", time.Now().Add(10*time.Minute)); err != nil {
 			log.Printf("CreateOAuthState (twitch) error: %v", err)
 			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
@@ -116,13 +107,8 @@ func handleTwitchOAuth(apx *ApxClient) http.HandlerFunc {
 }
 
 // GET /auth/twitch/callback
-func handleTwitchCallback(apx *ApxClient) http.HandlerFunc {
+func handleTwitchCallback(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-			return
-		}
-
 		q := r.URL.Query()
 		code := q.Get("code")
 		state := q.Get("state")
@@ -141,7 +127,7 @@ func handleTwitchCallback(apx *ApxClient) http.HandlerFunc {
 			return
 		}
 
-		oauthState, err := apx.GetAndDeleteOAuthState(state)
+		oauthState, err := apx.GetAndDeleteOAuthState(r.Context(), state)
 		if err != nil {
 			redirectFail("invalid_state")
 			return
@@ -161,7 +147,7 @@ func handleTwitchCallback(apx *ApxClient) http.HandlerFunc {
 			return
 		}
 
-		if err := apx.UpsertLinkedAccount(oauthState.UserID, "twitch", twitchUser.ID, twitchUser.DisplayName, twitchUser.ProfileImageURL, ""); err != nil {
+		if err := apx.UpsertLinkedAccount(r.Context(), oauthState.UserID, "twitch", twitchUser.ID, twitchUser.DisplayName, twitchUser.ProfileImageURL, ""); err != nil {
 			log.Printf("UpsertLinkedAccount (twitch) error: %v", err)
 			redirectFail("db_error")
 			return
@@ -250,11 +236,6 @@ func fetchTwitchUser(accessToken string) (*twitchUserResponse, error) {
 
 // GET /api/twitch/live
 func handleTwitchLiveStatus(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-		return
-	}
-
 	if twitchClientID() == "" || twitchClientSecret() == "" {
 		jsonError(w, http.StatusServiceUnavailable, "Twitch not configured")
 		return
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	weeklyStatsInterval   = time.Hour // how often the job checks for a missing snapshot
+	weeklyLeaderboardSize = 10
+	weeklyBackfillMax     = 12 // most weeks snapshotted at once after downtime
+)
+
+// WeeklyStats is the content of a weekly snapshot.
+type WeeklyStats struct {
+	// Backfilled snapshots were built more than a week after their week
+	// ended; they have no Roster and Leaderboard because only the current
+	// values would be available.
+	Backfilled       bool           `json:"backfilled,omitempty"`
+	Roster           []rankedPlayer `json:"roster"`      // all-time totals when the snapshot was taken
+	WeekRoster       []rankedPlayer `json:"week_roster"` // matches of the week only
+	Matches          int            `json:"matches"`
+	Wins             int            `json:"wins"`
+	Losses           int            `json:"losses"`
+	Draws            int            `json:"draws"`
+	Leaderboard      []WeeklyLeader `json:"leaderboard"`
+	Events           int            `json:"events"`      // events dated in the week
+	EventJoins       int            `json:"event_joins"` // event sign-ups made during the week
+	NewRegistrations int            `json:"new_registrations"`
+}
+
+// WeeklyLeader is one entry of the progression leaderboard in a snapshot.
+type WeeklyLeader struct {
+	UserID          string `json:"user_id"`
+	DiscordUsername string `json:"discord_username"`
+	Level           int    `json:"level"`
+	XP              int    `json:"xp"`
+	Gold            int    `json:"gold"`
+}
+
+// isoWeek returns the ISO week of t, e.g. "2026-W42".
+func isoWeek(t time.Time) string {
+	year, week := t.UTC().ISOWeek()
+	return fmt.Sprintf("%04d-W%02d", year, week)
+}
+
+// weekStart returns Monday 00:00 UTC of the week containing t.
+func weekStart(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+}
+
+// parseISOWeek returns the start of an ISO week given as "2026-W42".
+func parseISOWeek(week string) (time.Time, error) {
+	var year, n int
+	if _, err := fmt.Sscanf(week, "%4d-W%2d", &year, &n); err != nil {
+		return time.Time{}, fmt.Errorf("invalid week %q", week)
+	}
+	// January 4th is always in week 1.
+	start := weekStart(time.Date(year, time.January, 4, 0, 0, 0, 0, time.UTC)).AddDate(0, 0, 7*(n-1))
+	if n < 1 || isoWeek(start) != week {
+		return time.Time{}, fmt.Errorf("invalid week %q", week)
+	}
+	return start, nil
+}
+
+// parseStoreTime parses the timestamp formats the stores and the admin forms
+// produce.
+func parseStoreTime(s string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04", "2006-01-02", "02.01.2006"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func inWeek(t, start time.Time) bool {
+	return !t.Before(start) && t.Before(start.AddDate(0, 0, 7))
+}
+
+// buildWeeklySnapshot collects the statistics of the week starting at start.
+// The roster totals and the leaderboard are the current values, so they are
+// only included while the following week is still running; later snapshots
+// are marked Backfilled.
+func buildWeeklySnapshot(ctx context.Context, apx Store, start time.Time) (WeeklySnapshot, error) {
+	end := start.AddDate(0, 0, 7)
+	snap := WeeklySnapshot{
+		Week:     isoWeek(start),
+		StartsAt: start.Format(time.RFC3339),
+		EndsAt:   end.Format(time.RFC3339),
+	}
+	stats := &snap.Stats
+	stats.Backfilled = !time.Now().Before(end.AddDate(0, 0, 7))
+
+	members, err := apx.GetTeamMembers(ctx)
+	if err != nil {
+		return snap, fmt.Errorf("team: %w", err)
+	}
+	enrichTeamAvatars(ctx, members, apx)
+	weekMembers := make([]TeamMember, len(members))
+	copy(weekMembers, members)
+
+	if !stats.Backfilled {
+		addMatchTotals(ctx, apx, members)
+		stats.Roster = rankRoster(members)
+	}
+
+	matches, err := apx.GetMatches(ctx)
+	if err != nil {
+		return snap, fmt.Errorf("matches: %w", err)
+	}
+	totals := matchTotalsBetween(matches, start, end)
+	for i := range weekMembers {
+		weekMembers[i].PlayerStats = totals[weekMembers[i].ID]
+		weekMembers[i].Rounds, weekMembers[i].KostPoints = 0, 0 // all-time only
+	}
+	stats.WeekRoster = rankRoster(weekMembers)
+	for _, m := range matches {
+		if t, ok := parseStoreTime(m.PlayedAt); !ok || !inWeek(t, start) {
+			continue
+		}
+		stats.Matches++
+		switch {
+		case m.ScoreUs > m.ScoreThem:
+			stats.Wins++
+		case m.ScoreUs < m.ScoreThem:
+			stats.Losses++
+		default:
+			stats.Draws++
+		}
+	}
+
+	if !stats.Backfilled {
+		leaders, err := apx.GetBotLeaderboard(ctx, apxGuildID, weeklyLeaderboardSize)
+		if err != nil {
+			return snap, fmt.Errorf("leaderboard: %w", err)
+		}
+		stats.Leaderboard = make([]WeeklyLeader, len(leaders))
+		for i, u := range leaders {
+			stats.Leaderboard[i] = WeeklyLeader{UserID: u.UserID, DiscordUsername: u.DiscordUsername, Level: u.Level, XP: u.XP, Gold: u.Gold}
+		}
+	}
+
+	events, err := apx.GetAllEvents(ctx)
+	if err != nil {
+		return snap, fmt.Errorf("events: %w", err)
+	}
+	for _, e := range events {
+		if t, ok := parseStoreTime(e.Date); ok && inWeek(t, start) {
+			stats.Events++
+		}
+		// Only events that existed during the week and have sign-ups can
+		// contribute joins; skip the participant lookup for the rest.
+		if t, ok := parseStoreTime(e.CreatedAt); e.ParticipantCount == 0 || ok && !t.Before(end) {
+			continue
+		}
+		participants, err := apx.GetEventParticipants(ctx, e.ID)
+		if err != nil {
+			return snap, fmt.Errorf("event %s participants: %w", e.ID, err)
+		}
+		for _, p := range participants {
+			if t, ok := parseStoreTime(p.JoinedAt); ok && inWeek(t, start) {
+				stats.EventJoins++
+			}
+		}
+	}
+
+	if stats.NewRegistrations, err = apx.CountUsersCreatedBetween(ctx, start, end); err != nil {
+		return snap, fmt.Errorf("registrations: %w", err)
+	}
+	return snap, nil
+}
+
+// snapshotMissingWeeks stores a snapshot for every completed week after the
+// newest stored one, at most weeklyBackfillMax weeks back. Without any
+// snapshot only the last completed week is taken. Weeks older than that are
+// saved as backfilled snapshots (see buildWeeklySnapshot).
+func snapshotMissingWeeks(ctx context.Context, apx Store) error {
+	last := weekStart(time.Now()).AddDate(0, 0, -7)
+	start := last
+	weeks, err := apx.GetWeeklySnapshotWeeks(ctx)
+	if err != nil {
+		return err
+	}
+	if len(weeks) > 0 {
+		newest, err := parseISOWeek(weeks[0])
+		if err != nil {
+			return err
+		}
+		start = newest.AddDate(0, 0, 7)
+	}
+	if oldest := last.AddDate(0, 0, -7*(weeklyBackfillMax-1)); start.Before(oldest) {
+		start = oldest
+	}
+	for ; !start.After(last); start = start.AddDate(0, 0, 7) {
+		if _, err := apx.GetWeeklySnapshot(ctx, isoWeek(start)); err == nil {
+			continue
+		} else if !errors.Is(err, errNotFound) {
+			return err
+		}
+		snap, err := buildWeeklySnapshot(ctx, apx, start)
+		if err != nil {
+			return err
+		}
+		if err := apx.SaveWeeklySnapshot(ctx, snap); err != nil {
+			return err
+		}
+		log.Printf("Weekly statistics: saved snapshot %s", snap.Week)
+	}
+	return nil
+}
+
+// runWeeklyStatsJob snapshots every completed week. It checks on start and
+// then every weeklyStatsInterval, so a failed or missed run is retried and
+// weeks missed while the server was down are filled in.
+func runWeeklyStatsJob(apx Store) {
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		if err := snapshotMissingWeeks(ctx, apx); err != nil {
+			log.Printf("Weekly statistics snapshot error: %v", err)
+		}
+		cancel()
+		time.Sleep(weeklyStatsInterval)
+	}
+}
+
+// weeklyDeltas are the changes against the previous week's snapshot.
+type weeklyDeltas struct {
+	Matches          int `json:"matches"`
+	Wins             int `json:"wins"`
+	Losses           int `json:"losses"`
+	Draws            int `json:"draws"`
+	Events           int `json:"events"`
+	EventJoins       int `json:"event_joins"`
+	NewRegistrations int `json:"new_registrations"`
+
+	Roster      []rosterDelta `json:"roster"`
+	Leaderboard []leaderDelta `json:"leaderboard"`
+}
+
+// rosterDelta compares a player's all-time rating. Rank is positive when the
+// player climbed; New marks players without a rating the week before.
+type rosterDelta struct {
+	TeamMemberID int64   `json:"team_member_id"`
+	Rating       float64 `json:"rating"`
+	Rank         int     `json:"rank"`
+	New          bool    `json:"new"`
+}
+
+type leaderDelta struct {
+	UserID string `json:"user_id"`
+	Level  int    `json:"level"`
+	XP     int    `json:"xp"`
+	New    bool   `json:"new"` // not on the previous leaderboard
+}
+
+// compareWeeks returns the deltas of cur against prev. The roster and
+// leaderboard deltas stay empty when either snapshot is backfilled.
+func compareWeeks(cur, prev WeeklyStats) weeklyDeltas {
+	d := weeklyDeltas{
+		Matches:          cur.Matches - prev.Matches,
+		Wins:             cur.Wins - prev.Wins,
+		Losses:           cur.Losses - prev.Losses,
+		Draws:            cur.Draws - prev.Draws,
+		Events:           cur.Events - prev.Events,
+		EventJoins:       cur.EventJoins - prev.EventJoins,
+		NewRegistrations: cur.NewRegistrations - prev.NewRegistrations,
+		Roster:           []rosterDelta{},
+		Leaderboard:      []leaderDelta{},
+	}
+	if cur.Backfilled || prev.Backfilled {
+		return d
+	}
+
+	prevRoster := map[int64]rankedPlayer{}
+	for _, p := range prev.Roster {
+		prevRoster[p.TeamMemberID] = p
+	}
+	for _, p := range cur.Roster {
+		before, ok := prevRoster[p.TeamMemberID]
+		if !ok {
+			d.Roster = append(d.Roster, rosterDelta{TeamMemberID: p.TeamMemberID, Rating: p.Rating, New: true})
+			continue
+		}
+		d.Roster = append(d.Roster, rosterDelta{
+			TeamMemberID: p.TeamMemberID,
+			Rating:       round(p.Rating-before.Rating, 1),
+			Rank:         before.Rank - p.Rank,
+		})
+	}
+
+	prevLeaders := map[string]WeeklyLeader{}
+	for _, u := range prev.Leaderboard {
+		prevLeaders[u.UserID] = u
+	}
+	for _, u := range cur.Leaderboard {
+		before, ok := prevLeaders[u.UserID]
+		if !ok {
+			d.Leaderboard = append(d.Leaderboard, leaderDelta{UserID: u.UserID, Level: u.Level, XP: u.XP, New: true})
+			continue
+		}
+		d.Leaderboard = append(d.Leaderboard, leaderDelta{UserID: u.UserID, Level: u.Level - before.Level, XP: u.XP - before.XP})
+	}
+	return d
+}
+
+// handleWeeklyStats serves GET /api/stats/weekly?week=2026-W42. Without week
+// the latest snapshot is returned. deltas is null when the previous week has
+// no snapshot.
+func handleWeeklyStats(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		weeks, err := apx.GetWeeklySnapshotWeeks(r.Context())
+		if err != nil {
+			log.Printf("GetWeeklySnapshotWeeks error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		if weeks == nil {
+			weeks = []string{}
+		}
+
+		week := r.URL.Query().Get("week")
+		if week == "" {
+			if len(weeks) == 0 {
+				jsonError(w, http.StatusNotFound, "Noch keine Wochenstatistik vorhanden")
+				return
+			}
+			week = weeks[0]
+		}
+		start, err := parseISOWeek(week)
+		if err != nil {
+			jsonError(w, http.StatusBadRequest, "Ungültige Woche (Format: 2026-W42)")
+			return
+		}
+
+		snap, err := apx.GetWeeklySnapshot(r.Context(), week)
+		if errors.Is(err, errNotFound) {
+			jsonError(w, http.StatusNotFound, "Keine Statistik für diese Woche")
+			return
+		} else if err != nil {
+			log.Printf("GetWeeklySnapshot error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+
+		prevWeek := isoWeek(start.AddDate(0, 0, -7))
+		var deltas *weeklyDeltas
+		if prev, err := apx.GetWeeklySnapshot(r.Context(), prevWeek); err == nil {
+			d := compareWeeks(snap.Stats, prev.Stats)
+			deltas = &d
+		} else if !errors.Is(err, errNotFound) {
+			log.Printf("GetWeeklySnapshot error: %v", err)
+		}
+
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"snapshot":      snap,
+			"previous_week": prevWeek,
+			"deltas":        deltas,
+			"weeks":         weeks,
+		})
+	}
+}
+
+// handleAdminWeeklyStatsRebuild serves POST /api/admin/stats/weekly/{week}:
+// it (re)builds a week's snapshot, e.g. after late match results were
+// entered. Roster totals and the leaderboard are taken as they are now, and
+// only for the last completed week.
+func handleAdminWeeklyStatsRebuild(apx Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		week := r.PathValue("week")
+		start, err := parseISOWeek(week)
+		if err != nil {
+			jsonError(w, http.StatusBadRequest, "Ungültige Woche (Format: 2026-W42)")
+			return
+		}
+		if !start.AddDate(0, 0, 7).Before(time.Now()) {
+			jsonError(w, http.StatusBadRequest, "Die Woche ist noch nicht vorbei")
+			return
+		}
+		snap, err := buildWeeklySnapshot(r.Context(), apx, start)
+		if err != nil {
+			log.Printf("buildWeeklySnapshot error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		if err := apx.SaveWeeklySnapshot(r.Context(), snap); err != nil {
+			log.Printf("SaveWeeklySnapshot error: %v", err)
+			jsonError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		recordAudit(apx, r, "stats.snapshot", "weekly_stats", week, nil, nil)
+		jsonResponse(w, http.StatusOK, map[string]interface{}{"snapshot": snap})
+	}
+}
@@ -25,24 +25,15 @@ const (
 )
 
 // GET /auth/youtube
-func handleYouTubeOAuth(apx *ApxClient) http.HandlerFunc {
+func handleYouTubeOAuth(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-			return
-		}
 		if ytClientID() == "" {
 			jsonError(w, http.StatusServiceUnavailable, "YouTube OAuth not configured")
 			return
 		}
 
-		cookie, err := r.Cookie("session")
-		if err != nil {
-			http.Redirect(w, r, "/login", http.StatusFound)
-			return
-		}
-		user, err := apx.GetSessionUser(cookie.Value)
-		if err != nil {
+		user := sessionUser(r)
+		if user == nil {
 			http.Redirect(w, r, "/login", http.StatusFound)
 			return
 		}
@@ -54,7 +45,7 @@ func handleYouTubeOAuth(apx *ApxClient) http.HandlerFunc {
 		}
 		state := hex.EncodeToString(b)
 
-		if err := apx.CreateOAuthState(state, user.ID, "", time.Now().Add(10*time.Minute)); err != nil {
+		if err := apx.CreateOAuthState(r.Context(), state, user.ID, "", time.Now().Add(10*time.Minute)); err != nil {
 			log.Printf("CreateOAuthState (youtube) error: %v", err)
 			jsonError(w, http.StatusInternalServerError, "internal error")
 			return
@@ -73,13 +64,8 @@ func handleYouTubeOAuth(apx *ApxClient) http.HandlerFunc {
 }
 
 // GET /auth/youtube/callback
-func handleYouTubeCallback(apx *ApxClient) http.HandlerFunc {
+func handleYouTubeCallback(apx Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			jsonError(w, http.StatusMethodNotAllowed, "method not allowed")
-			return
-		}
-
 		q := r.URL.Query()
 		code := q.Get("code")
 		state := q.Get("state")
@@ -98,7 +84,7 @@ func handleYouTubeCallback(apx *ApxClient) http.HandlerFunc {
 			return
 		}
 
-		oauthState, err := apx.GetAndDeleteOAuthState(state)
+		oauthState, err := apx.GetAndDeleteOAuthState(r.Context(), state)
 		if err != nil {
 			redirectFail("invalid_state")
 			return
@@ -118,7 +104,7 @@ func handleYouTubeCallback(apx *ApxClient) http.HandlerFunc {
 			return
 		}
 
-		if err := apx.UpsertLinkedAccount(oauthState.UserID, "youtube", channel.ID, channel.displayName(), channel.avatarURL(), channel.profileURL()); err != nil {
+		if err := apx.UpsertLinkedAccount(r.Context(), oauthState.UserID, "youtube", channel.ID, channel.displayName(), channel.avatarURL(), channel.profileURL()); err != nil {
 			log.Printf("UpsertLinkedAccount (youtube) error: %v", err)
 			redirectFail("db_error")
 			return